@@ -40,7 +40,8 @@ function CreateMessages(data, currId) {
     
     // Iterate over the data in reverse order
     for (let i = data.length - 1; i >= 0; i--) {
-      const { id, sender_id, content, date } = data[i];
+      const { id, sender_id, receiver_id, content, date, seq } = data[i];
+      noteSeq(sender_id == currId ? receiver_id : sender_id, seq);
   
       // Check if the message with the same ID already exists in the chatbox
       if (document.getElementById(`message-${id}`)) {
@@ -61,6 +62,7 @@ function CreateMessages(data, currId) {
   
       // Set a unique ID for each message element
       receiverContainer.id = `message-${id}`;
+      receiverContainer.dataset.seq = seq || 0;
   
       // Append the message elements to the log container
       appendLog(receiverContainer, receiver, messagedate, true);
@@ -70,8 +72,252 @@ function CreateMessages(data, currId) {
         chatBox.scrollTop = chatBox.scrollHeight;
       //firstId = id;
     }
+
+    if (chatPartner) {
+        markRead(chatPartner);
+    }
   }
   
+// The user whose conversation is open, and the settings of the conversations opened so far
+var chatPartner = 0;
+var convSettings = {};
+
+// Shows whether the user shares typing indicators and read receipts with the open conversation
+function showConversationSettings(settings) {
+    convSettings[settings.with] = settings;
+    if (settings.with != chatPartner) {
+        return;
+    }
+    document.querySelector("#conv-typing").checked = settings.mine.typing;
+    document.querySelector("#conv-receipts").checked = settings.mine.receipts;
+    document.querySelector("#conv-typing").title = settings.agreed.typing ? "" : "Only shown when you both allow it";
+    document.querySelector("#conv-receipts").title = settings.agreed.receipts ? "" : "Only shown when you both allow it";
+}
+
+function loadConversationSettings(rid) {
+    fetch('/conversations/' + rid + '/settings').then(response => {
+        return response.ok ? response.json() : null;
+    }).then(settings => {
+        if (settings) {
+            showConversationSettings(settings);
+        }
+    }).catch(error => console.log(error));
+}
+
+// Saves the settings of the open conversation, both users are then sent what they agree on
+function saveConversationSettings() {
+    if (!chatPartner) {
+        return;
+    }
+    fetch('/conversations/' + chatPartner + '/settings', {
+        method: 'PUT',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({
+            typing: document.querySelector("#conv-typing").checked,
+            receipts: document.querySelector("#conv-receipts").checked
+        })
+    }).catch(error => console.log(error));
+}
+
+document.querySelector("#conv-typing").addEventListener("change", saveConversationSettings);
+document.querySelector("#conv-receipts").addEventListener("change", saveConversationSettings);
+
+// Marks the messages shown so far as read, the server only tells the other user when
+// both allow read receipts
+function markRead(partner) {
+    if (!conn || conn.readyState !== WebSocket.OPEN) {
+        return;
+    }
+    conn.send(JSON.stringify({ msg_type: "mark_read", receiver_id: partner, seq: lastSeq[partner] || 0 }));
+}
+
+// Loads how many messages of each conversation are unread, the websocket keeps it up to date afterwards
+function loadUnread() {
+    return fetch('/conversations/unread').then(response => {
+        return response.ok ? response.json() : null;
+    }).then(counts => {
+        if (counts) {
+            unread = counts.conversations.map(c => [c.user_id, c.count]);
+        }
+    }).catch(error => console.log(error));
+}
+
+// Marks the messages the other user of the open conversation has read
+function showRead(receipt) {
+    if (receipt.reader_id != chatPartner) {
+        return;
+    }
+    log.querySelectorAll(".sender-container").forEach(el => {
+        var seq = parseInt(el.dataset.seq || "0", 10);
+        if (seq && seq <= receipt.seq) {
+            el.classList.add("seen");
+        }
+    });
+}
+
+// Whether the open conversation has messages older than the ones loaded
+var hasOlder = true;
+
+// Loads the page of a conversation before the message with the given id, or the latest page
+// without one. Messages come newest first, as CreateMessages expects them.
+function loadHistory(partner, before) {
+    return getData('/messages?with=' + partner + '&before=' + (before || 0) + '&limit=10').then(page => {
+        hasOlder = page.has_before;
+        return page.messages.reverse();
+    });
+}
+
+// Messages are numbered within each conversation, this is the newest number seen per user
+var lastSeq = {};
+
+// Remembers the newest sequence number of a conversation
+function noteSeq(partner, seq) {
+    if (seq && !(lastSeq[partner] >= seq)) {
+        lastSeq[partner] = seq;
+    }
+}
+
+// Checks a message received live against the sequence of its conversation. Returns false
+// for a message already shown, and fetches the messages in between when some were missed.
+function trackSeq(partner, seq) {
+    if (!seq) {
+        return true;
+    }
+
+    var last = lastSeq[partner];
+    if (last !== undefined && seq <= last) {
+        return false;
+    }
+
+    if (last !== undefined && seq > last + 1) {
+        fillGap(partner, last, seq);
+    }
+
+    lastSeq[partner] = seq;
+    return true;
+}
+
+// Fetches and shows the messages of a conversation between two sequence numbers
+function fillGap(partner, after, before) {
+    fetch('/messages?with=' + partner + '&after_seq=' + after).then(response => {
+        return response.ok ? response.json() : null;
+    }).then(window => {
+        if (!window) {
+            return;
+        }
+
+        window.messages.filter(m => m.seq < before).forEach(m => {
+            if (document.getElementById('message-' + m.id)) {
+                return;
+            }
+
+            var container = document.createElement("div");
+            container.className = (m.sender_id == currId) ? "sender-container" : "receiver-container";
+            container.id = 'message-' + m.id;
+            container.dataset.seq = m.seq;
+            var text = document.createElement("div");
+            text.className = (m.sender_id == currId) ? "sender" : "receiver";
+            text.innerText = m.content;
+            var date = document.createElement("div");
+            date.className = "chat-time";
+            date.innerText = m.date.slice(0, -3);
+            appendLog(container, text, date);
+            orderBySeq(container);
+        });
+    }).catch(() => {});
+}
+
+// Moves a message before the messages of the conversation that have a higher sequence number
+function orderBySeq(container) {
+    var seq = parseInt(container.dataset.seq || "0", 10);
+    if (!seq) {
+        return;
+    }
+
+    for (var el = log.firstElementChild; el; el = el.nextElementSibling) {
+        if (el !== container && parseInt(el.dataset.seq || "0", 10) > seq) {
+            log.insertBefore(container, el);
+            return;
+        }
+    }
+}
+
+// Messages sent but not acknowledged yet, by temporary id
+var pending = {};
+var tempCount = 0;
+
+// Shows a message being sent, faded until the server acknowledges it
+function showPending(msgData) {
+    var container = document.getElementById('pending-' + msgData.temp_id);
+    if (!container) {
+        container = document.createElement("div");
+        container.id = 'pending-' + msgData.temp_id;
+        var text = document.createElement("div");
+        text.className = "sender";
+        text.innerText = msgData.content;
+        var date = document.createElement("div");
+        date.className = "chat-time";
+        appendLog(container, text, date);
+    }
+
+    container.className = "sender-container pending";
+    container.querySelector(".chat-time").innerText = "Sending...";
+    container.onclick = null;
+    pending[msgData.temp_id] = msgData;
+}
+
+// Gives a pending message the id, number and date the server stored it with
+function ackMessage(ack) {
+    var msgData = pending[ack.temp_id];
+    delete pending[ack.temp_id];
+
+    var container = document.getElementById('pending-' + ack.temp_id);
+    if (!msgData || !container) {
+        return;
+    }
+
+    trackSeq(msgData.receiver_id, ack.seq);
+    container.id = 'message-' + ack.id;
+    container.dataset.seq = ack.seq;
+    container.className = "sender-container";
+    container.querySelector(".chat-time").innerText = ack.date.slice(0, -3);
+    orderBySeq(container);
+}
+
+// Marks a pending message as not sent, offering to send it again when that may work
+function nackMessage(nack) {
+    var msgData = pending[nack.temp_id];
+    var container = document.getElementById('pending-' + nack.temp_id);
+    if (!msgData || !container) {
+        return;
+    }
+
+    container.className = "sender-container failed";
+    var date = container.querySelector(".chat-time");
+    if (!nack.retry) {
+        delete pending[nack.temp_id];
+        date.innerText = "Not sent";
+        // Messaging someone new can need a verified email and a complete profile
+        if (nack.reason === "steps") {
+            date.innerText = nack.missing.indexOf("verify_email") !== -1
+                ? "Not sent, verify your email first"
+                : "Not sent, add your date of birth and gender first";
+        }
+        return;
+    }
+
+    date.innerText = "Not sent, click to retry";
+    container.onclick = function() {
+        if (!conn || conn.readyState !== WebSocket.OPEN) {
+            return;
+        }
+        showPending(msgData);
+        conn.send(JSON.stringify(msgData));
+    };
+}
+
 // Create users from data and append to users container
 function sendMsg(conn, rid, msg, msg_type) {
     console.log("receiver ID from sendmsg:",rid)
@@ -92,9 +338,13 @@ function sendMsg(conn, rid, msg, msg_type) {
         content: msg.value,
         date: '',
         msg_type: msg_type,
-        is_typing: false
+        is_typing: false,
+        temp_id: 'tmp-' + Date.now() + '-' + (++tempCount)
     }
 
+    // Shows the message right away, the server acknowledges it with its real id
+    showPending(msgData);
+
     conn.send(JSON.stringify(msgData))
     msg.value = "";
     updateUsers()
@@ -110,6 +360,8 @@ var chatBox = document.querySelector(".chat");
 
 function OpenChat(rid, conn, data, currId, firstId) {
     document.getElementById('id' + rid).style.fontWeight = "400";
+    chatPartner = rid;
+    loadConversationSettings(rid);
 
     for (var i = 0; i < unread.length; i++) {
         if (unread[i][0] == rid) {
@@ -128,7 +380,31 @@ function OpenChat(rid, conn, data, currId, firstId) {
     var msg = document.getElementById("chat-input");
 
     log.innerHTML = "";
-    
+
+    // Restores the message left unsent in this conversation, possibly on another device
+    msg.value = "";
+    fetch('/conversations/' + rid + '/draft').then(response => {
+        return response.ok ? response.json() : null;
+    }).then(draft => {
+        if (draft && draft.content && msg.value === "") {
+            msg.value = draft.content;
+        }
+    }).catch(error => console.log(error));
+
+    // Saves the draft shortly after the user stops typing
+    var draftTimer;
+    msg.addEventListener("input", function() {
+        clearTimeout(draftTimer);
+        draftTimer = setTimeout(function() {
+            fetch('/conversations/' + rid + '/draft', {
+                method: 'PUT',
+                headers: {
+                    'Content-Type': 'application/json'
+                },
+                body: JSON.stringify({content: msg.value})
+            }).catch(error => console.log(error));
+        }, 1000);
+    });
 
     var typingTimer;
     var typingInterval = 3000;
@@ -158,27 +434,8 @@ function OpenChat(rid, conn, data, currId, firstId) {
     document.querySelector("#send-btn").addEventListener("click", function() {
         sendMsg(conn, rid, msg, 'msg');
         offset = null;
-        console.log("Sending message From button:", msg.value);
-        firstId = firstId + 10;
-        let resp = getData('http://localhost:8000/message?receiver=' + rid + '&firstId=512' + '&offset=10');
-        resp.then(value => {
-            console.log("enter resp then =>", value);
-            console.log("Enter value", value);
-            if (value && value.length > 0) {
-                const lastIndex = value.length - 1;
-                firstId = value[lastIndex].id;
-                lastFetchedId = firstId;
-                  console.log("Button lastFetchedID", firstId);
-              }
-            CreateMessages(value, currId);
-            console.log("Sending message From button:", value);
-            console.log("firstId from button ", firstId);
-            // Scroll to the bottom of the chat box after adding the new message
-            chatBox.scrollTop = chatBox.scrollHeight;
-        }).catch();
-        //resetScroll();
-        //firstId = 512;
-        log.innerHTML = "";
+        // Scroll to the bottom of the chat box after adding the new message
+        chatBox.scrollTop = chatBox.scrollHeight;
         clearTimeout(typingTimer); // Clear the previous timer
         isTyping = false; // Reset the typing flag
     });
@@ -187,28 +444,10 @@ function OpenChat(rid, conn, data, currId, firstId) {
         if (event.keyCode === 13) { // Send message on Enter keypress
             offset = 0;
             sendMsg(conn, rid, msg, 'msg');
-            console.log("Sending message From Enter:", msg.value);
-            firstId = firstId + 10;
-            let resp = getData('http://localhost:8000/message?receiver=' + rid + '&firstId=512'  + '&offset=10');
-            resp.then(value => {
-                console.log("enter resp then =>", value);
-                console.log("Enter value", value);
-                if (value && value.length > 0) {
-                    const lastIndex = value.length - 1;
-                    firstId = value[lastIndex].id;
-                    lastFetchedId = firstId;
-                      console.log("Button lastFetchedID", firstId);
-                  }
-                CreateMessages(value, currId);
-                console.log("Sending message From Enter:", value);
-                console.log("firstId from enter", firstId);
-                // Scroll to the bottom of the chat box after adding the new message
-                chatBox.scrollTop = chatBox.scrollHeight;
-            }).catch();
-            log.innerHTML = "";
+            // Scroll to the bottom of the chat box after adding the new message
+            chatBox.scrollTop = chatBox.scrollHeight;
             clearTimeout(typingTimer); // Clear the previous timer
             isTyping = false; // Reset the typing flag
-            offset += value.length;
         }
     });
 
@@ -231,8 +470,11 @@ function OpenChat(rid, conn, data, currId, firstId) {
           
       
           // User has scrolled to the top
-          // Fetch more chat log history for the current receiver
-          let resp = getData('http://localhost:8000/message?receiver=' + rid + '&firstId=' + firstId + '&offset=' + offset);
+          // Fetch the page before the oldest message shown, unless it was the first one
+          if (!hasOlder) {
+            return;
+          }
+          let resp = loadHistory(rid, firstId);
           resp.then(value => {
             // Process the retrieved chat log history
             console.log("scroll fetch messages", value);
@@ -309,9 +551,15 @@ function animateDots(typingInterval) {
 }
 
 function sendTypingStatus(conn, receiverId, isTyping) {
+    // Typing indicators are only shown when both users allow them
+    var settings = convSettings[receiverId];
+    if (isTyping && settings && !settings.agreed.typing) {
+        return;
+    }
+
     let typingData = {
-        receiver_id: receiverId,
-        is_typing: isTyping
+        msg_type: isTyping ? "typing_start" : "typing_stop",
+        receiver_id: receiverId
     };
 
     conn.send(JSON.stringify(typingData));
@@ -322,6 +570,7 @@ function sendTypingStatus(conn, receiverId, isTyping) {
 // close chat
 document.querySelector(".close-chat").addEventListener("click", function() {
     document.querySelector(".chat-wrapper").style.display = "none";
+    chatPartner = 0;
     console.log("closed chat");
     resetScroll();
 });
@@ -335,7 +584,8 @@ function resetScroll() {
       chatBox.removeEventListener("scroll", debouncedScrollHandler);
     }
         // Reset variables and scroll position
-        firstId = 512;
+        firstId = 0;
+        hasOlder = true;
         offset = 0;
         currentScrollPos = 0;
         lastFetchedId = null;
@@ -19,9 +19,15 @@ let counter = 0
 var unread = []
 
 var conn;
+var reconnectToken = ""
+var clockOffset = 0
+var newPosts = 0
+var typingTimeout
+var closingWS = false
 var currId = 0
 var currUsername = ""
 var currPost = 0
+var currReaction = ""
 
 var allPosts = []
 var filteredPosts = []
@@ -31,6 +37,42 @@ var online = []
 
 var currComments = []
 
+//Version of the frontend sent with error reports
+const appVersion = "1.0.0"
+
+//Id and status of the last api response, so errors can be matched with it in the access log
+var lastResponse = {request_id: "", status: 0}
+
+function rememberResponse(response) {
+    lastResponse = {request_id: response.headers.get('X-Request-ID') || "", status: response.status}
+}
+
+//Reports an error of the frontend to the server
+function reportError(message, stack) {
+    fetch('http://localhost:8000/client-errors', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({
+            message: String(message),
+            stack: stack || "",
+            route: window.location.pathname + window.location.hash,
+            app_version: appVersion,
+            request_id: lastResponse.request_id,
+            status: lastResponse.status
+        })
+    }).catch(() => {})
+}
+
+window.addEventListener('error', (e) => {
+    reportError(e.message, e.error && e.error.stack)
+})
+
+window.addEventListener('unhandledrejection', (e) => {
+    reportError(e.reason && e.reason.message || e.reason, e.reason && e.reason.stack)
+})
+
 //POST fetch function
 async function postData(url = '', data = {}) {
     const response = await fetch(url, {
@@ -40,6 +82,7 @@ async function postData(url = '', data = {}) {
         },
         body: JSON.stringify(data)
     })
+    rememberResponse(response)
     console.log('posted')
 
     return response.json()
@@ -53,6 +96,7 @@ async function getData(url = '') {
     const response = await fetch(url, {
         method: 'GET'
     })
+    rememberResponse(response)
 
     return response.json()
 }
@@ -110,25 +154,182 @@ async function updateUsers() {
 }
 
 
+// Current time corrected to the server clock, in milliseconds
+function serverNow() {
+    return Date.now() + clockOffset;
+}
+
+// Updates the difference between the server and the local clock
+function setServerTime(time) {
+    clockOffset = time - Date.now();
+}
+
+async function syncClock() {
+    try {
+        const response = await fetch("/time");
+        if (response.ok) {
+            const data = await response.json();
+            setServerTime(data.time);
+        }
+    } catch (error) {
+        console.log(error);
+    }
+}
+
+// Tells the server which post is open so its comments and reactions are streamed live
+function subscribePost(postId) {
+    if (conn && conn.readyState === WebSocket.OPEN) {
+        conn.send(JSON.stringify({
+            msg_type: postId ? "subscribe" : "unsubscribe",
+            post_id: postId
+        }));
+    }
+}
+
 function startWS() {
     if (window["WebSocket"]) {
-        conn = new WebSocket("ws://" + document.location.host + "/ws");
+        var url = "ws://" + document.location.host + "/ws";
+        if (reconnectToken !== "") {
+            url += "?token=" + encodeURIComponent(reconnectToken);
+            reconnectToken = "";
+        }
+        conn = new WebSocket(url);
+        closingWS = false;
 
         conn.onopen = function() {
             console.log("WebSocket connection is open");
             createUsers(allUsers, conn);
+            if (currPost) {
+                subscribePost(currPost);
+            }
         };
 
         conn.onclose = function(evt) {
             // Handle WebSocket connection close
             console.log("WebSocket connection is closed");
+
+            // Reconnects after a dropped connection, unless the user logged out.
+            // A full server asks to wait before trying again, a restarting one
+            // needs a moment to come back. Banned users are logged out, and so
+            // are users logged out everywhere.
+            if (evt.code === 1008 && evt.reason === "banned") {
+                reconnectToken = "";
+                alert("Your account has been banned.");
+                location.reload();
+                return;
+            }
+            if (evt.code === 1008 && evt.reason === "logged_out") {
+                reconnectToken = "";
+                alert("You have been logged out.");
+                location.reload();
+                return;
+            }
+            if (!closingWS) {
+                var delay = 1000;
+                var retry = /retry-after=(\d+)/.exec(evt.reason || "");
+                if (evt.code === 1013 && retry) {
+                    delay = parseInt(retry[1], 10) * 1000;
+                } else if (evt.code === 1012 && evt.reason === "server_shutdown") {
+                    delay = 3000;
+                }
+                setTimeout(startWS, delay);
+            }
         };
 
         conn.onmessage = function(evt) {
             var data = JSON.parse(evt.data);
             console.log(data);
 
+            if (data.msg_type === "server_time") {
+                setServerTime(data.time);
+                return;
+            }
+
+            if (data.msg_type === "reconnect_token") {
+                reconnectToken = data.token;
+                return;
+            }
+
+            if (data.msg_type === "new_comment") {
+                if (data.post_id === currPost && !(currComments || []).some(c => c.id === data.comment.id)) {
+                    currComments = (currComments || []).concat([data.comment]);
+                    createComments(currComments);
+                    document.getElementById('post-comments').innerHTML = currComments.length + " Comments";
+                }
+                return;
+            }
+
+            if (data.msg_type === "viewers") {
+                if (data.post_id === currPost) {
+                    var viewers = document.getElementById('post-viewers');
+                    viewers.innerHTML = (data.count === 0) ? "" : data.count + (data.count === 1 ? " person is" : " people are") + " reading this post";
+                }
+                return;
+            }
+
+            if (data.msg_type === "post_counts") {
+                // Keeps the counters of the posts on the feed fresh
+                data.posts.forEach(function(counts) {
+                    var post = postsContainer.querySelector('.post[id="' + counts.post_id + '"]');
+                    if (!post) {
+                        return;
+                    }
+                    post.querySelector(".likes").innerText = counts.likes;
+                    post.querySelector(".dislike").innerText = counts.dislikes;
+                    post.querySelector(".comment").innerText = counts.comments + " Comments";
+                });
+                return;
+            }
+
+            if (data.msg_type === "notification") {
+                if (data.kind === "login") {
+                    alert(data.content + ". If it wasn't you, use the link we emailed you to lock your account.");
+                }
+                return;
+            }
+
+            if (data.msg_type === "reaction") {
+                if (data.post_id === currPost) {
+                    document.getElementById('post-likes').innerHTML = data.likes;
+                    document.getElementById('post-dislikes').innerHTML = data.dislikes;
+                }
+                return;
+            }
+
+            if (data.msg_type === "ack") {
+                ackMessage(data);
+                return;
+            }
+
+            if (data.msg_type === "nack") {
+                nackMessage(data);
+                return;
+            }
+
+            if (data.msg_type === "read") {
+                showRead(data);
+                return;
+            }
+
+            if (data.msg_type === "conversation_settings") {
+                showConversationSettings(data);
+                return;
+            }
+
+            if (data.msg_type === "message_deleted") {
+                const el = document.getElementById('message-' + data.id);
+                if (el) {
+                    el.remove();
+                }
+                return;
+            }
+
             if (data.msg_type === "msg") {
+                // Skips messages already shown, and fetches the ones missed before this one
+                if (!trackSeq(data.sender_id == currId ? data.receiver_id : data.sender_id, data.seq)) {
+                    return;
+                }
+
                 // Handle message logs
                 var senderContainer = document.createElement("div");
                 senderContainer.className = (data.sender_id == currId) ? "sender-container" : "receiver-container";
@@ -138,7 +339,14 @@ function startWS() {
                 var date = document.createElement("div");
                 date.className = "chat-time";
                 date.innerText = data.date.slice(0, -3);
+                senderContainer.id = 'message-' + data.id;
+                senderContainer.dataset.seq = data.seq || 0;
                 appendLog(senderContainer, sender, date);
+                orderBySeq(senderContainer);
+
+                if (data.sender_id == chatPartner) {
+                    markRead(chatPartner);
+                }
 
                     // Call CreateMessages to append the new message to the chatbox
                     //CreateMessages(data, currId);
@@ -152,7 +360,8 @@ function startWS() {
                     return u[0] == id;
                 });
 
-                if (document.querySelector('.chat-wrapper').style.display == "none") {
+                // Messages of the open conversation are read straight away
+                if (data.sender_id != chatPartner) {
                     if (unreadMsgs.length == 0) {
                         unread.push([data.sender_id, 1]);
                     } else {
@@ -167,18 +376,34 @@ function startWS() {
                 getUsers().then(function() {
                     updateUsers();
                 });
-            } else if (data.msg_type === "post") {
+            } else if (data.msg_type === "new_post") {
                 // Handle post notifications
+                newPosts++;
+                document.querySelectorAll(".new-post-popup").forEach(function(popup) {
+                    popup.innerText = newPosts + (newPosts === 1 ? " new post" : " new posts");
+                });
                 newPostNotif.style.display = "flex";
         
-            } else if (data.msg_type === "") {
-                // Handle typing status updates
-                if (data.is_typing) {
+            } else if (data.msg_type === "typing_start" || data.msg_type === "typing_stop") {
+                // Typing indicators are only shown in the conversation with the user typing
+                if (data.sender_id != chatPartner) {
+                    return;
+                }
+
+                if (data.msg_type === "typing_start") {
                     console.log("User " + data.sender_id + " started typing");
                     document.querySelector("#typing-indicator").style.display = "block";
                     document.querySelector("#typing-text").textContent = "is typing";
                     var typingInterval = 2000; 
                     animateDots(typingInterval);
+
+                    // Hides the indicator when the server-assigned expiry passes
+                    clearTimeout(typingTimeout);
+                    if (data.expires) {
+                        typingTimeout = setTimeout(function() {
+                            document.querySelector("#typing-indicator").style.display = "none";
+                        }, Math.max(0, data.expires - serverNow()));
+                    }
                 } else {
                     console.log("User " + data.sender_id + " stopped typing");
                     document.querySelector("#typing-indicator").style.display = "none";
@@ -193,24 +418,28 @@ function startWS() {
 }
 
 window.addEventListener('DOMContentLoaded', async function() {
-    await getPosts();
-    await getUsers();
+    // The current user and server time come in one request, alongside the posts and users
+    let boot;
+    try {
+        [boot] = await Promise.all([getData('http://localhost:8000/bootstrap'), getPosts(), getUsers()]);
+        setServerTime(boot.server_time.time);
+    } catch (error) {
+        console.log(error);
+        await syncClock();
+    }
 
     document.querySelector('.chat-wrapper').style.display = "none";
 
-    let sess;
-    try {
-        sess = await postData('http://localhost:8000/session');
-    } catch (error) {
-        // Handle the absence of session cookie here
-        // For example, you can redirect the user to the login page or display a message
+    if (!boot || !boot.user) {
         console.log("Session cookie not found or expired");
+        if (boot && boot.features.public_read) {
+            showReadOnly(boot.categories);
+        }
         return;
     }
 
-    let vals = sess.msg.split("|");
-    currId = parseInt(vals[0]);
-    currUsername = vals[1];
+    currId = boot.user.id;
+    currUsername = boot.user.username;
 
     signinContainer.style.display = "none";
     signupNav.style.display = "none";
@@ -218,9 +447,12 @@ window.addEventListener('DOMContentLoaded', async function() {
     logoutNav.style.display = "flex";
 
     document.querySelector('.profile').innerText = currUsername;
+    fillCategories(boot.categories);
     startWS();
+    await loadUnread();
 
     createPosts(allPosts);
+    openSharedPost();
     updateUsers();
 });
 
@@ -228,12 +460,17 @@ window.addEventListener('DOMContentLoaded', async function() {
 function createPost(postdata) {
 
     document.querySelector('#title').innerHTML = postdata.title
-    document.querySelector('#username').innerHTML = allUsers.filter(u => {return u.id == postdata.user_id})[0].username
+    document.querySelector('#username').innerText = authorName(postdata.user_id, postdata.display_name)
     document.querySelector('#date').innerHTML = (postdata.date).slice(0, -3)
+    if (postdata.edited) {
+        document.querySelector('#date').title = "Edited " + postdata.edited.slice(0, -3)
+        document.querySelector('#date').innerHTML += " (edited)"
+    }
     document.querySelector('.category').innerHTML = postdata.category
     document.querySelector('.full-content').innerHTML = postdata.content
     document.getElementById('post-likes').innerHTML = postdata.likes
     document.getElementById('post-dislikes').innerHTML = postdata.dislikes
+    currReaction = postdata.reaction || ""
 }
 
 function createComments(commentsdata) {
@@ -242,7 +479,7 @@ function createComments(commentsdata) {
         return
     }
 
-    commentsdata.map(({id, post_id, user_id, content, date}) =>{
+    commentsdata.map(({id, post_id, user_id, content, date, display_name}) =>{
         var commentWrapper = document.createElement("div");
         commentWrapper.className = "comment-wrapper"
         commentsContainer.appendChild(commentWrapper)
@@ -257,7 +494,7 @@ function createComments(commentsdata) {
         comment.appendChild(commentUserWrapper)
         var commentUsername = document.createElement("div");
         commentUsername.className = "comment-username"
-        commentUsername.innerText = allUsers.filter(u => {return u.id == user_id})[0].username
+        commentUsername.innerText = authorName(user_id, display_name)
         commentUserWrapper.appendChild(commentUsername)
         var commentDate = document.createElement("div");
         commentDate.className = "comment-date"
@@ -276,9 +513,7 @@ function createPosts(postdata) {
         return
     }
 
-    postdata.map(async ({id, user_id, category, title, content, date, likes, dislikes}) => {
-        await getComments(id)
-
+    postdata.map(({id, user_id, category, title, content, date, likes, dislikes, comments: commentCount, display_name}) => {
         var post = document.createElement("div");
         post.className = "post"
         post.setAttribute("id", id)
@@ -295,7 +530,7 @@ function createPosts(postdata) {
         author.appendChild(img)
         var user = document.createElement("div");
         user.className = "post-username"
-        user.innerHTML = allUsers.filter(u => {return u.id == user_id})[0].username
+        user.innerText = authorName(user_id, display_name)
         author.appendChild(user)
         var postdate = document.createElement("div");
         postdate.className = "date"
@@ -339,11 +574,13 @@ function createPosts(postdata) {
         comments.appendChild(commentsImg)
         var comment = document.createElement("div");
         comment.className = "comment"
-        comment.innerText = (currComments === null) ? "0 Comments" : currComments.length + " Comments"
+        comment.innerText = commentCount + " Comments"
         comments.appendChild(comment)
 
         post.addEventListener("click", async function(e) {
             currPost = parseInt(post.getAttribute("id"))
+            document.getElementById('post-viewers').innerHTML = ""
+            subscribePost(currPost)
 
             await getComments(currPost)
 
@@ -357,7 +594,8 @@ function createPosts(postdata) {
         })
     })
 }
-var firstId = 512;
+// Id of the oldest message loaded in the open conversation, older pages are loaded from it
+var firstId = 0;
 
 function createUsers(userdata, conn) {
     onlineUsers.innerHTML = ""
@@ -415,7 +653,7 @@ function createUsers(userdata, conn) {
                 return;
             }
             
-            let resp = getData('http://localhost:8000/message?receiver=' + id+'&firstId='+ firstId);// Get the message logs from the database for the receiver ID
+            let resp = loadHistory(id, 0);// Get the latest page of the conversation with the receiver ID
             resp.then(value => {
                 let ridStr = user.getAttribute("id");
                 console.log("ridstr:", ridStr);
@@ -453,6 +691,22 @@ function appendLog(container, msg, date) {
     }
 }
 
+// Lists the categories the admins set up in the filter, and the ones the user can post in
+// in the new post form
+function fillCategories(categories) {
+    const filter = document.getElementById("categories");
+    const create = document.getElementById("create-post-categories");
+
+    filter.innerHTML = '<option value="all">All posts</option>';
+    create.innerHTML = "";
+    categories.forEach(({name, label, can_post}) => {
+        filter.add(new Option(label, name));
+        if (can_post) {
+            create.add(new Option(label, name));
+        }
+    });
+}
+
 document.getElementById("categories").onchange = function () {
     let val = document.getElementById("categories").value
 
@@ -463,29 +717,25 @@ document.getElementById("categories").onchange = function () {
 
     filteredPosts = allPosts.filter((i) => {
         console.log(i.category)
-        return i.category == val
+        return i.category == val || (i.crossposts || []).includes(val)
     })
     console.log(filteredPosts)
     createPosts(filteredPosts)
 }
 
-document.getElementById("like-btn").addEventListener("click", () => {
-    let resp = postData('http://localhost:8000/like?post_id='+currPost+'&col=likes')
+// Reacting the same way again takes the reaction back
+function react(kind) {
+    let resp = postData('http://localhost:8000/posts/'+currPost+'/react', {reaction: currReaction === kind ? "" : kind})
     resp.then(value => {
-        let vals = value.msg.split("|")
-        document.getElementById('post-likes').innerHTML = parseInt(vals[0])
-        document.getElementById('post-dislikes').innerHTML = parseInt(vals[1])
+        currReaction = value.reaction
+        document.getElementById('post-likes').innerHTML = value.likes
+        document.getElementById('post-dislikes').innerHTML = value.dislikes
     }).catch()
-})
+}
 
-document.getElementById("dislike-btn").addEventListener("click", () => {
-    let resp = postData('http://localhost:8000/like?post_id='+currPost+'&col=dislikes')
-    resp.then(value => {
-        let vals = value.msg.split("|")
-        document.getElementById('post-likes').innerHTML = parseInt(vals[0])
-        document.getElementById('post-dislikes').innerHTML = parseInt(vals[1])
-    }).catch()
-})
+document.getElementById("like-btn").addEventListener("click", () => react("like"))
+
+document.getElementById("dislike-btn").addEventListener("click", () => react("dislike"))
 
 //Sign in
 document.querySelector('.signin-btn').addEventListener("click", signIn);
@@ -537,7 +787,28 @@ async function signIn() {
 
     const errorMessageElement = document.querySelector('.error-message');
 
-    postData('http://localhost:8000/login', data)
+    fetch('http://localhost:8000/login', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify(data)
+    })
+        .then(async response => {
+            backOff(response, document.querySelector('.signin-btn'));
+            if (response.status === 429) {
+                throw { tooMany: true };
+            }
+            if (response.ok) {
+                return response.json();
+            }
+            // New accounts have to verify their email before signing in
+            let text = await response.text();
+            if (response.status === 403 && text.includes("Email not verified")) {
+                throw { unverified: true };
+            }
+            throw new Error(text);
+        })
         .then(resp => {
             let vals = resp.msg.split("|");
             currId = parseInt(vals[0]);
@@ -552,23 +823,151 @@ async function signIn() {
 
             emailUsername.value = "";
             signinPassword.value = "";
+            document.body.classList.remove('read-only');
 
             startWS();
 
             createPosts(allPosts);
-            updateUsers();
+            openSharedPost();
+            loadUnread().then(updateUsers);
 
             // Clear the error message
             errorMessageElement.innerText = "";
             errorMessageElement.classList.remove('show'); // Hide the error message box
         })
         .catch(error => {
+            if (error.tooMany) {
+                errorMessageElement.innerText = "Too many attempts, try again in a moment.";
+                errorMessageElement.classList.add('show');
+                return;
+            }
+            if (error.unverified) {
+                errorMessageElement.innerText = "Verify your email first, the link is in the email sent when you signed up.";
+                errorMessageElement.classList.add('show');
+                resendVerification(emailUsernameValue);
+                return;
+            }
             const errorMessage = "Username or password is incorrect.";
             errorMessageElement.innerText = errorMessage;
             errorMessageElement.classList.add('show'); // Show the error message box
         });
 }
 
+// Disables a button until the rate limit of the endpoint it calls resets, once no requests are left
+function backOff(response, button) {
+    if (response.headers.get('X-RateLimit-Remaining') !== "0") {
+        return;
+    }
+    let reset = parseInt(response.headers.get('X-RateLimit-Reset') || "0", 10) * 1000;
+    let wait = Math.max(0, reset - serverNow());
+    button.disabled = true;
+    setTimeout(() => { button.disabled = false; }, wait);
+}
+
+// Offers to send a new verification link, to the email signed in with or one asked for
+function resendVerification(emailUsername) {
+    if (!confirm("Send a new verification link?")) {
+        return;
+    }
+    let email = emailUsername.includes("@") ? emailUsername : prompt("Enter the email of your account:");
+    if (!email) {
+        return;
+    }
+    fetch('http://localhost:8000/verify', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ email: email })
+    }).then(response => {
+        if (response.status === 429) {
+            alert("Too many attempts, try again later.");
+        } else if (response.ok) {
+            alert("If this account still needs verifying, a new link is on its way.");
+        }
+    }).catch(error => console.log(error));
+}
+
+// Locks the account from the link of a new login email (/?not-me=), the link to choose a
+// new password is emailed to the owner of the account
+function notMe(token) {
+    history.replaceState(null, "", location.pathname);
+    fetch('http://localhost:8000/login/not-me?token=' + encodeURIComponent(token), {
+        method: 'POST'
+    }).then(response => {
+        alert(response.ok ? "Your account has been logged out everywhere. Check your email to choose a new password." : "This link is invalid or has expired.");
+    }).catch(error => console.log(error));
+}
+
+// Asks for the email of an account and has a reset link sent to it
+function forgotPassword() {
+    let email = prompt("Enter the email of your account:");
+    if (!email) {
+        return;
+    }
+    fetch('http://localhost:8000/forgot-password', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ email: email })
+    }).then(response => {
+        if (response.status === 429) {
+            alert("Too many attempts, try again later.");
+        } else if (response.ok) {
+            alert("If an account uses this email, a link to reset its password is on its way.");
+        }
+    }).catch(error => console.log(error));
+}
+
+// Sets a new password with the token of a reset link (/?reset=)
+function resetPassword(token) {
+    history.replaceState(null, "", location.pathname);
+    let password = prompt("Choose a new password:");
+    if (!password) {
+        return;
+    }
+    fetch('http://localhost:8000/reset-password', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify({ token: token, password: password })
+    }).then(response => {
+        alert(response.ok ? "Your password has been changed, you can now sign in." : "This link is invalid or has expired.");
+    }).catch(error => console.log(error));
+}
+
+// Shows the posts and comments to a visitor who isn't logged in, when the forum can be
+// read in public. Chat and everything that writes stays hidden until they sign in.
+function showReadOnly(categories) {
+    document.body.classList.add('read-only');
+    contentWrapper.style.display = "flex";
+    fillCategories(categories);
+    createPosts(allPosts);
+    openSharedPost();
+}
+
+// Name shown for the author of a post or comment. Visitors don't get the list of users,
+// so the display name that comes with it is used instead.
+function authorName(user_id, display_name) {
+    let user = allUsers.find(u => u && u.id == user_id);
+    return user ? user.username : display_name;
+}
+
+// Opens the post a share link (/s/{token}) led to, once the posts are shown
+function openSharedPost() {
+    let id = new URLSearchParams(location.search).get('post');
+    if (!id) {
+        return;
+    }
+    history.replaceState(null, "", location.pathname);
+    let post = postsContainer.querySelector('.post[id="' + parseInt(id) + '"]');
+    if (post) {
+        post.click();
+    }
+}
+
 // Function to shake a field
 function shakeField(field) {
     field.classList.add('shake');
@@ -581,6 +980,26 @@ function shakeField(field) {
 
 
 document.addEventListener('DOMContentLoaded', function() {
+    document.querySelectorAll('.forgot-link').forEach(link => link.addEventListener('click', forgotPassword));
+
+    // Opened from the link of a password reset email
+    let reset = new URLSearchParams(location.search).get('reset');
+    if (reset) {
+        resetPassword(reset);
+    }
+
+    // Opened from the link of a new login email
+    let notMeToken = new URLSearchParams(location.search).get('not-me');
+    if (notMeToken) {
+        notMe(notMeToken);
+    }
+
+    // Back from the link of a verification email
+    if (new URLSearchParams(location.search).get('verified')) {
+        history.replaceState(null, "", location.pathname);
+        alert("Your email is verified, you can now sign in.");
+    }
+
     // Sign up/Sign in button + link
     const signupLink = document.querySelector('#signup-link');
     const signinLink = document.querySelector('#signin-link');
@@ -637,7 +1056,7 @@ document.addEventListener('DOMContentLoaded', function() {
         const lname = document.querySelector("#lname").value;
         const email = document.querySelector("#email").value;
         const username = document.querySelector("#register-username").value;
-        const age = document.querySelector("#age").value;
+        const dob = document.querySelector("#dob").value;
         const gender = document.querySelector("#gender").value;
         const password = document.querySelector("#register-password").value;
 
@@ -670,18 +1089,6 @@ document.addEventListener('DOMContentLoaded', function() {
             isValid = false;
         }
 
-        if (age === "") {
-            errorMessageElement.innerText += "Enter a DOB. ";
-            errorMessageElement.classList.add('show'); // Show the error message box
-            isValid = false;
-        }
-
-        if (gender === "") {
-            errorMessageElement.innerText += "Enter a gender. ";
-            errorMessageElement.classList.add('show'); // Show the error message box
-            isValid = false;
-        }
-
         if (password === "") {
             errorMessageElement.innerText += "Enter a password. ";
             errorMessageElement.classList.add('show'); // Show the error message box
@@ -706,16 +1113,17 @@ document.addEventListener('DOMContentLoaded', function() {
             surname: lname,
             gender: gender,
             email: email,
-            dob: age,
+            dob: dob,
             password: password
         }
 
         postData('http://localhost:8000/register', data)
             .then(value => {
-                // Check if the response has an error property
-                if (value.error) {
-                    let customErrorMessage = "The email or username you entered is already taken.";
-                    errorMessageElement.innerText = customErrorMessage;
+                // Shows the problems the server found with each field
+                if (value.errors) {
+                    errorMessageElement.innerText = Object.entries(value.errors)
+                        .map(([field, problem]) => field + ": " + problem)
+                        .join("\n");
                     errorMessageElement.classList.add('show'); // Show the error message box
                     return;
                 }
@@ -806,8 +1214,6 @@ document.querySelector(".create-post-btn").addEventListener("click", function()
         await getPosts()
         createPosts(allPosts)
 
-        sendMsg(conn, 0, {value: "New Post"}, 'post')
-
         createPostContainer.style.display = "none"
         postsContainer.style.display = "flex"
         topPanel.style.display = "flex"
@@ -851,6 +1257,9 @@ document.querySelector(".back").addEventListener("click", home)
 document.querySelector("#back-btn").addEventListener("click", home)
 
 async function home() {
+    subscribePost(0)
+    currPost = 0
+
     selectCategories = document.getElementById("categories");
     selectCategories.selectedIndex = 0;
 
@@ -862,6 +1271,7 @@ async function home() {
     postsContainer.style.display = "flex"
     topPanel.style.display = "flex"
     newPostNotif.style.display = "none"
+    newPosts = 0
 }
 
 newPostNotif.addEventListener('click', async function() {
@@ -869,10 +1279,13 @@ newPostNotif.addEventListener('click', async function() {
     await getPosts()
     createPosts(allPosts)
     newPostNotif.style.display = "none"
+    newPosts = 0
     window.scrollTo(0, 0);
 });
 
 function closeWS() {
+    closingWS = true
+    reconnectToken = ""
     if (conn.readyState === WebSocket.OPEN) {
         conn.close()
     }
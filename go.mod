@@ -1,6 +1,6 @@
 module real-time-forum
 
-go 1.17
+go 1.21
 
 require (
 	github.com/gofrs/uuid v4.3.1+incompatible
@@ -0,0 +1,83 @@
+// Package chaos injects faults in development, so the retry and reconnect logic of
+// clients can be exercised against a misbehaving server.
+package chaos
+
+import (
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/config"
+)
+
+// Rates of the faults, every one of them is off unless set in the environment
+var (
+	errorRate   float64
+	latencyRate float64
+	latency     time.Duration
+	dropRate    float64
+)
+
+func init() {
+	spec := os.Getenv(config.ChaosEnv)
+	if spec == "" {
+		return
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 {
+			log.Fatalf("invalid %s entry %q", config.ChaosEnv, pair)
+		}
+
+		n, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || n < 0 {
+			log.Fatalf("invalid %s value %q", config.ChaosEnv, pair)
+		}
+
+		switch parts[0] {
+		case "error":
+			errorRate = n
+		case "latency":
+			latencyRate = n
+		case "delay":
+			latency = time.Duration(n) * time.Millisecond
+		case "drop":
+			dropRate = n
+		default:
+			log.Fatalf("unknown %s fault %q", config.ChaosEnv, parts[0])
+		}
+	}
+
+	//Uses the default maximum delay when only the rate is set
+	if latencyRate > 0 && latency == 0 {
+		latency = config.ChaosDefaultDelay * time.Millisecond
+	}
+
+	log.Printf("Fault injection enabled: error=%g latency=%g delay=%v drop=%g", errorRate, latencyRate, latency, dropRate)
+}
+
+// Enabled reports whether any fault is injected
+func Enabled() bool {
+	return errorRate > 0 || latencyRate > 0 || dropRate > 0
+}
+
+// Fail reports whether a request should fail
+func Fail() bool {
+	return errorRate > 0 && rand.Float64() < errorRate
+}
+
+// Delay sleeps for a random time up to the configured delay when latency is injected
+func Delay() {
+	if latencyRate > 0 && rand.Float64() < latencyRate {
+		time.Sleep(time.Duration(rand.Int63n(int64(latency) + 1)))
+	}
+}
+
+// Drop reports whether a websocket frame should be dropped
+func Drop() bool {
+	return dropRate > 0 && rand.Float64() < dropRate
+}
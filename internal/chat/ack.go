@@ -0,0 +1,48 @@
+package chat
+
+import (
+	"encoding/json"
+
+	"real-time-forum/internal/structure"
+)
+
+// Reasons a message can be refused for
+const (
+	nackEmpty   = "empty"   // The message has no content
+	nackStorage = "storage" // The message could not be saved, sending it again may work
+	nackSteps   = "steps"   // The sender has to complete their account first, see MessageNack.Missing
+)
+
+// ack tells the client that sent a message under a temporary id that it was stored. The
+// other connections of the sender get the message itself instead, see Hub.sendMessage.
+func (c *Client) ack(tempID string, msg structure.Message) {
+	data, err := json.Marshal(structure.MessageAck{
+		Msg_type: "ack",
+		Temp_id:  tempID,
+		Id:       msg.Id,
+		Seq:      msg.Seq,
+		Date:     msg.Date,
+	})
+	if err != nil {
+		return
+	}
+
+	c.queue(data, essential)
+}
+
+// nack tells the client that sent a message under a temporary id that it was not stored,
+// with the steps the sender has to complete first when that is the reason.
+func (c *Client) nack(tempID, reason string, retry bool, missing ...string) {
+	data, err := json.Marshal(structure.MessageNack{
+		Msg_type: "nack",
+		Temp_id:  tempID,
+		Reason:   reason,
+		Retry:    retry,
+		Missing:  missing,
+	})
+	if err != nil {
+		return
+	}
+
+	c.queue(data, essential)
+}
@@ -1,15 +1,25 @@
 package chat
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 
+	"real-time-forum/internal/chaos"
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/completion"
 	"real-time-forum/internal/config"
 	"real-time-forum/internal/database"
+	"real-time-forum/internal/events"
 	"real-time-forum/internal/structure"
 )
 
@@ -32,19 +42,27 @@ var (
 )
 
 var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
+	ReadBufferSize:    1024,
+	WriteBufferSize:   1024,
+	EnableCompression: config.WSCompression,
 }
 
 // Client is a middleman between the websocket connection and the hub.
 type Client struct {
-	hub        *Hub
-	conn       *websocket.Conn // The websocket connection.
-	send       chan []byte     // Buffered channel of outbound messages.
-	userID     int             // The user id of the client
-	typing     bool            // Track the typing status
-	typingLock chan bool
-	isReceiver bool // Track if the client is the receiver
+	hub         *Hub
+	conn        *websocket.Conn // The websocket connection.
+	send        chan []byte     // Buffered channel of outbound messages.
+	userID      int             // The user id of the client
+	postID      int             // The post the client is viewing, only used by the hub
+	hideViewing bool            // Whether the client is left out of viewer counts, only used by the hub
+	presence    string          // Who can see the user online, only used by the hub
+	name        string          // The display name of the user, only used by the hub
+	contacts    map[int]bool    // The users the user has chatted with, only used by the hub
+	mu          sync.Mutex      // Guards send, slow, closed and closeMsg, see queue
+	slow        bool            // Whether the send queue is nearly full
+	closed      bool            // Whether send is closed
+	closeMsg    []byte          // Close frame sent once send is closed, only set before that
+	compress    bool            // Whether messages over the compression threshold are compressed
 }
 
 // readPump pumps messages from the websocket connection to the hub.
@@ -56,12 +74,21 @@ func (c *Client) readPump() {
 	defer func() {
 		c.hub.unregister <- c
 		c.conn.Close()
+		releaseConnection()
+		if c.compress {
+			releaseCompression()
+		}
+		c.hub.pumps.Done()
 	}()
 
 	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error { c.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
 
+	//The request the connection was opened with is over once it is upgraded, the queries
+	//of each message are only bounded by their timeout
+	ctx := context.Background()
+
 	for {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
@@ -71,27 +98,93 @@ func (c *Client) readPump() {
 			break
 		}
 
-		log.Printf("Received message from client: %s", string(message))
-
-		var msg structure.Message
-		if err := json.Unmarshal(message, &msg); err != nil {
-			log.Printf("Error unmarshaling message: %v", err)
+		msg, err := parseMessage(message)
+		if err != nil {
+			log.Printf("Error reading message: %v", err)
 			break
 		}
 
+		//Subscriptions are bookkeeping for the hub and are not relayed
+		if msg.Msg_type == "subscribe" || msg.Msg_type == "unsubscribe" {
+			postID := msg.Post_id
+			if msg.Msg_type == "unsubscribe" {
+				postID = 0
+			}
+			//Users can choose not to show up as reading a post
+			p, _ := c.hub.db.FindPrivacy(ctx, c.userID)
+
+			//Each user counts once towards the views of a post, however often they open it
+			if postID != 0 {
+				c.hub.db.IncrementViews(ctx, postID, c.userID)
+			}
+
+			c.hub.subscriptions <- subscription{client: c, postID: postID, hidden: p.Hide_viewing}
+			continue
+		}
+
+		//Typing indicators are kept by the hub, which tells the receiver when they start and stop
+		if isTyping, ok := typingState(msg); ok {
+			c.hub.UpdateTypingStatus(c.userID, msg.Receiver_id, isTyping)
+			continue
+		}
+
+		//Messages are marked read up to seq, or all of them without one. The sender is told
+		//with a read receipt, as "read" with reader_id and seq.
+		if msg.Msg_type == "read" || msg.Msg_type == "mark_read" {
+			seq, err := c.hub.db.MarkMessagesRead(ctx, c.userID, msg.Receiver_id, msg.Seq, clock.Now().Format(config.TimeFormat))
+			if err != nil {
+				log.Printf("Error marking messages read: %v", err)
+				continue
+			}
+
+			if seq > 0 {
+				c.hub.MarkRead(c.userID, msg.Receiver_id, seq)
+			}
+			continue
+		}
+
 		msg.Sender_id = c.userID
+		msg.Display_name, _ = c.hub.db.FindDisplayName(ctx, c.userID)
+
+		//Never trusts the client clock
+		msg.Date = clock.Now().Format(config.TimeFormat)
+		msg.Expires = 0
 
 		if msg.Msg_type == "msg" {
-			msg.Date = time.Now().Format("01-02-2006 15:04:05")
+			//The temporary id only means something to the sender
+			tempID := msg.Temp_id
+			msg.Temp_id = ""
+
+			if strings.TrimSpace(msg.Content) == "" {
+				c.nack(tempID, nackEmpty, false)
+				continue
+			}
+
+			missing, err := completion.MissingToMessage(ctx, c.hub.db, c.userID, msg.Receiver_id)
+			if err != nil {
+				log.Printf("Error checking message requirements: %v", err)
+				c.nack(tempID, nackStorage, true)
+				continue
+			}
+			if len(missing) > 0 {
+				c.nack(tempID, nackSteps, false, missing...)
+				continue
+			}
 
-			err = database.NewMessage(config.Path, msg)
+			msg.Id, msg.Seq, err = c.hub.db.NewMessage(ctx, msg)
 			if err != nil {
 				log.Printf("Error storing new message: %v", err)
-				break
+				c.nack(tempID, nackStorage, true)
+				continue
 			}
 
-		} else if msg.Msg_type == "typing" {
-			c.hub.UpdateTypingStatus(c.userID, msg.Receiver_id, msg.IsTyping)
+			c.ack(tempID, msg)
+			c.hub.events.PublishAsync(events.MessageSent{Message: msg})
+
+			//The first message of a conversation makes the users contacts
+			if msg.Seq == 1 {
+				c.hub.contacts <- conversationOf(c.userID, msg.Receiver_id)
+			}
 		}
 
 		sendMsg, err := json.Marshal(msg)
@@ -100,11 +193,45 @@ func (c *Client) readPump() {
 			break
 		}
 
-		c.hub.broadcast <- sendMsg
+		c.hub.sendMessage(c, msg.Receiver_id, sendMsg, msg.Msg_type == "msg")
+	}
+}
+
+// parseMessage decodes a message from a client and checks it is one clients may send.
+// An empty msg_type is a typing status, as sent by older clients.
+func parseMessage(data []byte) (structure.Message, error) {
+	var msg structure.Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return msg, err
+	}
+
+	switch msg.Msg_type {
+	case "msg":
+		if msg.Receiver_id <= 0 {
+			return msg, errors.New("message without a receiver")
+		}
+	case "", "typing":
+	case "typing_start", "typing_stop":
+		if msg.Receiver_id <= 0 {
+			return msg, errors.New("typing indicator without a receiver")
+		}
+	case "read":
+		if msg.Receiver_id <= 0 || msg.Seq <= 0 {
+			return msg, errors.New("read receipt without a sender or sequence number")
+		}
+	case "mark_read":
+		if msg.Receiver_id <= 0 || msg.Seq < 0 {
+			return msg, errors.New("read receipt without a sender or with an invalid sequence number")
+		}
+	case "subscribe", "unsubscribe":
+		if msg.Post_id < 0 {
+			return msg, errors.New("invalid post id")
+		}
+	default:
+		return msg, fmt.Errorf("unknown message type %q", msg.Msg_type)
 	}
 
-	// Stop typing when the readPump exits
-	c.typingLock <- false
+	return msg, nil
 }
 
 // writePump pumps messages from the hub to the websocket connection.
@@ -118,6 +245,7 @@ func (c *Client) writePump() {
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
+		c.hub.pumps.Done()
 	}()
 
 	for {
@@ -126,21 +254,39 @@ func (c *Client) writePump() {
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				// The hub closed the channel.
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.conn.WriteMessage(websocket.CloseMessage, c.closeMsg)
 				return
 			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
+			// Fault injection loses the frame as a flaky network would.
+			if chaos.Drop() {
+				continue
 			}
-			w.Write(message)
 
 			// Add queued chat messages to the current websocket message.
+			batch := [][]byte{message}
+			size := len(message)
 			n := len(c.send)
 			for i := 0; i < n; i++ {
-				w.Write(newline)
-				w.Write(<-c.send)
+				queued := <-c.send
+				batch = append(batch, queued)
+				size += len(newline) + len(queued)
+			}
+
+			if c.compress {
+				c.conn.EnableWriteCompression(compressible(size))
+				atomic.AddInt64(&compressionStats.payload, int64(size))
+			}
+
+			w, err := c.conn.NextWriter(websocket.TextMessage)
+			if err != nil {
+				return
+			}
+			for i, m := range batch {
+				if i > 0 {
+					w.Write(newline)
+				}
+				w.Write(m)
 			}
 
 			if err := w.Close(); err != nil {
@@ -151,80 +297,133 @@ func (c *Client) writePump() {
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
-		case isTyping := <-c.typingLock:
-			c.typing = isTyping
-
-			// Send typing status only if the client is the receiver
-			if c.isReceiver {
-				typingStatus := struct {
-					ReceiverID int    `json:"receiver_id"`
-					IsTyping   bool   `json:"is_typing"`
-					MsgType    string `json:"msg_type"`
-				}{
-					ReceiverID: c.userID,
-					IsTyping:   c.typing,
-					MsgType:    "typing",
-				}
-				sendTypingStatus, err := json.Marshal(typingStatus)
-				if err != nil {
-					log.Println("Error marshaling typing status:", err)
-					continue
-				}
-				c.hub.broadcast <- sendTypingStatus
-			}
 		}
 	}
 }
 
-// serveWs handles websocket requests from the peer.
-func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Println(err)
-		return
+// ServerTime is the current time of the server as sent to clients.
+func ServerTime() structure.ServerTime {
+	now := clock.Now()
+	return structure.ServerTime{
+		Msg_type: "server_time",
+		Time:     now.UnixMilli(),
+		Date:     now.Format(config.TimeFormat),
+	}
+}
+
+// authenticate finds the user of a websocket request, from a reconnect token
+// when one is given, otherwise from the session cookie.
+func authenticate(db *database.Store, r *http.Request) (int, bool) {
+	if token := r.URL.Query().Get("token"); token != "" {
+		if userID, ok := redeemReconnectToken(token); ok {
+			return userID, true
+		}
 	}
 
 	cookie, err := r.Cookie("session")
 	if err != nil {
+		return 0, false
+	}
+
+	curr, err := db.CurrentUser(r.Context(), cookie.Value)
+	if err != nil {
+		return 0, false
+	}
+
+	return curr.Id, true
+}
+
+// serveWs handles websocket requests from the peer.
+func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
+	userID, ok := authenticate(hub.db, r)
+	if !ok {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	foundVal := cookie.Value
+	ServeWsAs(hub, w, r, userID)
+}
+
+// ServeWsAs handles a websocket request of a user the caller already authenticated, such
+// as the user an admin impersonates.
+func ServeWsAs(hub *Hub, w http.ResponseWriter, r *http.Request, userID int) {
+	reason, ok := acquireConnection()
 
-	curr, err := database.CurrentUser(config.Path, foundVal)
+	// Clients that offer permessage-deflate get it as long as the server has memory to spare
+	// for it, the others are sent uncompressed messages
+	compress := ok && config.WSCompression && offersCompression(r) && acquireCompression()
+
+	conn, err := upgrader.Upgrade(&countingWriter{ResponseWriter: w, counting: compress}, r, nil)
 	if err != nil {
+		if ok {
+			releaseConnection()
+		}
+		if compress {
+			releaseCompression()
+		}
+		log.Println(err)
+		return
+	}
+
+	// Browsers cannot read the status of a refused handshake, so the connection is
+	// opened and closed right away with a code they can read
+	if !ok {
+		refuseConnection(conn, reason)
 		return
 	}
 
+	// Who may see the user online, invisible if the setting cannot be read
+	presence := config.VisibilityPrivate
+	if p, err := hub.db.FindPrivacy(r.Context(), userID); err == nil {
+		presence = p.Presence
+	}
+
+	//The online users are sent with the names and contacts loaded here, the hub never
+	//reads them from the database
+	name, _ := hub.db.FindDisplayName(r.Context(), userID)
+
 	client := &Client{
-		hub:        hub,
-		conn:       conn,
-		send:       make(chan []byte, 256),
-		userID:     curr.Id,
-		typing:     false,
-		typingLock: make(chan bool),
-		isReceiver: false, // true or false based on your logic to determine if the client is the receiver,
+		hub:      hub,
+		conn:     conn,
+		send:     make(chan []byte, config.ClientQueueSize),
+		userID:   userID,
+		presence: presence,
+		name:     name,
+		contacts: userContacts(r.Context(), hub.db, userID),
+		compress: compress,
+	}
+	if compress {
+		conn.SetCompressionLevel(config.WSCompressionLevel)
+	} else {
+		conn.EnableWriteCompression(false)
+	}
+
+	// Tells the client the server time so it can correct its clock
+	msg, err := json.Marshal(ServerTime())
+	if err == nil {
+		client.queue(msg, essential)
+	}
+
+	// Hand out a fresh token for the next reconnect
+	token, expires, err := issueReconnectToken(userID)
+	if err == nil {
+		msg, err := json.Marshal(structure.ReconnectToken{
+			Msg_type: "reconnect_token",
+			Token:    token,
+			Expires:  expires.Unix(),
+		})
+		if err == nil {
+			client.queue(msg, essential)
+		}
 	}
 
-	log.Println("Client isReceiver:", client.isReceiver)
+	// The handshake messages go out without waiting for the hub to register the client
+	hub.pumps.Add(2)
+	go client.writePump()
+
 	client.hub.register <- client
 
 	// Allow collection of memory referenced by the caller by doing all work in
 	// new goroutines.
-	go client.writePump()
 	go client.readPump()
-
-	// Start a goroutine to listen for typing events
-	go func() {
-		for{
-			select {
-			case <-client.typingLock:
-				if client.typing {
-					log.Println("User started typing")
-				} else {
-					log.Println("User stopped typing")
-				}
-			}
-		}
-	}()
 }
@@ -0,0 +1,105 @@
+package chat
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Counters of what permessage-deflate saves on the connections that compress. They are
+// only accessed atomically.
+var compressionStats struct {
+	connections int64 // Connections currently compressing
+	payload     int64 // Bytes of the messages written to them
+	wire        int64 // Bytes sent on the network for them, frame headers included
+}
+
+// offersCompression checks whether the client asked for permessage-deflate in the handshake
+func offersCompression(r *http.Request) bool {
+	for _, ext := range r.Header.Values("Sec-Websocket-Extensions") {
+		if strings.Contains(ext, "permessage-deflate") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// acquireCompression takes one of the connections allowed to compress, returning false
+// when they are all taken. Every compressing writer holds a window in memory.
+func acquireCompression() bool {
+	if atomic.AddInt64(&compressionStats.connections, 1) > config.WSCompressionMaxConnections {
+		atomic.AddInt64(&compressionStats.connections, -1)
+		return false
+	}
+
+	return true
+}
+
+// releaseCompression gives back the connection taken by acquireCompression once it is closed.
+func releaseCompression() {
+	atomic.AddInt64(&compressionStats.connections, -1)
+}
+
+// compressible tells whether a message of the given size is worth compressing.
+func compressible(size int) bool {
+	return size >= config.WSCompressionThreshold
+}
+
+// countingWriter counts the bytes sent on the connection it hijacks, so the savings of
+// compression can be measured on the wire.
+type countingWriter struct {
+	http.ResponseWriter
+	counting bool
+}
+
+func (w *countingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, brw, err := h.Hijack()
+	if err != nil || !w.counting {
+		return conn, brw, err
+	}
+
+	return &countingConn{Conn: conn}, brw, nil
+}
+
+type countingConn struct {
+	net.Conn
+	upgraded bool // Set once the handshake response went out, which isn't counted
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+
+	if c.upgraded {
+		atomic.AddInt64(&compressionStats.wire, int64(n))
+	}
+	c.upgraded = true
+
+	return n, err
+}
+
+// compressionReport returns the compression counters since the server started.
+func compressionReport() structure.CompressionStats {
+	s := structure.CompressionStats{
+		Enabled:       config.WSCompression,
+		Connections:   atomic.LoadInt64(&compressionStats.connections),
+		Payload_bytes: atomic.LoadInt64(&compressionStats.payload),
+		Wire_bytes:    atomic.LoadInt64(&compressionStats.wire),
+	}
+
+	if s.Payload_bytes > 0 {
+		s.Ratio = float64(s.Wire_bytes) / float64(s.Payload_bytes)
+	}
+
+	return s
+}
@@ -0,0 +1,88 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+
+	"real-time-forum/internal/structure"
+)
+
+// pair identifies the conversation of two users, the lower id first.
+type pair [2]int
+
+func conversationOf(a, b int) pair {
+	if a > b {
+		a, b = b, a
+	}
+	return pair{a, b}
+}
+
+// agreement is what both users of a conversation allow.
+type agreement = structure.ConversationPrefs
+
+// conversationChange is what the users of a conversation now agree on.
+type conversationChange struct {
+	users  pair
+	agreed agreement
+}
+
+// readReceipt is a user having read the messages of another up to a sequence number.
+type readReceipt struct {
+	readerID int
+	senderID int
+	seq      int
+	allowed  bool // Whether both users allow read receipts
+}
+
+// UpdateConversation tells the hub what the users of a conversation agree on after
+// one of them changed their settings.
+func (h *Hub) UpdateConversation(a, b int, agreed structure.ConversationPrefs) {
+	key := conversationOf(a, b)
+	h.conversations.Store(key, agreed)
+	h.settings <- conversationChange{users: key, agreed: agreed}
+}
+
+// MarkRead tells the sender that the reader has read their messages up to seq, if both allow read receipts.
+func (h *Hub) MarkRead(readerID, senderID, seq int) {
+	allowed := h.agreed(readerID, senderID).Receipts
+	h.reads <- readReceipt{readerID: readerID, senderID: senderID, seq: seq, allowed: allowed}
+}
+
+// agreed returns what the users of a conversation both allow, read from the database the
+// first time. Nothing is allowed when the settings cannot be read. It is called before
+// handing a change to the hub, so Run never waits for the database.
+func (h *Hub) agreed(a, b int) agreement {
+	key := conversationOf(a, b)
+	if p, ok := h.conversations.Load(key); ok {
+		return p.(agreement)
+	}
+
+	s, err := h.db.FindConversationSettings(context.Background(), a, b)
+	if err != nil {
+		return agreement{}
+	}
+
+	//Settings changed in the meantime are newer than the ones just read
+	p, _ := h.conversations.LoadOrStore(key, s.Agreed)
+	return p.(agreement)
+}
+
+// sendRead forwards a read receipt to the sender of the messages. It must only be called from Run.
+func (h *Hub) sendRead(r readReceipt) {
+	if len(h.clients[r.senderID]) == 0 || !r.allowed {
+		return
+	}
+
+	msg, err := json.Marshal(structure.ReadReceipt{
+		Msg_type:  "read",
+		Reader_id: r.readerID,
+		Seq:       r.seq,
+	})
+	if err != nil {
+		return
+	}
+
+	for client := range h.clients[r.senderID] {
+		client.queue(msg, essential)
+	}
+}
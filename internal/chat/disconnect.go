@@ -0,0 +1,28 @@
+package chat
+
+import "github.com/gorilla/websocket"
+
+// Close frame telling clients the user was banned, so they don't connect again
+var bannedClose = websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "banned")
+
+// Close frame telling clients the user was logged out everywhere, so they log in again
+var loggedOutClose = websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "logged_out")
+
+// userClose closes every connection of a user with the close frame.
+type userClose struct {
+	userID int
+	frame  []byte
+}
+
+// Disconnect closes the connection of a user who was banned with the banned close frame.
+// Their reconnect tokens have to be revoked too, see RevokeReconnectTokens.
+func (h *Hub) Disconnect(userID int) {
+	h.disconnect <- userClose{userID: userID, frame: bannedClose}
+}
+
+// LogOut closes the connections of a user who was logged out everywhere, like after a
+// password reset, with the logged_out close frame. Their reconnect tokens have to be
+// revoked too, see RevokeReconnectTokens.
+func (h *Hub) LogOut(userID int) {
+	h.disconnect <- userClose{userID: userID, frame: loggedOutClose}
+}
@@ -0,0 +1,77 @@
+package chat
+
+import (
+	"encoding/json"
+	"sort"
+
+	"real-time-forum/internal/structure"
+)
+
+// feedPost is a new post for the clients displaying the feed, those of its author excepted.
+type feedPost struct {
+	authorID int
+	message  []byte
+}
+
+// AnnouncePost lets the clients displaying the feed know about a new post, the author's
+// own clients excepted.
+func (h *Hub) AnnouncePost(authorID int, message []byte) {
+	h.newPosts <- feedPost{authorID: authorID, message: message}
+}
+
+// UpdateCounts queues the new counters of a post for the clients displaying the feed.
+func (h *Hub) UpdateCounts(counts structure.PostCounts) {
+	h.counts <- counts
+}
+
+// sendCounts pushes every counter update queued since the last call, in a single
+// message, to the clients that are on the feed. It must only be called from Run.
+func (h *Hub) sendCounts() {
+	if len(h.pendingCounts) == 0 {
+		return
+	}
+
+	batch := structure.FeedCounts{Msg_type: "post_counts"}
+	for _, c := range h.pendingCounts {
+		batch.Posts = append(batch.Posts, c)
+	}
+	h.pendingCounts = make(map[int]structure.PostCounts)
+
+	sort.Slice(batch.Posts, func(i, j int) bool { return batch.Posts[i].Post_id < batch.Posts[j].Post_id })
+
+	msg, err := json.Marshal(batch)
+	if err != nil {
+		return
+	}
+
+	for _, clients := range h.clients {
+		for c := range clients {
+			//Counters are best effort, a slow client just misses this batch
+			if onFeed(c) {
+				c.queue(msg, kindCounts)
+			}
+		}
+	}
+}
+
+// sendPost announces a new post to the clients on the feed. It must only be called from Run.
+func (h *Hub) sendPost(p feedPost) {
+	for userID, clients := range h.clients {
+		if userID == p.authorID {
+			continue
+		}
+
+		for c := range clients {
+			if onFeed(c) {
+				c.queue(p.message, essential)
+			}
+		}
+	}
+}
+
+// onFeed checks whether a client is displaying the feed, which lists every post like
+// GET /post does. Clients viewing a single post get its updates live instead.
+// It must only be called from Run.
+func onFeed(c *Client) bool {
+	return c.postID == 0
+}
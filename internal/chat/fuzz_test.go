@@ -0,0 +1,17 @@
+package chat
+
+import "testing"
+
+// Messages the parser accepts must be ones the read pump can act on
+func FuzzMessage(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := parseMessage(data)
+		if err != nil {
+			return
+		}
+
+		if msg.Msg_type == "msg" && msg.Receiver_id <= 0 {
+			t.Errorf("message without a receiver accepted: %s", data)
+		}
+	})
+}
@@ -1,157 +1,265 @@
 package chat
 
 import (
-	"encoding/json"
-	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/database"
+	"real-time-forum/internal/events"
 	"real-time-forum/internal/structure"
 )
 
-// Hub maintains the set of active clients and broadcasts messages to the clients.
+// Hub maintains the set of active clients and routes messages to the clients.
+//
+// Concurrency model: every field of the hub, and the hub-only fields of the clients
+// (postID, hideViewing, presence, name, contacts), is owned by the goroutine running Run
+// and is never touched by any other goroutine. Other goroutines, the read and write pumps of the
+// clients and the http handlers, only talk to the hub through its channels, using the
+// exported methods. A client is in clients until the hub removes it, which closes its
+// send queue and makes the write pump close the connection. The read pump then
+// unregisters a client that is already gone, which the hub ignores.
+//
+// The exceptions are safe to use from any goroutine: the pumps wait group, the routes
+// snapshot of the clients, the conversation settings and the send queues of the clients,
+// see Client.queue. What Run needs from the database, the settings, display names and
+// contacts of the users, is read before a change is handed to the hub, never by Run itself.
+// A user can be connected from several tabs and devices at once, each with its own client.
+// Messages for a single user, chat messages among them, go straight to the queues of
+// their clients through the routes, so they never wait for the hub or for other clients.
+// The hub fans out what concerns several clients, the viewers of a post, the feed and
+// the online users, and a slow client only ever loses its own messages.
 type Hub struct {
-	clients      map[int]*Client // Registered clients
-	broadcast    chan []byte     // Inbound messages from the clients
-	register     chan *Client    // Register requests from the clients
-	unregister   chan *Client    // Unregister requests from clients
-	typing       map[int]bool    // Map to store the typing status of clients
-	typing2      map[string]bool // Map to store the typing status of clients
-	typingStatus map[int]int     // Map to store the typing s
+	clients         map[int]map[*Client]struct{} // Registered clients of each user
+	routes          atomic.Value                 // Copy of clients read by other goroutines, never modified
+	register        chan *Client                 // Register requests from the clients
+	unregister      chan *Client                 // Unregister requests from clients
+	typing          chan typingUpdate            // Typing status changes of the clients
+	typists         map[int]typist               // Who each user is typing to
+	subscriptions   chan subscription            // Clients opening or leaving a post
+	postEvents      chan postEvent               // Messages for the viewers of a post
+	posts           map[int]map[*Client]bool     // Clients subscribed to each post
+	viewersChanged  map[int]bool                 // Posts whose viewer count has to be sent again
+	counts          chan structure.PostCounts    // Updated counters of posts
+	pendingCounts   map[int]structure.PostCounts // Counters waiting to be sent to the feed
+	newPosts        chan feedPost                // New posts to announce on the feed
+	presence        chan presenceChange          // Users changing who can see them online
+	presenceChanged bool                         // Whether the online users have to be sent again
+	names           chan nameChange              // Users changing their display name
+	contacts        chan pair                    // Conversations whose users just became contacts
+	settings        chan conversationChange      // Conversations whose settings changed
+	conversations   sync.Map                     // What the users of each conversation allow, see agreed
+	reads           chan readReceipt             // Users having read messages
+	online          chan onlineQuery             // Questions whether users are connected
+	shutdown        chan chan struct{}           // Requests to close every connection, see Shutdown
+	disconnect      chan userClose               // Users whose connections are closed, see Disconnect
+	closing         bool                         // Whether the server is shutting down and new clients are turned away
+	pumps           sync.WaitGroup               // Read and write pumps still running
+	events          *events.Bus                  // Where the hub publishes what happens on the websocket
+	db              *database.Store              // The database, shared with the http handlers
 }
 
-func NewHub() *Hub {
-	return &Hub{
-		broadcast:    make(chan []byte),     // Initialize the broadcast channel
-		register:     make(chan *Client),    // Initialize the register channel
-		unregister:   make(chan *Client),    // Initialize the unregister channel
-		clients:      make(map[int]*Client), // Initialize the clients map
-		typing:       make(map[int]bool),    // Initialize the typing map
-		typing2:      make(map[string]bool), // Initialize the typing map
-		typingStatus: make(map[int]int),     // Initialize the typing status map
+func NewHub(bus *events.Bus, db *database.Store) *Hub {
+	h := &Hub{
+		register:       make(chan *Client),                                   // Initialize the register channel
+		unregister:     make(chan *Client),                                   // Initialize the unregister channel
+		clients:        make(map[int]map[*Client]struct{}),                   // Initialize the clients map
+		typing:         make(chan typingUpdate, config.HubQueueSize),         // Initialize the typing status channel
+		typists:        make(map[int]typist),                                 // Initialize the typing indicators map
+		subscriptions:  make(chan subscription),                              // Initialize the subscription channel
+		postEvents:     make(chan postEvent, config.HubQueueSize),            // Initialize the post event channel
+		posts:          make(map[int]map[*Client]bool),                       // Initialize the post subscriptions map
+		viewersChanged: make(map[int]bool),                                   // Initialize the changed viewer counts map
+		counts:         make(chan structure.PostCounts, config.HubQueueSize), // Initialize the post counters channel
+		pendingCounts:  make(map[int]structure.PostCounts),                   // Initialize the pending counters map
+		newPosts:       make(chan feedPost, config.HubQueueSize),             // Initialize the new posts channel
+		presence:       make(chan presenceChange, config.HubQueueSize),       // Initialize the presence change channel
+		names:          make(chan nameChange, config.HubQueueSize),           // Initialize the name change channel
+		contacts:       make(chan pair, config.HubQueueSize),                 // Initialize the new contacts channel
+		settings:       make(chan conversationChange, config.HubQueueSize),   // Initialize the conversation settings channel
+		reads:          make(chan readReceipt, config.HubQueueSize),          // Initialize the read receipt channel
+		online:         make(chan onlineQuery),                               // Initialize the online query channel
+		shutdown:       make(chan chan struct{}),                             // Initialize the shutdown channel
+		disconnect:     make(chan userClose),                                 // Initialize the disconnect channel
+		events:         bus,
+		db:             db,
 	}
+	h.routes.Store(map[int][]*Client{})
+
+	return h
 }
 
 func (h *Hub) Run() { // Run the hub
+	// Viewer counts are sent in batches so opening and closing posts does not flood clients
+	viewerTicker := clock.NewTicker(config.ViewerCountInterval * time.Millisecond)
+	defer viewerTicker.Stop()
+
+	// Counter updates for the feed are batched the same way
+	countsTicker := clock.NewTicker(config.FeedCountsInterval * time.Millisecond)
+	defer countsTicker.Stop()
+
+	// Online users are sent again regularly so clients that missed a change catch up
+	presenceTicker := clock.NewTicker(config.PresenceInterval * time.Second)
+	defer presenceTicker.Stop()
+
+	// Changes to the online users are sent in batches, see PresenceBatchInterval
+	presenceBatchTicker := clock.NewTicker(config.PresenceBatchInterval * time.Millisecond)
+	defer presenceBatchTicker.Stop()
+
+	// Typing indicators that were not renewed are stopped by the hub
+	typingTicker := clock.NewTicker(time.Second)
+	defer typingTicker.Stop()
+
 	for {
 		select {
-		case client := <-h.register: // Register a client
-			h.clients[client.userID] = client // Add the client to the clients map
-
-			// Notify other clients that this client is online
-			uids := make([]int, 0, len(h.clients)) // Create a slice of user IDs
-			for id := range h.clients {            // Iterate over the clients map
-				uids = append(uids, id) // Append the user ID to the slice
+		case <-viewerTicker.C:
+			h.sendViewerCounts()
+		case <-countsTicker.C:
+			h.sendCounts()
+		case <-presenceTicker.C:
+			if len(h.clients) > 0 {
+				h.broadcastOnline()
 			}
-			msg := structure.OnlineUsers{ // Create a message
-				UserIds:  uids,     // Set the user IDs
-				Msg_type: "online", // Set the message type
+		case <-presenceBatchTicker.C:
+			if h.presenceChanged {
+				h.broadcastOnline()
 			}
-			sendMsg, err := json.Marshal(msg)
-			if err != nil {
-				panic(err)
+		case <-typingTicker.C:
+			h.expireTyping()
+		case c := <-h.counts:
+			h.pendingCounts[c.Post_id] = c
+		case p := <-h.newPosts:
+			h.sendPost(p)
+		case p := <-h.presence:
+			for client := range h.clients[p.userID] {
+				client.presence = p.mode
+				h.presenceChanged = true
 			}
-
-			for _, c := range h.clients {
-				select {
-				case c.send <- sendMsg: // Send the message to the client
-				default:
-					close(c.send)               // Close the send channel
-					delete(h.clients, c.userID) // Delete the client from the clients map
-				}
+		case n := <-h.names:
+			for client := range h.clients[n.userID] {
+				client.name = n.name
+				h.presenceChanged = true
 			}
-		case client := <-h.unregister: // Unregister a client
-			if _, ok := h.clients[client.userID]; ok { // Check if the client is registered
-				delete(h.clients, client.userID)
-
-				// Notify other clients that this client is offline
-				uids := make([]int, 0, len(h.clients))
-				for id := range h.clients {
-					uids = append(uids, id)
-				}
-				msg := structure.OnlineUsers{
-					UserIds:  uids,
-					Msg_type: "online",
-				}
-				sendMsg, err := json.Marshal(msg)
-				if err != nil {
-					panic(err)
-				}
-
-				for _, c := range h.clients {
-					select {
-					case c.send <- sendMsg:
-					default:
-						close(c.send)
-						delete(h.clients, c.userID)
-					}
-				}
-
-				close(client.send)
+		case users := <-h.contacts:
+			h.addContacts(users)
+		case done := <-h.shutdown:
+			h.closeAll()
+			close(done)
+		case d := <-h.disconnect:
+			for client := range h.clients[d.userID] {
+				h.drop(client, d.frame)
 			}
-		case message := <-h.broadcast:
-			// Process the message
-			var msg structure.Message
-			if err := json.Unmarshal(message, &msg); err != nil {
-				panic(err)
+		case client := <-h.register: // Register a client
+			// Connections opened while shutting down are closed straight away
+			if h.closing {
+				client.close(shutdownClose)
+				continue
 			}
 
-			sendMsg, err := json.Marshal(msg)
-			if err != nil {
-				panic(err)
+			// Every connection of the user is kept, each tab and device gets their messages,
+			// up to the limit. The new one is refused, the user's open tabs keep working.
+			if len(h.clients[client.userID]) >= config.MaxConnectionsPerUser {
+				client.close(tooManyClose)
+				continue
+			}
+			if h.clients[client.userID] == nil {
+				h.clients[client.userID] = make(map[*Client]struct{})
 			}
+			h.clients[client.userID][client] = struct{}{} // Add the client to the clients map
+			h.updateRoutes()
 
-			if msg.Msg_type == "msg" { // Check if the message is a chat message
-				for _, client := range h.clients {
-					if client.userID == msg.Receiver_id {
-						select {
-						case client.send <- sendMsg:
-						default:
-							close(client.send)
-							delete(h.clients, client.userID)
-						}
-					}
-				}
-			} else { // Check if the message is a typing status update
-				for _, client := range h.clients { // Iterate over the clients map
-					if client.userID != msg.Sender_id { // Check if the client is not the sender
-						select {
-						case client.send <- sendMsg: // Send the message to the client
-						default:
-							close(client.send)
-							delete(h.clients, client.userID)
-						}
-					}
-				}
+			// Other clients are told that this user is online with the next batch, and the
+			// new client who else is
+			h.presenceChanged = true
+		case client := <-h.unregister: // Unregister a client
+			// Clients already dropped are gone
+			if h.registered(client) {
+				h.drop(client, nil)
+
+				// Other clients are told with the next batch, once the user is offline
+				h.presenceChanged = true
 			}
+		case t := <-h.typing:
+			h.sendTyping(t)
+		case c := <-h.settings:
+			if !c.agreed.Typing {
+				h.stopTypingBetween(c.users)
+			}
+		case r := <-h.reads:
+			h.sendRead(r)
+		case q := <-h.online:
+			q.reply <- len(h.clients[q.userID]) > 0
+		case s := <-h.subscriptions:
+			h.subscribe(s)
+		case e := <-h.postEvents:
+			h.publish(e)
 		}
 	}
 }
 
-// UpdateTypingStatus updates the typing status of a client in the hub.
-func (h *Hub) UpdateTypingStatus(senderID, receiverID int, isTyping bool) {
-	h.typing2[fmt.Sprintf("%d_%d", senderID, receiverID)] = isTyping // Update the typing status of the client
-
-	// Notify the receiver client about the typing status change
-	msg := structure.TypingStatus{
-		UserID:     senderID,
-		IsTyping:   isTyping,
-		Msgtype:    "typing",
-		ReceiverID: receiverID,
-		SenderID:   senderID,
-	}
-	sendMsg, err := json.Marshal(msg) // Create a message
-	if err != nil {
-		panic(err)
+// SendTo delivers a message to every connection of a user.
+func (h *Hub) SendTo(userID int, message []byte) {
+	for _, c := range h.route(userID) {
+		c.queue(message, essential)
 	}
+}
+
+// sendMessage delivers a chat message to its receiver, straight from the read pump of the
+// sender. A stored message also goes to the other connections of the sender, so every tab
+// shows the conversation. Sending the message ends the typing indicator of the sender.
+func (h *Hub) sendMessage(sender *Client, receiverID int, message []byte, stored bool) {
+	h.SendTo(receiverID, message)
 
-	for _, client := range h.clients {
-		if client.userID == receiverID {
-			select {
-			case client.send <- sendMsg:
-			default:
-				close(client.send)
-				delete(h.clients, client.userID)
+	if stored && receiverID != sender.userID {
+		for _, c := range h.route(sender.userID) {
+			if c != sender {
+				c.queue(message, essential)
 			}
-			break // Exit the loop after sending the message to the receiver client
 		}
 	}
+
+	h.UpdateTypingStatus(sender.userID, receiverID, false)
+}
+
+// route finds the clients of a user from any goroutine.
+func (h *Hub) route(userID int) []*Client {
+	return h.routes.Load().(map[int][]*Client)[userID]
+}
+
+// updateRoutes replaces the routes with a copy of clients after it changed.
+// It must only be called from Run.
+func (h *Hub) updateRoutes() {
+	routes := make(map[int][]*Client, len(h.clients))
+	connected := 0
+	for id, clients := range h.clients {
+		for c := range clients {
+			routes[id] = append(routes[id], c)
+		}
+		connected += len(clients)
+	}
+
+	h.routes.Store(routes)
+	atomic.StoreInt64(&hubStats.connected, int64(connected))
+}
+
+// registered checks whether a client is still in clients. It must only be called from Run.
+func (h *Hub) registered(c *Client) bool {
+	_, ok := h.clients[c.userID][c]
+	return ok
+}
+
+// drop removes a client from the hub and closes its send queue with the given close frame,
+// which ends its connection. The typing indicator of the user ends with their last client.
+// It must only be called from Run, for a client that is in clients.
+func (h *Hub) drop(c *Client, closeMsg []byte) {
+	h.unsubscribe(c)
+	delete(h.clients[c.userID], c)
+	if len(h.clients[c.userID]) == 0 {
+		delete(h.clients, c.userID)
+		h.stopTyping(c.userID)
+	}
+	c.close(closeMsg)
+	h.updateRoutes()
 }
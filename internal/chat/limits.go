@@ -0,0 +1,54 @@
+package chat
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"real-time-forum/internal/config"
+)
+
+// Open websocket connections, the ones of each user are counted by the hub.
+var connections = struct {
+	sync.Mutex
+	total int
+}{}
+
+// acquireConnection counts a new connection, returning why it is refused when the server is full.
+func acquireConnection() (string, bool) {
+	connections.Lock()
+	defer connections.Unlock()
+
+	if connections.total >= config.MaxConnections {
+		return "server is full", false
+	}
+
+	connections.total++
+	return "", true
+}
+
+// releaseConnection stops counting a connection once it is closed.
+func releaseConnection() {
+	connections.Lock()
+	defer connections.Unlock()
+
+	connections.total--
+}
+
+// Close frame refusing a connection of a user who already has as many as allowed
+var tooManyClose = limitClose("too many connections")
+
+// limitClose is the try again later close frame, telling the client how many seconds to
+// wait in the reason.
+func limitClose(reason string) []byte {
+	return websocket.FormatCloseMessage(websocket.CloseTryAgainLater,
+		"retry-after="+strconv.Itoa(config.ConnectionRetryAfter)+"; "+reason)
+}
+
+// refuseConnection closes a connection over the server limit before it reaches the hub.
+func refuseConnection(conn *websocket.Conn, reason string) {
+	conn.WriteControl(websocket.CloseMessage, limitClose(reason), time.Now().Add(writeWait))
+	conn.Close()
+}
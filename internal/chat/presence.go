@@ -0,0 +1,167 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/database"
+	"real-time-forum/internal/structure"
+)
+
+// presenceChange updates who can see that a user is online.
+type presenceChange struct {
+	userID int
+	mode   string
+}
+
+// nameChange is a user changing their display name.
+type nameChange struct {
+	userID int
+	name   string
+}
+
+// onlineQuery asks the hub whether a user is connected.
+type onlineQuery struct {
+	userID int
+	reply  chan bool
+}
+
+// IsOnline tells whether a user is connected, whoever they let see it.
+func (h *Hub) IsOnline(userID int) bool {
+	q := onlineQuery{userID: userID, reply: make(chan bool, 1)}
+	h.online <- q
+	return <-q.reply
+}
+
+// SetPresence changes the presence visibility of a connected user and tells everyone again who is online.
+func (h *Hub) SetPresence(userID int, mode string) {
+	h.presence <- presenceChange{userID: userID, mode: mode}
+}
+
+// Rename changes the display name a connected user is listed with among the online users.
+func (h *Hub) Rename(userID int, name string) {
+	h.names <- nameChange{userID: userID, name: name}
+}
+
+// broadcastOnline sends every client the online users it is allowed to see. Most clients
+// see the same users, those visible to everyone, and share a single message. Only clients
+// whose users also see themselves or contacts who hide from others get their own.
+// It must only be called from Run.
+func (h *Hub) broadcastOnline() {
+	h.presenceChanged = false
+
+	// Every client of a user has the same presence, name and contacts, the first one
+	// stands for the user
+	var public, hidden []*Client
+	for _, clients := range h.clients {
+		c := anyClient(clients)
+		switch c.presence {
+		case config.VisibilityPrivate, config.VisibilityContacts:
+			hidden = append(hidden, c)
+		default:
+			public = append(public, c)
+		}
+	}
+
+	var shared []byte
+	for id, clients := range h.clients {
+		visible := make([]*Client, 0, len(public)+1)
+		visible = append(visible, public...)
+		for _, subject := range hidden {
+			if canSeePresence(id, subject) {
+				visible = append(visible, subject)
+			}
+		}
+
+		sendMsg := shared
+		if len(visible) != len(public) || shared == nil {
+			var err error
+			sendMsg, err = onlineMessage(visible)
+			if err != nil {
+				log.Printf("Error marshaling online users: %v", err)
+				continue
+			}
+
+			if len(visible) == len(public) {
+				shared = sendMsg
+			}
+		}
+
+		for recipient := range clients {
+			recipient.queue(sendMsg, kindPresence)
+		}
+	}
+}
+
+// anyClient returns one of the clients of a user.
+func anyClient(clients map[*Client]struct{}) *Client {
+	for c := range clients {
+		return c
+	}
+
+	return nil
+}
+
+// onlineMessage lists the given clients as the online users.
+func onlineMessage(visible []*Client) ([]byte, error) {
+	online := structure.OnlineUsers{
+		UserIds:       make([]int, 0, len(visible)),
+		Msg_type:      "online",
+		Display_names: make(map[int]string, len(visible)),
+	}
+	for _, c := range visible {
+		online.UserIds = append(online.UserIds, c.userID)
+		online.Display_names[c.userID] = c.name
+	}
+
+	return json.Marshal(online)
+}
+
+// canSeePresence checks whether the viewer may know that the subject is online.
+func canSeePresence(viewer int, subject *Client) bool {
+	if viewer == subject.userID {
+		return true
+	}
+
+	switch subject.presence {
+	case config.VisibilityPrivate:
+		return false
+	case config.VisibilityContacts:
+		return subject.contacts[viewer]
+	default:
+		return true
+	}
+}
+
+// addContacts makes the users of a conversation contacts of each other, on every client
+// of both. It must only be called from Run.
+func (h *Hub) addContacts(users pair) {
+	for i, id := range users {
+		for c := range h.clients[id] {
+			c.contacts[users[1-i]] = true
+			h.presenceChanged = true
+		}
+	}
+}
+
+// userContacts finds the users a user has chatted with.
+func userContacts(ctx context.Context, db *database.Store, userID int) map[int]bool {
+	contacts := make(map[int]bool)
+
+	chats, err := db.FindUserChats(ctx, userID)
+	if err != nil {
+		return contacts
+	}
+
+	for _, c := range chats {
+		if c.User_one == userID {
+			contacts[c.User_two] = true
+		} else {
+			contacts[c.User_one] = true
+		}
+	}
+
+	return contacts
+}
@@ -0,0 +1,77 @@
+package chat
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+)
+
+// reconnectToken lets a client reopen its websocket without the session cookie.
+type reconnectToken struct {
+	userID  int
+	expires time.Time
+}
+
+// Issued reconnect tokens, keyed by the token itself.
+var reconnectTokens = struct {
+	sync.Mutex
+	tokens map[string]reconnectToken
+}{tokens: make(map[string]reconnectToken)}
+
+// issueReconnectToken creates a single-use token bound to the user.
+func issueReconnectToken(userID int) (string, time.Time, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+
+	token := hex.EncodeToString(b)
+	expires := clock.Now().Add(config.ReconnectTokenAge * time.Second)
+
+	reconnectTokens.Lock()
+	defer reconnectTokens.Unlock()
+
+	// Drop expired tokens so the map does not grow forever
+	for t, rt := range reconnectTokens.tokens {
+		if clock.Now().After(rt.expires) {
+			delete(reconnectTokens.tokens, t)
+		}
+	}
+
+	reconnectTokens.tokens[token] = reconnectToken{userID: userID, expires: expires}
+	return token, expires, nil
+}
+
+// redeemReconnectToken consumes a token, returning the user it was issued to.
+func redeemReconnectToken(token string) (int, bool) {
+	reconnectTokens.Lock()
+	defer reconnectTokens.Unlock()
+
+	rt, ok := reconnectTokens.tokens[token]
+	if !ok {
+		return 0, false
+	}
+
+	delete(reconnectTokens.tokens, token)
+	if clock.Now().After(rt.expires) {
+		return 0, false
+	}
+
+	return rt.userID, true
+}
+
+// RevokeReconnectTokens invalidates every reconnect token of a user, e.g. on logout.
+func RevokeReconnectTokens(userID int) {
+	reconnectTokens.Lock()
+	defer reconnectTokens.Unlock()
+
+	for t, rt := range reconnectTokens.tokens {
+		if rt.userID == userID {
+			delete(reconnectTokens.tokens, t)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package chat
+
+import (
+	"context"
+
+	"github.com/gorilla/websocket"
+)
+
+// Close frame telling clients the server is restarting, so they connect again
+var shutdownClose = websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server_shutdown")
+
+// Shutdown closes the connection of every client with the server_shutdown close frame and
+// turns away new ones. It returns once the pumps of the clients have finished, so messages
+// being read are stored, or when the context is done.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case h.shutdown <- done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	<-done
+
+	drained := make(chan struct{})
+	go func() {
+		h.pumps.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeAll drops every client with the shutdown close frame. It must only be called from Run.
+func (h *Hub) closeAll() {
+	h.closing = true
+	for _, clients := range h.clients {
+		for c := range clients {
+			h.drop(c, shutdownClose)
+		}
+	}
+}
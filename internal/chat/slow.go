@@ -0,0 +1,102 @@
+package chat
+
+import (
+	"sync/atomic"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Kinds of messages, the ones other than essential are dropped for clients that fall behind.
+const (
+	essential    = ""
+	kindTyping   = "typing"
+	kindPresence = "presence"
+	kindViewers  = "viewers"
+	kindCounts   = "counts"
+)
+
+// Counters of how the hub copes with slow clients. They are written by Run and the
+// goroutines queueing messages and read by Stats, so they are only accessed atomically.
+var hubStats struct {
+	connected       int64
+	slowClients     int64
+	disconnected    int64
+	droppedTyping   int64
+	droppedPresence int64
+	droppedViewers  int64
+	droppedCounts   int64
+}
+
+// queue adds a message to the send queue of a client without ever waiting for it. Once the
+// queue is nearly full the client is downgraded: everything but essential messages is dropped
+// until it catches up. A client whose queue is full for an essential message is disconnected:
+// its queue is closed, which closes the connection, and the hub removes it once its read pump
+// unregisters it. It can be called from any goroutine.
+func (c *Client) queue(message []byte, kind string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	slow := len(c.send)*100 >= cap(c.send)*config.SlowConsumerPercent
+	if slow && !c.slow {
+		atomic.AddInt64(&hubStats.slowClients, 1)
+	}
+	c.slow = slow
+
+	if slow && kind != essential {
+		switch kind {
+		case kindTyping:
+			atomic.AddInt64(&hubStats.droppedTyping, 1)
+		case kindPresence:
+			atomic.AddInt64(&hubStats.droppedPresence, 1)
+		case kindViewers:
+			atomic.AddInt64(&hubStats.droppedViewers, 1)
+		case kindCounts:
+			atomic.AddInt64(&hubStats.droppedCounts, 1)
+		}
+		return
+	}
+
+	select {
+	case c.send <- message:
+	default:
+		atomic.AddInt64(&hubStats.disconnected, 1)
+		c.closed = true
+		close(c.send)
+	}
+}
+
+// close closes the send queue of a client with the close frame the write pump sends before
+// closing the connection, unless it is already closed. It can be called from any goroutine.
+func (c *Client) close(closeMsg []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	c.closed = true
+	c.closeMsg = closeMsg
+	close(c.send)
+}
+
+// Stats returns the counters of the hub since the server started.
+func Stats() structure.HubStats {
+	return structure.HubStats{
+		Connected:         atomic.LoadInt64(&hubStats.connected),
+		Slow_clients:      atomic.LoadInt64(&hubStats.slowClients),
+		Disconnected_slow: atomic.LoadInt64(&hubStats.disconnected),
+		Dropped: map[string]int64{
+			kindTyping:   atomic.LoadInt64(&hubStats.droppedTyping),
+			kindPresence: atomic.LoadInt64(&hubStats.droppedPresence),
+			kindViewers:  atomic.LoadInt64(&hubStats.droppedViewers),
+			kindCounts:   atomic.LoadInt64(&hubStats.droppedCounts),
+		},
+		Compression: compressionReport(),
+	}
+}
@@ -0,0 +1,83 @@
+package chat
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"real-time-forum/internal/database"
+	"real-time-forum/internal/events"
+	"real-time-forum/internal/structure"
+	"real-time-forum/internal/wsclient"
+)
+
+// Has hundreds of clients connect, chat, view posts and disconnect at once, like the
+// hub-stress command. Run with -race to have unsynchronised access to the hub reported.
+func TestHubStress(t *testing.T) {
+	if testing.Short() {
+		t.Skip("stress test skipped in short mode")
+	}
+
+	const clients, rounds, posts = 300, 3, 5
+
+	db, err := database.Open(filepath.Join(t.TempDir(), "forum.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := make([]wsclient.Session, clients)
+	for i := range sessions {
+		name := "stress" + strconv.Itoa(i)
+		err := db.NewUser(context.Background(), structure.User{Username: name, Firstname: name, Surname: name, Email: name + "@example.com", Password: "-"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		u, err := db.FindUserByParam(context.Background(), "username", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sessions[i] = wsclient.Session{UserID: u.Id, Session: name}
+		err = db.NewSession(context.Background(), name, u.Id, "stress", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//Every dropped connection is logged, which is noise here
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	hub := NewHub(events.NewBus(), db)
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, w, r)
+	}))
+	defer server.Close()
+
+	report := wsclient.Stress(server.URL, sessions, rounds, posts)
+
+	if report.Errors > 0 {
+		t.Errorf("%d errors out of %d connections", report.Errors, report.Connections)
+	}
+	if report.Connections != clients*rounds {
+		t.Errorf("%d connections, expected %d", report.Connections, clients*rounds)
+	}
+	if report.Received == 0 {
+		t.Errorf("none of the %d messages sent were received", report.Sent)
+	}
+}
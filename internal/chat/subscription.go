@@ -0,0 +1,107 @@
+package chat
+
+import (
+	"encoding/json"
+
+	"real-time-forum/internal/structure"
+)
+
+// subscription moves a client to the post it is viewing, 0 unsubscribes it.
+// Hidden clients are left out of the viewer counts.
+type subscription struct {
+	client *Client
+	postID int
+	hidden bool
+}
+
+// postEvent is a message for every client viewing a post.
+type postEvent struct {
+	postID  int
+	message []byte
+}
+
+// Publish delivers a message to every client subscribed to the post.
+func (h *Hub) Publish(postID int, message []byte) {
+	h.postEvents <- postEvent{postID: postID, message: message}
+}
+
+// subscribe records the post a client is viewing. It must only be called from Run.
+func (h *Hub) subscribe(s subscription) {
+	c := s.client
+	h.unsubscribe(c)
+
+	if s.postID == 0 {
+		return
+	}
+
+	if h.posts[s.postID] == nil {
+		h.posts[s.postID] = make(map[*Client]bool)
+	}
+	h.posts[s.postID][c] = true
+	c.postID = s.postID
+	c.hideViewing = s.hidden
+	h.viewersChanged[s.postID] = true
+}
+
+// unsubscribe removes a client from the post it was viewing. It must only be called from Run.
+func (h *Hub) unsubscribe(c *Client) {
+	if c.postID == 0 {
+		return
+	}
+
+	delete(h.posts[c.postID], c)
+	if len(h.posts[c.postID]) == 0 {
+		delete(h.posts, c.postID)
+	}
+	h.viewersChanged[c.postID] = true
+	c.postID = 0
+}
+
+// publish sends a post event to its subscribers. It must only be called from Run.
+func (h *Hub) publish(e postEvent) {
+	for c := range h.posts[e.postID] {
+		//Skips clients whose connection has already been dropped
+		if !h.registered(c) {
+			h.unsubscribe(c)
+			continue
+		}
+
+		c.queue(e.message, essential)
+	}
+}
+
+// sendViewerCounts tells the viewers of every post whose subscriptions changed
+// since the last call how many other people are reading it. Users reading it in several
+// tabs count once. It must only be called from Run.
+func (h *Hub) sendViewerCounts() {
+	for postID := range h.viewersChanged {
+		delete(h.viewersChanged, postID)
+
+		visible := make(map[int]bool)
+		for c := range h.posts[postID] {
+			if !c.hideViewing {
+				visible[c.userID] = true
+			}
+		}
+
+		for c := range h.posts[postID] {
+			//Viewers never count themselves, whichever of their clients they are shown in
+			count := len(visible)
+			if visible[c.userID] {
+				count--
+			}
+
+			msg, err := json.Marshal(structure.ViewerCount{
+				Msg_type: "viewers",
+				Post_id:  postID,
+				Count:    count,
+			})
+			if err != nil || !h.registered(c) {
+				continue
+			}
+
+			//Counts are best effort, a slow client just misses this update
+			c.queue(msg, kindViewers)
+		}
+	}
+}
@@ -1 +1,140 @@
 package chat
+
+import (
+	"encoding/json"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// typingUpdate is a user starting or stopping to type to another.
+type typingUpdate struct {
+	senderID   int
+	receiverID int
+	isTyping   bool
+	allowed    bool // Whether both users allow typing indicators, only read when isTyping
+}
+
+// UpdateTypingStatus tells the receiver whether the sender is typing to them.
+func (h *Hub) UpdateTypingStatus(senderID, receiverID int, isTyping bool) {
+	t := typingUpdate{senderID: senderID, receiverID: receiverID, isTyping: isTyping}
+	if isTyping && receiverID != senderID {
+		t.allowed = h.agreed(senderID, receiverID).Typing
+	}
+	h.typing <- t
+}
+
+// typingState reads a typing indicator sent by a client, as "typing_start" or "typing_stop",
+// or as the older "typing" (or empty msg_type) with is_typing. It returns false for other messages.
+func typingState(msg structure.Message) (isTyping bool, ok bool) {
+	switch msg.Msg_type {
+	case "typing_start":
+		return true, true
+	case "typing_stop":
+		return false, true
+	case "", "typing":
+		return msg.IsTyping, true
+	}
+
+	return false, false
+}
+
+// typist is the user someone is typing to and when the indicator expires.
+type typist struct {
+	to    int
+	until time.Time
+}
+
+// sendTyping records a typing status change of a user and tells the receiver about it.
+// A user types to a single other user at a time, from whichever of their clients, starting
+// to type to another stops the previous indicator, and starting again only pushes the expiry
+// back. It must only be called from Run.
+func (h *Hub) sendTyping(t typingUpdate) {
+	if len(h.clients[t.senderID]) == 0 || t.receiverID == t.senderID {
+		return
+	}
+
+	current := h.typists[t.senderID]
+	if !t.isTyping {
+		if current.to == t.receiverID {
+			h.stopTyping(t.senderID)
+		}
+		return
+	}
+
+	if current.to != t.receiverID {
+		h.stopTyping(t.senderID)
+	}
+
+	// Only users who both allow typing indicators see them start
+	if !t.allowed {
+		return
+	}
+
+	until := clock.Now().Add(config.TypingTimeout * time.Second)
+	h.typists[t.senderID] = typist{to: t.receiverID, until: until}
+	h.notifyTyping(t.senderID, t.receiverID, until)
+}
+
+// stopTyping ends the typing indicator of a user, if they have one. It must only be called from Run.
+func (h *Hub) stopTyping(userID int) {
+	t, ok := h.typists[userID]
+	if !ok {
+		return
+	}
+
+	delete(h.typists, userID)
+	h.notifyTyping(userID, t.to, time.Time{})
+}
+
+// stopTypingBetween ends the typing indicators of the users of a conversation to each other,
+// once they no longer both allow them. It must only be called from Run.
+func (h *Hub) stopTypingBetween(users pair) {
+	for i, id := range users {
+		if t, ok := h.typists[id]; ok && t.to == users[1-i] {
+			h.stopTyping(id)
+		}
+	}
+}
+
+// expireTyping stops the typing indicators of the users that have not said they are still
+// typing before the timeout, so indicators never stay on when a stop is lost.
+// It must only be called from Run.
+func (h *Hub) expireTyping() {
+	now := clock.Now()
+	for id, t := range h.typists {
+		if !now.Before(t.until) {
+			h.stopTyping(id)
+		}
+	}
+}
+
+// notifyTyping sends every client of the receiver a "typing_start" with the time the indicator
+// expires, in unix milliseconds, or a "typing_stop" when until is zero. It must only be called from Run.
+func (h *Hub) notifyTyping(senderID, receiverID int, until time.Time) {
+	if len(h.clients[receiverID]) == 0 {
+		return
+	}
+
+	msg := structure.TypingStatus{
+		UserID:     senderID,
+		Msgtype:    "typing_stop",
+		ReceiverID: receiverID,
+		SenderID:   senderID,
+	}
+	if !until.IsZero() {
+		msg.IsTyping = true
+		msg.Msgtype = "typing_start"
+		msg.Expires = until.UnixMilli()
+	}
+	sendMsg, err := json.Marshal(msg) // Create a message
+	if err != nil {
+		panic(err)
+	}
+
+	for client := range h.clients[receiverID] {
+		client.queue(sendMsg, kindTyping)
+	}
+}
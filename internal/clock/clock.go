@@ -0,0 +1,156 @@
+// Package clock is where the server reads the current time from and gets its tickers.
+// Tests can swap in a fake clock and move it forward instead of sleeping.
+package clock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and schedules periodic work
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) *Ticker
+}
+
+// Ticker delivers ticks on C until it is stopped
+type Ticker struct {
+	C    <-chan time.Time
+	stop func()
+}
+
+// Stop turns off the ticker, no more ticks are sent after it returns
+func (t *Ticker) Stop() {
+	t.stop()
+}
+
+// Next waits for the next tick, returning false instead once the context is done, so
+// periodic work stops with whatever started it
+func (t *Ticker) Next(ctx context.Context) bool {
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+var (
+	mu      sync.RWMutex
+	current Clock = System{}
+)
+
+// Set replaces the clock used by the server, it should be called before the server starts
+func Set(c Clock) {
+	mu.Lock()
+	current = c
+	mu.Unlock()
+}
+
+// Now returns the current time of the clock
+func Now() time.Time {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return current.Now()
+}
+
+// NewTicker returns a ticker of the clock that ticks every d
+func NewTicker(d time.Duration) *Ticker {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return current.NewTicker(d)
+}
+
+// System is the real time of the machine
+type System struct{}
+
+func (System) Now() time.Time {
+	return time.Now()
+}
+
+func (System) NewTicker(d time.Duration) *Ticker {
+	t := time.NewTicker(d)
+	return &Ticker{C: t.C, stop: t.Stop}
+}
+
+// Fake is a clock that only moves when told to, firing the tickers it passes
+type Fake struct {
+	sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+type fakeTicker struct {
+	c       chan time.Time
+	next    time.Time
+	period  time.Duration
+	stopped bool
+}
+
+// NewFake returns a fake clock set to the time
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.now
+}
+
+func (f *Fake) NewTicker(d time.Duration) *Ticker {
+	f.Lock()
+	defer f.Unlock()
+
+	t := &fakeTicker{c: make(chan time.Time, 1), next: f.now.Add(d), period: d}
+	f.tickers = append(f.tickers, t)
+
+	return &Ticker{C: t.c, stop: func() {
+		f.Lock()
+		t.stopped = true
+		f.Unlock()
+	}}
+}
+
+// WaitForTickers waits until n tickers are running, so a test knows the periodic work it
+// started is waiting for ticks before moving the clock
+func (f *Fake) WaitForTickers(n int) {
+	for {
+		f.Lock()
+		running := 0
+		for _, t := range f.tickers {
+			if !t.stopped {
+				running++
+			}
+		}
+		f.Unlock()
+
+		if running >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Advance moves the clock forward. Tickers due in that time tick, dropping ticks
+// that are not received like real tickers do.
+func (f *Fake) Advance(d time.Duration) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.now = f.now.Add(d)
+
+	for _, t := range f.tickers {
+		for !t.stopped && !t.next.After(f.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Package completion decides which steps of completing their account users still have to take
+// before they can do something, like commenting or messaging people they never talked to.
+// The steps each action requires are set in config.Requirements.
+package completion
+
+import (
+	"context"
+	"strconv"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/database"
+)
+
+// Messages telling users what a missing step is about
+var descriptions = map[string]string{
+	config.StepVerifyEmail:     "Email not verified.",
+	config.StepCompleteProfile: "Profile not complete, add your date of birth and gender.",
+}
+
+// Missing returns the steps an action requires that the user hasn't completed, in the
+// order config.Requirements lists them
+func Missing(ctx context.Context, db *database.Store, uid int, action string) ([]string, error) {
+	missing := []string{}
+
+	for _, step := range config.Requirements[action] {
+		done, err := completed(ctx, db, uid, step)
+		if err != nil {
+			return nil, err
+		}
+
+		if !done {
+			missing = append(missing, step)
+		}
+	}
+
+	return missing, nil
+}
+
+// MissingToMessage returns the steps the sender has to complete before messaging the
+// receiver. Users who already exchanged messages can keep talking whatever the steps.
+func MissingToMessage(ctx context.Context, db *database.Store, sender, receiver int) ([]string, error) {
+	talked, err := db.HasConversation(ctx, sender, receiver)
+	if err != nil || talked {
+		return []string{}, err
+	}
+
+	return Missing(ctx, db, sender, config.ActionMessageStranger)
+}
+
+// Describe returns a message telling the user about the first missing step
+func Describe(missing []string) string {
+	if len(missing) == 0 {
+		return ""
+	}
+
+	return descriptions[missing[0]]
+}
+
+// Checks whether a user completed a step
+func completed(ctx context.Context, db *database.Store, uid int, step string) (bool, error) {
+	switch step {
+	case config.StepVerifyEmail:
+		unverified, err := db.IsUnverified(ctx, uid)
+		return !unverified, err
+	case config.StepCompleteProfile:
+		u, err := db.FindUserByParam(ctx, "id", strconv.Itoa(uid))
+		if err != nil {
+			return false, err
+		}
+
+		return u.DOB != "" && u.Gender != "", nil
+	default:
+		//Steps the forum doesn't know can never be completed, so they are not ignored silently
+		return false, nil
+	}
+}
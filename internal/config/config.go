@@ -1,6 +1,792 @@
 package config
 
 const (
-	Path = "forum.db"
+	Path      = "forum.db"
 	CookieAge = 60 * 60 * 24
-)
\ No newline at end of file
+)
+
+// Database connection pool. SQLite takes a single writer at a time, the others wait for
+// the lock up to the busy timeout in milliseconds before failing.
+const (
+	DBMaxOpenConns = 8
+	DBBusyTimeout  = 5000
+
+	// Prepared statements kept open, queries past it are run without being prepared
+	DBStatementCache = 256
+
+	// Seconds the queries of a single database call can take before they are cancelled
+	DBQueryTimeout = 10
+)
+
+// Sessions expire CookieAge seconds after they were last used. Their expiry is moved
+// forward at most this often, so not every request writes to the database.
+const SessionTouchInterval = 60
+
+// Timestamps are always assigned by the server, in its local time
+const (
+	TimeFormat = "01-02-2006 15:04:05"
+
+	// Dates without a time of day, such as dates of birth, are ISO 8601 dates
+	DateFormat = "2006-01-02"
+
+	// Seconds a typing indicator stays up without a new typing event
+	TypingTimeout = 5
+
+	// Seconds between broadcasts of the online users, besides the ones sent when they change
+	PresenceInterval = 30
+)
+
+// User directory settings
+const (
+	DirectoryPageSize = 20
+	// Posts per page of a category
+	PostPageSize = 20
+
+	// Requests a client can make to the directory per window of seconds
+	DirectoryRateLimit  = 30
+	DirectoryRateWindow = 60
+)
+
+// Public read-only mode. When it is on, visitors who aren't logged in can read the posts,
+// comments and categories and search them. Their requests are limited per ip address and
+// served from a short-lived cache, so scraping doesn't weigh on the database. Chat,
+// posting and profiles always need an account.
+const (
+	PublicReadOnly = false
+
+	// Requests a visitor can make per window of seconds
+	PublicRateLimit  = 60
+	PublicRateWindow = 60
+
+	// Seconds a response to visitors is cached for, by the server and by browsers
+	PublicCacheAge = 30
+	// Responses kept in the cache at most, and the largest one kept
+	PublicCacheEntries = 1000
+	PublicCacheMaxBody = 1 << 20
+)
+
+// Search settings
+const (
+	// Matches per page of search results
+	SearchPageSize = 20
+	// Longest search query accepted, in bytes
+	MaxSearchLength = 256
+)
+
+// Ids of each type that can be asked for at once from /batch
+const BatchMaxIds = 100
+
+// Changes to posts, messages and notifications read by clients catching up (/sync)
+const (
+	// Changes returned at once, clients ask again from the cursor while there are more
+	SyncMaxChanges = 500
+
+	// Seconds changes are kept for, clients with an older cursor have to load everything again
+	SyncRetention = 60 * 60 * 24 * 30
+)
+
+// Contact matching settings
+const (
+	// Email hashes a single request can look up
+	ContactMatchMaxHashes = 1000
+
+	// Requests a user can make to match contacts per window of seconds
+	ContactMatchRateLimit  = 10
+	ContactMatchRateWindow = 60 * 60
+)
+
+// Token bucket limiting how often a client can call an endpoint: Burst requests at once,
+// then one more every Interval seconds
+type RateLimit struct {
+	Burst    int
+	Interval float64
+}
+
+// Limits of the endpoints guessing or creating credentials, per ip address
+var (
+	RegisterRateLimit       = RateLimit{Burst: 5, Interval: 60}
+	LoginRateLimit          = RateLimit{Burst: 10, Interval: 6}
+	ForgotPasswordRateLimit = RateLimit{Burst: 3, Interval: 60 * 5}
+)
+
+// An experiment showing users one of its variants. Each user is assigned a variant from a
+// hash of their id, with a chance proportional to its weight, and keeps it for as long as
+// the variants stay the same. Inactive experiments assign no variants and take no events.
+type Experiment struct {
+	Name     string
+	Active   bool
+	Variants []Variant
+}
+
+type Variant struct {
+	Name   string
+	Weight int
+}
+
+// Experiments of the frontend, e.g.
+// {Name: "post_form", Active: true, Variants: []Variant{{"control", 50}, {"inline", 50}}}
+var Experiments = []Experiment{}
+
+// Bytes of json a form or api request can send, larger bodies are refused
+const MaxRequestBody = 1 << 20
+
+// Client preference limits
+const (
+	ClientPrefMaxKeys      = 50
+	ClientPrefMaxKeyLength = 64
+
+	// Bytes of json a single value can take
+	ClientPrefMaxSize = 1024
+
+	// Bytes of json a single request can send
+	ClientPrefMaxBody = 64 << 10
+)
+
+// Built-in roles, the admins holding every permission and the moderators those of ModeratorPermissions
+const (
+	RoleAdmin     = "admin"
+	RoleModerator = "moderator"
+)
+
+// Actions that can be granted to roles
+const (
+	PermPostDeleteAny    = "post.delete.any"
+	PermCommentDeleteAny = "comment.delete.any"
+	PermThreadLock       = "thread.lock"
+	PermUserBan          = "user.ban"
+	PermUserImpersonate  = "user.impersonate"
+	PermCategoryManage   = "category.manage"
+	PermRoleManage       = "role.manage"
+	PermMetricsView      = "metrics.view"
+	PermAlertManage      = "alert.manage"
+	PermLicenseManage    = "license.manage"
+	PermCategoryAnnounce = "category.announce"
+	PermBannerManage     = "banner.manage"
+)
+
+// Every permission, in the order they are listed to admins
+var Permissions = []string{
+	PermPostDeleteAny,
+	PermCommentDeleteAny,
+	PermThreadLock,
+	PermUserBan,
+	PermUserImpersonate,
+	PermCategoryManage,
+	PermRoleManage,
+	PermMetricsView,
+	PermAlertManage,
+	PermLicenseManage,
+	PermCategoryAnnounce,
+	PermBannerManage,
+}
+
+// Permissions of the built-in moderator role
+var ModeratorPermissions = []string{
+	PermPostDeleteAny,
+	PermCommentDeleteAny,
+	PermThreadLock,
+	PermUserBan,
+}
+
+// Seconds between reloads of the bans from the database, so access tokens of users banned
+// elsewhere stop working
+const BanSync = 60
+
+// Characters of the reason given for a ban, a removal or a locked thread
+const MaxModerationReason = 500
+
+// Service level alerts on the requests of each endpoint, for deployments without a
+// monitoring system. Admins define rules such as "p95 latency of /post over 300ms" or
+// "error rate of /login over 1%", which are checked against the requests of the last interval.
+const (
+	// Seconds between evaluations of the rules, each over the requests since the last one
+	AlertInterval = 60
+
+	// Latencies kept per endpoint and interval to compute percentiles, sampled once there are more
+	AlertMaxSamples = 1000
+
+	// Environment variable holding a url alerts are posted to as json, they are only
+	// sent as notifications to the users allowed to manage alerts when unset
+	AlertWebhookEnv = "FORUM_ALERT_WEBHOOK"
+
+	// Seconds allowed to post an alert to the webhook
+	AlertWebhookTimeout = 10
+)
+
+// Metrics an alert rule can watch, latencies in milliseconds and the error rate as the
+// percentage of responses with a 5xx status
+var AlertMetrics = []string{"p50", "p95", "p99", "error_rate"}
+
+// Seconds an admin can impersonate a user before having to start again
+const ImpersonationMaxAge = 60 * 15
+
+// Version of the settings export document
+const SettingsVersion = 1
+
+// Messages returned around a date when jumping through a conversation
+const MessageWindowSize = 50
+
+// Messages returned per page of the history of a conversation, unless the client asks for
+// fewer or more, up to MessageWindowSize
+const MessagePageSize = 10
+
+// Licenses posts can be published under, a post without one keeps the default copyright
+var PostLicenses = []string{
+	"CC-BY-4.0",
+	"CC-BY-SA-4.0",
+	"CC-BY-NC-4.0",
+	"CC0-1.0",
+	"All-Rights-Reserved",
+}
+
+// Forum settings changed by admins at runtime, by their key
+const (
+	// License given to new posts that don't choose one
+	SettingDefaultLicense = "default_license"
+
+	// Account the forum posts as, and when the last recap was posted along with the newest
+	// post and user it covered
+	SettingSystemUser = "system_user_id"
+	SettingRecapTime  = "recap_time"
+	SettingRecapPost  = "recap_post_id"
+	SettingRecapUser  = "recap_user_id"
+)
+
+// Levels of replies a comment thread can go down to, top level comments being the first
+const CommentMaxDepth = 6
+
+// Categories a post can be cross-posted to besides its own
+const MaxCrossposts = 3
+
+// Author analytics
+const (
+	// Days covered by the daily buckets, today included
+	AnalyticsDays = 30
+	// Seconds the analytics of an author are kept before being computed again
+	AnalyticsCacheAge = 5 * 60
+)
+
+// Share links of posts
+const (
+	// Random bytes in a share token, base64 encoded into 8 characters
+	ShareTokenBytes = 6
+	// Longest referrer host kept with the clicks of a share link
+	MaxReferrerLength = 255
+)
+
+// Series of posts
+const (
+	MaxSeriesTitleLength       = 128
+	MaxSeriesDescriptionLength = 2000
+)
+
+// Event posts
+const (
+	// Who can see the guest list of an event: everyone, only its guests, or only its authors
+	EventAttendeesPublic  = "public"
+	EventAttendeesGuests  = "attendees"
+	EventAttendeesPrivate = "private"
+
+	// Answers to an event, going and maybe count as guests
+	RsvpGoing = "going"
+	RsvpMaybe = "maybe"
+	RsvpNo    = "no"
+
+	MaxLocationLength = 255
+
+	// Seconds before an event starts that its guests are reminded of it
+	EventReminderLead = 60 * 60
+	// Seconds between checks for events to send reminders for
+	EventReminderInterval = 60
+)
+
+// Activity streaks
+const (
+	// Header clients send their IANA time zone in, so days follow the user's midnight
+	TimezoneHeader = "X-Timezone"
+	// Time zone of users whose client never sent one
+	DefaultTimezone = "UTC"
+)
+
+// Weekly community recap, posted by the system user
+const (
+	RecapEnabled  = true
+	RecapCategory = "Announcements"
+	// Seconds between two recaps
+	RecapInterval = 60 * 60 * 24 * 7
+
+	// Posts, new members and categories listed in a recap
+	RecapTopPosts   = 5
+	RecapNewMembers = 10
+	RecapCategories = 3
+
+	// Name of the account system posts are made by. It contains a space so no one can register it.
+	SystemUsername = "Forum Team"
+	SystemEmail    = "system@forum.invalid"
+)
+
+// Templates of the recap title and content, filled in with a structure.Recap. The content
+// is html, values from user posts are escaped.
+var (
+	RecapTitleTemplate = `Weekly recap: {{.Week}}`
+	RecapTemplate      = `<p>Here is what happened in the community this week.</p>
+{{if .Top_posts}}<h3>Top posts</h3>
+<ul>{{range .Top_posts}}<li>{{.Title}} by {{.Display_name}} ({{.Likes}} likes, {{.Comment_count}} comments)</li>{{end}}</ul>
+{{end}}{{if .Categories}}<h3>Most active categories</h3>
+<ul>{{range .Categories}}<li>{{.Name}}: {{.Posts}} new posts</li>{{end}}</ul>
+{{end}}{{if .New_members}}<h3>Welcome to our new members</h3>
+<p>{{range $i, $m := .New_members}}{{if $i}}, {{end}}{{$m}}{{end}}{{if .More_members}} and {{.More_members}} more{{end}}</p>
+{{end}}`
+)
+
+// Streak lengths in days that earn the user an achievement
+var StreakMilestones = []int{3, 7, 30, 100, 365}
+
+// Collaborative editing of posts
+const (
+	// Co-authors a post can have besides its author
+	MaxCoauthors = 10
+
+	// Seconds an edit lock on a post is held for unless it is renewed
+	PostLockTTL = 60 * 2
+)
+
+// Direct message draft settings
+const (
+	DraftMaxLength = 10000
+
+	// Seconds after which an untouched draft is removed
+	DraftMaxAge = 60 * 60 * 24 * 30
+)
+
+// Mention autocomplete settings
+const (
+	// Suggestions returned per request
+	MentionSuggestionLimit = 8
+
+	// Users matching the prefix that are ranked to pick the suggestions from
+	MentionCandidateLimit = 200
+)
+
+// Milliseconds between "currently viewing" count updates of a post
+const ViewerCountInterval = 2000
+
+// Milliseconds between batches of comment and reaction counters pushed to the feed
+const FeedCountsInterval = 3000
+
+// Post categories the forum starts with, as their slug, the name shown to users and the
+// permission needed to post in them, empty when anyone can. Admins manage the categories
+// from then on, see structure.Category.
+var Categories = [][3]string{
+	{"CyberSecurity", "CyberSecurity", ""},
+	{"Games", "Game nights", ""},
+	{"Freetime", "Freetime", ""},
+	{"Events", "Events", ""},
+	{"Random", "Random", ""},
+	{"Announcements", "Announcements", PermCategoryAnnounce},
+}
+
+// Registration requirements for optional profile fields
+const (
+	RequireDOB    = false
+	RequireGender = false
+)
+
+// Steps of completing an account: opening the link emailed at registration, and filling in
+// the date of birth and gender (PUT /me/profile)
+const (
+	StepVerifyEmail     = "verify_email"
+	StepCompleteProfile = "complete_profile"
+)
+
+// Actions that can require steps of completing an account first. Messaging a stranger is
+// starting a conversation with someone the user never exchanged a message with.
+const (
+	ActionLogin           = "login"
+	ActionPost            = "post"
+	ActionComment         = "comment"
+	ActionMessageStranger = "message_stranger"
+)
+
+// Steps users have to complete before each action. Removing the steps of logging in lets
+// new users in straight away and asks for the other steps only when they are needed.
+var Requirements = map[string][]string{
+	ActionLogin:           {StepVerifyEmail},
+	ActionPost:            {StepVerifyEmail},
+	ActionComment:         {StepVerifyEmail},
+	ActionMessageStranger: {StepVerifyEmail, StepCompleteProfile},
+}
+
+// Limits on the fields of a new account
+const (
+	MinPasswordLength = 6
+	MaxNameLength     = 64
+	MaxAge            = 150
+	// Youngest age in years users can register at
+	MinAge = 13
+)
+
+// Visibility levels for optional profile fields
+const (
+	VisibilityPublic   = "public"
+	VisibilityContacts = "contacts"
+	VisibilityPrivate  = "private"
+
+	DefaultVisibility = VisibilityPublic
+)
+
+// Display name preferences
+const (
+	DisplayUsername = "username"
+	DisplayFullName = "fullname"
+
+	DefaultDisplayName = DisplayUsername
+)
+
+// Upload settings
+const (
+	UploadDir     = "uploads"
+	MaxUploadSize = 10 << 20
+
+	// Total bytes of attachments each user can keep
+	StorageQuota = 100 << 20
+
+	// Environment variable selecting the storage backend, "local" (default) or "s3"
+	StorageEnv = "FORUM_STORAGE"
+
+	// Environment variables configuring the S3-compatible storage backend
+	S3EndpointEnv  = "FORUM_S3_ENDPOINT"
+	S3BucketEnv    = "FORUM_S3_BUCKET"
+	S3RegionEnv    = "FORUM_S3_REGION"
+	S3AccessKeyEnv = "FORUM_S3_ACCESS_KEY"
+	S3SecretKeyEnv = "FORUM_S3_SECRET_KEY"
+
+	// Interval between sweeps for stored files no longer referenced by an attachment
+	CleanupInterval = 60 * 60
+	// Age a stored file must reach before it can be removed as orphaned
+	CleanupGrace = 60 * 60
+
+	// Lifetime of a signed attachment url in seconds
+	SignedURLAge = 60 * 15
+
+	// Environment variable holding the key used to sign attachment urls
+	UploadSecretEnv = "FORUM_UPLOAD_SECRET"
+)
+
+// Content types attachments are stored with, as sniffed from their first bytes. Files of
+// any other type are kept as application/octet-stream. Only images are shown inline,
+// everything else is served as a download.
+var AttachmentTypes = []string{
+	"image/jpeg",
+	"image/png",
+	"image/gif",
+	"image/webp",
+	"application/pdf",
+	"application/zip",
+	"text/plain; charset=utf-8",
+}
+
+// Malware scanning settings
+const (
+	QuarantinePrefix = "quarantine/"
+
+	// Environment variable holding the host:port of a clamd server, scanning is skipped when unset
+	ClamAVAddrEnv = "FORUM_CLAMAV_ADDR"
+)
+
+// Image proxy settings
+const (
+	ImageProxyMaxSize   = 5 << 20
+	ImageProxyCacheSize = 64 << 20
+	// Seconds a proxied image is cached for
+	ImageProxyCacheAge = 60 * 60
+	// Seconds allowed to fetch an external image
+	ImageProxyTimeout = 10
+)
+
+// Widths of the resized copies generated for uploaded images
+var ImageVariantWidths = []int{320, 640, 1280}
+
+// Pixels an uploaded image can have to be resized, a small file can declare a huge image
+// that takes gigabytes of memory to decode. Larger images are kept without copies.
+const MaxImagePixels = 25 * 1000 * 1000
+
+// Login throttling settings
+const (
+	// Failed logins allowed before delays start, per identifier and ip address
+	LoginFreeAttempts = 3
+	// Failed logins allowed before delays start from a device the user logged in with before
+	KnownDeviceFreeAttempts = 10
+
+	// Seconds of the first delay, doubled with every further failure up to the maximum
+	LoginBaseDelay = 1
+	LoginMaxDelay  = 60 * 15
+
+	// Seconds without a failure after which the count starts over
+	LoginFailureReset = 60 * 60
+
+	// Seconds a device stays known after logging in
+	DeviceCookieAge = 60 * 60 * 24 * 365
+)
+
+// Seconds the "this wasn't me" link of a new login alert can be used
+const LoginAlertAge = 60 * 60 * 24 * 7
+
+// Forgotten password settings
+const (
+	// Seconds a reset token sent for a forgotten password can be used
+	PasswordResetAge = 60 * 60
+
+	// Reset emails sent per email address and per ip address within the window, in seconds
+	PasswordResetLimit  = 3
+	PasswordResetWindow = 60 * 60
+)
+
+// Seconds the link verifying the email of a new account can be used, a new one can be asked for afterwards
+const EmailVerificationAge = 60 * 60 * 24 * 2
+
+// Address of the forum as seen by users, for links in emails
+const SiteURL = "http://localhost:8000"
+
+// Email settings
+const (
+	// Environment variables configuring the SMTP server, emails are only logged when unset
+	SMTPAddrEnv     = "FORUM_SMTP_ADDR"
+	SMTPUserEnv     = "FORUM_SMTP_USER"
+	SMTPPasswordEnv = "FORUM_SMTP_PASSWORD"
+
+	// Environment variable holding the sender of emails
+	MailFromEnv = "FORUM_MAIL_FROM"
+	MailFrom    = "forum@localhost"
+)
+
+// Notifications returned when listing them
+const NotificationLimit = 50
+
+// Session hardening against stolen cookies. Sessions are bound to the browser that logged in,
+// and optionally to the network it logged in from.
+const (
+	SessionBindUserAgent = true
+	SessionPinIP         = false
+
+	// Leading bits of the ip address that have to match when pinning
+	SessionIPv4PrefixBits = 24
+	SessionIPv6PrefixBits = 64
+)
+
+// Access tokens for API clients
+const (
+	// Environment variable holding the signing keys as "kid:secret" pairs separated by commas.
+	// The first key signs new tokens, the others are only used to verify tokens they signed
+	// until those expire, which allows the keys to be rotated. Tokens are disabled when unset.
+	JWTKeysEnv = "FORUM_JWT_KEYS"
+
+	// Seconds an access token is valid
+	JWTAccessTokenAge = 60 * 15
+
+	// Seconds between reloads of the revoked tokens from the database
+	JWTRevocationSync = 60
+
+	// Credentials the API accepts, one of AuthCookie, AuthToken and AuthBoth
+	AuthMode = AuthBoth
+)
+
+// Credentials the API can accept: the session cookie browsers get when logging in, access
+// tokens sent in the Authorization header, or either. Logging in gives an access token
+// when only tokens are accepted, otherwise when the client asks for one.
+const (
+	AuthCookie = "cookie"
+	AuthToken  = "token"
+	AuthBoth   = "both"
+)
+
+// Secrets in the environment can be stored encrypted as "enc:..." values, made with
+// "go run . encrypt-secret". They are decrypted at startup with a key from one of these.
+const (
+	SecretKeyEnv     = "FORUM_SECRET_KEY"
+	SecretKeyFileEnv = "FORUM_SECRET_KEY_FILE"
+)
+
+// Key of the encrypted database, only used when built with -tags sqlcipher.
+// The key can also be an encrypted secret.
+const (
+	DBKeyEnv     = "FORUM_DB_KEY"
+	DBKeyFileEnv = "FORUM_DB_KEY_FILE"
+)
+
+// Environment variable holding the keys private message bodies are encrypted with, as
+// "kid:key" pairs separated by commas. The first key encrypts new messages, the others
+// decrypt older ones until "go run . encrypt-messages" has moved them to the first key.
+// Messages are stored in plaintext when unset.
+const MessageKeysEnv = "FORUM_MESSAGE_KEYS"
+
+// Outbox of side effects that have to happen after a write
+const (
+	// Milliseconds between checks for pending events
+	OutboxPollInterval = 1000
+	OutboxBatchSize    = 50
+
+	// Failed deliveries are retried with a doubling delay, starting at a second up to an hour
+	OutboxMaxAttempts = 15
+	OutboxMaxDelay    = 60 * 60
+
+	// Seconds delivered events are kept for
+	OutboxRetention = 60 * 60 * 24
+)
+
+// Seconds deleted posts, comments and messages can be restored for by their author before
+// they are removed for good
+const TrashRetention = 60 * 60 * 24 * 30
+
+// Outbox event topics
+const (
+	TopicPostCreated    = "post.created"
+	TopicCommentCreated = "comment.created"
+)
+
+// Websocket connection limits. Connections over a limit are closed with code 1013
+// (try again later) and a reason starting with "retry-after=<seconds>". Every tab and
+// device of a user has its own connection, the hub refuses those over the per user limit.
+const (
+	MaxConnections        = 1000
+	MaxConnectionsPerUser = 3
+
+	// Seconds clients are told to wait before connecting again
+	ConnectionRetryAfter = 30
+)
+
+// Seconds the server waits on shutdown for requests in progress to finish and websocket
+// clients to be told it is going away, code 1012 (service restart) with the reason
+// "server_shutdown", before exiting anyway
+const ShutdownTimeout = 10
+
+// Http server. The SPA sends many small requests in parallel, which HTTP/2 multiplexes on a
+// single connection, so it is served whenever TLS is set up by giving the certificate and
+// key files in the environment. Without them the server speaks plain HTTP/1.1. Websockets
+// always open their own HTTP/1.1 connection.
+const (
+	ServerAddr = ":8000"
+
+	TLSCertEnv = "FORUM_TLS_CERT"
+	TLSKeyEnv  = "FORUM_TLS_KEY"
+
+	// Whether HTTP/2 is offered to the clients over TLS
+	HTTP2 = true
+
+	// Seconds idle keep-alive connections are kept open between requests, long enough for
+	// the next page of the SPA to reuse them
+	ServerIdleTimeout = 120
+
+	// Seconds clients have to send the headers of a request
+	ServerReadHeaderTimeout = 10
+
+	MaxHeaderBytes = 1 << 16
+)
+
+// Websocket permessage-deflate compression, negotiated with the clients that offer it.
+// Messages under the threshold in bytes are sent as they are, they barely shrink. Every
+// compressing connection holds a deflate window in memory while it writes, so only so many
+// connections compress, the others get uncompressed messages.
+const (
+	WSCompression               = true
+	WSCompressionLevel          = 1 // 1 (fastest) to 9 (smallest)
+	WSCompressionThreshold      = 256
+	WSCompressionMaxConnections = 500
+)
+
+// Percentage of its send queue a websocket client has to fill up to be treated as slow.
+// Slow clients only get chat messages and post events until they catch up.
+const SlowConsumerPercent = 75
+
+// Messages queued for a websocket client, a client whose queue is full for a chat message
+// or post event is disconnected
+const ClientQueueSize = 256
+
+// Updates the hub can have waiting before the goroutines sending them wait for it
+const HubQueueSize = 1024
+
+// Milliseconds changes to the online users are gathered for before they are sent, so a
+// burst of connections sends a single update instead of one per connection
+const PresenceBatchInterval = 250
+
+// Seconds a websocket reconnect token stays valid
+const ReconnectTokenAge = 60 * 5
+
+// Environment variable choosing how logs are written, "text" (default) or "json"
+const LogFormatEnv = "FORUM_LOG_FORMAT"
+
+// Header carrying the id of a request, sent back with the response. Ids sent by clients
+// and proxies longer than the maximum are replaced.
+const (
+	RequestIDHeader    = "X-Request-ID"
+	RequestIDMaxLength = 64
+)
+
+// Errors reported by the frontend (/client-errors)
+const (
+	// Environment variable holding the share of reports kept, from 0 to 1 (default)
+	ClientErrorSampleRateEnv = "FORUM_CLIENT_ERROR_SAMPLE_RATE"
+
+	// Environment variable holding a url reports are also posted to as json
+	ClientErrorWebhookEnv = "FORUM_CLIENT_ERROR_WEBHOOK"
+
+	// Seconds allowed to post a report to the webhook
+	ClientErrorWebhookTimeout = 10
+
+	// Reports a client can send per window of seconds
+	ClientErrorRateLimit  = 20
+	ClientErrorRateWindow = 60 * 10
+
+	// Bytes kept of the stack trace and of the other fields of a report
+	ClientErrorMaxStack = 16 * 1024
+	ClientErrorMaxField = 1024
+
+	// Reports listed at once in /admin/client-errors
+	ClientErrorListLimit = 100
+
+	// Seconds reports are kept for
+	ClientErrorRetention = 60 * 60 * 24 * 14
+)
+
+// Site banners shown at the top of every page until their end or until the user dismisses them
+const (
+	BannerInfo     = "info"
+	BannerWarning  = "warning"
+	BannerCritical = "critical"
+
+	// Who sees a banner, everyone, only logged in users or only visitors
+	BannerAudienceAll      = "all"
+	BannerAudienceMembers  = "members"
+	BannerAudienceVisitors = "visitors"
+
+	// Characters a banner text can have
+	BannerMaxLength = 500
+)
+
+// Debug logging of request and response bodies
+const (
+	// Environment variable holding the share of requests logged, from 0 (default) to 1
+	LogSampleRateEnv = "FORUM_LOG_SAMPLE_RATE"
+
+	// Bytes of each body that are logged
+	LogBodyMaxSize = 4096
+)
+
+// Json keys and form fields whose values are never logged, matched anywhere in the name
+var LogRedactedFields = []string{"password", "token", "secret", "email", "session", "key", "dob"}
+
+// Fault injection for development, to exercise the retry and reconnect logic of clients.
+// The environment variable holds "fault=value" pairs separated by commas:
+// error and latency are the share of requests failed or delayed, delay is the maximum
+// delay in milliseconds and drop is the share of websocket frames dropped.
+// For example FORUM_CHAOS="error=0.05,latency=0.2,delay=1500,drop=0.01".
+const (
+	ChaosEnv = "FORUM_CHAOS"
+
+	// Milliseconds of the maximum delay when only the latency rate is set
+	ChaosDefaultDelay = 1000
+)
+
+// Origins other than the site itself whose pages may call the API with the credentials of
+// the user, e.g. "http://localhost:3000" for a frontend served by a development server
+var CORSOrigins = []string{}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Stores a new alert rule and returns it with its id and creation date
+func (s *Store) NewAlertRule(ctx context.Context, rule structure.AlertRule) (structure.AlertRule, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rule.Created = clock.Now().Format(config.TimeFormat)
+
+	res, err := s.exec(ctx, AddAlertRule, rule.Endpoint, rule.Metric, rule.Threshold, rule.Created)
+	if err != nil {
+		return rule, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return rule, err
+	}
+	rule.Id = int(id)
+
+	return rule, nil
+}
+
+// Finds every alert rule, oldest first
+func (s *Store) FindAlertRules(ctx context.Context) ([]structure.AlertRule, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rules := []structure.AlertRule{}
+
+	rows, err := s.query(ctx, GetAlertRules)
+	if err != nil {
+		return rules, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var rule structure.AlertRule
+		err = rows.Scan(&rule.Id, &rule.Endpoint, &rule.Metric, &rule.Threshold, &rule.Created)
+		if err != nil {
+			return rules, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// Removes an alert rule, returning whether it existed
+func (s *Store) DeleteAlertRule(ctx context.Context, id int) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, RemoveAlertRule, id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Finds the users whose role has a permission
+func (s *Store) FindPermissionUsers(ctx context.Context, permission string) ([]int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var uids []int
+
+	rows, err := s.query(ctx, GetPermissionUsers, permission)
+	if err != nil {
+		return uids, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var uid int
+		err = rows.Scan(&uid)
+		if err != nil {
+			return uids, err
+		}
+
+		uids = append(uids, uid)
+	}
+
+	return uids, rows.Err()
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strconv"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Works out the analytics of an author from the posts they have, the daily view counts,
+// the dates of the reactions and comments on their posts and of the follows of their series.
+// The daily buckets start with the since day.
+func (s *Store) FindAuthorAnalytics(ctx context.Context, uid int, since time.Time) (structure.AuthorAnalytics, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	first := since.Format(config.DateFormat)
+	stats := structure.AuthorAnalytics{
+		Since:         first,
+		Posts:         []structure.PostAnalytics{},
+		Follower_days: []structure.FollowerDay{},
+		Generated_at:  clock.Now().Format(config.TimeFormat),
+	}
+
+	posts, err := s.FindPostByParam(ctx, "user_id", strconv.Itoa(uid))
+	if err != nil {
+		return stats, err
+	}
+
+	//Activity of each post per day, filled in from the queries below
+	days := map[int]map[string]*structure.AnalyticsDay{}
+	totals := map[int]*structure.PostAnalytics{}
+	for _, p := range posts {
+		days[p.Id] = map[string]*structure.AnalyticsDay{}
+		totals[p.Id] = &structure.PostAnalytics{Post_id: p.Id, Title: p.Title, Date: p.Date, Views: p.View_count}
+	}
+
+	bucket := func(pid int, day string) *structure.AnalyticsDay {
+		if days[pid][day] == nil {
+			days[pid][day] = &structure.AnalyticsDay{Day: day}
+		}
+		return days[pid][day]
+	}
+
+	rows, err := s.query(ctx, GetAuthorViews, uid, first)
+	if err != nil {
+		return stats, err
+	}
+
+	for rows.Next() {
+		var pid, views int
+		var day string
+		err = rows.Scan(&pid, &day, &views)
+		if err != nil {
+			rows.Close()
+			return stats, err
+		}
+
+		bucket(pid, day).Views += views
+	}
+	rows.Close()
+
+	//Reactions and comments count towards the totals whenever they were made
+	err = eachDated(ctx, s.db, GetAuthorReactions, uid, func(pid int, day string) {
+		totals[pid].Reactions++
+		if day >= first {
+			bucket(pid, day).Reactions++
+		}
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	err = eachDated(ctx, s.db, GetAuthorComments, uid, func(pid int, day string) {
+		totals[pid].Comments++
+		if day >= first {
+			bucket(pid, day).Comments++
+		}
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	for _, p := range posts {
+		t := totals[p.Id]
+		t.Days = []structure.AnalyticsDay{}
+		for _, d := range days[p.Id] {
+			t.Days = append(t.Days, *d)
+		}
+		sort.Slice(t.Days, func(i, j int) bool { return t.Days[i].Day < t.Days[j].Day })
+
+		stats.Posts = append(stats.Posts, *t)
+	}
+
+	//A user following several series of the author counts once, from their first follow
+	followed := map[int]string{}
+	err = eachDated(ctx, s.db, GetAuthorFollowers, uid, func(follower int, day string) {
+		if prev, ok := followed[follower]; !ok || day < prev {
+			followed[follower] = day
+		}
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	gained := map[string]int{}
+	total := 0
+	for _, day := range followed {
+		if day >= first {
+			gained[day]++
+		} else {
+			total++
+		}
+	}
+
+	today := clock.Now().Format(config.DateFormat)
+	for d := since; d.Format(config.DateFormat) <= today; d = d.AddDate(0, 0, 1) {
+		day := d.Format(config.DateFormat)
+		total += gained[day]
+		stats.Follower_days = append(stats.Follower_days, structure.FollowerDay{Day: day, New: gained[day], Total: total})
+	}
+	stats.Followers = len(followed)
+
+	return stats, nil
+}
+
+// Runs a query listing an id with a date for the user, handing each row to fn with the
+// date turned into its day. Dates that can't be read, like those of reactions made before
+// they were dated, come with an empty day.
+func eachDated(ctx context.Context, db *sql.DB, query string, uid int, fn func(id int, day string)) error {
+	rows, err := db.QueryContext(ctx, query, uid)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var date string
+		err = rows.Scan(&id, &date)
+		if err != nil {
+			return err
+		}
+
+		day := ""
+		if t, err := time.Parse(config.TimeFormat, date); err == nil {
+			day = t.Format(config.DateFormat)
+		}
+
+		fn(id, day)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,260 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Inserts a new attachment into the database and returns its id, which is 0 when the
+// attachment would take its uploader over the quota
+func (s *Store) NewAttachment(ctx context.Context, a structure.Attachment, quota int64) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	dt := clock.Now().Format(config.TimeFormat)
+
+	//Checks the quota in the insert itself, so parallel uploads can't all fit in the same space
+	res, err := s.exec(ctx, AddAttachment, a.User_id, a.Receiver_id, a.Name, a.Path, a.Mime, a.Size, dt, a.Scan_status, a.User_id, a.Size, quota)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// Finds an attachment by its id
+func (s *Store) FindAttachment(ctx context.Context, id int) (structure.Attachment, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var a structure.Attachment
+
+	err := s.queryRow(ctx, GetAttachmentById, id).Scan(&a.Id, &a.User_id, &a.Receiver_id, &a.Name, &a.Path, &a.Mime, &a.Size, &a.Date, &a.Scan_status, &a.Post_id, &a.Width, &a.Height, &a.Placeholder)
+	if err != nil {
+		return structure.Attachment{}, errors.New("could not find attachment")
+	}
+
+	return a, nil
+}
+
+// Stores the result of scanning an attachment and where the file now lives
+func (s *Store) UpdateAttachmentScan(ctx context.Context, id int, status, filePath string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, UpdateScan, status, filePath, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Finds the storage keys of every attachment
+func (s *Store) FindAttachmentPaths(ctx context.Context) (map[string]bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	keys := make(map[string]bool)
+
+	rows, err := s.query(ctx, GetAllAttachmentPaths)
+	if err != nil {
+		return keys, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+
+		err := rows.Scan(&key)
+		if err != nil {
+			return keys, err
+		}
+
+		keys[key] = true
+	}
+
+	return keys, rows.Err()
+}
+
+// Converts attachment table query results into an array of attachment structs
+func ConvertRowToAttachment(rows *sql.Rows) ([]structure.Attachment, error) {
+	var attachments []structure.Attachment
+
+	defer rows.Close()
+	for rows.Next() {
+		var a structure.Attachment
+
+		err := rows.Scan(&a.Id, &a.User_id, &a.Receiver_id, &a.Name, &a.Path, &a.Mime, &a.Size, &a.Date, &a.Scan_status, &a.Post_id, &a.Width, &a.Height, &a.Placeholder)
+		if err != nil {
+			break
+		}
+
+		attachments = append(attachments, a)
+	}
+
+	return attachments, nil
+}
+
+// Finds the images of a post along with their resized variants
+func (s *Store) FindPostImages(ctx context.Context, pid int) ([]structure.Attachment, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	q, err := s.query(ctx, GetPostAttachments, pid)
+	if err != nil {
+		return []structure.Attachment{}, err
+	}
+
+	images, err := ConvertRowToAttachment(q)
+	if err != nil {
+		return []structure.Attachment{}, err
+	}
+
+	for i := range images {
+		images[i].Variants, err = findVariants(ctx, s.db, images[i].Id)
+		if err != nil {
+			return []structure.Attachment{}, err
+		}
+	}
+
+	return images, nil
+}
+
+// Finds the resized variants of an attachment, smallest first
+func (s *Store) FindVariants(ctx context.Context, id int) ([]structure.ImageVariant, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	return findVariants(ctx, s.db, id)
+}
+
+func findVariants(ctx context.Context, db *sql.DB, id int) ([]structure.ImageVariant, error) {
+	var variants []structure.ImageVariant
+
+	rows, err := db.QueryContext(ctx, GetAttachmentVariants, id)
+	if err != nil {
+		return variants, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var v structure.ImageVariant
+		var aid int
+
+		err := rows.Scan(&aid, &v.Width, &v.Height, &v.Path)
+		if err != nil {
+			return variants, err
+		}
+
+		variants = append(variants, v)
+	}
+
+	return variants, rows.Err()
+}
+
+// Stores the dimensions, placeholder colour and resized variants of an image
+func (s *Store) UpdateAttachmentImage(ctx context.Context, a structure.Attachment) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, UpdateImage, a.Width, a.Height, a.Placeholder, a.Id)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range a.Variants {
+		_, err = s.exec(ctx, AddVariant, a.Id, v.Width, v.Height, v.Path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Links public attachments uploaded by the user to their post
+func (s *Store) LinkPostAttachments(ctx context.Context, pid, uid int, ids []int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	for _, id := range ids {
+		_, err := s.exec(ctx, LinkPostImage, pid, id, uid)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Finds how many attachments a user has and their total size in bytes
+func (s *Store) FindUserStorage(ctx context.Context, uid int) (int, int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	var used int64
+
+	err := s.queryRow(ctx, GetUserStorage, uid).Scan(&count, &used)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return count, used, nil
+}
+
+// Removes an attachment and its variants, returning the storage keys that were freed
+func (s *Store) DeleteAttachment(ctx context.Context, id int) ([]string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	a, err := s.FindAttachment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	variants, err := s.FindVariants(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.exec(ctx, RemoveVariants, id)
+	if err != nil {
+		return nil, err
+	}
+
+	//An avatar whose file is gone is removed with it
+	_, err = s.exec(ctx, RemoveAvatarFile, id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.exec(ctx, RemoveAttachment, id)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{a.Path}
+	for _, v := range variants {
+		keys = append(keys, v.Path)
+	}
+
+	return keys, nil
+}
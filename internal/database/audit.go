@@ -0,0 +1,19 @@
+package database
+
+import (
+	"context"
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Records an action in the audit log
+func (s *Store) NewAuditEntry(ctx context.Context, a structure.AuditEntry) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	dt := clock.Now().Format(config.TimeFormat)
+
+	_, err := s.exec(ctx, AddAudit, a.Actor_id, a.Subject_id, a.Action, a.Detail, dt)
+	return err
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"real-time-forum/internal/structure"
+)
+
+// Sets the image shown as a user's avatar, replacing the one they had
+func (s *Store) SetAvatar(ctx context.Context, uid, aid int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddAvatar, uid, aid)
+	return err
+}
+
+// Removes a user's avatar, returning whether they had one
+func (s *Store) RemoveUserAvatar(ctx context.Context, uid int) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, RemoveAvatar, uid)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Finds the avatar image of a user along with its resized variants, the id is 0 when
+// they have none
+func (s *Store) FindAvatar(ctx context.Context, uid int) (structure.Attachment, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	q, err := s.query(ctx, GetAvatar, uid)
+	if err != nil {
+		return structure.Attachment{}, err
+	}
+
+	avatars, err := ConvertRowToAttachment(q)
+	if err != nil || len(avatars) == 0 {
+		return structure.Attachment{}, err
+	}
+
+	avatar := avatars[0]
+	avatar.Variants, err = findVariants(ctx, s.db, avatar.Id)
+	if err != nil {
+		return structure.Attachment{}, err
+	}
+
+	return avatar, nil
+}
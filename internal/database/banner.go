@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Adds a banner, returning it with its id
+func (s *Store) NewBanner(ctx context.Context, b structure.Banner) (structure.Banner, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, AddBanner, b.Text, b.Severity, b.Audience, b.Starts, b.Ends, b.User_id, b.Created)
+	if err != nil {
+		return b, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return b, err
+	}
+	b.Id = int(id)
+
+	return b, nil
+}
+
+// Finds a banner by its id, returning false when there is none
+func (s *Store) FindBanner(ctx context.Context, id int) (structure.Banner, bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var b structure.Banner
+
+	err := s.queryRow(ctx, GetBanner, id).Scan(&b.Id, &b.Text, &b.Severity, &b.Audience, &b.Starts, &b.Ends, &b.User_id, &b.Created)
+	if err == sql.ErrNoRows {
+		return b, false, nil
+	}
+
+	return b, err == nil, err
+}
+
+// Finds every banner, newest first, including the ones that ended or haven't started
+func (s *Store) FindBanners(ctx context.Context) ([]structure.Banner, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	return s.findBanners(ctx, GetBanners)
+}
+
+// Finds the banners showing now to an audience that the user hasn't dismissed, oldest
+// first. Visitors have the user id 0 and no dismissals.
+func (s *Store) FindActiveBanners(ctx context.Context, audience string, uid int) ([]structure.Banner, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	now := clock.Now().Unix()
+	return s.findBanners(ctx, GetActiveBanners, now, now, config.BannerAudienceAll, audience, uid)
+}
+
+func (s *Store) findBanners(ctx context.Context, query string, args ...interface{}) ([]structure.Banner, error) {
+	banners := []structure.Banner{}
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return banners, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var b structure.Banner
+		err = rows.Scan(&b.Id, &b.Text, &b.Severity, &b.Audience, &b.Starts, &b.Ends, &b.User_id, &b.Created)
+		if err != nil {
+			return banners, err
+		}
+
+		banners = append(banners, b)
+	}
+
+	return banners, rows.Err()
+}
+
+// Changes the text, severity, audience and times of a banner, returning false when there is none
+func (s *Store) EditBanner(ctx context.Context, b structure.Banner) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, UpdateBanner, b.Text, b.Severity, b.Audience, b.Starts, b.Ends, b.Id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Removes a banner and who dismissed it, returning false when there is none
+func (s *Store) DeleteBanner(ctx context.Context, id int) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := tx.ExecContext(ctx, RemoveBanner, id)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		tx.Rollback()
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, RemoveDismissals, id)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// Records that a user dismissed a banner, dismissing it again changes nothing
+func (s *Store) DismissBanner(ctx context.Context, id, uid int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddDismissal, id, uid, clock.Now().Unix())
+	return err
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+
+	"real-time-forum/internal/structure"
+)
+
+// Ids are given to the queries as a json array, so a single prepared statement finds any
+// number of them
+func idList(ids []int) string {
+	list, _ := json.Marshal(ids)
+	return string(list)
+}
+
+// Finds the posts with the given ids in a single query. Ids without a post are left out.
+func (s *Store) FindPostsByIds(ctx context.Context, ids []int) ([]structure.Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, GetPostsByIds, idList(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	return ConvertRowToPost(rows)
+}
+
+// Finds the users with the given ids in a single query, like FindPostsByIds
+func (s *Store) FindUsersByIds(ctx context.Context, ids []int) ([]structure.User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, GetUsersByIds, idList(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	return ConvertRowToUser(rows)
+}
+
+// Finds the comments with the given ids in a single query, like FindPostsByIds
+func (s *Store) FindCommentsByIds(ctx context.Context, ids []int) ([]structure.Comment, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, GetCommentsByIds, idList(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	return ConvertRowToComment(rows)
+}
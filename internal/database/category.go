@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Adds the categories the forum starts with when there are none yet. Categories removed by
+// the admins later on don't come back.
+func seedCategories(db *sql.DB) error {
+	var count int
+	err := db.QueryRow(GetCategoryCount).Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	for i, c := range config.Categories {
+		_, err = db.Exec(AddCategory, c[0], c[1], i+1, c[2], "")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Finds every category in the order they are shown
+func (s *Store) FindCategories(ctx context.Context) ([]structure.Category, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	categories := []structure.Category{}
+
+	rows, err := s.query(ctx, GetCategories)
+	if err != nil {
+		return categories, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var c structure.Category
+		err = rows.Scan(&c.Name, &c.Label, &c.Position, &c.Post_permission, &c.Comment_permission)
+		if err != nil {
+			return categories, err
+		}
+
+		categories = append(categories, c)
+	}
+
+	return categories, rows.Err()
+}
+
+// Finds a category by its slug, returning false when there is none
+func (s *Store) FindCategory(ctx context.Context, slug string) (structure.Category, bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var c structure.Category
+
+	err := s.queryRow(ctx, GetCategory, slug).Scan(&c.Name, &c.Label, &c.Position, &c.Post_permission, &c.Comment_permission)
+	if err == sql.ErrNoRows {
+		return c, false, nil
+	}
+
+	return c, err == nil, err
+}
+
+// Adds a category
+func (s *Store) NewCategory(ctx context.Context, c structure.Category) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddCategory, c.Name, c.Label, c.Position, c.Post_permission, c.Comment_permission)
+	return err
+}
+
+// Changes the name a category is shown with, its position and who can post and comment in
+// it, returning false when there is no such category
+func (s *Store) EditCategory(ctx context.Context, c structure.Category) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, UpdateCategory, c.Label, c.Position, c.Post_permission, c.Comment_permission, c.Name)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Checks whether any post is in a category, including the posts in the trash and the ones
+// cross-posted to it
+func (s *Store) CategoryInUse(ctx context.Context, slug string) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := s.queryRow(ctx, GetCategoryUse, slug, slug, slug).Scan(&count)
+	return count > 0, err
+}
+
+// Removes a category, returning false when there is no such category
+func (s *Store) DeleteCategory(ctx context.Context, slug string) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, RemoveCategory, slug)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Finds a page of the posts of a category, including the ones cross-posted to it, newest first
+func (s *Store) FindCategoryPosts(ctx context.Context, slug string, limit, offset int) ([]structure.Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, GetCategoryPage, slug, slug, limit, offset)
+	if err != nil {
+		return []structure.Post{}, err
+	}
+
+	return ConvertRowToPost(rows)
+}
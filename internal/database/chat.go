@@ -1,17 +1,18 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"time"
 
+	"real-time-forum/internal/clock"
 	"real-time-forum/internal/structure"
 )
 
-func UpdateChatTime(u1, u2 int, db *sql.DB) error {
-	now := time.Now()
+func UpdateChatTime(ctx context.Context, u1, u2 int, db *sql.DB) error {
+	now := clock.Now()
 
-	chats, err := FindChatsBetween(u1, u2, db)
+	chats, err := FindChatsBetween(ctx, u1, u2, db)
 	if err != nil {
 		return err
 	}
@@ -19,12 +20,12 @@ func UpdateChatTime(u1, u2 int, db *sql.DB) error {
 	fmt.Println(chats)
 
 	if len(chats) == 0 {
-		_, err = db.Exec(AddChat, u1, u2, now.UnixMilli())
+		_, err = db.ExecContext(ctx, AddChat, u1, u2, now.UnixMilli())
 		if err != nil {
 			return err
 		}
 	} else {
-		_, err = db.Exec(UpdateChat, now.UnixMilli(), chats[0].User_one, chats[0].User_two)
+		_, err = db.ExecContext(ctx, UpdateChat, now.UnixMilli(), chats[0].User_one, chats[0].User_two)
 		if err != nil {
 			return err
 
@@ -52,17 +53,13 @@ func ConvertRowToChat(rows *sql.Rows) ([]structure.Chat, error) {
 	return chats, nil
 }
 
-func FindUserChats(path string, uid int) ([]structure.Chat, error) {
-	var q *sql.Rows
-
-	db, err := OpenDB(path)
-	if err != nil {
-		return []structure.Chat{}, err
-	}
+func (s *Store) FindUserChats(ctx context.Context, uid int) ([]structure.Chat, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	defer db.Close()
+	var q *sql.Rows
 
-	q, err = db.Query(GetUserChats, uid, uid)
+	q, err := s.query(ctx, GetUserChats, uid, uid)
 	if err != nil {
 		return []structure.Chat{}, err
 	}
@@ -75,10 +72,10 @@ func FindUserChats(path string, uid int) ([]structure.Chat, error) {
 	return users, nil
 }
 
-func FindChatsBetween(u1, u2 int, db *sql.DB) ([]structure.Chat, error) {
+func FindChatsBetween(ctx context.Context, u1, u2 int, db *sql.DB) ([]structure.Chat, error) {
 	var q *sql.Rows
 
-	q, err := db.Query(GetChatBetween, u1, u2, u2, u1)
+	q, err := db.QueryContext(ctx, GetChatBetween, u1, u2, u2, u1)
 	fmt.Print(q)
 	if err != nil {
 		return []structure.Chat{}, err
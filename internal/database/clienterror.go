@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+
+	"real-time-forum/internal/structure"
+)
+
+// Stores an error reported by the frontend
+func (s *Store) NewClientError(ctx context.Context, e structure.ClientError) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddClientError, e.User_id, e.Message, e.Stack, e.Route, e.App_version, e.Request_id, e.Status, e.User_agent, e.Created)
+	return err
+}
+
+// Finds the latest errors reported by the frontend
+func (s *Store) FindClientErrors(ctx context.Context, limit int) ([]structure.ClientError, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, GetClientErrors, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	errors := []structure.ClientError{}
+	for rows.Next() {
+		var e structure.ClientError
+
+		err := rows.Scan(&e.Id, &e.User_id, &e.Message, &e.Stack, &e.Route, &e.App_version, &e.Request_id, &e.Status, &e.User_agent, &e.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		errors = append(errors, e)
+	}
+
+	return errors, rows.Err()
+}
+
+// Removes the errors reported before the given unix time
+func (s *Store) DeleteOldClientErrors(ctx context.Context, before int64) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, RemoveOldErrors, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
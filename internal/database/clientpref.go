@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Finds every client preference of a user, mapped by key
+func (s *Store) FindClientPrefs(ctx context.Context, uid int) (map[string]json.RawMessage, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	prefs := make(map[string]json.RawMessage)
+
+	rows, err := s.query(ctx, GetClientPrefs, uid)
+	if err != nil {
+		return prefs, err
+	}
+
+	defer rows.Close()
+
+	//Loops through the rows provided
+	for rows.Next() {
+		var key, value string
+
+		err := rows.Scan(&key, &value)
+		if err != nil {
+			break
+		}
+
+		prefs[key] = json.RawMessage(value)
+	}
+
+	return prefs, nil
+}
+
+// Sets or removes client preferences of a user in one transaction, a null value removes the key
+func (s *Store) UpdateClientPrefs(ctx context.Context, uid int, prefs map[string]json.RawMessage) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range prefs {
+		if value == nil || string(value) == "null" {
+			_, err = tx.ExecContext(ctx, RemoveClientPref, uid, key)
+		} else {
+			_, err = tx.ExecContext(ctx, AddClientPref, uid, key, string(value))
+		}
+
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Adds a co-author to a post, returning false when they already were one
+func (s *Store) AddPostCoauthor(ctx context.Context, pid, uid int) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, AddCoauthor, pid, uid, clock.Now().Format(config.TimeFormat))
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Removes a co-author from a post, returning whether they were one
+func (s *Store) RemovePostCoauthor(ctx context.Context, pid, uid int) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, RemoveCoauthor, pid, uid)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	//A lock held by the removed co-author no longer lets them edit
+	_, err = s.exec(ctx, RemovePostLock, pid, uid)
+	return true, err
+}
+
+// Finds the co-authors of a post in the order they were added
+func (s *Store) FindPostCoauthors(ctx context.Context, pid int) ([]int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	uids := []int{}
+
+	rows, err := s.query(ctx, GetCoauthors, pid)
+	if err != nil {
+		return uids, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var uid int
+		err = rows.Scan(&uid)
+		if err != nil {
+			return uids, err
+		}
+
+		uids = append(uids, uid)
+	}
+
+	return uids, rows.Err()
+}
+
+// Checks whether a user is a co-author of a post
+func (s *Store) IsPostCoauthor(ctx context.Context, pid, uid int) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var n int
+	err := s.queryRow(ctx, GetCoauthor, pid, uid).Scan(&n)
+	return n > 0, err
+}
+
+// Takes the edit lock of a post for a user until the given unix time, or extends it when
+// they already hold it. Returns the lock as it stands, which belongs to someone else when
+// they hold it and it has not expired.
+func (s *Store) AcquirePostLock(ctx context.Context, pid, uid int, now, expires int64) (structure.PostLock, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	lock := structure.PostLock{Post_id: pid}
+
+	_, err := s.exec(ctx, AddPostLock, pid, uid, expires, now)
+	if err != nil {
+		return lock, err
+	}
+
+	err = s.queryRow(ctx, GetPostLock, pid, now).Scan(&lock.User_id, &lock.Expires)
+	return lock, err
+}
+
+// Extends the edit lock a user holds on a post, returning false when they no longer hold it
+func (s *Store) RenewPostLock(ctx context.Context, pid, uid int, now, expires int64) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, UpdatePostLock, expires, pid, uid, now)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Gives up the edit lock a user holds on a post
+func (s *Store) ReleasePostLock(ctx context.Context, pid, uid int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, RemovePostLock, pid, uid)
+	return err
+}
+
+// Finds the unexpired edit lock of a post, the user id is 0 when nobody holds it
+func (s *Store) FindPostLock(ctx context.Context, pid int, now int64) (structure.PostLock, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	lock := structure.PostLock{Post_id: pid}
+
+	err := s.queryRow(ctx, GetPostLock, pid, now).Scan(&lock.User_id, &lock.Expires)
+	if err == sql.ErrNoRows {
+		return lock, nil
+	}
+
+	return lock, err
+}
+
+// Changes the title and content of a post, recording the new version as a revision by the
+// editor. The post as it was created is recorded as the first revision on its first edit.
+func (s *Store) EditPost(ctx context.Context, p structure.Post, editor int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var revisions int
+	err = tx.QueryRowContext(ctx, GetRevisionCount, p.Id).Scan(&revisions)
+	if err == nil && revisions == 0 {
+		var orig structure.Post
+		err = tx.QueryRowContext(ctx, GetPostById, p.Id).Scan(&orig.Id, &orig.User_id, &orig.Category, &orig.Title, &orig.Content, &orig.Date, &orig.Likes, &orig.Dislikes, &orig.Comment_count, &orig.View_count, &orig.License, &orig.Edited)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, AddRevision, orig.Id, orig.User_id, orig.Title, orig.Content, orig.Date)
+		}
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx, UpdatePost, p.Title, p.Content, p.License, p.Id)
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx, AddRevision, p.Id, editor, p.Title, p.Content, clock.Now().Format(config.TimeFormat))
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Finds the revisions of a post, oldest first
+func (s *Store) FindPostRevisions(ctx context.Context, pid int) ([]structure.PostRevision, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	revisions := []structure.PostRevision{}
+
+	rows, err := s.query(ctx, GetRevisions, pid)
+	if err != nil {
+		return revisions, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var rev structure.PostRevision
+		err = rows.Scan(&rev.Id, &rev.Post_id, &rev.Editor_id, &rev.Title, &rev.Content, &rev.Date)
+		if err != nil {
+			return revisions, err
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, rows.Err()
+}
@@ -1,33 +1,56 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"strconv"
-	"time"
 
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
 	"real-time-forum/internal/structure"
 )
 
-// Attempts to insert a new comment to the database
-func NewComment(path string, c structure.Comment) error {
-	//Opens the database
-	db, err := OpenDB(path)
+// Attempts to insert a new comment to the database and returns its id
+func (s *Store) NewComment(ctx context.Context, c structure.Comment) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	dt := clock.Now().Format(config.TimeFormat)
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	defer db.Close()
+	//Executes the insert statement
+	res, err := tx.ExecContext(ctx, AddComment, c.Post_id, c.User_id, c.Content, dt, c.Parent_comment_id)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
 
-	dt := time.Now().Format("01-02-2006 15:04:05")
+	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
 
-	//Executes the insert statement
-	_, err = db.Exec(AddComment, c.Post_id, c.User_id, c.Content, dt)
+	//The counter is kept in step with the comments
+	_, err = tx.ExecContext(ctx, UpdateCommentCount, c.Post_id)
 	if err != nil {
-		return err
+		tx.Rollback()
+		return 0, err
 	}
 
-	return nil
+	//The side effects of the comment are only queued if the comment is saved
+	err = addOutboxEvent(ctx, tx, config.TopicCommentCreated, structure.RowEvent{Id: int(id)})
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
 }
 
 // Converts comment table query results to an array of comment structs
@@ -39,7 +62,7 @@ func ConvertRowToComment(rows *sql.Rows) ([]structure.Comment, error) {
 		var c structure.Comment
 
 		//Stores the row data in a temporary comment struct
-		err := rows.Scan(&c.Id, &c.Post_id, &c.User_id, &c.Content, &c.Date)
+		err := rows.Scan(&c.Id, &c.Post_id, &c.User_id, &c.Content, &c.Date, &c.Parent_comment_id)
 		if err != nil {
 			break
 		}
@@ -52,16 +75,11 @@ func ConvertRowToComment(rows *sql.Rows) ([]structure.Comment, error) {
 }
 
 // Gets comments from the database based on the passed parameter (id, post_id, user_id)
-func FindCommentByParam(path, param, data string) ([]structure.Comment, error) {
-	var q *sql.Rows
-
-	//Opens the database
-	db, err := OpenDB(path)
-	if err != nil {
-		return []structure.Comment{}, errors.New("failed to open database")
-	}
+func (s *Store) FindCommentByParam(ctx context.Context, param, data string) ([]structure.Comment, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	defer db.Close()
+	var q *sql.Rows
 
 	//Convert data to an integer
 	i, err := strconv.Atoi(data)
@@ -72,19 +90,19 @@ func FindCommentByParam(path, param, data string) ([]structure.Comment, error) {
 	switch param {
 	case "id":
 		//Searches database by id
-		q, err = db.Query(GetCommentById, i)
+		q, err = s.query(ctx, GetCommentById, i)
 		if err != nil {
 			return []structure.Comment{}, errors.New("could not find id")
 		}
 	case "post_id":
 		//Searches database by post_id
-		q, err = db.Query(GetAllPostComment, i)
+		q, err = s.query(ctx, GetAllPostComment, i)
 		if err != nil {
 			return []structure.Comment{}, errors.New("could not find post_id")
 		}
 	case "user_id":
 		//Searches database by user_id
-		q, err = db.Query(GetAllUserComment, i)
+		q, err = s.query(ctx, GetAllUserComment, i)
 		if err != nil {
 			return []structure.Comment{}, errors.New("could not find user_id")
 		}
@@ -101,3 +119,14 @@ func FindCommentByParam(path, param, data string) ([]structure.Comment, error) {
 
 	return comments, nil
 }
+
+// Finds how deep a comment is in its thread, 1 for top level comments and 0 when there is
+// no such comment
+func (s *Store) FindCommentDepth(ctx context.Context, id int) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var depth int
+	err := s.queryRow(ctx, GetCommentDepth, id).Scan(&depth)
+	return depth, err
+}
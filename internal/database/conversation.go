@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"real-time-forum/internal/structure"
+)
+
+// Finds what a user allows in their conversation with another user, everything unless they changed it
+func (s *Store) FindConversationPrefs(ctx context.Context, uid, with int) (structure.ConversationPrefs, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	p := structure.ConversationPrefs{Typing: true, Receipts: true}
+
+	err := s.queryRow(ctx, GetConvSettings, uid, with).Scan(&p.Typing, &p.Receipts)
+	if err != nil && err != sql.ErrNoRows {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// Stores what a user allows in their conversation with another user
+func (s *Store) UpdateConversationPrefs(ctx context.Context, uid, with int, p structure.ConversationPrefs) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddConvSettings, uid, with, p.Typing, p.Receipts)
+	return err
+}
+
+// Finds the settings of a conversation as seen by one of its users
+func (s *Store) FindConversationSettings(ctx context.Context, uid, with int) (structure.ConversationSettings, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	cs := structure.ConversationSettings{With: with}
+
+	mine, err := s.FindConversationPrefs(ctx, uid, with)
+	if err != nil {
+		return cs, err
+	}
+
+	theirs, err := s.FindConversationPrefs(ctx, with, uid)
+	if err != nil {
+		return cs, err
+	}
+
+	cs.Mine = mine
+	cs.Agreed = structure.ConversationPrefs{
+		Typing:   mine.Typing && theirs.Typing,
+		Receipts: mine.Receipts && theirs.Receipts,
+	}
+
+	return cs, nil
+}
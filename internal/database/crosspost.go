@@ -0,0 +1,86 @@
+package database
+
+import "context"
+
+// Finds the categories each post is cross-posted to
+func (s *Store) FindCrossposts(ctx context.Context) (map[int][]string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	crossposts := make(map[int][]string)
+
+	rows, err := s.query(ctx, GetCrossposts)
+	if err != nil {
+		return crossposts, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var pid int
+		var category string
+		err = rows.Scan(&pid, &category)
+		if err != nil {
+			return crossposts, err
+		}
+
+		crossposts[pid] = append(crossposts[pid], category)
+	}
+
+	return crossposts, rows.Err()
+}
+
+// Finds the categories a post is cross-posted to, in the order they were added
+func (s *Store) FindPostCrossposts(ctx context.Context, pid int) ([]string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	categories := []string{}
+
+	rows, err := s.query(ctx, GetPostCrossposts, pid)
+	if err != nil {
+		return categories, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var category string
+		err = rows.Scan(&category)
+		if err != nil {
+			return categories, err
+		}
+
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+// Cross-posts a post to a category, returning false when it already was
+func (s *Store) AddPostCrosspost(ctx context.Context, pid int, category string) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, AddCrosspost, pid, category)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Takes a post out of a category it was cross-posted to, returning whether it was there
+func (s *Store) RemovePostCrosspost(ctx context.Context, pid int, category string) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, RemoveCrosspost, pid, category)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
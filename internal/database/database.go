@@ -1,34 +1,149 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"real-time-forum/internal/config"
 )
 
-//Opens the database for sql execution and querying
-func OpenDB(path string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", path)
+// Store is the database of the forum. A single pool of connections is shared by every
+// request, SQLite handles that far better than a connection opened for each query, and
+// each query is prepared once and reused.
+type Store struct {
+	db *sql.DB
+
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+// Open opens the database at path, see InitDB to create its tables
+func Open(path string) (*Store, error) {
+	db, err := open(path)
 	if err != nil {
 		return nil, err
 	}
 
-	return db, nil
+	db.SetMaxOpenConns(config.DBMaxOpenConns)
+	db.SetMaxIdleConns(config.DBMaxOpenConns)
+
+	return &Store{db: db, stmts: make(map[string]*sql.Stmt)}, nil
+}
+
+// Close closes the prepared statements and the connections of the store
+func (s *Store) Close() error {
+	s.mu.Lock()
+	for _, stmt := range s.stmts {
+		stmt.Close()
+	}
+	s.stmts = make(map[string]*sql.Stmt)
+	s.mu.Unlock()
+
+	return s.db.Close()
+}
+
+//Opens the database in WAL mode, so reads don't wait for writes. Writers wait for the
+//lock up to the busy timeout instead of failing, and transactions take it when they
+//begin so they can't deadlock upgrading a read lock.
+func open(path string) (*sql.DB, error) {
+	source := dataSource(path)
+	if strings.Contains(source, "?") {
+		source += "&"
+	} else {
+		source += "?"
+	}
+	source += "_journal_mode=WAL&_busy_timeout=" + strconv.Itoa(config.DBBusyTimeout) + "&_txlock=immediate"
+
+	return sql.Open(driverName, source)
 }
 
-//Opens the database and executes the initialisation statement, creating the database if it does not exist
-func InitDB(path string) error {
-	db, err := OpenDB(path)
+// Finds the prepared statement of a query, preparing it the first time. Returns nil when
+// the cache is full, queries built on the fly would otherwise fill it up.
+func (s *Store) stmt(query string) (*sql.Stmt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stmt, ok := s.stmts[query]; ok {
+		return stmt, nil
+	}
+
+	if len(s.stmts) >= config.DBStatementCache {
+		return nil, nil
+	}
+
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+
+	s.stmts[query] = stmt
+	return stmt, nil
+}
+
+// Bounds the time the queries of a call can take, so a stuck query fails instead of holding
+// the request up. The context of the request already ends them when the client goes away.
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, config.DBQueryTimeout*time.Second)
+}
+
+// Runs a statement with its prepared statement
+func (s *Store) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := s.stmt(query)
+	if err != nil || stmt == nil {
+		return s.db.ExecContext(ctx, query, args...)
+	}
+
+	return stmt.ExecContext(ctx, args...)
+}
+
+// Runs a query with its prepared statement
+func (s *Store) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := s.stmt(query)
+	if err != nil || stmt == nil {
+		return s.db.QueryContext(ctx, query, args...)
+	}
+
+	return stmt.QueryContext(ctx, args...)
+}
+
+// Runs a query returning a single row with its prepared statement
+func (s *Store) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	stmt, err := s.stmt(query)
+	if err != nil || stmt == nil {
+		//The query reports the error
+		return s.db.QueryRowContext(ctx, query, args...)
+	}
+
+	return stmt.QueryRowContext(ctx, args...)
+}
+
+//Executes the initialisation statement, creating the tables that do not exist. It holds
+//several statements, so it can't be prepared.
+func (s *Store) InitDB() error {
+	_, err := s.db.Exec(CreateTables)
 	if err != nil {
 		return err
 	}
 
-	defer db.Close()
+	err = initSearch(s.db)
+	if err != nil {
+		return err
+	}
 
-	_, err = db.Exec(CreateTables)
+	//Then the changes made to the schema since, see the migrations package and legacy.go
+	_, err = s.Migrate(context.Background())
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
\ No newline at end of file
+	err = seedCategories(s.db)
+	if err != nil {
+		return err
+	}
+
+	return s.SeedRoles(context.Background())
+}
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"context"
+	"real-time-forum/internal/clock"
+)
+
+// Remembers a device the user logged in with
+func (s *Store) NewKnownDevice(ctx context.Context, uid int, token string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddDevice, uid, token, clock.Now().Unix())
+	return err
+}
+
+// Checks whether the user has logged in with the device since the given unix time
+func (s *Store) IsKnownDevice(ctx context.Context, uid int, token string, since int64) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := s.queryRow(ctx, GetDevice, uid, token, since).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
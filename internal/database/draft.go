@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/structure"
+)
+
+// Inserts or replaces the draft a user is writing to another user
+func (s *Store) UpdateDraft(ctx context.Context, d structure.Draft) (structure.Draft, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	d.Updated = clock.Now().UnixMilli()
+
+	_, err := s.exec(ctx, AddDraft, d.User_id, d.Receiver_id, d.Content, d.Updated)
+	if err != nil {
+		return d, err
+	}
+
+	return d, nil
+}
+
+// Finds the draft a user is writing to another user, returning an empty draft if there is none
+func (s *Store) FindDraft(ctx context.Context, uid, rid int) (structure.Draft, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	d := structure.Draft{
+		User_id:     uid,
+		Receiver_id: rid,
+	}
+
+	err := s.queryRow(ctx, GetDraft, uid, rid).Scan(&d.User_id, &d.Receiver_id, &d.Content, &d.Updated)
+	if err != nil && err != sql.ErrNoRows {
+		return d, err
+	}
+
+	return d, nil
+}
+
+// Removes the draft a user is writing to another user
+func (s *Store) DeleteDraft(ctx context.Context, uid, rid int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, RemoveDraft, uid, rid)
+	return err
+}
+
+// Removes every draft that has not been updated since the given time, returning how many were removed
+func (s *Store) DeleteOldDrafts(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, RemoveOldDrafts, before.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,100 @@
+//go:build sqlcipher
+// +build sqlcipher
+
+// Encrypted database mode. The whole database file is encrypted with SQLCipher using a key
+// read at startup, see config.DBKeyEnv. Building it needs the driver to be added first:
+//
+//	go get github.com/mutecomm/go-sqlcipher/v4
+//	go build -tags sqlcipher
+
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	sqlite3 "github.com/mutecomm/go-sqlcipher/v4"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/secrets"
+)
+
+// The sqlcipher driver with the functions of the forum, under the same name as the plain
+// sqlite one, see functions.go
+const driverName = "sqlite3_forum"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("forum_now", now, false)
+		},
+	})
+}
+
+// Encrypted reports whether the database is encrypted at rest
+const Encrypted = true
+
+var dbKey = loadDBKey()
+
+// Reads the key material from the environment or the file it names
+func loadDBKey() string {
+	material := secrets.Getenv(config.DBKeyEnv)
+	if material == "" {
+		if file := os.Getenv(config.DBKeyFileEnv); file != "" {
+			b, err := ioutil.ReadFile(file)
+			if err != nil {
+				log.Fatalf("Cannot read database key: %v", err)
+			}
+			material = strings.TrimSpace(string(b))
+		}
+	}
+
+	if material == "" {
+		log.Fatalf("Encrypted database mode needs %s or %s", config.DBKeyEnv, config.DBKeyFileEnv)
+	}
+
+	return rawKey(material)
+}
+
+// Stretches any key material to a raw 256-bit SQLCipher key
+func rawKey(material string) string {
+	sum := sha256.Sum256([]byte(material))
+	return "x'" + hex.EncodeToString(sum[:]) + "'"
+}
+
+func dataSource(path string) string {
+	return path + "?_pragma_key=" + url.QueryEscape(dbKey) + "&_pragma_cipher_page_size=4096"
+}
+
+// Rekey re-encrypts the database with a key made from new key material. The server has
+// to be stopped, and started again with the new key configured.
+func Rekey(path, material string) error {
+	if material == "" {
+		return errors.New("new key cannot be empty")
+	}
+
+	//Opens the database
+	db, err := open(path)
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	//Fails early if the current key is wrong
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM sqlite_master`).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`PRAGMA rekey = "` + rawKey(material) + `"`)
+	return err
+}
@@ -0,0 +1,34 @@
+//go:build !sqlcipher
+// +build !sqlcipher
+
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// The sqlite driver with the functions of the forum, see functions.go
+const driverName = "sqlite3_forum"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("forum_now", now, false)
+		},
+	})
+}
+
+// Encrypted reports whether the database is encrypted at rest, see driver_sqlcipher.go
+const Encrypted = false
+
+func dataSource(path string) string {
+	return path
+}
+
+// Rekey changes the encryption key of the database, which needs the sqlcipher build
+func Rekey(path, material string) error {
+	return errors.New("database encryption needs a build with -tags sqlcipher")
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Finds the event of a post with the answer of the viewer, false when it isn't an event post
+func (s *Store) FindPostEvent(ctx context.Context, pid, viewer int) (structure.PostEvent, bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var e structure.PostEvent
+
+	err := s.queryRow(ctx, GetPostEvent, pid).Scan(&e.Post_id, &e.Starts, &e.Ends, &e.Location, &e.Attendees, &e.Going, &e.Maybe, &e.Not_going)
+	if err == sql.ErrNoRows {
+		return e, false, nil
+	}
+	if err != nil {
+		return e, false, err
+	}
+
+	if viewer != 0 {
+		err = s.queryRow(ctx, GetRsvp, pid, viewer).Scan(&e.Rsvp)
+		if err != nil && err != sql.ErrNoRows {
+			return e, false, err
+		}
+	}
+
+	return e, true, nil
+}
+
+// Changes when and where an event takes place. Its guests are reminded again when it moves
+// to another time.
+func (s *Store) UpdateEvent(ctx context.Context, e structure.PostEvent) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, UpdatePostEvent, e.Starts, e.Starts, e.Ends, e.Location, e.Attendees, e.Post_id)
+	return err
+}
+
+// Sets the answer of a user to an event, an empty status removing it
+func (s *Store) SetRsvp(ctx context.Context, pid, uid int, status string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var err error
+	if status == "" {
+		_, err = s.exec(ctx, RemoveRsvp, pid, uid)
+	} else {
+		_, err = s.exec(ctx, AddRsvp, pid, uid, status, clock.Now().Format(config.TimeFormat))
+	}
+
+	return err
+}
+
+// Finds the answers to an event in the order they were first given
+func (s *Store) FindRsvps(ctx context.Context, pid int) ([]structure.Rsvp, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rsvps := []structure.Rsvp{}
+
+	rows, err := s.query(ctx, GetRsvps, pid)
+	if err != nil {
+		return rsvps, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var r structure.Rsvp
+		err = rows.Scan(&r.User_id, &r.Status, &r.Date)
+		if err != nil {
+			return rsvps, err
+		}
+
+		rsvps = append(rsvps, r)
+	}
+
+	return rsvps, rows.Err()
+}
+
+// Finds the events starting before the given unix time whose guests haven't been reminded
+func (s *Store) FindDueEvents(ctx context.Context, now, before int64) ([]structure.DueEvent, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	events := []structure.DueEvent{}
+
+	rows, err := s.query(ctx, GetDueEvents, now, before)
+	if err != nil {
+		return events, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var e structure.DueEvent
+		err = rows.Scan(&e.Post_id, &e.Title, &e.Starts)
+		if err != nil {
+			return events, err
+		}
+
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// Marks an event as reminded, returning the users going or maybe going to it
+func (s *Store) RemindEvent(ctx context.Context, pid int) ([]int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	uids := []int{}
+
+	_, err := s.exec(ctx, UpdateEventReminded, pid)
+	if err != nil {
+		return uids, err
+	}
+
+	rows, err := s.query(ctx, GetEventGuests, pid)
+	if err != nil {
+		return uids, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var uid int
+		err = rows.Scan(&uid)
+		if err != nil {
+			return uids, err
+		}
+
+		uids = append(uids, uid)
+	}
+
+	return uids, rows.Err()
+}
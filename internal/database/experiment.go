@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+
+	"real-time-forum/internal/clock"
+)
+
+// Records that a user was shown their variant of an experiment, only the first time counts
+func (s *Store) AddExposure(ctx context.Context, experiment, variant string, uid int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddExposure, experiment, variant, uid, clock.Now().Unix())
+	return err
+}
+
+// Records that a user reached a goal in an experiment, only the first time counts
+func (s *Store) AddConversion(ctx context.Context, experiment, variant string, uid int, goal string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddConversion, experiment, variant, uid, goal, clock.Now().Unix())
+	return err
+}
+
+// Counts the users shown each variant of an experiment
+func (s *Store) CountExposures(ctx context.Context, experiment string) (map[string]int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, GetExposureCounts, experiment)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var variant string
+		var count int
+
+		err := rows.Scan(&variant, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		counts[variant] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// Counts the users of each variant of an experiment who reached each goal
+func (s *Store) CountConversions(ctx context.Context, experiment string) (map[string]map[string]int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, GetConversionCounts, experiment)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	counts := make(map[string]map[string]int)
+	for rows.Next() {
+		var variant, goal string
+		var count int
+
+		err := rows.Scan(&variant, &goal, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		if counts[variant] == nil {
+			counts[variant] = make(map[string]int)
+		}
+		counts[variant][goal] = count
+	}
+
+	return counts, rows.Err()
+}
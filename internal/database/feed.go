@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Records the newest post a user has seen in the main feed. The position only moves
+// forward, so scrolling back to older posts doesn't bring the newer ones back as new.
+func (s *Store) SaveFeedPosition(ctx context.Context, uid, pid int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddFeedPosition, uid, pid, clock.Now().Format(config.TimeFormat))
+	return err
+}
+
+// Finds the newest post a user has seen in the main feed, 0 when they haven't seen any,
+// and how many posts by others were made after it
+func (s *Store) FindFeedPosition(ctx context.Context, uid int) (structure.FeedPosition, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var pos structure.FeedPosition
+
+	err := s.queryRow(ctx, GetFeedPosition, uid).Scan(&pos.Last_seen_post_id)
+	if err != nil && err != sql.ErrNoRows {
+		return pos, err
+	}
+
+	err = s.queryRow(ctx, GetNewPostCount, pos.Last_seen_post_id, uid).Scan(&pos.New_posts)
+	return pos, err
+}
+
+// Finds the posts made after the given one, newest first
+func (s *Store) FindPostsAfter(ctx context.Context, pid int) ([]structure.Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, GetAllPostAfter, pid)
+	if err != nil {
+		return []structure.Post{}, err
+	}
+
+	return ConvertRowToPost(rows)
+}
@@ -0,0 +1,10 @@
+package database
+
+import "real-time-forum/internal/clock"
+
+// now is forum_now() in SQL, the time of the server clock in unix seconds. Triggers stamp
+// rows with it instead of strftime('%s', 'now'), so the rows follow the clock of the server,
+// a fake one in tests.
+func now() int64 {
+	return clock.Now().Unix()
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"context"
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/structure"
+)
+
+// Starts an impersonation. Its token is only kept here, it is never a session of the user.
+func (s *Store) NewImpersonation(ctx context.Context, imp structure.Impersonation) (structure.Impersonation, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, AddImpersonation, imp.Admin_id, imp.User_id, imp.Token, imp.Write, imp.Reason, imp.Started, imp.Expires)
+	if err != nil {
+		return imp, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return imp, err
+	}
+	imp.Id = int(id)
+
+	return imp, nil
+}
+
+// Finds an impersonation by its token
+func (s *Store) FindImpersonation(ctx context.Context, token string) (structure.Impersonation, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var imp structure.Impersonation
+
+	err := s.queryRow(ctx, GetImpersonation, token).Scan(&imp.Id, &imp.Admin_id, &imp.User_id, &imp.Token, &imp.Write, &imp.Reason, &imp.Started, &imp.Expires, &imp.Ended)
+	return imp, err
+}
+
+// Ends an impersonation
+func (s *Store) EndImpersonation(ctx context.Context, token string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, UpdateImpersonationEnd, clock.Now().UnixMilli(), token)
+	return err
+}
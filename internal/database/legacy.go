@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"real-time-forum/internal/migrations"
+)
+
+// The columns added before the schema was versioned are a migration written in Go, as SQL
+// can't add a column only where it is missing. Databases created since have them already
+// and only record it as applied. The reactions and dates of birth older versions left are
+// the SQL migrations after it.
+func init() {
+	migrations.Register(11, "legacy_columns", addColumns, keepColumns)
+}
+
+// Adds the columns missing from tables created by an older version, see AddColumns
+func addColumns(ctx context.Context, tx *sql.Tx) error {
+	for _, c := range AddColumns {
+		var count int
+		err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, c.Table, c.Column).Scan(&count)
+		if err != nil {
+			return err
+		}
+
+		if count > 0 {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, `ALTER TABLE `+c.Table+` ADD COLUMN `+c.Column+` `+c.Definition)
+		if err != nil {
+			return err
+		}
+
+		if c.Backfill != "" {
+			_, err = tx.ExecContext(ctx, c.Backfill)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Rolling back leaves the columns, the versions of the forum before migrations added them
+// themselves
+func keepColumns(ctx context.Context, tx *sql.Tx) error {
+	return nil
+}
@@ -1,125 +1,110 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"strconv"
 
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
 	"real-time-forum/internal/structure"
 )
 
-// Updates liked_posts or disliked_posts table
-func UpdateLikedPosts(col string, pid, uid, i int, db *sql.DB) error {
-	//Checks which column to update
-	switch col {
-	case "likes":
-		//Checks whether it needs to be added or removed and executes the sql statment
-		if i == 1 {
-			_, err := db.Exec(AddLike, pid, uid)
-			if err != nil {
-				return err
-			}
-		} else {
-			_, err := db.Exec(RemoveLike, pid, uid)
-			if err != nil {
-				return err
-			}
-		}
-	case "dislikes":
-		//Checks whether it needs to be added or removed and executes the sql statment
-		if i == 1 {
-			_, err := db.Exec(AddDislike, pid, uid)
-			if err != nil {
-				return err
-			}
-		} else {
-			_, err := db.Exec(RemoveDislike, pid, uid)
-			if err != nil {
-				return err
-			}
-		}
-	default:
-		//Returns an error if a different column is passed
-		return errors.New("cannot edit that column")
-	}
+// Kinds of reaction to a post, by the name of the post column counting them
+var reactionKinds = map[string]string{
+	"likes":    "like",
+	"dislikes": "dislike",
+}
 
-	return nil
+// Checks a reaction kind is one that posts count
+func IsReactionKind(kind string) bool {
+	return kind == "like" || kind == "dislike"
 }
 
-// Adds like to database
-func UpdateLikeDislike(path, post_id, user_id, col string, i int) error {
-	//Opens the database
-	db, err := OpenDB(path)
+// Sets the reaction of a user to a post, an empty kind removing it. Returns the counts of
+// the post afterwards, and false when there is no such post.
+func (s *Store) React(ctx context.Context, pid, uid int, kind string) (structure.PostReaction, bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	reaction := structure.PostReaction{Post_id: pid, Reaction: kind}
+
+	//The reaction and the counts returned with it are read together
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return reaction, false, err
 	}
 
-	defer db.Close()
-
-	//Checks whether i is 1 or -1
-	if i != 1 && i != -1 {
-		return errors.New("can only change by 1 or -1")
+	var posts int
+	err = tx.QueryRowContext(ctx, GetPostCount, pid).Scan(&posts)
+	if err != nil || posts == 0 {
+		tx.Rollback()
+		return reaction, false, err
 	}
 
-	//Finds the post
-	temp, err := FindPostByParam(path, "id", post_id)
+	if kind == "" {
+		_, err = tx.ExecContext(ctx, RemoveReaction, pid, uid)
+	} else {
+		_, err = tx.ExecContext(ctx, AddReaction, pid, uid, kind, clock.Now().Format(config.TimeFormat))
+	}
+	if err == nil {
+		err = tx.QueryRowContext(ctx, GetReactionCounts, pid).Scan(&reaction.Likes, &reaction.Dislikes)
+	}
 	if err != nil {
-		return err
+		tx.Rollback()
+		return reaction, false, err
 	}
 
-	p := temp[0]
+	return reaction, true, tx.Commit()
+}
 
-	//Converts post_id and user_id to integers
-	pid, err := strconv.Atoi(post_id)
-	if err != nil {
-		return err
+// Finds the reaction of a user to a post, empty when they have not reacted
+func (s *Store) FindReaction(ctx context.Context, pid, uid int) (string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var kind string
+	err := s.queryRow(ctx, GetReaction, pid, uid).Scan(&kind)
+	if err == sql.ErrNoRows {
+		return "", nil
 	}
 
-	uid, err := strconv.Atoi(user_id)
+	return kind, err
+}
+
+// Finds the reactions of a user by post id
+func (s *Store) FindUserReactions(ctx context.Context, uid int) (map[int]string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	reactions := make(map[int]string)
+
+	rows, err := s.query(ctx, GetUserReactions, uid)
 	if err != nil {
-		return err
+		return reactions, err
 	}
 
-	//Checks which column to update
-	switch col {
-	case "likes":
-		//Updates the like count by +1 or -1 and updates the post table
-		count := p.Likes + i
+	defer rows.Close()
 
-		_, err := db.Exec(UpdateLike, count, pid)
+	for rows.Next() {
+		var pid int
+		var kind string
+		err = rows.Scan(&pid, &kind)
 		if err != nil {
-			return err
+			return reactions, err
 		}
-	case "dislikes":
-		//Increases the dislike count by 1 and updates the post table
-		count := p.Dislikes + i
 
-		_, err := db.Exec(UpdateDislike, count, pid)
-		if err != nil {
-			return err
-		}
-	default:
-		//Returns an error when trying to update a different column
-		return errors.New("can only update likes and dislikes")
+		reactions[pid] = kind
 	}
 
-	//Updates the liked_posts table
-	UpdateLikedPosts(col, pid, uid, i, db)
-
-	return nil
+	return reactions, rows.Err()
 }
 
 // Finds all users who liked or disliked a post
-func PostLikedBy(path, post_id, col string) ([]structure.User, error) {
-	var q *sql.Rows
-
-	//Opens the database
-	db, err := OpenDB(path)
-	if err != nil {
-		return []structure.User{}, err
-	}
-
-	defer db.Close()
+func (s *Store) PostLikedBy(ctx context.Context, post_id, col string) ([]structure.User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
 	//Converts post_id to an integer
 	pid, err := strconv.Atoi(post_id)
@@ -127,23 +112,17 @@ func PostLikedBy(path, post_id, col string) ([]structure.User, error) {
 		return []structure.User{}, err
 	}
 
-	switch col {
-	case "likes":
-		//Finds all users that liked a post through liked_posts table
-		q, err = db.Query(GetPostLikes, pid)
-		if err != nil {
-			return []structure.User{}, err
-		}
-	case "dislikes":
-		//Finds all users that disliked a post through disliked_posts table
-		q, err = db.Query(GetPostDislikes, pid)
-		if err != nil {
-			return []structure.User{}, err
-		}
-	default:
+	kind, ok := reactionKinds[col]
+	if !ok {
 		return []structure.User{}, errors.New("incorrect column")
 	}
 
+	//Finds all users that reacted to a post that way through the reactions table
+	q, err := s.query(ctx, GetPostReactors, pid, kind)
+	if err != nil {
+		return []structure.User{}, err
+	}
+
 	//Converts the query results to an array of user structs
 	users, err := ConvertRowToUser(q)
 	if err != nil {
@@ -154,41 +133,28 @@ func PostLikedBy(path, post_id, col string) ([]structure.User, error) {
 }
 
 // Finds all posts liked or disliked by a user
-func UserLiked(path, user_id, col string) ([]structure.Post, error) {
-	var q *sql.Rows
+func (s *Store) UserLiked(ctx context.Context, user_id, col string) ([]structure.Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	//Opens the database
-	db, err := OpenDB(path)
+	//Converts user_id to an integer
+	uid, err := strconv.Atoi(user_id)
 	if err != nil {
 		return []structure.Post{}, err
 	}
 
-	defer db.Close()
+	kind, ok := reactionKinds[col]
+	if !ok {
+		return []structure.Post{}, errors.New("incorrect column")
+	}
 
-	//Converts post_id to an integer
-	uid, err := strconv.Atoi(user_id)
+	//Finds all posts the user reacted to that way through the reactions table
+	q, err := s.query(ctx, GetUserReacted, uid, kind)
 	if err != nil {
 		return []structure.Post{}, err
 	}
 
-	switch col {
-	case "likes":
-		//Finds all users that liked a post through liked_posts table
-		q, err = db.Query(GetUserLikes, uid)
-		if err != nil {
-			return []structure.Post{}, err
-		}
-	case "dislikes":
-		//Finds all users that disliked a post through disliked_posts table
-		q, err = db.Query(GetUserDislikes, uid)
-		if err != nil {
-			return []structure.Post{}, err
-		}
-	default:
-		return []structure.Post{}, errors.New("incorrect column")
-	}
-
-	//Converts the query results to an array of user structs
+	//Converts the query results to an array of post structs
 	posts, err := ConvertRowToPost(q)
 	if err != nil {
 		return []structure.Post{}, err
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"real-time-forum/internal/clock"
+)
+
+// Records a login of the user and reports whether it came from an ip address and
+// user agent they have not logged in from before. The first login on record is
+// never reported, there is nothing to compare it to.
+func (s *Store) RecordLogin(ctx context.Context, uid int, ip, userAgent string) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var total, seen int
+	err := s.queryRow(ctx, GetLoginCount, uid).Scan(&total)
+	if err != nil {
+		return false, err
+	}
+
+	err = s.queryRow(ctx, GetLogin, uid, ip, userAgent).Scan(&seen)
+	if err != nil {
+		return false, err
+	}
+
+	now := clock.Now().Unix()
+	_, err = s.exec(ctx, AddLogin, uid, ip, userAgent, now, now)
+	if err != nil {
+		return false, err
+	}
+
+	return total > 0 && seen == 0, nil
+}
+
+// Stores the token of a "this wasn't me" link sent to the user
+func (s *Store) NewLoginAlert(ctx context.Context, uid int, token string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddLoginAlert, token, uid, clock.Now().Unix())
+	return err
+}
+
+// Uses a "this wasn't me" link created since the given unix time. Every session and
+// known device of the user is removed and they cannot log in until they have set a
+// new password with the returned reset token.
+func (s *Store) LockAccount(ctx context.Context, alert, reset string, since int64) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var uid int
+	err = tx.QueryRowContext(ctx, GetLoginAlert, alert, since).Scan(&uid)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	now := clock.Now().Unix()
+
+	_, err = tx.ExecContext(ctx, UpdateLoginAlertUsed, now, alert)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	//Logs out everyone using the account
+	_, err = tx.ExecContext(ctx, RemoveCookie, uid)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, RemoveDevices, uid)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, AddReset, uid, reset, now)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return uid, tx.Commit()
+}
+
+// Checks whether the user has to reset their password before logging in
+func (s *Store) IsResetRequired(ctx context.Context, uid int) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := s.queryRow(ctx, GetUserReset, uid).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// Gives a user who forgot their password a reset token. Unlike the token of a locked
+// account it expires, and the user can still log in with their old password meanwhile.
+func (s *Store) NewPasswordReset(ctx context.Context, uid int, token string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddForgotReset, uid, token, clock.Now().Unix())
+	return err
+}
+
+// Sets a new password hash with a reset token and returns the id of its user. Tokens of
+// forgotten passwords are only valid when created after the given unix time. Every
+// session of the user is logged out.
+func (s *Store) ResetPassword(ctx context.Context, token, hash string, since int64) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var uid int
+	err = tx.QueryRowContext(ctx, GetReset, token, since).Scan(&uid)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, UpdatePassword, hash, uid)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, RemoveReset, uid)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, RemoveCookie, uid)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return uid, tx.Commit()
+}
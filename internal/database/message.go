@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"strconv"
@@ -8,28 +9,42 @@ import (
 	"real-time-forum/internal/structure"
 )
 
-// Attempts to insert a new message into the database
-func NewMessage(path string, m structure.Message) error {
-	//Opens the database
-	db, err := OpenDB(path)
+// Attempts to insert a new message into the database and returns its id along with its
+// sequence number, which counts up from 1 within each conversation
+func (s *Store) NewMessage(ctx context.Context, m structure.Message) (int, int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	//Message bodies are encrypted at rest when a key is configured
+	content, err := sealMessage(m.Content)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 
-	defer db.Close()
+	//Executes the insert statement, the sequence number is assigned in the same statement
+	//so two messages can never get the same one
+	res, err := s.exec(ctx, AddMessage, m.Sender_id, m.Receiver_id, content, m.Date, m.Sender_id, m.Receiver_id, m.Receiver_id, m.Sender_id)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, 0, err
+	}
 
-	//Executes the insert statement
-	_, err = db.Exec(AddMessage, m.Sender_id, m.Receiver_id, m.Content, m.Date)
+	var seq int
+	err = s.queryRow(ctx, GetMessageSeq, id).Scan(&seq)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 
-	err = UpdateChatTime(m.Sender_id, m.Receiver_id, db)
+	err = UpdateChatTime(ctx, m.Sender_id, m.Receiver_id, s.db)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 
-	return nil
+	return int(id), seq, nil
 }
 
 // Converts message table query results into an array of message structs
@@ -41,10 +56,11 @@ func ConvertRowToMessage(rows *sql.Rows) ([]structure.Message, error) {
 		var m structure.Message
 
 		//Stores the row data in a temporary message struct
-		err := rows.Scan(&m.Id, &m.Sender_id, &m.Receiver_id, &m.Content, &m.Date)
+		err := rows.Scan(&m.Id, &m.Sender_id, &m.Receiver_id, &m.Content, &m.Date, &m.Seq, &m.Read_at)
 		if err != nil {
 			break
 		}
+		m.Content = openMessage(m.Content)
 
 		//Appends the temporary struct to the array
 		messages = append(messages, m)
@@ -53,40 +69,18 @@ func ConvertRowToMessage(rows *sql.Rows) ([]structure.Message, error) {
 	return messages, nil
 }
 
-// Finds chat messages between users
-func FindChatMessages(path, sender, receiver string, firstId int) ([]structure.Message, error) {
-	//Opens the database
-	db, err := OpenDB(path)
-	if err != nil {
-		return []structure.Message{}, errors.New("failed to open database")
-	}
+// Finds the messages of a conversation older than the message with the given id, or the
+// latest ones when before is 0, newest first
+func (s *Store) FindMessagesBefore(ctx context.Context, u1, u2, before, limit int) ([]structure.Message, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	defer db.Close()
-
-	//Converts sender and receiver ids to integers
-	s, err := strconv.Atoi(sender)
-	if err != nil {
-		return []structure.Message{}, errors.New("sender id must be an integer")
-	}
-
-	r, err := strconv.Atoi(receiver)
-	if err != nil {
-		return []structure.Message{}, errors.New("receiver id must be an integer")
-	}
-
-	//Searches database for all messages between the two users
-	q, err := db.Query(GetAllChatMessage, s, r, r, s, firstId)
-	//`SELECT * FROM messages WHERE (sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?) AND  id <= ?  ORDER BY id DESC LIMIT 10`
+	q, err := s.query(ctx, GetMessagesBefore, u1, u2, u2, u1, before, before, limit)
 	if err != nil {
 		return []structure.Message{}, errors.New("could not find chat messages")
 	}
 
-	//search database for last message between the two users
-	// q, err := db.Query(GetLastMessage, r, r, r, s)
-	// //`SELECT * FROM messages WHERE (sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?) ORDER BY id DESC LIMIT 1`
-	// if err != nil {
-	// 	return []structure.Message{}, errors.New("could not find chat messages")
-	// }
+	defer q.Close()
 
 	//Converts rows to an array of message structs
 	messages, err := ConvertRowToMessage(q)
@@ -98,17 +92,12 @@ func FindChatMessages(path, sender, receiver string, firstId int) ([]structure.M
 }
 
 // find the last message between two users
-func FindLastMessage(path, sender, receiver string) (structure.Message, error) {
-	//Opens the database
-	db, err := OpenDB(path)
-	if err != nil {
-		return structure.Message{}, errors.New("failed to open database")
-	}
-
-	defer db.Close()
+func (s *Store) FindLastMessage(ctx context.Context, sender, receiver string) (structure.Message, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
 	//Converts sender and receiver ids to integers
-	s, err := strconv.Atoi(sender)
+	sid, err := strconv.Atoi(sender)
 	if err != nil {
 		return structure.Message{}, errors.New("sender id must be an integer")
 	}
@@ -119,7 +108,7 @@ func FindLastMessage(path, sender, receiver string) (structure.Message, error) {
 	}
 
 	//search database for last message between the two users
-	q, err := db.Query(GetLastMessage, r, r, r, s)
+	q, err := s.query(ctx, GetLastMessage, r, r, r, sid)
 	//`SELECT * FROM messages WHERE (sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?) ORDER BY id DESC LIMIT 1`
 	if err != nil {
 		return structure.Message{}, errors.New("could not find chat messages")
@@ -133,3 +122,99 @@ func FindLastMessage(path, sender, receiver string) (structure.Message, error) {
 
 	return messages[0], nil
 }
+
+// Finds every message between two users, oldest first
+func (s *Store) FindConversation(ctx context.Context, u1, u2 int) ([]structure.Message, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	q, err := s.query(ctx, GetConversation, u1, u2, u2, u1)
+	if err != nil {
+		return []structure.Message{}, errors.New("could not find chat messages")
+	}
+
+	defer q.Close()
+
+	//Converts rows to an array of message structs
+	messages, err := ConvertRowToMessage(q)
+	if err != nil {
+		return []structure.Message{}, errors.New("failed to convert")
+	}
+
+	return messages, nil
+}
+
+// Finds the messages of a conversation that come after a sequence number, oldest first
+func (s *Store) FindMessagesAfter(ctx context.Context, u1, u2, seq, limit int) ([]structure.Message, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	q, err := s.query(ctx, GetMessagesAfter, u1, u2, u2, u1, seq, limit)
+	if err != nil {
+		return []structure.Message{}, errors.New("could not find chat messages")
+	}
+
+	defer q.Close()
+
+	//Converts rows to an array of message structs
+	messages, err := ConvertRowToMessage(q)
+	if err != nil {
+		return []structure.Message{}, errors.New("failed to convert")
+	}
+
+	return messages, nil
+}
+
+// Marks the messages a user has received from another as read up to a sequence number, or
+// up to the latest one when seq is 0. Returns the sequence number read up to.
+func (s *Store) MarkMessagesRead(ctx context.Context, reader, sender, seq int, readAt string) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	if seq == 0 {
+		err := s.queryRow(ctx, GetLastSentSeq, sender, reader).Scan(&seq)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	_, err := s.exec(ctx, UpdateMessagesRead, readAt, sender, reader, seq)
+	return seq, err
+}
+
+// Counts the messages a user has not read yet, per user who sent them
+func (s *Store) FindUnreadCounts(ctx context.Context, uid int) ([]structure.UnreadCount, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	counts := []structure.UnreadCount{}
+
+	rows, err := s.query(ctx, GetUnreadCounts, uid)
+	if err != nil {
+		return counts, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var c structure.UnreadCount
+		err = rows.Scan(&c.User_id, &c.Count)
+		if err != nil {
+			return counts, err
+		}
+
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
+
+// Checks whether two users ever exchanged a message, in either direction
+func (s *Store) HasConversation(ctx context.Context, uid, other int) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var exists bool
+	err := s.queryRow(ctx, GetConversationExists, uid, other, other, uid).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"context"
+	"log"
+
+	"real-time-forum/internal/migrations"
+)
+
+// Migrate applies the schema migrations the database doesn't have yet, see the migrations
+// package, and returns them
+func (s *Store) Migrate(ctx context.Context) ([]migrations.Migration, error) {
+	applied, err := migrations.Up(ctx, s.db)
+	for _, m := range applied {
+		log.Printf("Applied migration %s", m)
+	}
+
+	return applied, err
+}
+
+// RollbackMigrations undoes the last steps schema migrations and returns them
+func (s *Store) RollbackMigrations(ctx context.Context, steps int) ([]migrations.Migration, error) {
+	return migrations.Down(ctx, s.db, steps)
+}
+
+// SchemaVersion returns the latest schema migration applied, 0 when there is none
+func (s *Store) SchemaVersion(ctx context.Context) (int, error) {
+	return migrations.Version(ctx, s.db)
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"real-time-forum/internal/structure"
+)
+
+// Closes a post to new comments, replacing the reason when it already is
+func (s *Store) LockThread(ctx context.Context, l structure.ThreadLock) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddThreadLock, l.Post_id, l.Moderator_id, l.Reason, l.Date)
+	return err
+}
+
+// Opens a locked post to comments again, returning false when it wasn't locked
+func (s *Store) UnlockThread(ctx context.Context, pid int) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, RemoveThreadLock, pid)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Finds the lock of a post, found is false when comments are open
+func (s *Store) FindThreadLock(ctx context.Context, pid int) (structure.ThreadLock, bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var l structure.ThreadLock
+
+	err := s.queryRow(ctx, GetThreadLock, pid).Scan(&l.Post_id, &l.Moderator_id, &l.Reason, &l.Date)
+	if err == sql.ErrNoRows {
+		return l, false, nil
+	}
+
+	return l, err == nil, err
+}
+
+// Bans a user, replacing the ban they already have
+func (s *Store) NewBan(ctx context.Context, b structure.Ban) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddBan, b.User_id, b.Moderator_id, b.Reason, b.Created, b.Expires)
+	return err
+}
+
+// Lifts the ban of a user, returning false when they weren't banned
+func (s *Store) LiftBan(ctx context.Context, uid int) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, RemoveBan, uid)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Finds the ban a user is under at the given unix time, found is false when there is none
+func (s *Store) FindBan(ctx context.Context, uid int, now int64) (structure.Ban, bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var b structure.Ban
+
+	err := s.queryRow(ctx, GetBan, uid, now).Scan(&b.User_id, &b.Moderator_id, &b.Reason, &b.Created, &b.Expires)
+	if err == sql.ErrNoRows {
+		return b, false, nil
+	}
+
+	return b, err == nil, err
+}
+
+// Finds the bans still in force at the given unix time, the newest first
+func (s *Store) FindBans(ctx context.Context, now int64) ([]structure.Ban, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	bans := []structure.Ban{}
+
+	rows, err := s.query(ctx, GetBans, now)
+	if err != nil {
+		return bans, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var b structure.Ban
+		err = rows.Scan(&b.User_id, &b.Moderator_id, &b.Reason, &b.Created, &b.Expires)
+		if err != nil {
+			return bans, err
+		}
+
+		bans = append(bans, b)
+	}
+
+	return bans, rows.Err()
+}
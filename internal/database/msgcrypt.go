@@ -0,0 +1,179 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"strings"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/secrets"
+)
+
+// Prefix of an encrypted message body, followed by the id of its key
+const messagePrefix = "encmsg:"
+
+type messageKey struct {
+	id  string
+	key []byte
+}
+
+// Keys for message bodies, the first one encrypts new messages
+var messageKeys = loadMessageKeys()
+
+// Reads the message keys from the environment, message bodies are stored in plaintext when unset
+func loadMessageKeys() []messageKey {
+	var keys []messageKey
+
+	for _, pair := range strings.Split(secrets.Getenv(config.MessageKeysEnv), ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			continue
+		}
+
+		sum := sha256.Sum256([]byte(parts[1]))
+		keys = append(keys, messageKey{id: parts[0], key: sum[:]})
+	}
+
+	return keys
+}
+
+// Encrypts a message body with the current key
+func sealMessage(content string) (string, error) {
+	if len(messageKeys) == 0 {
+		return content, nil
+	}
+
+	sealed, err := secrets.Encrypt(messageKeys[0].key, content)
+	if err != nil {
+		return "", err
+	}
+
+	return messagePrefix + messageKeys[0].id + ":" + sealed, nil
+}
+
+// Decrypts a stored message body, returning it unchanged if it is not encrypted or can't be decrypted
+func openMessage(stored string) string {
+	content, err := decryptMessage(stored)
+	if err != nil {
+		log.Printf("Error decrypting message: %v", err)
+		return stored
+	}
+
+	return content
+}
+
+// Decrypts a stored message body, plaintext bodies are returned as they are. It fails when
+// the key of an encrypted body is unknown or the body doesn't decrypt with it.
+func decryptMessage(stored string) (string, error) {
+	id, sealed, ok := splitMessage(stored)
+	if !ok {
+		return stored, nil
+	}
+
+	for _, k := range messageKeys {
+		if k.id == id {
+			return secrets.Decrypt(k.key, sealed)
+		}
+	}
+
+	return "", fmt.Errorf("unknown message key %s", id)
+}
+
+// Splits a stored message body into the id of its key and the encrypted value
+func splitMessage(stored string) (string, string, bool) {
+	if !strings.HasPrefix(stored, messagePrefix) {
+		return "", "", false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(stored, messagePrefix), ":", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[1], secrets.Prefix) {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
+// EncryptMessages encrypts every message body that is not encrypted with the current key yet,
+// both plaintext rows and rows under an older key, in the messages and in the trash. It returns
+// how many were changed and how many were skipped because they could not be decrypted, those
+// are left as they are rather than encrypted twice. Like Repair it isn't bounded by the query timeout.
+func (s *Store) EncryptMessages(ctx context.Context) (int, int, error) {
+	if len(messageKeys) == 0 {
+		return 0, 0, nil
+	}
+
+	var encrypted, skipped int
+	for _, table := range [][2]string{
+		{GetAllMessageContent, UpdateMessageContent},
+		{GetAllTrashedContent, UpdateTrashedContent},
+	} {
+		n, k, err := s.encryptBodies(ctx, table[0], table[1])
+		encrypted += n
+		skipped += k
+		if err != nil {
+			return encrypted, skipped, err
+		}
+	}
+
+	return encrypted, skipped, nil
+}
+
+// Encrypts the message bodies listed by one query with the current key, writing them back with another
+func (s *Store) encryptBodies(ctx context.Context, list, update string) (int, int, error) {
+	rows, err := s.query(ctx, list)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	//Collects the rows first, sqlite cannot update a table while reading it on the same connection
+	pending := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var stored string
+
+		err := rows.Scan(&id, &stored)
+		if err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+
+		if kid, _, ok := splitMessage(stored); ok && kid == messageKeys[0].id {
+			continue
+		}
+
+		pending[id] = stored
+	}
+	rows.Close()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	encrypted, skipped := 0, 0
+	for id, stored := range pending {
+		content, err := decryptMessage(stored)
+		if err != nil {
+			log.Printf("Skipping message %d: %v", id, err)
+			skipped++
+			continue
+		}
+
+		sealed, err := sealMessage(content)
+		if err != nil {
+			tx.Rollback()
+			return 0, 0, err
+		}
+
+		_, err = tx.ExecContext(ctx, update, sealed, id)
+		if err != nil {
+			tx.Rollback()
+			return 0, 0, err
+		}
+		encrypted++
+	}
+
+	return encrypted, skipped, tx.Commit()
+}
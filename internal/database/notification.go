@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Stores a notification for a user and returns it with its id and date
+func (s *Store) NewNotification(ctx context.Context, n structure.Notification) (structure.Notification, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	n.Date = clock.Now().Format(config.TimeFormat)
+
+	res, err := s.exec(ctx, AddNotification, n.User_id, n.Kind, n.Content, n.Link, n.Date)
+	if err != nil {
+		return n, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return n, err
+	}
+	n.Id = int(id)
+
+	return n, nil
+}
+
+// Finds the latest notifications of a user
+func (s *Store) FindNotifications(ctx context.Context, uid, limit int) ([]structure.Notification, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, GetNotifications, uid, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	notifications := []structure.Notification{}
+	for rows.Next() {
+		var n structure.Notification
+
+		err := rows.Scan(&n.Id, &n.User_id, &n.Kind, &n.Content, &n.Link, &n.Date, &n.Seen)
+		if err != nil {
+			return nil, err
+		}
+
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// Counts the notifications a user has not seen yet
+func (s *Store) CountUnseenNotifications(ctx context.Context, uid int) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := s.queryRow(ctx, GetUnseenCount, uid).Scan(&count)
+	return count, err
+}
+
+// Marks every notification of a user as seen
+func (s *Store) SeeNotifications(ctx context.Context, uid int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, UpdateNotificationSeen, uid)
+	return err
+}
+
+// Marks a notification of a user as seen, returning false when they have no such notification
+func (s *Store) SeeNotification(ctx context.Context, uid, id int) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, UpdateNotificationRead, id, uid)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Checks whether the same notification was already sent to the user
+func (s *Store) HasNotification(ctx context.Context, n structure.Notification) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := s.queryRow(ctx, GetNotificationCount, n.User_id, n.Kind, n.Content, n.Link).Scan(&count)
+	return count > 0, err
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/structure"
+)
+
+// Queues an event in the transaction of the write it belongs to, so the event
+// exists exactly when the write does
+func addOutboxEvent(ctx context.Context, tx *sql.Tx, topic string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	now := clock.Now().Unix()
+	_, err = tx.ExecContext(ctx, AddOutboxEvent, topic, string(b), now, now)
+	return err
+}
+
+// Finds the events that are due to be delivered, oldest first
+func (s *Store) FindPendingEvents(ctx context.Context, now int64, limit int) ([]structure.OutboxEvent, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, GetPendingEvents, now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var events []structure.OutboxEvent
+	for rows.Next() {
+		var e structure.OutboxEvent
+
+		err := rows.Scan(&e.Id, &e.Topic, &e.Payload, &e.Created, &e.Attempts, &e.Next_attempt, &e.Status, &e.Last_error)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// Finds the subscribers an event has already been delivered to
+func (s *Store) FindDeliveries(ctx context.Context, id int) (map[string]bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, GetDeliveries, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	delivered := make(map[string]bool)
+	for rows.Next() {
+		var subscriber string
+
+		err := rows.Scan(&subscriber)
+		if err != nil {
+			return nil, err
+		}
+
+		delivered[subscriber] = true
+	}
+
+	return delivered, rows.Err()
+}
+
+// Records that an event has been delivered to a subscriber
+func (s *Store) AddDelivery(ctx context.Context, id int, subscriber string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddDelivery, id, subscriber)
+	return err
+}
+
+// Marks an event as delivered, forgetting which subscribers had it as none is run again
+func (s *Store) MarkEventDelivered(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, UpdateEventDelivered, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, RemoveDeliveries, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Records a failed delivery, the event is retried at next unless its status is changed
+func (s *Store) MarkEventFailed(ctx context.Context, e structure.OutboxEvent) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, UpdateEventFailed, e.Status, e.Attempts, e.Next_attempt, e.Last_error, e.Id)
+	return err
+}
+
+// Removes delivered events created before the given unix time
+func (s *Store) DeleteOldEvents(ctx context.Context, before int64) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, RemoveOldEvents, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -1,33 +1,67 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"strconv"
-	"time"
 
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
 	"real-time-forum/internal/structure"
 )
 
-// Attempts to insert a new post into the database
-func NewPost(path string, p structure.Post, u structure.User) error {
-	//Opens the database
-	db, err := OpenDB(path)
+// Attempts to insert a new post into the database and returns its id
+func (s *Store) NewPost(ctx context.Context, p structure.Post, u structure.User) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	dt := clock.Now().Format(config.TimeFormat)
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	defer db.Close()
+	//Executes the insert statement
+	res, err := tx.ExecContext(ctx, AddPost, u.Id, p.Category, p.Title, p.Content, dt, p.License)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
 
-	dt := time.Now().Format("01-02-2006 15:04:05")
+	id, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
 
-	//Executes the insert statement
-	_, err = db.Exec(AddPost, u.Id, p.Category, p.Title, p.Content, dt, p.Likes, p.Dislikes)
+	//Cross-posts only reference the post, which stays in its own category
+	for _, category := range p.Crossposts {
+		_, err = tx.ExecContext(ctx, AddCrosspost, id, category)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	//Event posts store when and where the event takes place
+	if p.Event != nil {
+		_, err = tx.ExecContext(ctx, AddPostEvent, id, p.Event.Starts, p.Event.Ends, p.Event.Location, p.Event.Attendees)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	//The side effects of the post are only queued if the post is saved
+	err = addOutboxEvent(ctx, tx, config.TopicPostCreated, structure.RowEvent{Id: int(id)})
 	if err != nil {
-		return err
+		tx.Rollback()
+		return 0, err
 	}
 
-	return nil
+	return int(id), tx.Commit()
 }
 
 // Converts post table query results into an array of post structs
@@ -39,7 +73,7 @@ func ConvertRowToPost(rows *sql.Rows) ([]structure.Post, error) {
 		var p structure.Post
 
 		//Stores the row data in a temporary post struct
-		err := rows.Scan(&p.Id, &p.User_id, &p.Category, &p.Title, &p.Content, &p.Date, &p.Likes, &p.Dislikes)
+		err := rows.Scan(&p.Id, &p.User_id, &p.Category, &p.Title, &p.Content, &p.Date, &p.Likes, &p.Dislikes, &p.Comment_count, &p.View_count, &p.License, &p.Edited)
 		if err != nil {
 			break
 		}
@@ -52,17 +86,12 @@ func ConvertRowToPost(rows *sql.Rows) ([]structure.Post, error) {
 }
 
 // Gets all posts from the database
-func FindAllPosts(path string) ([]structure.Post, error) {
-	//Opens the database
-	db, err := OpenDB(path)
-	if err != nil {
-		return []structure.Post{}, errors.New("failed to open database")
-	}
-
-	defer db.Close()
+func (s *Store) FindAllPosts(ctx context.Context) ([]structure.Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
 	//Finds all the users
-	rows, err := db.Query(GetAllPost)
+	rows, err := s.query(ctx, GetAllPost)
 	if err != nil {
 		return []structure.Post{}, errors.New("failed to find posts")
 	}
@@ -77,16 +106,12 @@ func FindAllPosts(path string) ([]structure.Post, error) {
 }
 
 // Gets posts from the database based on the passed parameter (id, user_id, category)
-func FindPostByParam(path, parameter, data string) ([]structure.Post, error) {
-	var q *sql.Rows
-
-	//Opens the database
-	db, err := OpenDB(path)
-	if err != nil {
-		return []structure.Post{}, errors.New("failed to open database")
-	}
+func (s *Store) FindPostByParam(ctx context.Context, parameter, data string) ([]structure.Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	defer db.Close()
+	var q *sql.Rows
+	var err error
 
 	//Checks which parameter to search the database by
 	switch parameter {
@@ -98,19 +123,19 @@ func FindPostByParam(path, parameter, data string) ([]structure.Post, error) {
 		}
 
 		//Searches the database by id
-		q, err = db.Query(GetPostById, i)
+		q, err = s.query(ctx, GetPostById, i)
 		if err != nil {
 			return []structure.Post{}, errors.New("could not find id")
 		}
 	case "user_id":
 		//Searches the database by user_id
-		q, err = db.Query(GetAllPostByUser, data)
+		q, err = s.query(ctx, GetAllPostByUser, data)
 		if err != nil {
 			return []structure.Post{}, errors.New("could not find any posts by that user")
 		}
 	case "category":
-		//Searches the database by category
-		q, err = db.Query(GetAllPostByCategory, data)
+		//Searches the database by category, including the posts cross-posted to it
+		q, err = s.query(ctx, GetAllPostByCategory, data, data)
 		if err != nil {
 			return []structure.Post{}, errors.New("could not find any posts with that category")
 		}
@@ -127,3 +152,41 @@ func FindPostByParam(path, parameter, data string) ([]structure.Post, error) {
 
 	return posts, nil
 }
+
+// Counts a view of a post by a user, in its total and under the current day. Each user
+// only counts once, the views of a user who already viewed the post are ignored.
+func (s *Store) IncrementViews(ctx context.Context, pid, uid int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, AddPostViewer, pid, uid)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, UpdateViewCount, pid)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, AddPostView, pid, clock.Now().Format(config.DateFormat))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
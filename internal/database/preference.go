@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Inserts or replaces the display preferences of a user
+func (s *Store) UpdatePreferences(ctx context.Context, p structure.Preferences) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	if p.Display_name == "" {
+		p.Display_name = config.DefaultDisplayName
+	}
+
+	_, err := s.exec(ctx, AddPreference, p.User_id, p.Display_name)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Finds the display preferences of a user, returning the defaults if none are stored
+func (s *Store) FindPreferences(ctx context.Context, uid int) (structure.Preferences, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	p := structure.Preferences{
+		User_id:      uid,
+		Display_name: config.DefaultDisplayName,
+	}
+
+	err := s.queryRow(ctx, GetUserPreference, uid).Scan(&p.User_id, &p.Display_name)
+	if err != nil && err != sql.ErrNoRows {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// Computes the name a user is shown as, based on their display preference
+func DisplayName(username, firstname, surname, pref string) string {
+	if pref == config.DisplayFullName {
+		if name := strings.TrimSpace(firstname + " " + surname); name != "" {
+			return name
+		}
+	}
+
+	return username
+}
+
+// Finds the display name of every user, mapped by user id
+func (s *Store) FindDisplayNames(ctx context.Context) (map[int]string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	names := make(map[int]string)
+
+	rows, err := s.query(ctx, GetAllDisplayNames)
+	if err != nil {
+		return names, err
+	}
+
+	defer rows.Close()
+
+	//Loops through the rows provided
+	for rows.Next() {
+		var id int
+		var username, firstname, surname string
+		var pref sql.NullString
+
+		err := rows.Scan(&id, &username, &firstname, &surname, &pref)
+		if err != nil {
+			break
+		}
+
+		names[id] = DisplayName(username, firstname, surname, pref.String)
+	}
+
+	return names, nil
+}
+
+// Finds the display name of a single user
+func (s *Store) FindDisplayName(ctx context.Context, uid int) (string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var id int
+	var username, firstname, surname string
+	var pref sql.NullString
+
+	err := s.queryRow(ctx, GetDisplayName, uid).Scan(&id, &username, &firstname, &surname, &pref)
+	if err != nil {
+		return "", err
+	}
+
+	return DisplayName(username, firstname, surname, pref.String), nil
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Inserts or replaces the visibility settings of a user
+func (s *Store) UpdatePrivacy(ctx context.Context, p structure.Privacy) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	return insertPrivacy(ctx, s.db, p)
+}
+
+// Executes the privacy insert statement, filling in unset fields with the default visibility
+func insertPrivacy(ctx context.Context, db *sql.DB, p structure.Privacy) error {
+	if p.DOB == "" {
+		p.DOB = config.DefaultVisibility
+	}
+
+	if p.Gender == "" {
+		p.Gender = config.DefaultVisibility
+	}
+
+	if p.Presence == "" {
+		p.Presence = config.DefaultVisibility
+	}
+
+	_, err := db.ExecContext(ctx, AddPrivacy, p.User_id, p.DOB, p.Gender, p.Hide_viewing, p.Presence, p.Discoverable)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Finds the visibility settings of a user, returning the defaults if none are stored
+func (s *Store) FindPrivacy(ctx context.Context, uid int) (structure.Privacy, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	p := structure.Privacy{
+		User_id:  uid,
+		DOB:      config.DefaultVisibility,
+		Gender:   config.DefaultVisibility,
+		Presence: config.DefaultVisibility,
+	}
+
+	err := s.queryRow(ctx, GetUserPrivacy, uid).Scan(&p.User_id, &p.DOB, &p.Gender, &p.Hide_viewing, &p.Presence, &p.Discoverable)
+	if err != nil && err != sql.ErrNoRows {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// Finds the email of every user who can be found by people knowing it, by user id
+func (s *Store) FindDiscoverableEmails(ctx context.Context) (map[int]string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, GetDiscoverable)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	emails := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var email string
+		err = rows.Scan(&id, &email)
+		if err != nil {
+			return nil, err
+		}
+		emails[id] = email
+	}
+
+	return emails, rows.Err()
+}
+
+// Checks whether two users are contacts, i.e. have an existing chat with each other
+func (s *Store) AreContacts(ctx context.Context, u1, u2 int) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	chats, err := FindChatsBetween(ctx, u1, u2, s.db)
+	if err != nil {
+		return false, err
+	}
+
+	return len(chats) > 0, nil
+}
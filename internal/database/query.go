@@ -2,51 +2,313 @@ package database
 
 // Insert statements to add data to the database
 const (
-	AddUser    = `INSERT INTO users(username, firstname, surname, gender, email, dob, password) values(?, ?, ?, ?, ?, ?, ?)`
-	AddPost    = `INSERT INTO posts(user_id, category, title, content, date, likes, dislikes) values(?, ?, ?, ?, ?, 0, 0)`
-	AddComment = `INSERT INTO comments(post_id, user_id, content, date) values(?, ?, ?, ?)`
-	AddMessage = `INSERT INTO messages(sender_id, receiver_id, content, date) values(?, ?, ?, ?)`
-	AddLike    = `INSERT INTO liked_posts(post_id, user_id) values(?, ?)`
-	AddDislike = `INSERT INTO disliked_posts(post_id, user_id) values(?, ?)`
-	AddSession = `INSERT INTO sessions(session_uuid, user_id) values(?, ?)`
-	AddChat    = `INSERT INTO chats(id_one, id_two, time) values(? ,?, ?)`
+	AddUser          = `INSERT INTO users(username, firstname, surname, gender, email, dob, password) values(?, ?, ?, ?, ?, ?, ?)`
+	AddPost          = `INSERT INTO posts(user_id, category, title, content, date, likes, dislikes, license) values(?, ?, ?, ?, ?, 0, 0, ?)`
+	AddComment       = `INSERT INTO comments(post_id, user_id, content, date, parent_comment_id) values(?, ?, ?, ?, ?)`
+	AddMessage       = `INSERT INTO messages(sender_id, receiver_id, content, date, seq) values(?, ?, ?, ?, (SELECT COALESCE(MAX(seq), 0) + 1 FROM messages WHERE (sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)))`
+	AddReaction      = `INSERT OR REPLACE INTO reactions(post_id, user_id, kind, date) values(?, ?, ?, ?)`
+	AddSession       = `INSERT INTO sessions(session_uuid, user_id, created_at, last_seen, expires_at, user_agent, ip) values(?, ?, ?, ?, ?, ?, ?)`
+	AddChat          = `INSERT INTO chats(id_one, id_two, time) values(? ,?, ?)`
+	AddPrivacy       = `INSERT OR REPLACE INTO user_privacy(user_id, dob, gender, hide_viewing, presence, discoverable) values(?, ?, ?, ?, ?, ?)`
+	AddPreference    = `INSERT OR REPLACE INTO user_preferences(user_id, display_name) values(?, ?)`
+	AddClientPref    = `INSERT OR REPLACE INTO client_preferences(user_id, key, value) values(?, ?, ?)`
+	AddConvSettings  = `INSERT OR REPLACE INTO conversation_settings(user_id, with_id, typing, receipts) values(?, ?, ?, ?)`
+	AddDraft         = `INSERT OR REPLACE INTO drafts(user_id, receiver_id, content, updated) values(?, ?, ?, ?)`
+	AddRole          = `INSERT OR IGNORE INTO roles(name, builtin) values(?, ?)`
+	AddPermission    = `INSERT OR IGNORE INTO role_permissions(role, permission) values(?, ?)`
+	AddUserRole      = `INSERT OR REPLACE INTO user_roles(user_id, role) values(?, ?)`
+	AddImpersonation = `INSERT INTO impersonations(admin_id, user_id, token, write, reason, started, expires) values(?, ?, ?, ?, ?, ?, ?)`
+	AddAudit         = `INSERT INTO audit_log(actor_id, subject_id, action, detail, date) values(?, ?, ?, ?, ?)`
+	AddDevice        = `INSERT INTO known_devices(user_id, token, created) values(?, ?, ?)`
+	AddOutboxEvent   = `INSERT INTO outbox(topic, payload, created, next_attempt) values(?, ?, ?, ?)`
+	AddDelivery      = `INSERT OR IGNORE INTO outbox_deliveries(event_id, subscriber) values(?, ?)`
+	AddExposure      = `INSERT OR IGNORE INTO experiment_exposures(experiment, variant, user_id, created) values(?, ?, ?, ?)`
+	AddConversion    = `INSERT OR IGNORE INTO experiment_conversions(experiment, variant, user_id, goal, created) values(?, ?, ?, ?, ?)`
+	AddClientError   = `INSERT INTO client_errors(user_id, message, stack, route, app_version, request_id, status, user_agent, created) values(?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	AddBanner        = `INSERT INTO banners(text, severity, audience, starts, ends, user_id, created) values(?, ?, ?, ?, ?, ?, ?)`
+	AddDismissal     = `INSERT OR IGNORE INTO banner_dismissals(banner_id, user_id, created) values(?, ?, ?)`
+	AddRevocation    = `INSERT OR IGNORE INTO revoked_tokens(jti, expires) values(?, ?)`
+	AddTokenCutoff   = `INSERT OR REPLACE INTO token_cutoffs(user_id, cutoff, expires) values(?, ?, ?)`
+	AddBinding       = `INSERT OR REPLACE INTO session_bindings(session_uuid, user_agent, ip_prefix) values(?, ?, ?)`
+	AddLogin         = `INSERT INTO login_history(user_id, ip, user_agent, first_seen, last_seen) values(?, ?, ?, ?, ?) ON CONFLICT(user_id, ip, user_agent) DO UPDATE SET last_seen = excluded.last_seen`
+	AddLoginAlert    = `INSERT INTO login_alerts(token, user_id, created) values(?, ?, ?)`
+	AddReset         = `INSERT OR REPLACE INTO password_resets(user_id, token, created, locked) values(?, ?, ?, 1)`
+	AddVerification  = `INSERT OR REPLACE INTO email_verifications(user_id, token, created) values(?, ?, ?)`
+	AddForgotReset   = `INSERT INTO password_resets(user_id, token, created) values(?, ?, ?) ON CONFLICT(user_id) DO UPDATE SET token = excluded.token, created = excluded.created`
+	AddNotification  = `INSERT INTO notifications(user_id, kind, content, link, date) values(?, ?, ?, ?, ?)`
+	AddVariant       = `INSERT INTO attachment_variants(attachment_id, width, height, path) values(?, ?, ?, ?)`
+	TrashPost        = `INSERT INTO deleted_posts(id, user_id, category, title, content, date, likes, dislikes, comment_count, view_count, license, deleted) SELECT id, user_id, category, title, content, date, likes, dislikes, comment_count, view_count, license, ? FROM posts WHERE id = ? AND user_id = ?`
+	TrashComment     = `INSERT INTO deleted_comments(id, post_id, user_id, content, date, parent_comment_id, deleted) SELECT id, post_id, user_id, content, date, parent_comment_id, ? FROM comments WHERE id = ? AND user_id = ?`
+	TrashMessage     = `INSERT INTO deleted_messages(id, sender_id, receiver_id, content, date, seq, read_at, deleted) SELECT id, sender_id, receiver_id, content, date, seq, read_at, ? FROM messages WHERE id = ? AND sender_id = ?`
+	RestorePost      = `INSERT INTO posts(id, user_id, category, title, content, date, likes, dislikes, comment_count, view_count, license) SELECT id, user_id, category, title, content, date, likes, dislikes, comment_count, view_count, license FROM deleted_posts WHERE id = ? AND user_id = ? AND deleted > ?`
+	RestoreComment   = `INSERT INTO comments(id, post_id, user_id, content, date, parent_comment_id) SELECT id, post_id, user_id, content, date, parent_comment_id FROM deleted_comments WHERE id = ? AND user_id = ? AND deleted > ?`
+	RestoreMessage   = `INSERT INTO messages(id, sender_id, receiver_id, content, date, seq, read_at) SELECT id, sender_id, receiver_id, content, date, seq, read_at FROM deleted_messages WHERE id = ? AND sender_id = ? AND deleted > ?`
+	AddAlertRule     = `INSERT INTO alert_rules(endpoint, metric, threshold, created) values(?, ?, ?, ?)`
+	AddAttachment    = `INSERT INTO attachments(user_id, receiver_id, name, path, mime, size, date, scan_status) SELECT ?, ?, ?, ?, ?, ?, ?, ? WHERE (SELECT COALESCE(SUM(size), 0) FROM attachments WHERE user_id = ?) + ? <= ?`
+	AddCoauthor      = `INSERT OR IGNORE INTO post_coauthors(post_id, user_id, added) values(?, ?, ?)`
+	AddPostLock      = `INSERT INTO post_locks(post_id, user_id, expires) values(?, ?, ?) ON CONFLICT(post_id) DO UPDATE SET user_id = excluded.user_id, expires = excluded.expires WHERE post_locks.user_id = excluded.user_id OR post_locks.expires <= ?`
+	AddRevision      = `INSERT INTO post_revisions(post_id, editor_id, title, content, date) values(?, ?, ?, ?, ?)`
+	AddSetting       = `INSERT OR REPLACE INTO forum_settings(key, value) values(?, ?)`
+	AddAvatar        = `INSERT OR REPLACE INTO user_avatars(user_id, attachment_id) values(?, ?)`
+	AddStreak        = `INSERT OR REPLACE INTO user_streaks(user_id, current, best, last_day, timezone) values(?, ?, ?, ?, ?)`
+	AddPostEvent     = `INSERT INTO post_events(post_id, starts, ends, location, attendees) values(?, ?, ?, ?, ?)`
+	AddRsvp          = `INSERT INTO event_rsvps(post_id, user_id, status, date) values(?, ?, ?, ?) ON CONFLICT(post_id, user_id) DO UPDATE SET status = excluded.status, date = excluded.date`
+	AddCategory      = `INSERT INTO categories(slug, label, position, post_permission, comment_permission) values(?, ?, ?, ?, ?)`
+	AddCrosspost     = `INSERT OR IGNORE INTO post_crossposts(post_id, category) values(?, ?)`
+	AddPostShare     = `INSERT OR IGNORE INTO post_shares(token, post_id, date) values(?, ?, ?)`
+	AddPostViewer    = `INSERT OR IGNORE INTO post_viewers(post_id, user_id) values(?, ?)`
+	AddPostView      = `INSERT INTO post_views(post_id, day, views) values(?, ?, 1) ON CONFLICT(post_id, day) DO UPDATE SET views = views + 1`
+	AddShareClick    = `INSERT INTO post_share_clicks(post_id, day, referrer, clicks) values(?, ?, ?, 1) ON CONFLICT(post_id, day, referrer) DO UPDATE SET clicks = clicks + 1`
+	AddThreadLock    = `INSERT OR REPLACE INTO thread_locks(post_id, moderator_id, reason, date) values(?, ?, ?, ?)`
+	AddBan           = `INSERT OR REPLACE INTO bans(user_id, moderator_id, reason, created, expires) values(?, ?, ?, ?, ?)`
+	AddSeries        = `INSERT INTO series(user_id, title, description, date) values(?, ?, ?, ?)`
+	AddSeriesPost    = `INSERT INTO series_posts(series_id, post_id, position) values(?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM series_posts WHERE series_id = ?))`
+	AddFollower      = `INSERT OR IGNORE INTO series_followers(series_id, user_id, date) values(?, ?, ?)`
+	AddFeedPosition  = `INSERT INTO feed_positions(user_id, post_id, updated) values(?, ?, ?) ON CONFLICT(user_id) DO UPDATE SET post_id = excluded.post_id, updated = excluded.updated WHERE excluded.post_id > feed_positions.post_id`
 )
 
+// Columns of the posts with their like and dislike counts added up from the reactions,
+// grouped by post by the queries using it
+const selectPosts = `SELECT posts.id, posts.user_id, posts.category, posts.title, posts.content, posts.date, COUNT(CASE WHEN reactions.kind = 'like' THEN 1 END), COUNT(CASE WHEN reactions.kind = 'dislike' THEN 1 END), posts.comment_count, posts.view_count, posts.license, COALESCE((SELECT date FROM post_revisions WHERE post_id = posts.id ORDER BY id DESC LIMIT 1), '') FROM posts LEFT JOIN reactions ON reactions.post_id = posts.id`
+
+// Columns of a series with how many users follow it
+const selectSeries = `SELECT id, user_id, title, description, date, (SELECT COUNT(*) FROM series_followers WHERE series_id = series.id) FROM series`
+
 // Query statements to filter data from the database
 const (
-	GetUserById          = `SELECT * FROM users WHERE id = ?`
-	GetUserByUsername    = `SELECT * FROM users WHERE username = ?`
-	GetUserByEmail       = `SELECT * FROM users WHERE email = ?`
-	GetAllUser           = `SELECT * FROM users ORDER BY username ASC`
-	GetPostById          = `SELECT * FROM posts WHERE id = ? ORDER BY id DESC`
-	GetAllPost           = `SELECT * FROM posts ORDER BY id DESC`
-	GetAllPostByCategory = `SELECT * FROM posts WHERE category = ? ORDER BY id DESC`
-	GetAllPostByUser     = `SELECT * FROM posts WHERE user_id = ? ORDER BY id DESC`
-	GetCommentById       = `SELECT * FROM comments WHERE id = ?`
-	GetAllPostComment    = `SELECT * FROM comments WHERE post_id = ?`
-	GetAllUserComment    = `SELECT * FROM comments WHERE user_id = ?`
-	GetMessage           = `SELECT * FROM messages WHERE id = ?`
-	GetAllChatMessage    = `SELECT * FROM messages WHERE ((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)) AND ( id <= ? ) ORDER BY id DESC LIMIT 10`
-	GetLastMessage       = `SELECT * FROM messages WHERE ((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)) ORDER BY id DESC LIMIT 1`
-	GetPostLikes         = `SELECT users.* FROM liked_posts INNER JOIN users ON liked_posts.user_id = users.id WHERE liked_posts.post_id = ?`
-	GetUserLikes         = `SELECT posts.* FROM liked_posts INNER JOIN posts ON liked_posts.post_id = posts.id WHERE liked_posts.user_id = ? ORDER BY id DESC`
-	GetPostDislikes      = `SELECT users.* FROM disliked_posts INNER JOIN users ON disliked_posts.user_id = users.id WHERE disliked_posts.post_id = ?`
-	GetUserDislikes      = `SELECT posts.* FROM disliked_posts INNER JOIN posts ON disliked_posts.post_id = posts.id WHERE disliked_posts.user_id = ? ORDER BY id DESC`
-	GetSessionUser       = `SELECT users.* FROM sessions INNER JOIN users ON sessions.user_id = users.id WHERE sessions.session_uuid = ?`
-	GetUserChats         = `SELECT * FROM chats WHERE id_one = ? OR id_two = ? ORDER BY time DESC`
-	GetChatBetween       = `SELECT * FROM chats WHERE id_one = ? AND id_two = ? OR id_one = ? AND id_two = ?`
+	GetUserById           = `SELECT * FROM users WHERE id = ?`
+	GetUsersByIds         = `SELECT * FROM users WHERE id IN (SELECT value FROM json_each(?))`
+	GetUserByUsername     = `SELECT * FROM users WHERE username = ?`
+	GetUserByEmail        = `SELECT * FROM users WHERE email = ?`
+	GetAllUser            = `SELECT * FROM users ORDER BY username ASC`
+	GetPostById           = selectPosts + ` WHERE posts.id = ? GROUP BY posts.id`
+	GetPostsByIds         = selectPosts + ` WHERE posts.id IN (SELECT value FROM json_each(?)) GROUP BY posts.id`
+	GetAllPost            = selectPosts + ` GROUP BY posts.id ORDER BY posts.id DESC`
+	GetAllPostByCategory  = selectPosts + ` WHERE posts.category = ? OR posts.id IN (SELECT post_id FROM post_crossposts WHERE category = ?) GROUP BY posts.id ORDER BY posts.id DESC`
+	GetAllPostByUser      = selectPosts + ` WHERE posts.user_id = ? GROUP BY posts.id ORDER BY posts.id DESC`
+	GetAllPostAfter       = selectPosts + ` WHERE posts.id > ? GROUP BY posts.id ORDER BY posts.id DESC`
+	GetRecapPosts         = selectPosts + ` WHERE posts.id > ? AND posts.user_id != ? GROUP BY posts.id ORDER BY COUNT(CASE WHEN reactions.kind = 'like' THEN 1 END) + posts.comment_count DESC, posts.id ASC LIMIT ?`
+	GetCommentById        = `SELECT * FROM comments WHERE id = ?`
+	GetCommentsByIds      = `SELECT * FROM comments WHERE id IN (SELECT value FROM json_each(?))`
+	GetAllPostComment     = `SELECT * FROM comments WHERE post_id = ?`
+	GetCommentDepth       = `WITH RECURSIVE thread(id, parent) AS (SELECT id, parent_comment_id FROM comments WHERE id = ? UNION ALL SELECT comments.id, comments.parent_comment_id FROM comments INNER JOIN thread ON comments.id = thread.parent) SELECT COUNT(*) FROM thread`
+	GetAllUserComment     = `SELECT * FROM comments WHERE user_id = ?`
+	GetMessage            = `SELECT * FROM messages WHERE id = ?`
+	GetMessagesByIds      = `SELECT * FROM messages WHERE id IN (SELECT value FROM json_each(?))`
+	GetAllMessageContent  = `SELECT id, content FROM messages`
+	GetAllTrashedContent  = `SELECT id, content FROM deleted_messages`
+	GetMessagesBefore     = `SELECT * FROM messages WHERE ((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)) AND (id < ? OR ? = 0) ORDER BY id DESC LIMIT ?`
+	GetMessagesAfter      = `SELECT * FROM messages WHERE ((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)) AND seq > ? ORDER BY seq ASC LIMIT ?`
+	GetMessageSeq         = `SELECT seq FROM messages WHERE id = ?`
+	GetLastSentSeq        = `SELECT COALESCE(MAX(seq), 0) FROM messages WHERE sender_id = ? AND receiver_id = ?`
+	GetUnreadCounts       = `SELECT sender_id, COUNT(*) FROM messages WHERE receiver_id = ? AND read_at = '' GROUP BY sender_id ORDER BY sender_id ASC`
+	GetConversation       = `SELECT * FROM messages WHERE (sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?) ORDER BY id ASC`
+	GetLastMessage        = `SELECT * FROM messages WHERE ((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)) ORDER BY id DESC LIMIT 1`
+	GetPostReactors       = `SELECT users.* FROM reactions INNER JOIN users ON reactions.user_id = users.id WHERE reactions.post_id = ? AND reactions.kind = ?`
+	GetUserReacted        = selectPosts + ` WHERE posts.id IN (SELECT post_id FROM reactions WHERE user_id = ? AND kind = ?) GROUP BY posts.id ORDER BY posts.id DESC`
+	GetUserReactions      = `SELECT post_id, kind FROM reactions WHERE user_id = ?`
+	GetReaction           = `SELECT kind FROM reactions WHERE post_id = ? AND user_id = ?`
+	GetReactionCounts     = `SELECT COUNT(CASE WHEN kind = 'like' THEN 1 END), COUNT(CASE WHEN kind = 'dislike' THEN 1 END) FROM reactions WHERE post_id = ?`
+	GetSessionUser        = `SELECT users.* FROM sessions INNER JOIN users ON sessions.user_id = users.id WHERE sessions.session_uuid = ? AND sessions.expires_at > ?`
+	GetSessions           = `SELECT rowid, session_uuid, created_at, last_seen, expires_at, user_agent, ip FROM sessions WHERE user_id = ? AND expires_at > ? ORDER BY last_seen DESC`
+	GetUserChats          = `SELECT * FROM chats WHERE id_one = ? OR id_two = ? ORDER BY time DESC`
+	GetChatBetween        = `SELECT * FROM chats WHERE id_one = ? AND id_two = ? OR id_one = ? AND id_two = ?`
+	GetUserPrivacy        = `SELECT * FROM user_privacy WHERE user_id = ?`
+	GetDiscoverable       = `SELECT users.id, users.email FROM users JOIN user_privacy ON users.id = user_privacy.user_id WHERE user_privacy.discoverable = 1`
+	GetUserPreference     = `SELECT * FROM user_preferences WHERE user_id = ?`
+	GetAllDisplayNames    = `SELECT users.id, users.username, users.firstname, users.surname, user_preferences.display_name FROM users LEFT JOIN user_preferences ON users.id = user_preferences.user_id`
+	GetAttachmentById     = `SELECT * FROM attachments WHERE id = ?`
+	GetAllAttachmentPaths = `SELECT path FROM attachments UNION SELECT path FROM attachment_variants`
+	GetUserStorage        = `SELECT COUNT(*), COALESCE(SUM(size), 0) FROM attachments WHERE user_id = ?`
+	GetPostAttachments    = `SELECT * FROM attachments WHERE post_id = ? ORDER BY id ASC`
+	GetAttachmentVariants = `SELECT * FROM attachment_variants WHERE attachment_id = ? ORDER BY width ASC`
+	GetDisplayName        = `SELECT users.id, users.username, users.firstname, users.surname, user_preferences.display_name FROM users LEFT JOIN user_preferences ON users.id = user_preferences.user_id WHERE users.id = ?`
+	GetClientPrefs        = `SELECT key, value FROM client_preferences WHERE user_id = ? ORDER BY key ASC`
+	GetUserRole           = `SELECT role FROM user_roles WHERE user_id = ?`
+	GetRoles              = `SELECT * FROM roles ORDER BY name ASC`
+	GetRole               = `SELECT * FROM roles WHERE name = ?`
+	GetRolePermissions    = `SELECT permission FROM role_permissions WHERE role = ? ORDER BY permission ASC`
+	GetUserPermission     = `SELECT COUNT(*) FROM user_roles INNER JOIN role_permissions ON user_roles.role = role_permissions.role WHERE user_roles.user_id = ? AND role_permissions.permission = ?`
+	GetImpersonation      = `SELECT * FROM impersonations WHERE token = ?`
+	GetConvSettings       = `SELECT typing, receipts FROM conversation_settings WHERE user_id = ? AND with_id = ?`
+	GetDraft              = `SELECT * FROM drafts WHERE user_id = ? AND receiver_id = ?`
+	GetCommentCounts      = `SELECT id, comment_count, (SELECT COUNT(*) FROM comments WHERE post_id = posts.id) FROM posts WHERE comment_count != (SELECT COUNT(*) FROM comments WHERE post_id = posts.id)`
+	GetMissingChats       = `SELECT MIN(sender_id, receiver_id), MAX(sender_id, receiver_id), date, MAX(id) FROM messages m WHERE NOT EXISTS (SELECT 1 FROM chats WHERE (id_one = m.sender_id AND id_two = m.receiver_id) OR (id_one = m.receiver_id AND id_two = m.sender_id)) GROUP BY MIN(sender_id, receiver_id), MAX(sender_id, receiver_id)`
+	GetPendingEvents      = `SELECT * FROM outbox WHERE status = 'pending' AND next_attempt <= ? ORDER BY id ASC LIMIT ?`
+	GetDeliveries         = `SELECT subscriber FROM outbox_deliveries WHERE event_id = ?`
+	GetClientErrors       = `SELECT * FROM client_errors ORDER BY id DESC LIMIT ?`
+	GetExposureCounts     = `SELECT variant, COUNT(*) FROM experiment_exposures WHERE experiment = ? GROUP BY variant`
+	GetConversionCounts   = `SELECT variant, goal, COUNT(*) FROM experiment_conversions WHERE experiment = ? GROUP BY variant, goal`
+	GetBanners            = `SELECT * FROM banners ORDER BY id DESC`
+	GetBanner             = `SELECT * FROM banners WHERE id = ?`
+	GetActiveBanners      = `SELECT * FROM banners WHERE starts <= ? AND (ends = 0 OR ends > ?) AND audience IN (?, ?) AND id NOT IN (SELECT banner_id FROM banner_dismissals WHERE user_id = ?) ORDER BY id ASC`
+	GetRevocations        = `SELECT jti, expires FROM revoked_tokens WHERE expires > ?`
+	GetTokenCutoffs       = `SELECT user_id, cutoff FROM token_cutoffs WHERE expires > ?`
+	GetBinding            = `SELECT user_agent, ip_prefix FROM session_bindings WHERE session_uuid = ?`
+	GetLoginCount         = `SELECT COUNT(*) FROM login_history WHERE user_id = ?`
+	GetLogin              = `SELECT COUNT(*) FROM login_history WHERE user_id = ? AND ip = ? AND user_agent = ?`
+	GetLoginAlert         = `SELECT user_id FROM login_alerts WHERE token = ? AND used = 0 AND created >= ?`
+	GetReset              = `SELECT user_id FROM password_resets WHERE token = ? AND (locked = 1 OR created > ?)`
+	GetVerification       = `SELECT user_id FROM email_verifications WHERE token = ? AND created > ?`
+	GetUserVerification   = `SELECT COUNT(*) FROM email_verifications WHERE user_id = ?`
+	GetConversationExists = `SELECT EXISTS(SELECT 1 FROM messages WHERE (sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?))`
+	GetUserReset          = `SELECT COUNT(*) FROM password_resets WHERE user_id = ? AND locked = 1`
+	GetUnseenCount        = `SELECT COUNT(*) FROM notifications WHERE user_id = ? AND seen = 0`
+	GetNotifications      = `SELECT * FROM notifications WHERE user_id = ? ORDER BY id DESC LIMIT ?`
+	GetNotificationsByIds = `SELECT * FROM notifications WHERE id IN (SELECT value FROM json_each(?))`
+	GetChanges            = `SELECT id, kind, item_id, action FROM changes WHERE id > ? AND (user_id = 0 OR user_id = ? OR with_id = ?) ORDER BY id ASC LIMIT ?`
+	GetChangeBounds       = `SELECT COALESCE((SELECT MIN(id) FROM changes), 0), COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'changes'), 0)`
+	GetNotificationCount  = `SELECT COUNT(*) FROM notifications WHERE user_id = ? AND kind = ? AND content = ? AND link = ?`
+	GetPostCount          = `SELECT COUNT(*) FROM posts WHERE id = ?`
+	GetFeedPosition       = `SELECT post_id FROM feed_positions WHERE user_id = ?`
+	GetStreak             = `SELECT current, best, last_day, timezone FROM user_streaks WHERE user_id = ?`
+	GetPostEvent          = `SELECT post_events.post_id, starts, ends, location, attendees, COUNT(CASE WHEN status = 'going' THEN 1 END), COUNT(CASE WHEN status = 'maybe' THEN 1 END), COUNT(CASE WHEN status = 'no' THEN 1 END) FROM post_events LEFT JOIN event_rsvps ON event_rsvps.post_id = post_events.post_id WHERE post_events.post_id = ? GROUP BY post_events.post_id`
+	GetRsvp               = `SELECT status FROM event_rsvps WHERE post_id = ? AND user_id = ?`
+	GetRsvps              = `SELECT user_id, status, date FROM event_rsvps WHERE post_id = ? ORDER BY rowid ASC`
+	GetEventGuests        = `SELECT user_id FROM event_rsvps WHERE post_id = ? AND status IN ('going', 'maybe')`
+	GetDueEvents          = `SELECT post_events.post_id, posts.title, post_events.starts FROM post_events INNER JOIN posts ON posts.id = post_events.post_id WHERE post_events.reminded = 0 AND post_events.starts > ? AND post_events.starts <= ?`
+	GetRecapMembers       = `SELECT id FROM users WHERE id > ? AND id != ? ORDER BY id ASC`
+	GetRecapCategories    = `SELECT category, COUNT(*) FROM posts WHERE id > ? AND user_id != ? GROUP BY category ORDER BY COUNT(*) DESC, category ASC LIMIT ?`
+	GetLastPostId         = `SELECT COALESCE(MAX(id), 0) FROM posts`
+	GetLastUserId         = `SELECT COALESCE(MAX(id), 0) FROM users`
+	GetNewPostCount       = `SELECT COUNT(*) FROM posts WHERE id > ? AND user_id != ?`
+	GetTrashedPosts       = `SELECT id, title, deleted FROM deleted_posts WHERE user_id = ? AND deleted > ? ORDER BY deleted DESC`
+	GetTrashedComments    = `SELECT id, post_id, content, deleted FROM deleted_comments WHERE user_id = ? AND deleted > ? ORDER BY deleted DESC`
+	GetTrashedMessages    = `SELECT id, receiver_id, content, deleted FROM deleted_messages WHERE sender_id = ? AND deleted > ? ORDER BY deleted DESC`
+	GetTrashedComment     = `SELECT post_id FROM deleted_comments WHERE id = ? AND user_id = ? AND deleted > ?`
+	GetTrashedMessage     = `SELECT receiver_id FROM deleted_messages WHERE id = ? AND sender_id = ? AND deleted > ?`
+	GetAlertRules         = `SELECT * FROM alert_rules ORDER BY id ASC`
+	GetPermissionUsers    = `SELECT user_roles.user_id FROM user_roles INNER JOIN role_permissions ON user_roles.role = role_permissions.role WHERE role_permissions.permission = ?`
+	GetDevice             = `SELECT COUNT(*) FROM known_devices WHERE user_id = ? AND token = ? AND created >= ?`
+	GetCoauthors          = `SELECT user_id FROM post_coauthors WHERE post_id = ? ORDER BY added ASC, user_id ASC`
+	GetCoauthor           = `SELECT COUNT(*) FROM post_coauthors WHERE post_id = ? AND user_id = ?`
+	GetPostLock           = `SELECT user_id, expires FROM post_locks WHERE post_id = ? AND expires > ?`
+	GetRevisions          = `SELECT * FROM post_revisions WHERE post_id = ? ORDER BY id ASC`
+	GetRevisionCount      = `SELECT COUNT(*) FROM post_revisions WHERE post_id = ?`
+	GetSetting            = `SELECT value FROM forum_settings WHERE key = ?`
+	GetCategories         = `SELECT slug, label, position, post_permission, comment_permission FROM categories ORDER BY position ASC, rowid ASC`
+	GetCategory           = `SELECT slug, label, position, post_permission, comment_permission FROM categories WHERE slug = ?`
+	GetCategoryCount      = `SELECT COUNT(*) FROM categories`
+	GetCategoryUse        = `SELECT (SELECT COUNT(*) FROM posts WHERE category = ?) + (SELECT COUNT(*) FROM deleted_posts WHERE category = ?) + (SELECT COUNT(*) FROM post_crossposts WHERE category = ?)`
+	GetCategoryPage       = selectPosts + ` WHERE posts.category = ? OR posts.id IN (SELECT post_id FROM post_crossposts WHERE category = ?) GROUP BY posts.id ORDER BY posts.id DESC LIMIT ? OFFSET ?`
+	GetCrossposts         = `SELECT post_id, category FROM post_crossposts ORDER BY post_id ASC, rowid ASC`
+	GetPostCrossposts     = `SELECT category FROM post_crossposts WHERE post_id = ? ORDER BY rowid ASC`
+	GetPostShare          = `SELECT token FROM post_shares WHERE post_id = ?`
+	GetSharedPost         = `SELECT post_id FROM post_shares WHERE token = ?`
+	GetShareReferrers     = `SELECT referrer, SUM(clicks) FROM post_share_clicks WHERE post_id = ? GROUP BY referrer ORDER BY SUM(clicks) DESC, referrer ASC`
+	GetShareDays          = `SELECT day, SUM(clicks) FROM post_share_clicks WHERE post_id = ? GROUP BY day ORDER BY day ASC`
+	GetThreadLock         = `SELECT * FROM thread_locks WHERE post_id = ?`
+	GetBan                = `SELECT * FROM bans WHERE user_id = ? AND (expires = 0 OR expires > ?)`
+	GetBans               = `SELECT * FROM bans WHERE expires = 0 OR expires > ? ORDER BY created DESC`
+	GetAuthorViews        = `SELECT post_views.post_id, post_views.day, post_views.views FROM post_views INNER JOIN posts ON posts.id = post_views.post_id WHERE posts.user_id = ? AND post_views.day >= ?`
+	GetAuthorReactions    = `SELECT reactions.post_id, reactions.date FROM reactions INNER JOIN posts ON posts.id = reactions.post_id WHERE posts.user_id = ?`
+	GetAuthorComments     = `SELECT comments.post_id, comments.date FROM comments INNER JOIN posts ON posts.id = comments.post_id WHERE posts.user_id = ?`
+	GetAuthorFollowers    = `SELECT series_followers.user_id, series_followers.date FROM series_followers INNER JOIN series ON series.id = series_followers.series_id WHERE series.user_id = ?`
+	GetSeries             = selectSeries + ` WHERE id = ?`
+	GetAllSeries          = selectSeries + ` ORDER BY id DESC`
+	GetUserSeries         = selectSeries + ` WHERE user_id = ? ORDER BY id DESC`
+	GetSeriesPosts        = selectPosts + ` INNER JOIN series_posts ON series_posts.post_id = posts.id WHERE series_posts.series_id = ? GROUP BY posts.id ORDER BY series_posts.position ASC`
+	GetSeriesParts        = `SELECT posts.id, posts.title FROM series_posts INNER JOIN posts ON posts.id = series_posts.post_id WHERE series_posts.series_id = ? ORDER BY series_posts.position ASC`
+	GetPostSeries         = `SELECT series.id, series.title FROM series_posts INNER JOIN series ON series.id = series_posts.series_id WHERE series_posts.post_id = ?`
+	GetFollowers          = `SELECT user_id FROM series_followers WHERE series_id = ? ORDER BY user_id ASC`
+	GetFollower           = `SELECT COUNT(*) FROM series_followers WHERE series_id = ? AND user_id = ?`
+	GetAvatar             = `SELECT attachments.* FROM user_avatars INNER JOIN attachments ON attachments.id = user_avatars.attachment_id WHERE user_avatars.user_id = ?`
+	SearchUser            = `SELECT users.id, users.username, users.firstname, users.surname, user_preferences.display_name FROM users LEFT JOIN user_preferences ON users.id = user_preferences.user_id WHERE users.username LIKE ? ESCAPE '\' OR (user_preferences.display_name = ? AND users.firstname || ' ' || users.surname LIKE ? ESCAPE '\') ORDER BY users.username ASC LIMIT ? OFFSET ?`
 )
 
 // Query statements to remove data from database
 const (
-	RemoveCookie  = `DELETE FROM sessions WHERE user_id = ?`
-	RemoveLike    = `DELETE FROM liked_posts WHERE post_id = ? AND user_id = ?`
-	RemoveDislike = `DELETE FROM disliked_posts WHERE post_id = ? AND user_id = ?`
+	RemoveCookie      = `DELETE FROM sessions WHERE user_id = ?`
+	RemoveReaction    = `DELETE FROM reactions WHERE post_id = ? AND user_id = ?`
+	RemoveVariants    = `DELETE FROM attachment_variants WHERE attachment_id = ?`
+	RemoveAttachment  = `DELETE FROM attachments WHERE id = ?`
+	RemoveAvatar      = `DELETE FROM user_avatars WHERE user_id = ?`
+	RemoveAvatarFile  = `DELETE FROM user_avatars WHERE attachment_id = ?`
+	RemoveClientPref  = `DELETE FROM client_preferences WHERE user_id = ? AND key = ?`
+	RemovePermissions = `DELETE FROM role_permissions WHERE role = ?`
+	RemoveRole        = `DELETE FROM roles WHERE name = ? AND builtin = 0`
+	RemoveRoleUsers   = `DELETE FROM user_roles WHERE role = ?`
+	RemoveUserRole    = `DELETE FROM user_roles WHERE user_id = ?`
+	RemoveSession     = `DELETE FROM sessions WHERE session_uuid = ?`
+	RemoveOldEvents   = `DELETE FROM outbox WHERE status = 'delivered' AND created < ?`
+	RemoveDeliveries  = `DELETE FROM outbox_deliveries WHERE event_id = ?`
+	RemoveOldChanges  = `DELETE FROM changes WHERE date < ?`
+	RemoveOldErrors   = `DELETE FROM client_errors WHERE created < ?`
+	RemoveBanner      = `DELETE FROM banners WHERE id = ?`
+	RemoveDismissals  = `DELETE FROM banner_dismissals WHERE banner_id = ?`
+	RemoveRevocations = `DELETE FROM revoked_tokens WHERE expires <= ?`
+	RemoveCutoffs     = `DELETE FROM token_cutoffs WHERE expires <= ?`
+	RemoveBinding     = `DELETE FROM session_bindings WHERE session_uuid = ?`
+	RemoveCategory    = `DELETE FROM categories WHERE slug = ?`
+	RemoveCrosspost   = `DELETE FROM post_crossposts WHERE post_id = ? AND category = ?`
+	RemoveSeriesPost  = `DELETE FROM series_posts WHERE series_id = ? AND post_id = ?`
+	RemoveFollower    = `DELETE FROM series_followers WHERE series_id = ? AND user_id = ?`
+	RemoveRsvp        = `DELETE FROM event_rsvps WHERE post_id = ? AND user_id = ?`
+	RemoveOldSessions = `DELETE FROM sessions WHERE expires_at <= ?`
+	RemoveOldBindings = `DELETE FROM session_bindings WHERE session_uuid NOT IN (SELECT session_uuid FROM sessions)`
+	RemoveDraft       = `DELETE FROM drafts WHERE user_id = ? AND receiver_id = ?`
+	RemoveOldDrafts   = `DELETE FROM drafts WHERE updated < ?`
+	RemoveDevices     = `DELETE FROM known_devices WHERE user_id = ?`
+	RemoveVerified    = `DELETE FROM email_verifications WHERE user_id = ?`
+	RemovePost        = `DELETE FROM posts WHERE id = ?`
+	RemoveComment     = `DELETE FROM comments WHERE id = ?`
+	RemoveMessage     = `DELETE FROM messages WHERE id = ?`
+	UntrashPost       = `DELETE FROM deleted_posts WHERE id = ?`
+	UntrashComment    = `DELETE FROM deleted_comments WHERE id = ?`
+	UntrashMessage    = `DELETE FROM deleted_messages WHERE id = ?`
+	PurgePostVariants = `DELETE FROM attachment_variants WHERE attachment_id IN (SELECT id FROM attachments WHERE post_id IN (SELECT id FROM deleted_posts WHERE deleted <= ?))`
+	PurgePostImages   = `DELETE FROM attachments WHERE post_id IN (SELECT id FROM deleted_posts WHERE deleted <= ?)`
+	PurgeReactions    = `DELETE FROM reactions WHERE post_id IN (SELECT id FROM deleted_posts WHERE deleted <= ?)`
+	PurgePostComments = `DELETE FROM comments WHERE post_id IN (SELECT id FROM deleted_posts WHERE deleted <= ?)`
+	PurgeOrphanTrash  = `DELETE FROM deleted_comments WHERE post_id IN (SELECT id FROM deleted_posts WHERE deleted <= ?)`
+	PurgePosts        = `DELETE FROM deleted_posts WHERE deleted <= ?`
+	PurgeComments     = `DELETE FROM deleted_comments WHERE deleted <= ?`
+	PurgeMessages     = `DELETE FROM deleted_messages WHERE deleted <= ?`
+	RemoveAlertRule   = `DELETE FROM alert_rules WHERE id = ?`
+	RemoveReset       = `DELETE FROM password_resets WHERE user_id = ?`
+	RemoveCoauthor    = `DELETE FROM post_coauthors WHERE post_id = ? AND user_id = ?`
+	RemovePostLock    = `DELETE FROM post_locks WHERE post_id = ? AND user_id = ?`
+	PurgeCoauthors    = `DELETE FROM post_coauthors WHERE post_id IN (SELECT id FROM deleted_posts WHERE deleted <= ?)`
+	PurgeRevisions    = `DELETE FROM post_revisions WHERE post_id IN (SELECT id FROM deleted_posts WHERE deleted <= ?)`
+	PurgePostLocks    = `DELETE FROM post_locks WHERE post_id IN (SELECT id FROM deleted_posts WHERE deleted <= ?)`
+	PurgeEvents       = `DELETE FROM post_events WHERE post_id IN (SELECT id FROM deleted_posts WHERE deleted <= ?)`
+	PurgeRsvps        = `DELETE FROM event_rsvps WHERE post_id IN (SELECT id FROM deleted_posts WHERE deleted <= ?)`
+	PurgeCrossposts   = `DELETE FROM post_crossposts WHERE post_id IN (SELECT id FROM deleted_posts WHERE deleted <= ?)`
+	PurgeSeriesPosts  = `DELETE FROM series_posts WHERE post_id IN (SELECT id FROM deleted_posts WHERE deleted <= ?)`
+	PurgePostViews    = `DELETE FROM post_views WHERE post_id IN (SELECT id FROM deleted_posts WHERE deleted <= ?)`
+	PurgePostViewers  = `DELETE FROM post_viewers WHERE post_id IN (SELECT id FROM deleted_posts WHERE deleted <= ?)`
+	PurgeShares       = `DELETE FROM post_shares WHERE post_id IN (SELECT id FROM deleted_posts WHERE deleted <= ?)`
+	PurgeShareClicks  = `DELETE FROM post_share_clicks WHERE post_id IN (SELECT id FROM deleted_posts WHERE deleted <= ?)`
+	PurgeThreadLocks  = `DELETE FROM thread_locks WHERE post_id IN (SELECT id FROM deleted_posts WHERE deleted <= ?)`
+	RemoveThreadLock  = `DELETE FROM thread_locks WHERE post_id = ?`
+	RemoveBan         = `DELETE FROM bans WHERE user_id = ?`
 )
 
 // Query statements to update data in database
 const (
-	UpdateLike    = `UPDATE posts SET likes = ? WHERE id = ?`
-	UpdateDislike = `UPDATE posts SET dislikes = ? WHERE id = ?`
-	UpdateChat    = `UPDATE chats SET time = ? WHERE id_one = ? AND id_two = ?`
+	UpdateCommentCount     = `UPDATE posts SET comment_count = comment_count + 1 WHERE id = ?`
+	UpdateCommentRemoved   = `UPDATE posts SET comment_count = comment_count - 1 WHERE id = ? AND comment_count > 0`
+	UpdateViewCount        = `UPDATE posts SET view_count = view_count + 1 WHERE id = ?`
+	UpdateUserProfile      = `UPDATE users SET gender = ?, dob = ? WHERE id = ?`
+	UpdatePostEvent        = `UPDATE post_events SET reminded = CASE WHEN starts = ? THEN reminded ELSE 0 END, starts = ?, ends = ?, location = ?, attendees = ? WHERE post_id = ?`
+	UpdateEventReminded    = `UPDATE post_events SET reminded = 1 WHERE post_id = ?`
+	UpdateSession          = `UPDATE sessions SET last_seen = ?, expires_at = ? WHERE session_uuid = ? AND expires_at > ? AND last_seen <= ?`
+	UpdateChat             = `UPDATE chats SET time = ? WHERE id_one = ? AND id_two = ?`
+	UpdateScan             = `UPDATE attachments SET scan_status = ?, path = ? WHERE id = ?`
+	UpdateImage            = `UPDATE attachments SET width = ?, height = ?, placeholder = ? WHERE id = ?`
+	LinkPostImage          = `UPDATE attachments SET post_id = ? WHERE id = ? AND user_id = ? AND receiver_id = 0 AND post_id = 0`
+	UpdateImpersonationEnd = `UPDATE impersonations SET ended = ? WHERE token = ? AND ended = 0`
+	UpdateEventDelivered   = `UPDATE outbox SET status = 'delivered', attempts = attempts + 1 WHERE id = ?`
+	UpdateEventFailed      = `UPDATE outbox SET status = ?, attempts = ?, next_attempt = ?, last_error = ? WHERE id = ?`
+	UpdateMessageContent   = `UPDATE messages SET content = ? WHERE id = ?`
+	UpdateTrashedContent   = `UPDATE deleted_messages SET content = ? WHERE id = ?`
+	UpdateMessagesRead     = `UPDATE messages SET read_at = ? WHERE sender_id = ? AND receiver_id = ? AND seq <= ? AND read_at = ''`
+	UpdateLoginAlertUsed   = `UPDATE login_alerts SET used = ? WHERE token = ?`
+	UpdatePassword         = `UPDATE users SET password = ? WHERE id = ?`
+	UpdateNotificationSeen = `UPDATE notifications SET seen = 1 WHERE user_id = ?`
+	UpdateNotificationRead = `UPDATE notifications SET seen = 1 WHERE id = ? AND user_id = ?`
+	UpdatePost             = `UPDATE posts SET title = ?, content = ?, license = ? WHERE id = ?`
+	UpdateCategory         = `UPDATE categories SET label = ?, position = ?, post_permission = ?, comment_permission = ? WHERE slug = ?`
+	UpdatePostLock         = `UPDATE post_locks SET expires = ? WHERE post_id = ? AND user_id = ? AND expires > ?`
+	UpdateBanner           = `UPDATE banners SET text = ?, severity = ?, audience = ?, starts = ?, ends = ? WHERE id = ?`
 )
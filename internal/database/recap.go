@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"strconv"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Finds the account the forum posts as, creating it the first time. It has no password,
+// so no one can log in as it.
+func (s *Store) FindSystemUser(ctx context.Context) (structure.User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	id, err := s.FindSetting(ctx, config.SettingSystemUser)
+	if err != nil {
+		return structure.User{}, err
+	}
+
+	if id == "" {
+		err = s.NewUser(ctx, structure.User{
+			Username:  config.SystemUsername,
+			Firstname: config.SystemUsername,
+			Email:     config.SystemEmail,
+			Password:  "-",
+		})
+		if err != nil {
+			return structure.User{}, err
+		}
+
+		u, err := s.FindUserByParam(ctx, "username", config.SystemUsername)
+		if err != nil {
+			return structure.User{}, err
+		}
+
+		return u, s.SaveSetting(ctx, config.SettingSystemUser, strconv.Itoa(u.Id))
+	}
+
+	return s.FindUserByParam(ctx, "id", id)
+}
+
+// Finds the posts, members and categories active after the given post and user, leaving
+// out what the system user did itself
+func (s *Store) FindRecap(ctx context.Context, afterPost, afterUser, system int) (structure.Recap, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var recap structure.Recap
+
+	err := s.queryRow(ctx, GetLastPostId).Scan(&recap.Last_post_id)
+	if err != nil {
+		return recap, err
+	}
+
+	err = s.queryRow(ctx, GetLastUserId).Scan(&recap.Last_user_id)
+	if err != nil {
+		return recap, err
+	}
+
+	rows, err := s.query(ctx, GetRecapPosts, afterPost, system, config.RecapTopPosts)
+	if err != nil {
+		return recap, err
+	}
+
+	recap.Top_posts, err = ConvertRowToPost(rows)
+	if err != nil {
+		return recap, err
+	}
+
+	rows, err = s.query(ctx, GetRecapMembers, afterUser, system)
+	if err != nil {
+		return recap, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var uid int
+		err = rows.Scan(&uid)
+		if err != nil {
+			return recap, err
+		}
+
+		recap.Member_ids = append(recap.Member_ids, uid)
+	}
+
+	rows, err = s.query(ctx, GetRecapCategories, afterPost, system, config.RecapCategories)
+	if err != nil {
+		return recap, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var c structure.CategoryActivity
+		err = rows.Scan(&c.Name, &c.Posts)
+		if err != nil {
+			return recap, err
+		}
+
+		recap.Categories = append(recap.Categories, c)
+	}
+
+	return recap, rows.Err()
+}
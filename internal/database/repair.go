@@ -0,0 +1,133 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Repair finds stored values that no longer match the rows they are derived from,
+// for example after a crash or a manual edit of the database, and fixes them unless
+// dryRun is set. Every discrepancy found is returned. It goes over whole tables, so it
+// isn't bounded by the query timeout.
+func (s *Store) Repair(ctx context.Context, dryRun bool) ([]structure.Discrepancy, error) {
+	var found []structure.Discrepancy
+
+	//Comment counters of posts
+	for _, c := range []struct {
+		check, query, column string
+	}{
+		{"post comments", GetCommentCounts, "comment_count"},
+	} {
+		counts, err := wrongCounts(ctx, s.db, c.check, c.query)
+		if err != nil {
+			return found, err
+		}
+		found = append(found, counts...)
+
+		if dryRun {
+			continue
+		}
+
+		for _, d := range counts {
+			_, err = s.exec(ctx, `UPDATE posts SET `+c.column+` = ? WHERE id = ?`, d.Actual, d.Row)
+			if err != nil {
+				return found, err
+			}
+		}
+	}
+
+	//Every conversation needs a chat row for the user list to be ordered by it
+	chats, err := missingChats(ctx, s.db)
+	if err != nil {
+		return found, err
+	}
+
+	for _, c := range chats {
+		found = append(found, structure.Discrepancy{
+			Check:  "missing chat",
+			Row:    strconv.Itoa(c.User_one) + "-" + strconv.Itoa(c.User_two),
+			Stored: "none",
+			Actual: strconv.Itoa(c.Time),
+		})
+
+		if dryRun {
+			continue
+		}
+
+		_, err = s.exec(ctx, AddChat, c.User_one, c.User_two, c.Time)
+		if err != nil {
+			return found, err
+		}
+	}
+
+	return found, nil
+}
+
+// Finds the posts whose counter differs from the count of the rows it counts
+func wrongCounts(ctx context.Context, db *sql.DB, check, query string) ([]structure.Discrepancy, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var found []structure.Discrepancy
+	for rows.Next() {
+		var id, stored, actual int
+
+		err := rows.Scan(&id, &stored, &actual)
+		if err != nil {
+			return nil, err
+		}
+
+		found = append(found, structure.Discrepancy{
+			Check:  check,
+			Row:    strconv.Itoa(id),
+			Stored: strconv.Itoa(stored),
+			Actual: strconv.Itoa(actual),
+		})
+	}
+
+	return found, rows.Err()
+}
+
+// Finds the conversations without a chat row, timed at their last message
+func missingChats(ctx context.Context, db *sql.DB) ([]structure.Chat, error) {
+	rows, err := db.QueryContext(ctx, GetMissingChats)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var chats []structure.Chat
+	for rows.Next() {
+		var c structure.Chat
+		var date string
+		var last int
+
+		//The date is the one of the last message, picked by MAX(id)
+		err := rows.Scan(&c.User_one, &c.User_two, &date, &last)
+		if err != nil {
+			return nil, err
+		}
+
+		//Dates are stored in the server's local time
+		t, err := time.ParseInLocation(config.TimeFormat, date, time.Local)
+		if err != nil {
+			t = clock.Now()
+		}
+		c.Time = int(t.UnixMilli())
+
+		chats = append(chats, c)
+	}
+
+	return chats, rows.Err()
+}
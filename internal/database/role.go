@@ -0,0 +1,227 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Finds the role of a user, an empty string if they have none
+func (s *Store) FindRole(ctx context.Context, uid int) (string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var role string
+	err := s.queryRow(ctx, GetUserRole, uid).Scan(&role)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	return role, nil
+}
+
+// Checks whether the role of a user grants the permission
+func (s *Store) HasPermission(ctx context.Context, uid int, permission string) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := s.queryRow(ctx, GetUserPermission, uid, permission).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// Finds the role of a user along with the permissions it grants
+func (s *Store) FindUserPermissions(ctx context.Context, uid int) (string, []string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var role string
+	err := s.queryRow(ctx, GetUserRole, uid).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", []string{}, nil
+	} else if err != nil {
+		return "", []string{}, err
+	}
+
+	perms, err := rolePermissions(ctx, s.db, role)
+	return role, perms, err
+}
+
+// Creates the built-in roles, the admin role with every permission and the moderator role
+// with the moderation ones
+func (s *Store) SeedRoles(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	builtin := map[string][]string{
+		config.RoleAdmin:     config.Permissions,
+		config.RoleModerator: config.ModeratorPermissions,
+	}
+
+	for role, perms := range builtin {
+		_, err := s.exec(ctx, AddRole, role, true)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range perms {
+			_, err = s.exec(ctx, AddPermission, role, p)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Finds every role with its permissions
+func (s *Store) FindRoles(ctx context.Context) ([]structure.Role, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	roles := []structure.Role{}
+
+	rows, err := s.query(ctx, GetRoles)
+	if err != nil {
+		return roles, err
+	}
+
+	//Loops through the rows provided
+	for rows.Next() {
+		var r structure.Role
+
+		err := rows.Scan(&r.Name, &r.Builtin)
+		if err != nil {
+			break
+		}
+
+		roles = append(roles, r)
+	}
+	rows.Close()
+
+	for i := range roles {
+		roles[i].Permissions, err = rolePermissions(ctx, s.db, roles[i].Name)
+		if err != nil {
+			return roles, err
+		}
+	}
+
+	return roles, nil
+}
+
+// Finds a role with its permissions
+func (s *Store) FindRoleByName(ctx context.Context, name string) (structure.Role, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var r structure.Role
+
+	err := s.queryRow(ctx, GetRole, name).Scan(&r.Name, &r.Builtin)
+	if err != nil {
+		return r, err
+	}
+
+	r.Permissions, err = rolePermissions(ctx, s.db, name)
+	return r, err
+}
+
+// Finds the permissions granted to a role
+func rolePermissions(ctx context.Context, db *sql.DB, role string) ([]string, error) {
+	perms := []string{}
+
+	rows, err := db.QueryContext(ctx, GetRolePermissions, role)
+	if err != nil {
+		return perms, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var p string
+
+		err := rows.Scan(&p)
+		if err != nil {
+			break
+		}
+
+		perms = append(perms, p)
+	}
+
+	return perms, nil
+}
+
+// Creates a custom role or replaces the permissions of an existing one
+func (s *Store) UpdateRole(ctx context.Context, r structure.Role) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, AddRole, r.Name, false)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, RemovePermissions, r.Name)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, p := range r.Permissions {
+		_, err = tx.ExecContext(ctx, AddPermission, r.Name, p)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Removes a custom role, taking it away from every user who had it
+func (s *Store) DeleteRole(ctx context.Context, name string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, q := range []string{RemoveRoleUsers, RemovePermissions, RemoveRole} {
+		_, err = tx.ExecContext(ctx, q, name)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Gives a role to a user, an empty role takes their role away
+func (s *Store) UpdateUserRole(ctx context.Context, uid int, role string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var err error
+	if role == "" {
+		_, err = s.exec(ctx, RemoveUserRole, uid)
+	} else {
+		_, err = s.exec(ctx, AddUserRole, uid, role)
+	}
+
+	return err
+}
@@ -1,5 +1,11 @@
 package database
 
+import (
+	"strconv"
+
+	"real-time-forum/internal/config"
+)
+
 //SQL statement to initialise the database tables
 const (
 	CreateTables = `
@@ -10,13 +16,166 @@ const (
 		surname VARCHAR(64) NOT NULL,
 		gender VARCHAR(64) NULL,
 		email VARCHAR(64) NOT NULL UNIQUE,
-		dob TEXT NULL,
+		dob DATE NULL,
 		password VARCHAR(255) NOT NULL
 	);
 
+	CREATE TABLE IF NOT EXISTS user_privacy (
+		user_id INTEGER NOT NULL UNIQUE,
+		dob VARCHAR(16) NOT NULL,
+		gender VARCHAR(16) NOT NULL,
+		hide_viewing BOOLEAN NOT NULL DEFAULT 0,
+		presence VARCHAR(16) NOT NULL DEFAULT 'public',
+		discoverable BOOLEAN NOT NULL DEFAULT 0,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS user_preferences (
+		user_id INTEGER NOT NULL UNIQUE,
+		display_name VARCHAR(16) NOT NULL,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS client_preferences (
+		user_id INTEGER NOT NULL,
+		key VARCHAR(64) NOT NULL,
+		value TEXT NOT NULL,
+		UNIQUE(user_id, key),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
 	CREATE TABLE IF NOT EXISTS sessions (
 		session_uuid VARCHAR(255) NOT NULL UNIQUE,
 		user_id INTEGER NOT NULL,
+		created_at INTEGER NOT NULL DEFAULT 0,
+		last_seen INTEGER NOT NULL DEFAULT 0,
+		expires_at INTEGER NOT NULL DEFAULT 0,
+		user_agent TEXT NOT NULL DEFAULT '',
+		ip VARCHAR(64) NOT NULL DEFAULT '',
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS user_roles (
+		user_id INTEGER NOT NULL UNIQUE,
+		role VARCHAR(32) NOT NULL,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS roles (
+		name VARCHAR(32) NOT NULL UNIQUE,
+		builtin BOOLEAN NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS role_permissions (
+		role VARCHAR(32) NOT NULL,
+		permission VARCHAR(64) NOT NULL,
+		UNIQUE(role, permission),
+		FOREIGN KEY(role) REFERENCES roles(name)
+	);
+
+	CREATE TABLE IF NOT EXISTS impersonations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		admin_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		token VARCHAR(255) NOT NULL UNIQUE,
+		write BOOLEAN NOT NULL,
+		reason TEXT NOT NULL,
+		started INTEGER NOT NULL,
+		expires INTEGER NOT NULL,
+		ended INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY(admin_id) REFERENCES users(id),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor_id INTEGER NOT NULL,
+		subject_id INTEGER NOT NULL,
+		action VARCHAR(64) NOT NULL,
+		detail TEXT NOT NULL,
+		date TEXT NOT NULL,
+		FOREIGN KEY(actor_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS outbox (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		topic VARCHAR(64) NOT NULL,
+		payload TEXT NOT NULL,
+		created INTEGER NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt INTEGER NOT NULL,
+		status VARCHAR(16) NOT NULL DEFAULT 'pending',
+		last_error TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS revoked_tokens (
+		jti VARCHAR(64) NOT NULL UNIQUE,
+		expires INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS session_bindings (
+		session_uuid VARCHAR(255) NOT NULL UNIQUE,
+		user_agent VARCHAR(64) NOT NULL,
+		ip_prefix VARCHAR(64) NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS login_history (
+		user_id INTEGER NOT NULL,
+		ip VARCHAR(64) NOT NULL,
+		user_agent TEXT NOT NULL,
+		first_seen INTEGER NOT NULL,
+		last_seen INTEGER NOT NULL,
+		UNIQUE(user_id, ip, user_agent),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS login_alerts (
+		token VARCHAR(255) NOT NULL UNIQUE,
+		user_id INTEGER NOT NULL,
+		created INTEGER NOT NULL,
+		used INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS alert_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		endpoint VARCHAR(255) NOT NULL,
+		metric VARCHAR(32) NOT NULL,
+		threshold REAL NOT NULL,
+		created TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS email_verifications (
+		user_id INTEGER NOT NULL UNIQUE,
+		token VARCHAR(255) NOT NULL UNIQUE,
+		created INTEGER NOT NULL,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS password_resets (
+		user_id INTEGER NOT NULL UNIQUE,
+		token VARCHAR(255) NOT NULL UNIQUE,
+		created INTEGER NOT NULL,
+		locked INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS notifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		kind VARCHAR(32) NOT NULL,
+		content TEXT NOT NULL,
+		link TEXT NOT NULL,
+		date TEXT NOT NULL,
+		seen BOOLEAN NOT NULL DEFAULT 0,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS known_devices (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		token VARCHAR(255) NOT NULL UNIQUE,
+		created INTEGER NOT NULL,
 		FOREIGN KEY(user_id) REFERENCES users(id)
 	);
 
@@ -29,6 +188,9 @@ const (
 		date TEXT NOT NULL,
 		likes INTEGER NOT NULL,
 		dislikes INTEGER NOT NULL,
+		comment_count INTEGER NOT NULL DEFAULT 0,
+		view_count INTEGER NOT NULL DEFAULT 0,
+		license VARCHAR(64) NOT NULL DEFAULT '',
 		FOREIGN KEY(user_id) REFERENCES users(id)
 	);
 
@@ -38,6 +200,7 @@ const (
 		user_id INTEGER NOT NULL,
 		content TEXT NOT NULL,
 		date TEXT NOT NULL,
+		parent_comment_id INTEGER NOT NULL DEFAULT 0,
 		FOREIGN KEY(post_id) REFERENCES posts(id),
 		FOREIGN KEY(user_id) REFERENCES users(id)
 	);
@@ -48,10 +211,250 @@ const (
 		receiver_id INTEGER NOT NULL,
 		content TEXT NOT NULL,
 		date TEXT NOT NULL,
+		seq INTEGER NOT NULL DEFAULT 0,
+		read_at TEXT NOT NULL DEFAULT '',
 		FOREIGN KEY(sender_id) REFERENCES users(id),
 		FOREIGN KEY(receiver_id) REFERENCES users(id)
 	);
 
+	CREATE TABLE IF NOT EXISTS deleted_posts (
+		id INTEGER PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		category VARCHAR(64),
+		title TEXT NOT NULL,
+		content TEXT NOT NULL,
+		date TEXT NOT NULL,
+		likes INTEGER NOT NULL,
+		dislikes INTEGER NOT NULL,
+		comment_count INTEGER NOT NULL,
+		view_count INTEGER NOT NULL,
+		deleted INTEGER NOT NULL,
+		license VARCHAR(64) NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS deleted_comments (
+		id INTEGER PRIMARY KEY,
+		post_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		date TEXT NOT NULL,
+		deleted INTEGER NOT NULL,
+		parent_comment_id INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS forum_settings (
+		key VARCHAR(64) PRIMARY KEY,
+		value TEXT NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS user_streaks (
+		user_id INTEGER PRIMARY KEY,
+		current INTEGER NOT NULL,
+		best INTEGER NOT NULL,
+		last_day VARCHAR(10) NOT NULL,
+		timezone VARCHAR(64) NOT NULL,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS feed_positions (
+		user_id INTEGER PRIMARY KEY,
+		post_id INTEGER NOT NULL,
+		updated TEXT NOT NULL,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS user_avatars (
+		user_id INTEGER PRIMARY KEY,
+		attachment_id INTEGER NOT NULL,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS post_coauthors (
+		post_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		added TEXT NOT NULL,
+		UNIQUE(post_id, user_id),
+		FOREIGN KEY(post_id) REFERENCES posts(id),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS post_locks (
+		post_id INTEGER PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		expires INTEGER NOT NULL,
+		FOREIGN KEY(post_id) REFERENCES posts(id),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS categories (
+		slug VARCHAR(64) PRIMARY KEY,
+		label VARCHAR(64) NOT NULL,
+		position INTEGER NOT NULL DEFAULT 0,
+		post_permission VARCHAR(64) NOT NULL DEFAULT '',
+		comment_permission VARCHAR(64) NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS post_crossposts (
+		post_id INTEGER NOT NULL,
+		category VARCHAR(64) NOT NULL,
+		UNIQUE(post_id, category),
+		FOREIGN KEY(post_id) REFERENCES posts(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS post_views (
+		post_id INTEGER NOT NULL,
+		day TEXT NOT NULL,
+		views INTEGER NOT NULL,
+		UNIQUE(post_id, day),
+		FOREIGN KEY(post_id) REFERENCES posts(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS post_shares (
+		token VARCHAR(16) PRIMARY KEY,
+		post_id INTEGER NOT NULL UNIQUE,
+		date TEXT NOT NULL,
+		FOREIGN KEY(post_id) REFERENCES posts(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS post_share_clicks (
+		post_id INTEGER NOT NULL,
+		day TEXT NOT NULL,
+		referrer VARCHAR(255) NOT NULL,
+		clicks INTEGER NOT NULL,
+		UNIQUE(post_id, day, referrer),
+		FOREIGN KEY(post_id) REFERENCES posts(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS thread_locks (
+		post_id INTEGER NOT NULL UNIQUE,
+		moderator_id INTEGER NOT NULL,
+		reason TEXT NOT NULL,
+		date TEXT NOT NULL,
+		FOREIGN KEY(post_id) REFERENCES posts(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS bans (
+		user_id INTEGER NOT NULL UNIQUE,
+		moderator_id INTEGER NOT NULL,
+		reason TEXT NOT NULL,
+		created INTEGER NOT NULL,
+		expires INTEGER NOT NULL,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS series (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		title VARCHAR(128) NOT NULL,
+		description TEXT NOT NULL,
+		date TEXT NOT NULL,
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS series_posts (
+		series_id INTEGER NOT NULL,
+		post_id INTEGER NOT NULL UNIQUE,
+		position INTEGER NOT NULL,
+		FOREIGN KEY(series_id) REFERENCES series(id),
+		FOREIGN KEY(post_id) REFERENCES posts(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS series_followers (
+		series_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		date TEXT NOT NULL,
+		UNIQUE(series_id, user_id),
+		FOREIGN KEY(series_id) REFERENCES series(id),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS post_events (
+		post_id INTEGER PRIMARY KEY,
+		starts INTEGER NOT NULL,
+		ends INTEGER NOT NULL,
+		location TEXT NOT NULL,
+		attendees VARCHAR(16) NOT NULL,
+		reminded INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY(post_id) REFERENCES posts(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS event_rsvps (
+		post_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		status VARCHAR(8) NOT NULL,
+		date TEXT NOT NULL,
+		UNIQUE(post_id, user_id),
+		FOREIGN KEY(post_id) REFERENCES posts(id),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS post_revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		post_id INTEGER NOT NULL,
+		editor_id INTEGER NOT NULL,
+		title TEXT NOT NULL,
+		content TEXT NOT NULL,
+		date TEXT NOT NULL,
+		FOREIGN KEY(post_id) REFERENCES posts(id),
+		FOREIGN KEY(editor_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS deleted_messages (
+		id INTEGER PRIMARY KEY,
+		sender_id INTEGER NOT NULL,
+		receiver_id INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		date TEXT NOT NULL,
+		seq INTEGER NOT NULL,
+		read_at TEXT NOT NULL,
+		deleted INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS conversation_settings (
+		user_id INTEGER NOT NULL,
+		with_id INTEGER NOT NULL,
+		typing INTEGER NOT NULL DEFAULT 1,
+		receipts INTEGER NOT NULL DEFAULT 1,
+		UNIQUE(user_id, with_id),
+		FOREIGN KEY(user_id) REFERENCES users(id),
+		FOREIGN KEY(with_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS drafts (
+		user_id INTEGER NOT NULL,
+		receiver_id INTEGER NOT NULL,
+		content TEXT NOT NULL,
+		updated INTEGER NOT NULL,
+		UNIQUE(user_id, receiver_id),
+		FOREIGN KEY(user_id) REFERENCES users(id),
+		FOREIGN KEY(receiver_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS attachments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		receiver_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		path TEXT NOT NULL,
+		mime VARCHAR(255) NOT NULL,
+		size INTEGER NOT NULL,
+		date TEXT NOT NULL,
+		scan_status VARCHAR(16) NOT NULL,
+		post_id INTEGER NOT NULL DEFAULT 0,
+		width INTEGER NOT NULL DEFAULT 0,
+		height INTEGER NOT NULL DEFAULT 0,
+		placeholder VARCHAR(7) NOT NULL DEFAULT '',
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS attachment_variants (
+		attachment_id INTEGER NOT NULL,
+		width INTEGER NOT NULL,
+		height INTEGER NOT NULL,
+		path TEXT NOT NULL,
+		FOREIGN KEY(attachment_id) REFERENCES attachments(id)
+	);
+
 	CREATE TABLE IF NOT EXISTS chats (
 		id_one INTEGER NOT NULL,
 		id_two INTEGER NOT NULL,
@@ -73,5 +476,70 @@ const (
 		FOREIGN KEY(post_id) REFERENCES posts(id),
 		FOREIGN KEY(user_id) REFERENCES users(id)
 	);
+
+	CREATE TABLE IF NOT EXISTS reactions (
+		post_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		kind VARCHAR(16) NOT NULL,
+		date TEXT NOT NULL,
+		UNIQUE(post_id, user_id),
+		FOREIGN KEY(post_id) REFERENCES posts(id),
+		FOREIGN KEY(user_id) REFERENCES users(id)
+	);
 	`
-)
\ No newline at end of file
+)
+
+//SQL statements keeping the search tables of search_fts5.go or search_fts4.go in step with
+//the posts and comments, and indexing the rows written before they existed
+const (
+	CreateSearchTriggers = `
+	CREATE TRIGGER IF NOT EXISTS search_posts_insert AFTER INSERT ON posts BEGIN
+		INSERT INTO search_posts(rowid, title, content) VALUES (new.id, new.title, new.content);
+	END;
+	CREATE TRIGGER IF NOT EXISTS search_posts_update AFTER UPDATE OF title, content ON posts BEGIN
+		UPDATE search_posts SET title = new.title, content = new.content WHERE rowid = old.id;
+	END;
+	CREATE TRIGGER IF NOT EXISTS search_posts_delete AFTER DELETE ON posts BEGIN
+		DELETE FROM search_posts WHERE rowid = old.id;
+	END;
+	CREATE TRIGGER IF NOT EXISTS search_comments_insert AFTER INSERT ON comments BEGIN
+		INSERT INTO search_comments(rowid, content) VALUES (new.id, new.content);
+	END;
+	CREATE TRIGGER IF NOT EXISTS search_comments_update AFTER UPDATE OF content ON comments BEGIN
+		UPDATE search_comments SET content = new.content WHERE rowid = old.id;
+	END;
+	CREATE TRIGGER IF NOT EXISTS search_comments_delete AFTER DELETE ON comments BEGIN
+		DELETE FROM search_comments WHERE rowid = old.id;
+	END;
+	`
+
+	FillSearch = `
+	INSERT INTO search_posts(rowid, title, content) SELECT id, title, content FROM posts WHERE id NOT IN (SELECT rowid FROM search_posts);
+	INSERT INTO search_comments(rowid, content) SELECT id, content FROM comments WHERE id NOT IN (SELECT rowid FROM search_comments);
+	`
+)
+
+// Columns added to tables after their creation, with the statement filling them in for
+// existing rows. They are added when missing as CREATE TABLE IF NOT EXISTS leaves old tables
+// alone, by the legacy_columns migration, see legacy.go.
+var AddColumns = []struct {
+	Table, Column, Definition, Backfill string
+}{
+	{"posts", "comment_count", "INTEGER NOT NULL DEFAULT 0", `UPDATE posts SET comment_count = (SELECT COUNT(*) FROM comments WHERE post_id = posts.id)`},
+	{"posts", "view_count", "INTEGER NOT NULL DEFAULT 0", ""},
+	{"messages", "seq", "INTEGER NOT NULL DEFAULT 0", `UPDATE messages SET seq = (SELECT COUNT(*) FROM messages m WHERE ((m.sender_id = messages.sender_id AND m.receiver_id = messages.receiver_id) OR (m.sender_id = messages.receiver_id AND m.receiver_id = messages.sender_id)) AND m.id <= messages.id)`},
+	{"user_privacy", "discoverable", "BOOLEAN NOT NULL DEFAULT 0", ""},
+	{"password_resets", "locked", "INTEGER NOT NULL DEFAULT 0", `UPDATE password_resets SET locked = 1`},
+	{"messages", "read_at", "TEXT NOT NULL DEFAULT ''", `UPDATE messages SET read_at = date`},
+	{"comments", "parent_comment_id", "INTEGER NOT NULL DEFAULT 0", ""},
+	{"deleted_comments", "parent_comment_id", "INTEGER NOT NULL DEFAULT 0", ""},
+	{"posts", "license", "VARCHAR(64) NOT NULL DEFAULT ''", ""},
+	{"deleted_posts", "license", "VARCHAR(64) NOT NULL DEFAULT ''", ""},
+	{"sessions", "created_at", "INTEGER NOT NULL DEFAULT 0", ""},
+	{"sessions", "last_seen", "INTEGER NOT NULL DEFAULT 0", ""},
+	{"sessions", "expires_at", "INTEGER NOT NULL DEFAULT 0", `UPDATE sessions SET created_at = forum_now(), last_seen = forum_now(), expires_at = forum_now() + ` + strconv.Itoa(config.CookieAge)},
+	{"sessions", "user_agent", "TEXT NOT NULL DEFAULT ''", ""},
+	{"sessions", "ip", "VARCHAR(64) NOT NULL DEFAULT ''", ""},
+	{"categories", "post_permission", "VARCHAR(64) NOT NULL DEFAULT ''", ""},
+	{"categories", "comment_permission", "VARCHAR(64) NOT NULL DEFAULT ''", ""},
+}
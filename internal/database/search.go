@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"real-time-forum/internal/structure"
+)
+
+// Creates the search tables and their triggers, then indexes the posts and comments
+// that aren't yet
+func initSearch(db *sql.DB) error {
+	_, err := db.Exec(CreateSearchTables)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(CreateSearchTriggers)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(FillSearch)
+	return err
+}
+
+// Turns what a user typed into a full-text query matching every word. Each word is quoted
+// so characters with a meaning in the query syntax are matched literally.
+func matchQuery(q string) string {
+	terms := []string{}
+	for _, word := range strings.Fields(q) {
+		word = strings.ReplaceAll(word, `"`, "")
+		if word != "" {
+			terms = append(terms, `"`+word+`"`)
+		}
+	}
+
+	return strings.Join(terms, " ")
+}
+
+// Finds a page of the posts and comments matching the words of a query, best matches
+// first. Matched terms in the snippets are wrapped in the \x02 and \x03 characters.
+func (s *Store) SearchPostsAndComments(ctx context.Context, q string, limit, offset int) ([]structure.SearchResult, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	match := matchQuery(q)
+	if match == "" {
+		return []structure.SearchResult{}, nil
+	}
+
+	rows, err := s.query(ctx, SearchContent, match, match, limit, offset)
+	if err != nil {
+		return []structure.SearchResult{}, err
+	}
+
+	defer rows.Close()
+
+	results := []structure.SearchResult{}
+	for rows.Next() {
+		var res structure.SearchResult
+		var rank float64
+
+		err := rows.Scan(&res.Kind, &res.Post_id, &res.Comment_id, &res.Title, &res.Snippet, &res.User_id, &res.Date, &rank)
+		if err != nil {
+			return []structure.SearchResult{}, err
+		}
+
+		results = append(results, res)
+	}
+
+	return results, rows.Err()
+}
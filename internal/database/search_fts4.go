@@ -0,0 +1,19 @@
+//go:build !sqlite_fts5
+// +build !sqlite_fts5
+
+// Full-text search with FTS4, which the sqlite driver always includes. It has no bm25, so
+// matches are ranked by how many times the terms appear, see search_fts5.go.
+
+package database
+
+const (
+	CreateSearchTables = `
+	CREATE VIRTUAL TABLE IF NOT EXISTS search_posts USING fts4(title, content);
+	CREATE VIRTUAL TABLE IF NOT EXISTS search_comments USING fts4(content);
+	`
+
+	//offsets() lists four numbers per term found, lower ranks are better matches
+	SearchContent = `SELECT 'post', posts.id, 0, posts.title, snippet(search_posts, char(2), char(3), '…', -1, 16), posts.user_id, posts.date, -length(offsets(search_posts)) AS rank FROM search_posts JOIN posts ON posts.id = search_posts.rowid WHERE search_posts MATCH ?
+	UNION ALL SELECT 'comment', posts.id, comments.id, posts.title, snippet(search_comments, char(2), char(3), '…', 0, 16), comments.user_id, comments.date, -length(offsets(search_comments)) AS rank FROM search_comments JOIN comments ON comments.id = search_comments.rowid JOIN posts ON posts.id = comments.post_id WHERE search_comments MATCH ?
+	ORDER BY rank ASC LIMIT ? OFFSET ?`
+)
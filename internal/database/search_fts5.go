@@ -0,0 +1,21 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+// Full-text search with FTS5, ranking matches with bm25. Building it needs the FTS5 module
+// of the sqlite driver:
+//
+//	go build -tags sqlite_fts5
+
+package database
+
+const (
+	CreateSearchTables = `
+	CREATE VIRTUAL TABLE IF NOT EXISTS search_posts USING fts5(title, content);
+	CREATE VIRTUAL TABLE IF NOT EXISTS search_comments USING fts5(content);
+	`
+
+	//Titles weigh more than the content of a post, lower ranks are better matches
+	SearchContent = `SELECT 'post', posts.id, 0, posts.title, snippet(search_posts, -1, char(2), char(3), '…', 16), posts.user_id, posts.date, bm25(search_posts, 10.0, 1.0) AS rank FROM search_posts JOIN posts ON posts.id = search_posts.rowid WHERE search_posts MATCH ?
+	UNION ALL SELECT 'comment', posts.id, comments.id, posts.title, snippet(search_comments, 0, char(2), char(3), '…', 16), comments.user_id, comments.date, bm25(search_comments) AS rank FROM search_comments JOIN comments ON comments.id = search_comments.rowid JOIN posts ON posts.id = comments.post_id WHERE search_comments MATCH ?
+	ORDER BY rank ASC LIMIT ? OFFSET ?`
+)
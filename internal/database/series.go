@@ -0,0 +1,218 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Creates a series and returns its id
+func (s *Store) NewSeries(ctx context.Context, se structure.Series) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, AddSeries, se.User_id, se.Title, se.Description, clock.Now().Format(config.TimeFormat))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+// Finds a series with whether the viewer follows it, returning false when there is none
+func (s *Store) FindSeries(ctx context.Context, sid, viewer int) (structure.Series, bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var se structure.Series
+
+	err := s.queryRow(ctx, GetSeries, sid).Scan(&se.Id, &se.User_id, &se.Title, &se.Description, &se.Date, &se.Followers)
+	if err == sql.ErrNoRows {
+		return se, false, nil
+	}
+	if err != nil {
+		return se, false, err
+	}
+
+	var n int
+	err = s.queryRow(ctx, GetFollower, sid, viewer).Scan(&n)
+	se.Following = n > 0
+
+	return se, true, err
+}
+
+// Finds the series of a user, or of everyone when the user id is 0, newest first
+func (s *Store) FindAllSeries(ctx context.Context, uid int) ([]structure.Series, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	series := []structure.Series{}
+
+	var rows *sql.Rows
+	var err error
+	if uid == 0 {
+		rows, err = s.query(ctx, GetAllSeries)
+	} else {
+		rows, err = s.query(ctx, GetUserSeries, uid)
+	}
+	if err != nil {
+		return series, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var se structure.Series
+		err = rows.Scan(&se.Id, &se.User_id, &se.Title, &se.Description, &se.Date, &se.Followers)
+		if err != nil {
+			return series, err
+		}
+
+		series = append(series, se)
+	}
+
+	return series, rows.Err()
+}
+
+// Finds the posts of a series in order, leaving out the ones in the trash
+func (s *Store) FindSeriesPosts(ctx context.Context, sid int) ([]structure.Post, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, GetSeriesPosts, sid)
+	if err != nil {
+		return []structure.Post{}, err
+	}
+
+	return ConvertRowToPost(rows)
+}
+
+// Adds a post to the end of a series. A post can only be part of one series.
+func (s *Store) AddPostToSeries(ctx context.Context, sid, pid int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddSeriesPost, sid, pid, sid)
+	return err
+}
+
+// Takes a post out of a series, returning whether it was part of it
+func (s *Store) RemovePostFromSeries(ctx context.Context, sid, pid int) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, RemoveSeriesPost, sid, pid)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Finds the series a post is part of with the parts around it, returning false when it
+// isn't part of any
+func (s *Store) FindPostSeries(ctx context.Context, pid int) (structure.SeriesLink, bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var link structure.SeriesLink
+
+	err := s.queryRow(ctx, GetPostSeries, pid).Scan(&link.Series_id, &link.Title)
+	if err == sql.ErrNoRows {
+		return link, false, nil
+	}
+	if err != nil {
+		return link, false, err
+	}
+
+	rows, err := s.query(ctx, GetSeriesParts, link.Series_id)
+	if err != nil {
+		return link, false, err
+	}
+
+	defer rows.Close()
+
+	//Parts in the trash are skipped, so the numbers follow what readers can open
+	parts := []structure.SeriesPart{}
+	for rows.Next() {
+		var part structure.SeriesPart
+		err = rows.Scan(&part.Post_id, &part.Title)
+		if err != nil {
+			return link, false, err
+		}
+
+		parts = append(parts, part)
+	}
+
+	for i := range parts {
+		if parts[i].Post_id != pid {
+			continue
+		}
+
+		link.Part = i + 1
+		if i > 0 {
+			link.Previous = &parts[i-1]
+		}
+		if i+1 < len(parts) {
+			link.Next = &parts[i+1]
+		}
+	}
+	link.Parts = len(parts)
+
+	return link, true, rows.Err()
+}
+
+// Makes a user follow a series
+func (s *Store) FollowSeries(ctx context.Context, sid, uid int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddFollower, sid, uid, clock.Now().Format(config.TimeFormat))
+	return err
+}
+
+// Stops a user following a series, returning whether they followed it
+func (s *Store) UnfollowSeries(ctx context.Context, sid, uid int) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, RemoveFollower, sid, uid)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Finds the users following a series
+func (s *Store) FindFollowers(ctx context.Context, sid int) ([]int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	uids := []int{}
+
+	rows, err := s.query(ctx, GetFollowers, sid)
+	if err != nil {
+		return uids, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var uid int
+		err = rows.Scan(&uid)
+		if err != nil {
+			return uids, err
+		}
+
+		uids = append(uids, uid)
+	}
+
+	return uids, rows.Err()
+}
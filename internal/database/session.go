@@ -0,0 +1,138 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Stores a new session of the user on the device it was created from.
+// Users can be logged in on several devices, each with its own session.
+func (s *Store) NewSession(ctx context.Context, session string, uid int, userAgent, ip string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	now := clock.Now().Unix()
+	_, err := s.exec(ctx, AddSession, session, uid, now, now, now+config.CookieAge, userAgent, ip)
+	return err
+}
+
+// Moves the expiry of a session forward now that it is used again, returning the new expiry.
+// Returns false when the session has expired, or was already moved within the touch interval.
+func (s *Store) TouchSession(ctx context.Context, session string) (int64, bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	now := clock.Now().Unix()
+	expires := now + config.CookieAge
+
+	res, err := s.exec(ctx, UpdateSession, now, expires, session, now, now-config.SessionTouchInterval)
+	if err != nil {
+		return 0, false, err
+	}
+
+	n, err := res.RowsAffected()
+	return expires, n > 0, err
+}
+
+// Finds the unexpired sessions of a user, most recently used first
+func (s *Store) FindSessions(ctx context.Context, uid int) ([]structure.Session, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	sessions := []structure.Session{}
+
+	rows, err := s.query(ctx, GetSessions, uid, clock.Now().Unix())
+	if err != nil {
+		return sessions, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		sess := structure.Session{User_id: uid}
+		err = rows.Scan(&sess.Id, &sess.Session_uuid, &sess.Created_at, &sess.Last_seen, &sess.Expires_at, &sess.User_agent, &sess.Ip)
+		if err != nil {
+			return sessions, err
+		}
+
+		sessions = append(sessions, sess)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Removes every session of a user, logging them out on all their devices
+func (s *Store) DeleteUserSessions(ctx context.Context, uid int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, RemoveCookie, uid)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx, RemoveOldBindings)
+	return err
+}
+
+// Removes the sessions that have expired along with their bindings, returning how many there were
+func (s *Store) PurgeSessions(ctx context.Context) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, RemoveOldSessions, clock.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = s.exec(ctx, RemoveOldBindings)
+	return n, err
+}
+
+// Binds a session to a hash of the browser's user agent and the prefix of its ip address
+func (s *Store) BindSession(ctx context.Context, session, userAgent, ipPrefix string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddBinding, session, userAgent, ipPrefix)
+	return err
+}
+
+// Finds what a session is bound to, found is false for sessions created without a binding
+func (s *Store) FindSessionBinding(ctx context.Context, session string) (userAgent, ipPrefix string, found bool, err error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	err = s.queryRow(ctx, GetBinding, session).Scan(&userAgent, &ipPrefix)
+	if err == sql.ErrNoRows {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, err
+	}
+
+	return userAgent, ipPrefix, true, nil
+}
+
+// Removes a single session along with its binding
+func (s *Store) DeleteSession(ctx context.Context, session string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, RemoveSession, session)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx, RemoveBinding, session)
+	return err
+}
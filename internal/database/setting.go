@@ -0,0 +1,30 @@
+package database
+
+import (
+	"context"
+
+	"database/sql"
+)
+
+// Finds a forum setting, empty when it was never set
+func (s *Store) FindSetting(ctx context.Context, key string) (string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var value string
+	err := s.queryRow(ctx, GetSetting, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+
+	return value, err
+}
+
+// Changes a forum setting
+func (s *Store) SaveSetting(ctx context.Context, key, value string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddSetting, key, value)
+	return err
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Finds the share token of a post, giving it the new token when it has none yet
+func (s *Store) NewPostShare(ctx context.Context, pid int, token string) (string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	//Leaves the token a post already has alone
+	_, err := s.exec(ctx, AddPostShare, token, pid, clock.Now().Format(config.TimeFormat))
+	if err != nil {
+		return "", err
+	}
+
+	err = s.queryRow(ctx, GetPostShare, pid).Scan(&token)
+	return token, err
+}
+
+// Finds the post a share token links to
+func (s *Store) FindSharedPost(ctx context.Context, token string) (int, bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var pid int
+	err := s.queryRow(ctx, GetSharedPost, token).Scan(&pid)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return pid, true, nil
+}
+
+// Counts a click on the share link of a post, under the current day and the referring site
+func (s *Store) CountShareClick(ctx context.Context, pid int, referrer string) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddShareClick, pid, clock.Now().Format(config.DateFormat), referrer)
+	return err
+}
+
+// Finds how often the share link of a post was followed, in total, per referring site
+// and per day
+func (s *Store) FindPostStats(ctx context.Context, post structure.Post) (structure.PostStats, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	stats := structure.PostStats{
+		Post_id:   post.Id,
+		Views:     post.View_count,
+		Referrers: []structure.ShareClicks{},
+		Days:      []structure.ShareClicks{},
+	}
+
+	rows, err := s.query(ctx, GetShareReferrers, post.Id)
+	if err != nil {
+		return stats, err
+	}
+
+	for rows.Next() {
+		var c structure.ShareClicks
+		err = rows.Scan(&c.Referrer, &c.Clicks)
+		if err != nil {
+			rows.Close()
+			return stats, err
+		}
+
+		stats.Clicks += c.Clicks
+		stats.Referrers = append(stats.Referrers, c)
+	}
+	rows.Close()
+
+	rows, err = s.query(ctx, GetShareDays, post.Id)
+	if err != nil {
+		return stats, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var c structure.ShareClicks
+		err = rows.Scan(&c.Day, &c.Clicks)
+		if err != nil {
+			return stats, err
+		}
+
+		stats.Days = append(stats.Days, c)
+	}
+
+	return stats, rows.Err()
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Finds the activity streak of a user, an empty one in the default time zone when they
+// have never been active
+func (s *Store) FindStreak(ctx context.Context, uid int) (structure.Streak, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	st := structure.Streak{Timezone: config.DefaultTimezone}
+
+	err := s.queryRow(ctx, GetStreak, uid).Scan(&st.Current, &st.Best, &st.Last_day, &st.Timezone)
+	if err == sql.ErrNoRows {
+		return st, nil
+	}
+
+	return st, err
+}
+
+// Stores the activity streak of a user
+func (s *Store) SaveStreak(ctx context.Context, uid int, st structure.Streak) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddStreak, uid, st.Current, st.Best, st.Last_day, st.Timezone)
+	return err
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+
+	"real-time-forum/internal/structure"
+)
+
+// Finds the changes after the given cursor to the posts, the messages of a user and their
+// notifications, oldest first. The log is written by the triggers of migration 0002.
+func (s *Store) FindChanges(ctx context.Context, uid, since, limit int) ([]structure.Change, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, GetChanges, since, uid, uid, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	changes := []structure.Change{}
+	for rows.Next() {
+		var c structure.Change
+
+		err := rows.Scan(&c.Cursor, &c.Kind, &c.Id, &c.Action)
+		if err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
+
+// Finds the oldest change still kept, 0 when there is none, and the cursor of the latest
+// change ever made
+func (s *Store) FindChangeBounds(ctx context.Context) (int, int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var first, last int
+	err := s.queryRow(ctx, GetChangeBounds).Scan(&first, &last)
+	return first, last, err
+}
+
+// Removes the changes made before the given unix time
+func (s *Store) DeleteOldChanges(ctx context.Context, before int64) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	res, err := s.exec(ctx, RemoveOldChanges, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// Finds the messages with the given ids in a single query, like FindPostsByIds
+func (s *Store) FindMessagesByIds(ctx context.Context, ids []int) ([]structure.Message, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, GetMessagesByIds, idList(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	return ConvertRowToMessage(rows)
+}
+
+// Finds the notifications with the given ids in a single query, like FindPostsByIds
+func (s *Store) FindNotificationsByIds(ctx context.Context, ids []int) ([]structure.Notification, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, GetNotificationsByIds, idList(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var notifications []structure.Notification
+	for rows.Next() {
+		var n structure.Notification
+
+		err := rows.Scan(&n.Id, &n.User_id, &n.Kind, &n.Content, &n.Link, &n.Date, &n.Seen)
+		if err != nil {
+			return nil, err
+		}
+
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
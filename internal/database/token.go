@@ -0,0 +1,89 @@
+package database
+
+import "context"
+
+// Stores the id of a revoked access token until the token would have expired
+func (s *Store) RevokeToken(ctx context.Context, jti string, expires int64) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddRevocation, jti, expires)
+	return err
+}
+
+// Finds the revoked access tokens that have not expired yet, forgetting the ones that have
+func (s *Store) FindRevokedTokens(ctx context.Context, now int64) (map[string]int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, RemoveRevocations, now)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(ctx, GetRevocations, now)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revoked := make(map[string]int64)
+	for rows.Next() {
+		var jti string
+		var expires int64
+
+		err := rows.Scan(&jti, &expires)
+		if err != nil {
+			return nil, err
+		}
+
+		revoked[jti] = expires
+	}
+
+	return revoked, rows.Err()
+}
+
+// Revokes the access tokens of a user issued before the cutoff, until the given time when
+// the last of them has expired
+func (s *Store) RevokeUserTokens(ctx context.Context, uid int, cutoff, expires int64) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddTokenCutoff, uid, cutoff, expires)
+	return err
+}
+
+// Finds the users whose access tokens issued before a cutoff are revoked, with their cutoff,
+// forgetting the cutoffs whose tokens have all expired
+func (s *Store) FindTokenCutoffs(ctx context.Context, now int64) (map[int]int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, RemoveCutoffs, now)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(ctx, GetTokenCutoffs, now)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	cutoffs := make(map[int]int64)
+	for rows.Next() {
+		var uid int
+		var cutoff int64
+
+		err := rows.Scan(&uid, &cutoff)
+		if err != nil {
+			return nil, err
+		}
+
+		cutoffs[uid] = cutoff
+	}
+
+	return cutoffs, rows.Err()
+}
@@ -0,0 +1,288 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Deleted posts, comments and messages are moved to their deleted_ table with the time they
+// were deleted, keeping their id so they can be moved back while they are recoverable.
+
+// Moves a row between its table and its trash table, returning false when there was no row to move
+func moveRow(ctx context.Context, tx *sql.Tx, insert, remove string, id int, args ...interface{}) (bool, error) {
+	res, err := tx.ExecContext(ctx, insert, args...)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, remove, id)
+	return err == nil, err
+}
+
+// Moves a post of a user to the trash, returning false when the user has no such post
+func (s *Store) DeletePost(ctx context.Context, uid, id int, now int64) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := moveRow(ctx, tx, TrashPost, RemovePost, id, now, id, uid)
+	if err != nil || !ok {
+		tx.Rollback()
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// Moves a comment of a user to the trash, returning the post it was on and false when the
+// user has no such comment
+func (s *Store) DeleteComment(ctx context.Context, uid, id int, now int64) (int, bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, err
+	}
+
+	ok, err := moveRow(ctx, tx, TrashComment, RemoveComment, id, now, id, uid)
+	if err != nil || !ok {
+		tx.Rollback()
+		return 0, false, err
+	}
+
+	var postID int
+	err = tx.QueryRowContext(ctx, GetTrashedComment, id, uid, 0).Scan(&postID)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, UpdateCommentRemoved, postID)
+	}
+	if err != nil {
+		tx.Rollback()
+		return 0, false, err
+	}
+
+	return postID, true, tx.Commit()
+}
+
+// Moves a message sent by a user to the trash, returning its receiver and false when the
+// user sent no such message
+func (s *Store) DeleteMessage(ctx context.Context, uid, id int, now int64) (int, bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, err
+	}
+
+	ok, err := moveRow(ctx, tx, TrashMessage, RemoveMessage, id, now, id, uid)
+	if err != nil || !ok {
+		tx.Rollback()
+		return 0, false, err
+	}
+
+	var receiverID int
+	err = tx.QueryRowContext(ctx, GetTrashedMessage, id, uid, 0).Scan(&receiverID)
+	if err != nil {
+		tx.Rollback()
+		return 0, false, err
+	}
+
+	return receiverID, true, tx.Commit()
+}
+
+// Moves a post of a user deleted since the given unix time back out of the trash,
+// returning false when there is no such post
+func (s *Store) RecoverPost(ctx context.Context, uid, id int, since int64) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := moveRow(ctx, tx, RestorePost, UntrashPost, id, id, uid, since)
+	if err != nil || !ok {
+		tx.Rollback()
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// Finds the post of a comment a user deleted since the given unix time
+func (s *Store) FindTrashedComment(ctx context.Context, uid, id int, since int64) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var postID int
+	err := s.queryRow(ctx, GetTrashedComment, id, uid, since).Scan(&postID)
+	return postID, err
+}
+
+// Moves a comment of a user deleted since the given unix time back onto its post, which
+// has to exist, returning false when there is no such comment
+func (s *Store) RecoverComment(ctx context.Context, uid, id int, since int64) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	var postID int
+	err = tx.QueryRowContext(ctx, GetTrashedComment, id, uid, since).Scan(&postID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return false, nil
+	}
+
+	var posts int
+	if err == nil {
+		err = tx.QueryRowContext(ctx, GetPostCount, postID).Scan(&posts)
+	}
+	if err != nil || posts == 0 {
+		tx.Rollback()
+		return false, err
+	}
+
+	ok, err := moveRow(ctx, tx, RestoreComment, UntrashComment, id, id, uid, since)
+	if err == nil && ok {
+		_, err = tx.ExecContext(ctx, UpdateCommentCount, postID)
+	}
+	if err != nil || !ok {
+		tx.Rollback()
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// Moves a message sent by a user and deleted since the given unix time back into its
+// conversation, returning its receiver and false when there is no such message
+func (s *Store) RecoverMessage(ctx context.Context, uid, id int, since int64) (int, bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var receiverID int
+	err = tx.QueryRowContext(ctx, GetTrashedMessage, id, uid, since).Scan(&receiverID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return 0, false, nil
+	}
+
+	ok := false
+	if err == nil {
+		ok, err = moveRow(ctx, tx, RestoreMessage, UntrashMessage, id, id, uid, since)
+	}
+	if err != nil || !ok {
+		tx.Rollback()
+		return 0, false, err
+	}
+
+	return receiverID, true, tx.Commit()
+}
+
+// Finds what a user deleted since the given unix time, newest first within each kind
+func (s *Store) FindTrash(ctx context.Context, uid int, since int64) ([]structure.TrashItem, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	items := []structure.TrashItem{}
+
+	kinds := []struct {
+		kind  string
+		query string
+	}{
+		{"post", GetTrashedPosts},
+		{"comment", GetTrashedComments},
+		{"message", GetTrashedMessages},
+	}
+
+	for _, k := range kinds {
+		rows, err := s.query(ctx, k.query, uid, since)
+		if err != nil {
+			return items, err
+		}
+
+		for rows.Next() {
+			item := structure.TrashItem{Kind: k.kind}
+			var deleted int64
+
+			switch k.kind {
+			case "post":
+				err = rows.Scan(&item.Id, &item.Preview, &deleted)
+			case "comment":
+				err = rows.Scan(&item.Id, &item.Post_id, &item.Preview, &deleted)
+			case "message":
+				err = rows.Scan(&item.Id, &item.Receiver_id, &item.Preview, &deleted)
+				item.Preview = openMessage(item.Preview)
+			}
+			if err != nil {
+				rows.Close()
+				return items, err
+			}
+
+			item.Deleted = time.Unix(deleted, 0).Format(config.TimeFormat)
+			item.Expires = time.Unix(deleted+config.TrashRetention, 0).Format(config.TimeFormat)
+			items = append(items, item)
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return items, err
+		}
+	}
+
+	return items, nil
+}
+
+// Permanently removes what was deleted at or before the given unix time, along with the
+// comments, reactions, images, co-authors and revisions of the posts removed. Returns the number of items removed.
+func (s *Store) PurgeTrash(ctx context.Context, before int64) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	//What hangs off the posts goes first, while the posts are still there to find it by
+	var removed int64
+	for _, q := range []string{PurgePostVariants, PurgePostImages, PurgeReactions, PurgePostComments, PurgeOrphanTrash, PurgeCoauthors, PurgeRevisions, PurgePostLocks, PurgeEvents, PurgeRsvps, PurgeSeriesPosts, PurgeCrossposts, PurgePostViews, PurgePostViewers, PurgeShares, PurgeShareClicks, PurgeThreadLocks, PurgePosts, PurgeComments, PurgeMessages} {
+		res, err := tx.ExecContext(ctx, q, before)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		if q == PurgePosts || q == PurgeComments || q == PurgeMessages {
+			n, _ := res.RowsAffected()
+			removed += n
+		}
+	}
+
+	return removed, tx.Commit()
+}
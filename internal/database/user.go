@@ -1,49 +1,66 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"strconv"
+	"strings"
 
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
 	"real-time-forum/internal/structure"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 // Attempts to insert a user into the database, returns an error if it cannot
-func NewUser(path string, u structure.User) error {
-	//Open database
-	db, err := OpenDB(path)
+func (s *Store) NewUser(ctx context.Context, u structure.User) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	//Execute the insert statement
+	res, err := s.exec(ctx, AddUser, u.Username, u.Firstname, u.Surname, u.Gender, u.Email, u.DOB, u.Password)
 	if err != nil {
 		return err
 	}
 
-	defer db.Close()
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
 
-	//Execute the insert statement
-	_, err = db.Exec(AddUser, u.Username, u.Firstname, u.Surname, u.Gender, u.Email, u.DOB, u.Password)
+	//Stores the visibility settings chosen at registration
+	var p structure.Privacy
+	if u.Privacy != nil {
+		p = *u.Privacy
+	}
+	p.User_id = int(id)
+
+	err = insertPrivacy(ctx, s.db, p)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	//The user cannot log in until they have used the token sent to their email
+	if u.Verification != "" {
+		_, err = s.exec(ctx, AddVerification, id, u.Verification, clock.Now().Unix())
+	}
+
+	return err
 }
 
 // Checks if a user with the given email or username already exists in the database
-func UserExists(path, value string) (bool, error) {
-	// Open the database
-	db, err := OpenDB(path)
-	if err != nil {
-		return false, err
-	}
-	defer db.Close()
+func (s *Store) UserExists(ctx context.Context, value string) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
 	// Query the database to check if the email or username already exists
 	query := `SELECT COUNT(*) FROM users WHERE email = ? OR username = ?`
-	row := db.QueryRow(query, value, value)
+	row := s.queryRow(ctx, query, value, value)
 
 	var count int
-	err = row.Scan(&count)
+	err := row.Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -73,17 +90,12 @@ func ConvertRowToUser(rows *sql.Rows) ([]structure.User, error) {
 }
 
 // Gets all users from the database
-func FindAllUsers(path string) ([]structure.User, error) {
-	//Opens the database
-	db, err := OpenDB(path)
-	if err != nil {
-		return []structure.User{}, errors.New("failed to open database")
-	}
-
-	defer db.Close()
+func (s *Store) FindAllUsers(ctx context.Context) ([]structure.User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
 	//Finds all the users
-	rows, err := db.Query(GetAllUser)
+	rows, err := s.query(ctx, GetAllUser)
 	if err != nil {
 		return []structure.User{}, errors.New("failed to find users")
 	}
@@ -98,16 +110,12 @@ func FindAllUsers(path string) ([]structure.User, error) {
 }
 
 // Finds user from the database based on the passed parameter (id, username, email)
-func FindUserByParam(path, parameter, data string) (structure.User, error) {
-	var q *sql.Rows
-
-	//Opens the database
-	db, err := OpenDB(path)
-	if err != nil {
-		return structure.User{}, errors.New("failed to open database")
-	}
+func (s *Store) FindUserByParam(ctx context.Context, parameter, data string) (structure.User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	defer db.Close()
+	var q *sql.Rows
+	var err error
 
 	//Checks which parameter to search the database by
 	switch parameter {
@@ -119,19 +127,19 @@ func FindUserByParam(path, parameter, data string) (structure.User, error) {
 		}
 
 		//Searches the database by id
-		q, err = db.Query(GetUserById, i)
+		q, err = s.query(ctx, GetUserById, i)
 		if err != nil {
 			return structure.User{}, errors.New("could not find id")
 		}
 	case "username":
 		//Searches the database by username
-		q, err = db.Query(GetUserByUsername, data)
+		q, err = s.query(ctx, GetUserByUsername, data)
 		if err != nil {
 			return structure.User{}, errors.New("could not find username")
 		}
 	case "email":
 		//Searches the database by email
-		q, err = db.Query(GetUserByEmail, data)
+		q, err = s.query(ctx, GetUserByEmail, data)
 		if err != nil {
 			return structure.User{}, errors.New("could not find email")
 		}
@@ -145,21 +153,19 @@ func FindUserByParam(path, parameter, data string) (structure.User, error) {
 	if err != nil {
 		return structure.User{}, errors.New("failed to convert")
 	}
+	if len(user) == 0 {
+		return structure.User{}, errors.New("no user found")
+	}
 
 	return user[0], nil
 }
 
 // Finds the currently logged in user from the cookie
-func CurrentUser(path, val string) (structure.User, error) {
-	//Open database
-	db, err := OpenDB(path)
-	if err != nil {
-		return structure.User{}, err
-	}
-
-	defer db.Close()
+func (s *Store) CurrentUser(ctx context.Context, val string) (structure.User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	q, err := db.Query(GetSessionUser, val)
+	q, err := s.query(ctx, GetSessionUser, val, clock.Now().Unix())
 	if err != nil {
 		return structure.User{}, err
 	}
@@ -173,3 +179,44 @@ func CurrentUser(path, val string) (structure.User, error) {
 	}
 	return users[0], nil
 }
+
+// Finds a page of users whose username or display name starts with the prefix, with their display names set
+func (s *Store) SearchUsers(ctx context.Context, prefix string, limit, offset int) ([]structure.User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	//Matches the prefix literally, escaping the LIKE wildcards
+	pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+
+	rows, err := s.query(ctx, SearchUser, pattern, config.DisplayFullName, pattern, limit, offset)
+	if err != nil {
+		return []structure.User{}, errors.New("failed to find users")
+	}
+
+	defer rows.Close()
+
+	users := []structure.User{}
+	for rows.Next() {
+		var u structure.User
+		var pref sql.NullString
+
+		err := rows.Scan(&u.Id, &u.Username, &u.Firstname, &u.Surname, &pref)
+		if err != nil {
+			break
+		}
+
+		u.Display_name = DisplayName(u.Username, u.Firstname, u.Surname, pref.String)
+		users = append(users, u)
+	}
+
+	return users, nil
+}
+
+// Fills in the optional profile fields of a user
+func (s *Store) UpdateProfile(ctx context.Context, uid int, p structure.Profile) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, UpdateUserProfile, p.Gender, p.DOB, uid)
+	return err
+}
@@ -0,0 +1,41 @@
+package database
+
+import "context"
+
+// Checks whether the user still has to verify their email before logging in
+func (s *Store) IsUnverified(ctx context.Context, uid int) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := s.queryRow(ctx, GetUserVerification, uid).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// Gives an unverified user a new verification token, replacing the previous one
+func (s *Store) RenewVerification(ctx context.Context, uid int, token string, created int64) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, AddVerification, uid, token, created)
+	return err
+}
+
+// Verifies the email of a user with a token created since the given unix time, returning the id of the user
+func (s *Store) VerifyEmail(ctx context.Context, token string, since int64) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var uid int
+	err := s.queryRow(ctx, GetVerification, token, since).Scan(&uid)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = s.exec(ctx, RemoveVerified, uid)
+	return uid, err
+}
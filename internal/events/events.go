@@ -0,0 +1,142 @@
+// Package events is an in-process event bus. Handlers publish what happened and
+// features react by subscribing, instead of being called from every handler.
+package events
+
+import (
+	"log"
+	"sync"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Event is something that happened, identified by its name
+type Event interface {
+	Name() string
+}
+
+// A post was created
+type PostCreated struct {
+	PostID int
+	UserID int
+}
+
+// A comment was created
+type CommentCreated struct {
+	CommentID int
+	PostID    int
+	UserID    int
+}
+
+// A user reacted to a post, an empty kind taking their reaction back
+type PostReacted struct {
+	PostID int
+	UserID int
+	Kind   string
+}
+
+// A private message was stored
+type MessageSent struct {
+	Message structure.Message
+}
+
+// A user registered
+type UserRegistered struct {
+	UserID int
+}
+
+// A user logged in
+type UserLoggedIn struct {
+	UserID    int
+	IP        string
+	UserAgent string
+}
+
+func (PostCreated) Name() string    { return config.TopicPostCreated }
+func (CommentCreated) Name() string { return config.TopicCommentCreated }
+func (PostReacted) Name() string    { return "post.reacted" }
+func (MessageSent) Name() string    { return "message.sent" }
+func (UserRegistered) Name() string { return "user.registered" }
+func (UserLoggedIn) Name() string   { return "user.logged_in" }
+
+// Subscriber reacts to an event, its name telling it apart from the other subscribers
+// of the same event
+type Subscriber struct {
+	Name   string
+	Handle func(Event) error
+}
+
+// Bus delivers events to their subscribers
+type Bus struct {
+	sync.RWMutex
+	subscribers map[string][]Subscriber
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[string][]Subscriber)}
+}
+
+func (b *Bus) subscribe(event, name string, fn func(Event) error) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.subscribers[event] = append(b.subscribers[event], Subscriber{Name: name, Handle: fn})
+}
+
+// Subscribers returns the subscribers of the event, in the order they are run
+func (b *Bus) Subscribers(e Event) []Subscriber {
+	b.RLock()
+	defer b.RUnlock()
+
+	return b.subscribers[e.Name()]
+}
+
+// Publish runs every subscriber of the event in order, stopping at the first error
+func (b *Bus) Publish(e Event) error {
+	for _, s := range b.Subscribers(e) {
+		if err := s.Handle(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PublishAsync publishes the event in the background, logging a failure
+func (b *Bus) PublishAsync(e Event) {
+	go func() {
+		if err := b.Publish(e); err != nil {
+			log.Printf("Error handling %s event: %v", e.Name(), err)
+		}
+	}()
+}
+
+// OnPostCreated subscribes to new posts
+func (b *Bus) OnPostCreated(name string, fn func(PostCreated) error) {
+	b.subscribe(PostCreated{}.Name(), name, func(e Event) error { return fn(e.(PostCreated)) })
+}
+
+// OnCommentCreated subscribes to new comments
+func (b *Bus) OnCommentCreated(name string, fn func(CommentCreated) error) {
+	b.subscribe(CommentCreated{}.Name(), name, func(e Event) error { return fn(e.(CommentCreated)) })
+}
+
+// OnPostReacted subscribes to reactions to posts
+func (b *Bus) OnPostReacted(name string, fn func(PostReacted) error) {
+	b.subscribe(PostReacted{}.Name(), name, func(e Event) error { return fn(e.(PostReacted)) })
+}
+
+// OnMessageSent subscribes to private messages
+func (b *Bus) OnMessageSent(name string, fn func(MessageSent) error) {
+	b.subscribe(MessageSent{}.Name(), name, func(e Event) error { return fn(e.(MessageSent)) })
+}
+
+// OnUserRegistered subscribes to new users
+func (b *Bus) OnUserRegistered(name string, fn func(UserRegistered) error) {
+	b.subscribe(UserRegistered{}.Name(), name, func(e Event) error { return fn(e.(UserRegistered)) })
+}
+
+// OnUserLoggedIn subscribes to logins
+func (b *Bus) OnUserLoggedIn(name string, fn func(UserLoggedIn) error) {
+	b.subscribe(UserLoggedIn{}.Name(), name, func(e Event) error { return fn(e.(UserLoggedIn)) })
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/ids"
+	"real-time-forum/internal/logger"
+)
+
+const accessKey contextKey = "access"
+
+// What is only known about a request once its handlers ran, filled in as they run
+type accessEntry struct {
+	userId int
+}
+
+// Remembers the status of a response, keeping the websocket upgrade working
+type accessWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (aw *accessWriter) WriteHeader(status int) {
+	if aw.status == 0 {
+		aw.status = status
+	}
+	aw.ResponseWriter.WriteHeader(status)
+}
+
+func (aw *accessWriter) Write(b []byte) (int, error) {
+	if aw.status == 0 {
+		aw.status = http.StatusOK
+	}
+
+	return aw.ResponseWriter.Write(b)
+}
+
+func (aw *accessWriter) Flush() {
+	if f, ok := aw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (aw *accessWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := aw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	aw.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// accessLogging gives every request an id, sent back in the X-Request-ID header, and logs
+// its method, path, status, latency and user once it is answered. Ids sent by a proxy in
+// front of the server are kept so its logs and ours can be matched. Websockets are logged
+// once they are upgraded, with status 101.
+func accessLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(config.RequestIDHeader)
+		if !validRequestId(id) {
+			var err error
+			id, err = ids.New()
+			if err != nil {
+				http.Error(w, "500 internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+		w.Header().Set(config.RequestIDHeader, id)
+
+		entry := &accessEntry{}
+		l := logger.FromContext(r.Context()).With("request_id", id)
+
+		ctx := context.WithValue(logger.WithLogger(r.Context(), l), accessKey, entry)
+		aw := &accessWriter{ResponseWriter: w}
+		next.ServeHTTP(aw, r.WithContext(ctx))
+
+		if aw.status == 0 {
+			aw.status = http.StatusOK
+		}
+
+		l.LogAttrs(ctx, slog.LevelInfo, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", aw.status),
+			slog.Float64("latency_ms", float64(time.Since(start))/float64(time.Millisecond)),
+			slog.Int("user_id", entry.userId),
+		)
+	})
+}
+
+// Records the user a request was made by for its access log
+func noteUser(r *http.Request, uid int) {
+	if entry, ok := r.Context().Value(accessKey).(*accessEntry); ok {
+		entry.userId = uid
+	}
+}
+
+// Checks that a request id sent by a client is short and printable, so it can't break
+// the log lines it ends up in
+func validRequestId(id string) bool {
+	if id == "" || len(id) > config.RequestIDMaxLength {
+		return false
+	}
+
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/routes"
+	"real-time-forum/internal/secrets"
+	"real-time-forum/internal/structure"
+)
+
+// Url alerts are posted to, read once at startup
+var alertWebhook = secrets.Getenv(config.AlertWebhookEnv)
+
+var alertClient = &http.Client{Timeout: config.AlertWebhookTimeout * time.Second}
+
+// Results of the last evaluation of each alert rule
+var alertResults = struct {
+	sync.Mutex
+	rules map[int]structure.AlertRule
+}{rules: make(map[int]structure.AlertRule)}
+
+// AlertHandler lists, creates and removes the service level alert rules (/admin/alerts).
+// Rules watch the pattern of a route of the router, like /posts/{id}/comments.
+func AlertHandler(rt *routes.Router, w http.ResponseWriter, r *http.Request) {
+	admin, ok := requirePermission(w, r, config.PermAlertManage)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		rules, err := db.FindAlertRules(r.Context())
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		//Adds what the rules were at when they were last evaluated
+		alertResults.Lock()
+		for i, rule := range rules {
+			if last, ok := alertResults.rules[rule.Id]; ok {
+				rules[i].Value, rules[i].Requests, rules[i].Firing = last.Value, last.Requests, last.Firing
+			}
+		}
+		alertResults.Unlock()
+
+		writeJSON(w, http.StatusOK, rules)
+	case "POST":
+		var rule structure.AlertRule
+
+		//Decodes the request body into the alert rule struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(r.Body).Decode(&rule)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		if !isAlertMetric(rule.Metric) {
+			http.Error(w, "400 bad request: Unknown metric.", http.StatusBadRequest)
+			return
+		}
+
+		if rule.Threshold < 0 {
+			http.Error(w, "400 bad request: Invalid threshold.", http.StatusBadRequest)
+			return
+		}
+
+		if len(rule.Endpoint) == 0 || rule.Endpoint[0] != '/' {
+			http.Error(w, "400 bad request: Invalid endpoint.", http.StatusBadRequest)
+			return
+		}
+		req := &http.Request{URL: &url.URL{Path: rule.Endpoint}}
+		if pattern, found := rt.Match(req); !found || pattern != rule.Endpoint {
+			http.Error(w, "400 bad request: Unknown endpoint.", http.StatusBadRequest)
+			return
+		}
+
+		rule, err = db.NewAlertRule(r.Context(), structure.AlertRule{
+			Endpoint:  rule.Endpoint,
+			Metric:    rule.Metric,
+			Threshold: rule.Threshold,
+		})
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		audit(r.Context(), admin, 0, "alert.create", describeAlertRule(rule))
+		writeCreated(w, "/admin/alerts", rule)
+	case "DELETE":
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		found, err := db.DeleteAlertRule(r.Context(), id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		alertResults.Lock()
+		delete(alertResults.rules, id)
+		alertResults.Unlock()
+
+		audit(r.Context(), admin, 0, "alert.delete", strconv.Itoa(id))
+		writeNoContent(w)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// Checks the metric of an alert rule is one that can be watched
+func isAlertMetric(m string) bool {
+	for _, known := range config.AlertMetrics {
+		if m == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Describes an alert rule for people, e.g. "p95 of /post over 300ms"
+func describeAlertRule(rule structure.AlertRule) string {
+	return fmt.Sprintf("%s of %s over %s", rule.Metric, rule.Endpoint, alertValue(rule.Metric, rule.Threshold))
+}
+
+// Formats a value of a metric with its unit
+func alertValue(metric string, v float64) string {
+	if metric == "error_rate" {
+		return fmt.Sprintf("%.4g%%", v)
+	}
+
+	return fmt.Sprintf("%.4gms", v)
+}
+
+// Evaluates the alert rules against the requests of every interval, alerting when a rule
+// starts or stops being breached. Endpoints without requests in an interval breach nothing.
+func evaluateAlerts(ctx context.Context, hub *chat.Hub) {
+	ticker := clock.NewTicker(config.AlertInterval * time.Second)
+	defer ticker.Stop()
+
+	for ticker.Next(ctx) {
+		endpoints := requests.take()
+
+		rules, err := db.FindAlertRules(ctx)
+		if err != nil {
+			log.Printf("Error reading alert rules: %v", err)
+			continue
+		}
+
+		for _, rule := range rules {
+			if e, ok := endpoints[rule.Endpoint]; ok {
+				rule.Requests = e.requests
+				rule.Value = e.metric(rule.Metric)
+				rule.Firing = rule.Value > rule.Threshold
+			}
+
+			alertResults.Lock()
+			wasFiring := alertResults.rules[rule.Id].Firing
+			alertResults.rules[rule.Id] = rule
+			alertResults.Unlock()
+
+			if rule.Firing != wasFiring {
+				sendAlert(ctx, hub, rule)
+			}
+		}
+	}
+}
+
+// Tells the users allowed to manage alerts, and the webhook if there is one, that a rule
+// started or stopped being breached
+func sendAlert(ctx context.Context, hub *chat.Hub, rule structure.AlertRule) {
+	alert := structure.Alert{
+		Status:   "resolved",
+		Rule:     rule,
+		Interval: config.AlertInterval,
+		Date:     clock.Now().Format(config.TimeFormat),
+	}
+	content := "Resolved: " + describeAlertRule(rule)
+	if rule.Firing {
+		alert.Status = "firing"
+		content = fmt.Sprintf("Alert: %s, at %s over %d requests", describeAlertRule(rule), alertValue(rule.Metric, rule.Value), rule.Requests)
+	}
+
+	log.Printf("%s", content)
+
+	uids, err := db.FindPermissionUsers(ctx, config.PermAlertManage)
+	if err != nil {
+		log.Printf("Error finding who to alert: %v", err)
+	}
+
+	for _, uid := range uids {
+		err := notify(ctx, hub, structure.Notification{
+			User_id: uid,
+			Kind:    "alert",
+			Content: content,
+		})
+		if err != nil {
+			log.Printf("Error storing alert notification: %v", err)
+		}
+	}
+
+	if alertWebhook != "" {
+		err = postAlert(alert)
+		if err != nil {
+			log.Printf("Error posting alert to the webhook: %v", err)
+		}
+	}
+}
+
+// Posts an alert to the webhook as json
+func postAlert(alert structure.Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	resp, err := alertClient.Post(alertWebhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Analytics computed for each author, so reloading the dashboard doesn't go through all
+// their reactions and comments again
+var analyticsCache sync.Map
+
+type cachedAnalytics struct {
+	stats   structure.AuthorAnalytics
+	expires time.Time
+}
+
+// AnalyticsHandler shows the logged in user how their posts did over the last days and
+// how their followers grew (/me/analytics)
+func AnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	uid := viewerId(r)
+	if uid == 0 {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	now := clock.Now()
+	if cached, ok := analyticsCache.Load(uid); ok && now.Before(cached.(cachedAnalytics).expires) {
+		writeJSON(w, http.StatusOK, cached.(cachedAnalytics).stats)
+		return
+	}
+
+	stats, err := db.FindAuthorAnalytics(r.Context(), uid, now.AddDate(0, 0, 1-config.AnalyticsDays))
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	analyticsCache.Store(uid, cachedAnalytics{stats: stats, expires: now.Add(config.AnalyticsCacheAge * time.Second)})
+	writeJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/routes"
+	"real-time-forum/internal/structure"
+)
+
+// BannerHandler lets admins list every banner (GET /admin/banners), post one (POST), change
+// it (PUT /admin/banners/{id}) and remove it (DELETE /admin/banners/{id}). Users see the
+// banners showing to them in /bootstrap.
+func BannerHandler(w http.ResponseWriter, r *http.Request) {
+	admin, ok := requirePermission(w, r, config.PermBannerManage)
+	if !ok {
+		return
+	}
+
+	id := 0
+	if param := routes.Param(r, "id"); param != "" {
+		var err error
+		id, err = strconv.Atoi(param)
+		if err != nil {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+	}
+
+	var b structure.Banner
+	if r.Method == "POST" || r.Method == "PUT" {
+		//Decodes the request body into the banner struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&b)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		if msg := validBanner(&b); msg != "" {
+			http.Error(w, "400 bad request: "+msg, http.StatusBadRequest)
+			return
+		}
+	}
+
+	switch {
+	case id == 0 && r.Method == "GET":
+		banners, err := db.FindBanners(r.Context())
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, banners)
+	case id == 0 && r.Method == "POST":
+		b.User_id = admin
+		b.Created = clock.Now().Unix()
+
+		created, err := db.NewBanner(r.Context(), b)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		audit(r.Context(), admin, 0, "banner.create", strconv.Itoa(created.Id))
+		writeCreated(w, "/admin/banners/"+strconv.Itoa(created.Id), created)
+	case id != 0 && r.Method == "PUT":
+		b.Id = id
+		found, err := db.EditBanner(r.Context(), b)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		//Returns the banner as stored, with its author and creation time
+		b, _, err = db.FindBanner(r.Context(), id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		audit(r.Context(), admin, 0, "banner.update", strconv.Itoa(id))
+		writeJSON(w, http.StatusOK, b)
+	case id != 0 && r.Method == "DELETE":
+		found, err := db.DeleteBanner(r.Context(), id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		audit(r.Context(), admin, 0, "banner.delete", strconv.Itoa(id))
+		writeNoContent(w)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// DismissBannerHandler hides a banner from the logged in user on every device
+// (POST /banners/{id}/dismiss). Visitors can only hide them in their browser.
+func DismissBannerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(routes.Param(r, "id"))
+	if err != nil {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	uid := viewerId(r)
+	if uid == 0 {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	_, found, err := db.FindBanner(r.Context(), id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	err = db.DismissBanner(r.Context(), id, uid)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeNoContent(w)
+}
+
+// Checks the fields of a banner sent by an admin, filling in the defaults, and returns what
+// is wrong with it or an empty string. Banners without a start show straight away.
+func validBanner(b *structure.Banner) string {
+	b.Text = strings.TrimSpace(b.Text)
+	if b.Text == "" || utf8.RuneCountInString(b.Text) > config.BannerMaxLength {
+		return "Invalid banner text."
+	}
+
+	switch b.Severity {
+	case "":
+		b.Severity = config.BannerInfo
+	case config.BannerInfo, config.BannerWarning, config.BannerCritical:
+	default:
+		return "Unknown severity."
+	}
+
+	switch b.Audience {
+	case "":
+		b.Audience = config.BannerAudienceAll
+	case config.BannerAudienceAll, config.BannerAudienceMembers, config.BannerAudienceVisitors:
+	default:
+		return "Unknown audience."
+	}
+
+	if b.Starts < 0 || b.Ends < 0 {
+		return "Invalid banner times."
+	}
+	if b.Starts == 0 {
+		b.Starts = clock.Now().Unix()
+	}
+	if b.Ends != 0 && b.Ends <= b.Starts {
+		return "The banner ends before it starts."
+	}
+
+	return ""
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// BatchHandler finds posts, users and comments by their ids in a single request (POST /batch),
+// with one query for each type. Clients bringing their cache up to date after reconnecting
+// send {"posts": [1, 2], "users": [3], "comments": [4]} and are told which ids are gone.
+func BatchHandler(w http.ResponseWriter, r *http.Request) {
+	//Finds the currently logged in user
+	curr, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var ids structure.BatchIds
+
+	//Decodes the request body into the ids struct
+	//Returns a bad request if there's an error
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&ids)
+	if err != nil {
+		http.Error(w, "400 bad request.", http.StatusBadRequest)
+		return
+	}
+
+	if len(ids.Posts) > config.BatchMaxIds || len(ids.Users) > config.BatchMaxIds || len(ids.Comments) > config.BatchMaxIds {
+		http.Error(w, "400 bad request: Up to "+strconv.Itoa(config.BatchMaxIds)+" ids of each type can be asked for.", http.StatusBadRequest)
+		return
+	}
+
+	resp := structure.Batch{
+		Posts:    []structure.Post{},
+		Users:    []structure.UserResponse{},
+		Comments: []structure.Comment{},
+		Missing:  structure.BatchIds{Posts: []int{}, Users: []int{}, Comments: []int{}},
+	}
+
+	if len(ids.Posts) > 0 {
+		posts, err := db.FindPostsByIds(r.Context(), ids.Posts)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		found := make(map[int]bool, len(posts))
+		for _, p := range posts {
+			found[p.Id] = true
+		}
+
+		resp.Posts = append(resp.Posts, hydratePosts(r, posts, nil)...)
+		resp.Missing.Posts = missingIds(ids.Posts, found)
+	}
+
+	if len(ids.Users) > 0 {
+		users, err := db.FindUsersByIds(r.Context(), ids.Users)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		found := make(map[int]bool, len(users))
+		for _, u := range users {
+			found[u.Id] = true
+		}
+
+		//Each profile is shown as far as its privacy settings let the user see it
+		resp.Users = userResponses(r.Context(), curr.Id, users)
+		resp.Missing.Users = missingIds(ids.Users, found)
+	}
+
+	if len(ids.Comments) > 0 {
+		comments, err := db.FindCommentsByIds(r.Context(), ids.Comments)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		found := make(map[int]bool, len(comments))
+		for _, c := range comments {
+			found[c.Id] = true
+		}
+
+		resp.Comments = append(resp.Comments, commentDisplayNames(r.Context(), comments)...)
+		resp.Missing.Comments = missingIds(ids.Comments, found)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Lists the ids asked for that weren't found, once each
+func missingIds(asked []int, found map[int]bool) []int {
+	missing := []int{}
+	for _, id := range asked {
+		if !found[id] {
+			missing = append(missing, id)
+			found[id] = true
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+
+	"real-time-forum/internal/config"
+)
+
+// Hashes the user agent of a request, the raw string is never stored
+func userAgentHash(r *http.Request) string {
+	sum := sha256.Sum256([]byte(r.UserAgent()))
+	return hex.EncodeToString(sum[:])
+}
+
+// Masks the ip address of a request down to its network prefix, so clients
+// moving around the same network keep their session
+func ipPrefix(r *http.Request) string {
+	ip := net.ParseIP(remoteIP(r))
+	if ip == nil {
+		return remoteIP(r)
+	}
+
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(config.SessionIPv4PrefixBits, 32)).String()
+	}
+
+	return ip.Mask(net.CIDRMask(config.SessionIPv6PrefixBits, 128)).String()
+}
+
+// Binds a new session to the browser that logged in
+func bindSession(r *http.Request, session string) error {
+	return db.BindSession(r.Context(), session, userAgentHash(r), ipPrefix(r))
+}
+
+// sessionBinding logs out sessions used from a different browser, or from a different network
+// when ip pinning is on, since the cookie has most likely been stolen
+func sessionBinding(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("session")
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ua, prefix, found, err := db.FindSessionBinding(r.Context(), c.Value)
+		if err != nil || !found {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		reason := ""
+		if config.SessionBindUserAgent && ua != userAgentHash(r) {
+			reason = "user agent"
+		} else if config.SessionPinIP && prefix != ipPrefix(r) {
+			reason = "ip address"
+		}
+
+		if reason == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		//Logs the event against the user before their session is gone
+		uid := viewerId(r)
+		log.Printf("Session of user %d used with a different %s from %s, logging it out", uid, reason, remoteIP(r))
+		if uid != 0 {
+			audit(r.Context(), uid, uid, "session.mismatch", reason+" from "+remoteIP(r))
+		}
+
+		err = db.DeleteSession(r.Context(), c.Value)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		c.MaxAge = -1
+		c.Path = "/"
+		http.SetCookie(w, c)
+
+		//Logging in again is the way out
+		if r.URL.Path == "/login" {
+			cookies := r.Cookies()
+			r.Header.Del("Cookie")
+			for _, ck := range cookies {
+				if ck.Name != "session" {
+					r.AddCookie(ck)
+				}
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		http.Error(w, "401 unauthorized: Please log in again.", http.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// BootstrapHandler returns everything the frontend needs on load in one response (/bootstrap)
+func BootstrapHandler(w http.ResponseWriter, r *http.Request) {
+	categories, err := db.FindCategories(r.Context())
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	err = categoryAccess(r.Context(), viewerId(r), categories)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	b := structure.Bootstrap{
+		Categories:  categories,
+		Features:    features(),
+		Server_time: chat.ServerTime(),
+	}
+	b.Server_time.Msg_type = ""
+
+	//Visitors only get the public part
+	curr, err := currentUser(r)
+	if err != nil {
+		b.Banners, err = db.FindActiveBanners(r.Context(), config.BannerAudienceVisitors, 0)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, b)
+		return
+	}
+
+	self := userResponse(r.Context(), curr.Id, curr)
+	b.User = &self
+	b.Experiments = experimentVariants(curr.Id)
+
+	b.Banners, err = db.FindActiveBanners(r.Context(), config.BannerAudienceMembers, curr.Id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	b.Unread_notifications, err = db.CountUnseenNotifications(r.Context(), curr.Id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	settings, err := exportSettings(r.Context(), curr.Id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	b.Settings = &settings
+
+	pos, err := db.FindFeedPosition(r.Context(), curr.Id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	b.Feed_position = &pos
+
+	writeJSON(w, http.StatusOK, b)
+}
+
+// Optional features and whether they are on, so the frontend knows what to show
+func features() map[string]bool {
+	return map[string]bool{
+		"require_dob":    config.RequireDOB,
+		"require_gender": config.RequireGender,
+		"access_tokens":  tokensEnabled(),
+		"session_ip_pin": config.SessionPinIP,
+		"notifications":  true,
+		"message_drafts": true,
+		"image_uploads":  true,
+		"user_directory": true,
+		"public_read":    config.PublicReadOnly,
+	}
+}
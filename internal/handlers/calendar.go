@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Checks the times, location and guest list visibility of an event, defaulting to a public
+// guest list. Writes a bad request when they are invalid.
+func validEvent(w http.ResponseWriter, e *structure.PostEvent) bool {
+	if e.Attendees == "" {
+		e.Attendees = config.EventAttendeesPublic
+	}
+
+	switch {
+	case e.Starts <= 0 || e.Ends < e.Starts:
+		http.Error(w, "400 bad request: An event has to end after it starts.", http.StatusBadRequest)
+	case utf8.RuneCountInString(e.Location) > config.MaxLocationLength:
+		http.Error(w, "400 bad request: The location is too long.", http.StatusBadRequest)
+	case e.Attendees != config.EventAttendeesPublic && e.Attendees != config.EventAttendeesGuests && e.Attendees != config.EventAttendeesPrivate:
+		http.Error(w, "400 bad request: Invalid attendee visibility.", http.StatusBadRequest)
+	default:
+		return true
+	}
+
+	return false
+}
+
+// Finds the event of a post for the viewer. Writes a not found when the post isn't an event.
+func findEvent(ctx context.Context, w http.ResponseWriter, pid, viewer int) (structure.PostEvent, bool) {
+	e, found, err := db.FindPostEvent(ctx, pid, viewer)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return e, false
+	}
+	if !found {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return e, false
+	}
+
+	return e, true
+}
+
+// Shows the event of a post (GET /posts/{id}/event), and lets its authors change when and
+// where it takes place (PUT)
+func postEvent(w http.ResponseWriter, r *http.Request, curr structure.User, post structure.Post) {
+	e, ok := findEvent(r.Context(), w, post.Id, curr.Id)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, e)
+	case "PUT":
+		ok, err := canEditPost(r.Context(), curr.Id, post)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "403 forbidden", http.StatusForbidden)
+			return
+		}
+
+		var edit structure.PostEvent
+
+		//Decodes the request body into the event struct
+		//Returns a bad request if there's an error
+		err = json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&edit)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+		if !validEvent(w, &edit) {
+			return
+		}
+
+		e.Starts, e.Ends, e.Location, e.Attendees = edit.Starts, edit.Ends, edit.Location, edit.Attendees
+		err = db.UpdateEvent(r.Context(), e)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, e)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Sets the answer of the user to an event (PUT /posts/{id}/rsvp with going, maybe or no)
+// or takes it back (DELETE). Events that are over can't be answered any more.
+func postRsvp(w http.ResponseWriter, r *http.Request, curr structure.User, post structure.Post) {
+	e, ok := findEvent(r.Context(), w, post.Id, curr.Id)
+	if !ok {
+		return
+	}
+
+	if e.Ends <= clock.Now().Unix() {
+		http.Error(w, "409 conflict: The event is over.", http.StatusConflict)
+		return
+	}
+
+	var status string
+	switch r.Method {
+	case "PUT":
+		var rsvp structure.Rsvp
+
+		//Decodes the request body into the rsvp struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&rsvp)
+		if err != nil || (rsvp.Status != config.RsvpGoing && rsvp.Status != config.RsvpMaybe && rsvp.Status != config.RsvpNo) {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		status = rsvp.Status
+	case "DELETE":
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := db.SetRsvp(r.Context(), post.Id, curr.Id, status)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	e, ok = findEvent(r.Context(), w, post.Id, curr.Id)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, e)
+}
+
+// Lists who answered an event (GET /posts/{id}/attendees). The authors decide whether
+// everyone, only the guests or only they can see the list, and only they see who isn't going.
+func postAttendees(w http.ResponseWriter, r *http.Request, curr structure.User, post structure.Post) {
+	e, ok := findEvent(r.Context(), w, post.Id, curr.Id)
+	if !ok {
+		return
+	}
+
+	author, err := canEditPost(r.Context(), curr.Id, post)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	guest := e.Rsvp == config.RsvpGoing || e.Rsvp == config.RsvpMaybe
+	if !author && (e.Attendees == config.EventAttendeesPrivate || (e.Attendees == config.EventAttendeesGuests && !guest)) {
+		http.Error(w, "403 forbidden", http.StatusForbidden)
+		return
+	}
+
+	rsvps, err := db.FindRsvps(r.Context(), post.Id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	names := displayNames(r.Context())
+	attendees := []structure.Rsvp{}
+	for _, rsvp := range rsvps {
+		if rsvp.Status == config.RsvpNo && !author {
+			continue
+		}
+
+		rsvp.Display_name = names[rsvp.User_id]
+		attendees = append(attendees, rsvp)
+	}
+
+	writeJSON(w, http.StatusOK, attendees)
+}
+
+// Exports an event as an iCalendar file to add it to a calendar (GET /posts/{id}/event.ics)
+func postICal(w http.ResponseWriter, r *http.Request, post structure.Post) {
+	e, ok := findEvent(r.Context(), w, post.Id, 0)
+	if !ok {
+		return
+	}
+
+	const stamp = "20060102T150405Z"
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//real-time-forum//events//EN",
+		"BEGIN:VEVENT",
+		"UID:post-" + strconv.Itoa(post.Id) + "@" + strings.TrimPrefix(strings.TrimPrefix(config.SiteURL, "https://"), "http://"),
+		"DTSTAMP:" + clock.Now().UTC().Format(stamp),
+		"DTSTART:" + time.Unix(e.Starts, 0).UTC().Format(stamp),
+		"DTEND:" + time.Unix(e.Ends, 0).UTC().Format(stamp),
+		"SUMMARY:" + icalText(post.Title),
+		"URL:" + config.SiteURL + "/post?param=id&data=" + strconv.Itoa(post.Id),
+	}
+	if e.Location != "" {
+		lines = append(lines, "LOCATION:"+icalText(e.Location))
+	}
+	lines = append(lines, "END:VEVENT", "END:VCALENDAR")
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(icalFold(line))
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="event-`+strconv.Itoa(post.Id)+`.ics"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(b.String()))
+}
+
+// Escapes the characters with a meaning in iCalendar text values
+func icalText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// Folds an iCalendar line into lines of at most 75 bytes, without splitting a character
+func icalFold(line string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range line {
+		size := utf8.RuneLen(c)
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(c)
+		n += size
+	}
+	b.WriteString("\r\n")
+
+	return b.String()
+}
+
+// Reminds the guests of events a while before they start
+func remindEvents(ctx context.Context, hub *chat.Hub) {
+	ticker := clock.NewTicker(config.EventReminderInterval * time.Second)
+	defer ticker.Stop()
+
+	for ticker.Next(ctx) {
+		now := clock.Now().Unix()
+		events, err := db.FindDueEvents(ctx, now, now+config.EventReminderLead)
+		if err != nil {
+			log.Printf("Error finding events to remind: %v", err)
+			continue
+		}
+
+		for _, e := range events {
+			uids, err := db.RemindEvent(ctx, e.Post_id)
+			if err != nil {
+				log.Printf("Error reminding guests of event %d: %v", e.Post_id, err)
+				continue
+			}
+
+			for _, uid := range uids {
+				notifyEvent(ctx, hub, e, uid)
+			}
+		}
+	}
+}
+
+// Lets a guest know an event they are going to starts soon
+func notifyEvent(ctx context.Context, hub *chat.Hub, e structure.DueEvent, uid int) {
+	err := notify(ctx, hub, structure.Notification{
+		User_id: uid,
+		Kind:    "event_reminder",
+		Content: "\"" + e.Title + "\" starts at " + time.Unix(e.Starts, 0).Format(config.TimeFormat),
+		Link:    "/post?param=id&data=" + strconv.Itoa(e.Post_id),
+	})
+	if err != nil {
+		log.Printf("Error storing event reminder: %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/routes"
+	"real-time-forum/internal/structure"
+)
+
+// Category slugs are used in urls, e.g. "CyberSecurity" or "game-nights"
+var categorySlug = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// CategoryHandler lists the post categories (GET /categories) and lets admins add them
+// (POST), rename or move them and change who can post and comment in them
+// (PUT /categories/{slug}), and remove the ones no post is in (DELETE /categories/{slug})
+func CategoryHandler(w http.ResponseWriter, r *http.Request) {
+	slug := routes.Param(r, "slug")
+
+	if slug == "" && r.Method == "GET" {
+		categories, err := db.FindCategories(r.Context())
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		err = categoryAccess(r.Context(), viewerId(r), categories)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, categories)
+		return
+	}
+
+	admin, ok := requirePermission(w, r, config.PermCategoryManage)
+	if !ok {
+		return
+	}
+
+	var c structure.Category
+	if r.Method != "DELETE" {
+		//Decodes the request body into the category struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&c)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		c.Label = strings.TrimSpace(c.Label)
+		if c.Label == "" || utf8.RuneCountInString(c.Label) > config.MaxNameLength {
+			http.Error(w, "400 bad request: Invalid category name.", http.StatusBadRequest)
+			return
+		}
+
+		//Categories open to every member have no permission
+		if (c.Post_permission != "" && !isPermission(c.Post_permission)) || (c.Comment_permission != "" && !isPermission(c.Comment_permission)) {
+			http.Error(w, "400 bad request: Unknown permission.", http.StatusBadRequest)
+			return
+		}
+		c.Can_post, c.Can_comment = false, false
+	}
+
+	switch {
+	case slug == "" && r.Method == "POST":
+		if !categorySlug.MatchString(c.Name) {
+			http.Error(w, "400 bad request: Invalid category slug.", http.StatusBadRequest)
+			return
+		}
+
+		_, found, err := db.FindCategory(r.Context(), c.Name)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if found {
+			http.Error(w, "409 conflict: The category already exists.", http.StatusConflict)
+			return
+		}
+
+		err = db.NewCategory(r.Context(), c)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		audit(r.Context(), admin, 0, "category.create", c.Name)
+		writeCreated(w, "/categories/"+c.Name, c)
+	case slug != "" && r.Method == "PUT":
+		//The slug can't change as posts are stored with it
+		c.Name = slug
+		found, err := db.EditCategory(r.Context(), c)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		audit(r.Context(), admin, 0, "category.update", c.Name)
+		writeJSON(w, http.StatusOK, c)
+	case slug != "" && r.Method == "DELETE":
+		used, err := db.CategoryInUse(r.Context(), slug)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if used {
+			http.Error(w, "409 conflict: Posts are still in the category.", http.StatusConflict)
+			return
+		}
+
+		found, err := db.DeleteCategory(r.Context(), slug)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		audit(r.Context(), admin, 0, "category.delete", slug)
+		writeNoContent(w)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Checks a category is one of the forum's
+func isCategory(ctx context.Context, category string) bool {
+	_, found, err := db.FindCategory(ctx, category)
+	return err == nil && found
+}
+
+// Checks whether a user holds the permission a category asks to post or comment in it.
+// Categories without one are open to every member.
+func categoryAllows(ctx context.Context, uid int, permission string) (bool, error) {
+	if permission == "" {
+		return uid != 0, nil
+	}
+
+	return can(ctx, uid, permission)
+}
+
+// Tells the viewer which of the categories they can post and comment in
+func categoryAccess(ctx context.Context, uid int, categories []structure.Category) error {
+	var err error
+	for i := range categories {
+		categories[i].Can_post, err = categoryAllows(ctx, uid, categories[i].Post_permission)
+		if err != nil {
+			return err
+		}
+
+		categories[i].Can_comment, err = categoryAllows(ctx, uid, categories[i].Comment_permission)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Checks a user can post in a category, writing a bad request when there is no such
+// category and a forbidden when it is restricted, e.g. to announcements by the admins
+func canPostIn(ctx context.Context, w http.ResponseWriter, uid int, slug string) bool {
+	c, found, err := db.FindCategory(ctx, slug)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if !found {
+		http.Error(w, "400 bad request: Unknown category.", http.StatusBadRequest)
+		return false
+	}
+
+	ok, err := categoryAllows(ctx, uid, c.Post_permission)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		http.Error(w, "403 forbidden: You can't post in "+c.Label+".", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// Checks a user can comment on a post, going by the category the post is in
+func canCommentOn(ctx context.Context, w http.ResponseWriter, uid, pid int) bool {
+	posts, err := db.FindPostByParam(ctx, "id", strconv.Itoa(pid))
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if len(posts) == 0 {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return false
+	}
+
+	c, found, err := db.FindCategory(ctx, posts[0].Category)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if !found {
+		return true
+	}
+
+	ok, err := categoryAllows(ctx, uid, c.Comment_permission)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		http.Error(w, "403 forbidden: You can't comment in "+c.Label+".", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// Finds the page of the posts of a category asked for with ?page=, starting at 1. Writes
+// a not found when there is no such category.
+func categoryPage(w http.ResponseWriter, r *http.Request, slug string) (*structure.PostPage, bool) {
+	if !isCategory(r.Context(), slug) {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return nil, false
+	}
+
+	//Pages start at 1
+	page := 1
+	if p := r.URL.Query().Get("page"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 {
+			http.Error(w, "400 bad request: Invalid page.", http.StatusBadRequest)
+			return nil, false
+		}
+		page = n
+	}
+
+	//Fetches one extra post to know whether there is a next page
+	posts, err := db.FindCategoryPosts(r.Context(), slug, config.PostPageSize+1, (page-1)*config.PostPageSize)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	resp := &structure.PostPage{Posts: []structure.Post{}, Page: page}
+	if len(posts) > config.PostPageSize {
+		posts = posts[:config.PostPageSize]
+		resp.Has_more = true
+	}
+	resp.Posts = append(resp.Posts, posts...)
+
+	return resp, true
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"real-time-forum/internal/chaos"
+)
+
+// faultInjection delays and fails random requests when fault injection is enabled.
+// The page and its static files are left alone so the frontend still loads.
+func faultInjection(next http.Handler) http.Handler {
+	if !chaos.Enabled() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" || strings.HasPrefix(r.URL.Path, "/frontend/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		chaos.Delay()
+
+		if chaos.Fail() {
+			w.Header().Set("X-Injected-Fault", "error")
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
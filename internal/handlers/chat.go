@@ -5,22 +5,10 @@ import (
 	"net/http"
 	"strconv"
 
-	"real-time-forum/internal/config"
-	"real-time-forum/internal/database"
 	"real-time-forum/internal/structure"
 )
 
 func ChatHandler(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/chat" {
-		http.Error(w, "404 not found.", http.StatusNotFound)
-		return
-	}
-
-	if r.Method != "GET" {
-		http.Error(w, "405 method not allowed.", http.StatusMethodNotAllowed)
-		return
-	}
-
 	user_id := r.URL.Query().Get("user_id")
 	if user_id == "" {
 		http.Error(w, "400 bad request", http.StatusBadRequest)
@@ -33,7 +21,7 @@ func ChatHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	users, err := database.FindUserChats(config.Path, uid)
+	users, err := db.FindUserChats(r.Context(), uid)
 	if err != nil {
 		http.Error(w, "500 internal server error", http.StatusInternalServerError)
 		return
@@ -50,8 +38,9 @@ func ChatHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var msg = structure.OnlineUsers{
-		UserIds:  chatUsers,
-		Msg_type: "",
+		UserIds:       chatUsers,
+		Msg_type:      "",
+		Display_names: displayNames(r.Context()),
 	}
 
 	resp, err := json.Marshal(msg)
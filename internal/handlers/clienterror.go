@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/secrets"
+	"real-time-forum/internal/structure"
+)
+
+// Share of the reports kept and the url they are posted to, read once at startup
+var (
+	clientErrorSampleRate = sampleRate(config.ClientErrorSampleRateEnv, 1)
+	clientErrorWebhook    = secrets.Getenv(config.ClientErrorWebhookEnv)
+)
+
+var clientErrorLimiter = newRateLimiter(config.ClientErrorRateLimit, config.ClientErrorRateWindow*time.Second)
+
+var clientErrorClient = &http.Client{Timeout: config.ClientErrorWebhookTimeout * time.Second}
+
+// ClientErrorHandler takes the reports of the errors the frontend runs into (POST /client-errors),
+// with the message, stack, route and app version, and the X-Request-ID and status of the api
+// response the error followed. A sample of them is stored, and posted to the webhook when
+// there is one. Visitors who aren't logged in can report errors too.
+func ClientErrorHandler(w http.ResponseWriter, r *http.Request) {
+	if !limitRequest(w, clientErrorLimiter.take(clientKey(r))) {
+		return
+	}
+
+	var report structure.ClientError
+
+	//Decodes the request body into the client error struct
+	//Returns a bad request if there's an error
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&report)
+	if err != nil {
+		http.Error(w, "400 bad request.", http.StatusBadRequest)
+		return
+	}
+
+	if report.Message == "" {
+		http.Error(w, "400 bad request: The error message is missing.", http.StatusBadRequest)
+		return
+	}
+
+	//Reports left out of the sample are accepted all the same, the client has nothing to do about them
+	if rand.Float64() >= clientErrorSampleRate {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	report = structure.ClientError{
+		User_id:     viewerId(r),
+		Message:     truncate(report.Message, config.ClientErrorMaxField),
+		Stack:       truncate(report.Stack, config.ClientErrorMaxStack),
+		Route:       truncate(report.Route, config.ClientErrorMaxField),
+		App_version: truncate(report.App_version, config.ClientErrorMaxField),
+		Request_id:  truncate(report.Request_id, config.RequestIDMaxLength),
+		Status:      report.Status,
+		User_agent:  truncate(r.UserAgent(), config.ClientErrorMaxField),
+		Created:     clock.Now().Unix(),
+	}
+
+	err = db.NewClientError(r.Context(), report)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if clientErrorWebhook != "" {
+		go func() {
+			err := postClientError(report)
+			if err != nil {
+				log.Printf("Error posting client error to the webhook: %v", err)
+			}
+		}()
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ClientErrorListHandler lists the latest errors reported by the frontend (GET /admin/client-errors)
+func ClientErrorListHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requirePermission(w, r, config.PermMetricsView); !ok {
+		return
+	}
+
+	errors, err := db.FindClientErrors(r.Context(), config.ClientErrorListLimit)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, errors)
+}
+
+// Posts a report to the webhook as json
+func postClientError(report structure.ClientError) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	resp, err := clientErrorClient.Post(clientErrorWebhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Removes the reports older than the retention period
+func cleanupClientErrors(ctx context.Context) {
+	ticker := clock.NewTicker(config.CleanupInterval * time.Second)
+	defer ticker.Stop()
+
+	for ticker.Next(ctx) {
+		removed, err := db.DeleteOldClientErrors(ctx, clock.Now().Add(-config.ClientErrorRetention*time.Second).Unix())
+		if err != nil {
+			log.Printf("Error removing old client errors: %v", err)
+			continue
+		}
+
+		if removed > 0 {
+			log.Printf("Removed %d old client errors", removed)
+		}
+	}
+}
+
+// Cuts a string down to at most n bytes without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"real-time-forum/internal/config"
+)
+
+// Keys are a namespace and a name separated by a dot, e.g. "ui.theme" or "chat.sound"
+var clientPrefKey = regexp.MustCompile(`^[a-z0-9_-]+\.[a-z0-9_.-]+$`)
+
+// ClientPrefHandler stores small namespaced preferences of the client apps so they follow the user across devices
+func ClientPrefHandler(w http.ResponseWriter, r *http.Request) {
+	//Finds the currently logged in user
+	curr, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		prefs, err := db.FindClientPrefs(r.Context(), curr.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		//Optionally only returns a single namespace (/me/client-preferences?namespace=ui)
+		if ns := r.URL.Query().Get("namespace"); ns != "" {
+			for key := range prefs {
+				if !strings.HasPrefix(key, ns+".") {
+					delete(prefs, key)
+				}
+			}
+		}
+
+		writeJSON(w, http.StatusOK, prefs)
+	case "PUT":
+		var changes map[string]json.RawMessage
+
+		//Decodes the request body into a map of keys to values, null values remove the key
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.ClientPrefMaxBody)).Decode(&changes)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		prefs, err := applyClientPrefs(r.Context(), curr.Id, changes)
+		if err != nil {
+			http.Error(w, "400 bad request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, prefs)
+	case "DELETE":
+		key := r.URL.Query().Get("key")
+		if key == "" {
+			http.Error(w, "400 bad request", http.StatusBadRequest)
+			return
+		}
+
+		err := db.UpdateClientPrefs(r.Context(), curr.Id, map[string]json.RawMessage{key: nil})
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeNoContent(w)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// Validates and stores changes to the client preferences of a user, returning all of their preferences
+func applyClientPrefs(ctx context.Context, uid int, changes map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	prefs, err := db.FindClientPrefs(ctx, uid)
+	if err != nil {
+		return prefs, err
+	}
+
+	for key, value := range changes {
+		if len(key) > config.ClientPrefMaxKeyLength || !clientPrefKey.MatchString(key) {
+			return prefs, errors.New("Invalid key " + key + ".")
+		}
+
+		if len(value) > config.ClientPrefMaxSize {
+			return prefs, errors.New("Value of " + key + " is too large.")
+		}
+
+		if value == nil || string(value) == "null" {
+			delete(prefs, key)
+		} else {
+			prefs[key] = value
+		}
+	}
+
+	if len(prefs) > config.ClientPrefMaxKeys {
+		return prefs, errors.New("Too many preferences.")
+	}
+
+	err = db.UpdateClientPrefs(ctx, uid, changes)
+	if err != nil {
+		return prefs, err
+	}
+
+	return prefs, nil
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/database"
+	"real-time-forum/internal/ids"
+	"real-time-forum/internal/structure"
+)
+
+// Sessions last CookieAge seconds of the server clock, and logging in hands out the next
+// identifiers of the generator
+func TestSessionExpiry(t *testing.T) {
+	fake := useFakeClock(t)
+	ids.Set(ids.NewSequence("test"))
+	t.Cleanup(func() { ids.Set(ids.UUID{}) })
+
+	setupTestDB(t)
+	newTestUser(t, "alice", "expiry-password")
+
+	w := httptest.NewRecorder()
+	LoginHandler(w, httptest.NewRequest("POST", "/login", bytes.NewReader([]byte(`{"emailUsername":"alice","password":"expiry-password"}`))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("login failed with %d: %s", w.Code, w.Body)
+	}
+
+	//The device is remembered first, then the session is created
+	cookies := map[string]string{}
+	for _, c := range w.Result().Cookies() {
+		cookies[c.Name] = c.Value
+	}
+	if cookies["device"] != "test-1" || cookies["session"] != "test-2" {
+		t.Fatalf("unexpected cookies %v", cookies)
+	}
+
+	fake.Advance(config.CookieAge*time.Second - time.Second)
+	_, err := db.CurrentUser(context.Background(), "test-2")
+	if err != nil {
+		t.Fatalf("session expired early: %v", err)
+	}
+
+	fake.Advance(time.Second)
+	_, err = db.CurrentUser(context.Background(), "test-2")
+	if err == nil {
+		t.Fatal("session still valid after CookieAge")
+	}
+
+	removed, err := db.PurgeSessions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("%d sessions purged, expected 1", removed)
+	}
+}
+
+// The change log is stamped with the server clock, and the cleanup ticks remove the changes
+// once they are older than SyncRetention
+func TestChangesCleanup(t *testing.T) {
+	fake := useFakeClock(t)
+	setupTestDB(t)
+	uid := newTestUser(t, "alice", "changes-password")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go cleanupChanges(ctx)
+	fake.WaitForTickers(1)
+
+	author := structure.User{Id: uid, Username: "alice"}
+	post := structure.Post{Category: config.Categories[0][0], Title: "Old", Content: "Posted when the log starts."}
+	_, err := db.NewPost(ctx, post, author)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//A cleanup tick right before the first change is due keeps it
+	fake.Advance(config.SyncRetention*time.Second - config.CleanupInterval*time.Second)
+
+	post.Title = "New"
+	_, err = db.NewPost(ctx, post, author)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, last, err := db.FindChangeBounds(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//The next ticks remove the first change, the second is not due yet
+	fake.Advance(2 * config.CleanupInterval * time.Second)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		first, _, err := db.FindChangeBounds(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first == last {
+			return
+		}
+		if first == 0 {
+			t.Fatal("every change was removed")
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("oldest change is %d, expected %d", first, last)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// useFakeClock sets the server clock to a fake one for the test
+func useFakeClock(t *testing.T) *clock.Fake {
+	fake := clock.NewFake(time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC))
+	clock.Set(fake)
+	t.Cleanup(func() { clock.Set(clock.System{}) })
+
+	return fake
+}
+
+// setupTestDB makes the handlers use a throwaway database for the test
+func setupTestDB(tb testing.TB) {
+	store, err := database.Open(filepath.Join(tb.TempDir(), "forum.db"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { store.Close() })
+
+	db = store
+	err = db.InitDB()
+	if err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// newTestUser creates an account with nothing left to verify and a complete profile,
+// returning its id
+func newTestUser(tb testing.TB, username, password string) int {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	err = db.NewUser(context.Background(), structure.User{Username: username, Firstname: username, Surname: "Test", Gender: "other", DOB: "2000-01-01", Email: username + "@example.com", Password: string(hash)})
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	u, err := db.FindUserByParam(context.Background(), "username", username)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return u.Id
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Checks whether a user may edit a post, as its author or one of its co-authors
+func canEditPost(ctx context.Context, uid int, post structure.Post) (bool, error) {
+	if uid == post.User_id {
+		return true, nil
+	}
+
+	return db.IsPostCoauthor(ctx, post.Id, uid)
+}
+
+// Changes the title and content of a post. The editor has to hold the edit lock of the post
+// so co-authors editing at the same time don't overwrite each other.
+func editPost(w http.ResponseWriter, r *http.Request, curr structure.User, post structure.Post) {
+	ok, err := canEditPost(r.Context(), curr.Id, post)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "403 forbidden", http.StatusForbidden)
+		return
+	}
+
+	var edit structure.Post
+
+	//Decodes the request body into the post struct
+	//Returns a bad request if there's an error
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&edit)
+	if err != nil {
+		http.Error(w, "400 bad request.", http.StatusBadRequest)
+		return
+	}
+
+	if strings.TrimSpace(edit.Title) == "" || strings.TrimSpace(edit.Content) == "" {
+		http.Error(w, "400 bad request: The title and content are required.", http.StatusBadRequest)
+		return
+	}
+
+	//The license is kept unless a new one is given
+	if edit.License != "" && !isLicense(edit.License) {
+		http.Error(w, "400 bad request: Unknown license.", http.StatusBadRequest)
+		return
+	}
+
+	lock, err := db.FindPostLock(r.Context(), post.Id, clock.Now().Unix())
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	if lock.User_id == 0 {
+		http.Error(w, "428 precondition required: Acquire the edit lock first.", http.StatusPreconditionRequired)
+		return
+	}
+	if lock.User_id != curr.Id {
+		lock.Display_name = displayNames(r.Context())[lock.User_id]
+		writeJSON(w, http.StatusConflict, lock)
+		return
+	}
+
+	post.Title, post.Content = edit.Title, edit.Content
+	if edit.License != "" {
+		post.License = edit.License
+	}
+	err = db.EditPost(r.Context(), post, curr.Id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	post.Edited = clock.Now().Format(config.TimeFormat)
+
+	writeJSON(w, http.StatusOK, postDisplayNames(r.Context(), []structure.Post{post})[0])
+}
+
+// Lists, adds and removes the co-authors of a post. Only the author adds and removes them,
+// except that co-authors can remove themselves.
+func postCoauthors(hub *chat.Hub, w http.ResponseWriter, r *http.Request, curr structure.User, post structure.Post) {
+	switch r.Method {
+	case "GET":
+		uids, err := db.FindPostCoauthors(r.Context(), post.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		names := displayNames(r.Context())
+		coauthors := []structure.Coauthor{}
+		for _, uid := range uids {
+			coauthors = append(coauthors, structure.Coauthor{User_id: uid, Display_name: names[uid]})
+		}
+
+		writeJSON(w, http.StatusOK, coauthors)
+	case "POST":
+		if curr.Id != post.User_id {
+			http.Error(w, "403 forbidden", http.StatusForbidden)
+			return
+		}
+
+		var c structure.Coauthor
+
+		//Decodes the request body into the co-author struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&c)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		names := displayNames(r.Context())
+		name, found := names[c.User_id]
+		if !found || c.User_id == post.User_id {
+			http.Error(w, "400 bad request: Unknown user.", http.StatusBadRequest)
+			return
+		}
+
+		uids, err := db.FindPostCoauthors(r.Context(), post.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if len(uids) >= config.MaxCoauthors {
+			http.Error(w, "409 conflict: Too many co-authors.", http.StatusConflict)
+			return
+		}
+
+		added, err := db.AddPostCoauthor(r.Context(), post.Id, c.User_id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		c.Display_name = name
+		if added {
+			go notifyCoauthor(context.Background(), hub, post, names[post.User_id], c.User_id)
+		}
+
+		writeCreated(w, "/posts/"+strconv.Itoa(post.Id)+"/coauthors", c)
+	case "DELETE":
+		uid, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		if curr.Id != post.User_id && curr.Id != uid {
+			http.Error(w, "403 forbidden", http.StatusForbidden)
+			return
+		}
+
+		found, err := db.RemovePostCoauthor(r.Context(), post.Id, uid)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		writeNoContent(w)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Acquires, renews and releases the edit lock of a post. Locks expire after PostLockTTL
+// seconds so one left behind by a closed editor doesn't block the other authors for long.
+func postLock(w http.ResponseWriter, r *http.Request, curr structure.User, post structure.Post) {
+	ok, err := canEditPost(r.Context(), curr.Id, post)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "403 forbidden", http.StatusForbidden)
+		return
+	}
+
+	now := clock.Now().Unix()
+	expires := now + config.PostLockTTL
+
+	switch r.Method {
+	case "POST":
+		lock, err := db.AcquirePostLock(r.Context(), post.Id, curr.Id, now, expires)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		lock.Display_name = displayNames(r.Context())[lock.User_id]
+		if lock.User_id != curr.Id {
+			//Tells who is editing and until when
+			writeJSON(w, http.StatusConflict, lock)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, lock)
+	case "PUT":
+		renewed, err := db.RenewPostLock(r.Context(), post.Id, curr.Id, now, expires)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !renewed {
+			http.Error(w, "409 conflict: The edit lock is not held.", http.StatusConflict)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, structure.PostLock{
+			Post_id:      post.Id,
+			User_id:      curr.Id,
+			Display_name: displayNames(r.Context())[curr.Id],
+			Expires:      expires,
+		})
+	case "DELETE":
+		err := db.ReleasePostLock(r.Context(), post.Id, curr.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeNoContent(w)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Lets a user know they were made a co-author of a post
+func notifyCoauthor(ctx context.Context, hub *chat.Hub, post structure.Post, author string, uid int) {
+	err := notify(ctx, hub, structure.Notification{
+		User_id: uid,
+		Kind:    "coauthor",
+		Content: author + " added you as a co-author of \"" + post.Title + "\"",
+		Link:    "/post?param=id&data=" + strconv.Itoa(post.Id),
+	})
+	if err != nil {
+		log.Printf("Error storing co-author notification: %v", err)
+	}
+}
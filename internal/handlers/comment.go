@@ -4,19 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"real-time-forum/internal/config"
-	"real-time-forum/internal/database"
 	"real-time-forum/internal/structure"
 )
 
 func CommentHandler(w http.ResponseWriter, r *http.Request) {
-	//Prevents the endpoint being called by other url paths
-	if r.URL.Path != "/comment" {
-		http.Error(w, "404 not found.", http.StatusNotFound)
-		return
-	}
-
 	//Checks whether it is a POST or GET request
 	switch r.Method {
 	case "GET":
@@ -29,14 +23,14 @@ func CommentHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		//Finds the comments based on the search parameter and data
-		comments, err := database.FindCommentByParam(config.Path, param, data)
+		comments, err := db.FindCommentByParam(r.Context(), param, data)
 		if err != nil {
 			http.Error(w, "500 internal server error", http.StatusInternalServerError)
 			return
 		}
 
 		//Marshals the array of comment structs to a json object
-		resp, err := json.Marshal(comments)
+		resp, err := json.Marshal(commentDisplayNames(r.Context(), comments))
 		if err != nil {
 			http.Error(w, "500 internal server error", http.StatusInternalServerError)
 			return
@@ -51,7 +45,7 @@ func CommentHandler(w http.ResponseWriter, r *http.Request) {
 
 		//Decodes the request body into the post struct
 		//Returns a bad request if there's an error
-		err := json.NewDecoder(r.Body).Decode(&newComment)
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&newComment)
 		if err != nil {
 			http.Error(w, "400 bad request.", http.StatusBadRequest)
 			return
@@ -59,23 +53,46 @@ func CommentHandler(w http.ResponseWriter, r *http.Request) {
 
 		fmt.Println(newComment)
 
+		if !requireSteps(r.Context(), w, newComment.User_id, config.ActionComment) {
+			return
+		}
+
+		//Replies have to be to a comment on the same post
+		if newComment.Parent_comment_id != 0 {
+			parent, ok := replyParent(r.Context(), w, newComment.Parent_comment_id)
+			if !ok {
+				return
+			}
+			if parent.Post_id != newComment.Post_id {
+				http.Error(w, "400 bad request.", http.StatusBadRequest)
+				return
+			}
+		}
+
+		//Locked threads take no new comments
+		if !threadOpen(r.Context(), w, newComment.Post_id) || !canCommentOn(r.Context(), w, newComment.User_id, newComment.Post_id) {
+			return
+		}
+
 		//Attemps to add the new post to the database
-		err = database.NewComment(config.Path, newComment)
+		id, err := db.NewComment(r.Context(), newComment)
 		if err != nil {
 			http.Error(w, "500 internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		var msg = structure.Resp{Msg: "Sent comment"}
-
-		resp, err := json.Marshal(msg)
-		if err != nil {
+		created, err := db.FindCommentByParam(r.Context(), "id", strconv.Itoa(id))
+		if err != nil || len(created) == 0 {
 			http.Error(w, "500 internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
-		w.Write(resp)
+		comment := commentDisplayNames(r.Context(), created)[0]
+
+		//The comment is streamed to the viewers of the post from the outbox
+		wakeOutbox()
+
+		writeCreated(w, "/comment?param=id&data="+strconv.Itoa(id), comment)
 	default:
 		//Prevents the use of other request types
 		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+	"real-time-forum/internal/wsclient"
+)
+
+// Runs the websocket conformance checks of the reference client against the whole server,
+// on a throwaway database
+func TestWebsocketConformance(t *testing.T) {
+	setupTestDB(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	//New posts reach the hub through the outbox
+	hub := chat.NewHub(bus, db)
+	subscribe(ctx, hub)
+	go hub.Run()
+	go dispatchOutbox(ctx)
+
+	server := httptest.NewServer(newRouter(hub))
+	defer server.Close()
+
+	//Accounts created in the database directly have nothing left to verify, and with a
+	//complete profile they can message each other as strangers
+	accounts := []wsclient.Account{
+		{Identifier: "alice", Password: "conformance-alice"},
+		{Identifier: "bob", Password: "conformance-bob"},
+	}
+	for _, a := range accounts {
+		newTestUser(t, a.Identifier, a.Password)
+	}
+
+	//The checks view a post, written by the first account
+	session, _, err := wsclient.Login(server.URL, accounts[0].Identifier, accounts[0].Password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post, err := wsclient.CreatePost(server.URL, session, structure.Post{
+		Category: config.Categories[0][0],
+		Title:    "Conformance",
+		Content:  "The post the conformance checks view.",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range wsclient.Conform(server.URL, accounts[0], accounts[1], post.Id) {
+		if r.Err != nil {
+			t.Errorf("%s: %v", r.Name, r.Err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Limits how often a user can look up their contacts, so the lookup cannot be used to
+// check whether arbitrary emails are registered
+var contactLimiter = newRateLimiter(config.ContactMatchRateLimit, config.ContactMatchRateWindow*time.Second)
+
+// ContactMatchHandler finds which contacts of the logged in user are on the forum (POST /contacts/match).
+// The client sends the hex encoded sha256 of each email, trimmed and lowercased, and gets
+// back the hashes that belong to users who turned on discoverable in their privacy settings.
+// Only users who are discoverable themselves can look up others.
+func ContactMatchHandler(w http.ResponseWriter, r *http.Request) {
+	//Finds the currently logged in user
+	curr, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	p, err := db.FindPrivacy(r.Context(), curr.Id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if !p.Discoverable {
+		http.Error(w, "403 forbidden: Turn on discoverable to find your contacts.", http.StatusForbidden)
+		return
+	}
+
+	var req structure.ContactHashes
+
+	//Decodes the request body into the contact hashes struct
+	//Returns a bad request if there's an error
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&req)
+	if err != nil {
+		http.Error(w, "400 bad request.", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Hashes) > config.ContactMatchMaxHashes {
+		http.Error(w, "413 request entity too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if !limitRequest(w, contactLimiter.take(clientKey(r))) {
+		return
+	}
+
+	emails, err := db.FindDiscoverableEmails(r.Context())
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	byHash := make(map[string]int, len(emails))
+	for id, email := range emails {
+		if id != curr.Id {
+			byHash[emailHash(email)] = id
+		}
+	}
+
+	names, err := db.FindDisplayNames(r.Context())
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	matches := []structure.ContactMatch{}
+	seen := make(map[string]bool)
+	for _, h := range req.Hashes {
+		h = strings.ToLower(h)
+		id, ok := byHash[h]
+		if !ok || seen[h] {
+			continue
+		}
+		seen[h] = true
+
+		matches = append(matches, structure.ContactMatch{Hash: h, User_id: id, Display_name: names[id]})
+	}
+
+	writeJSON(w, http.StatusOK, matches)
+}
+
+// Hashes an email the way clients do before sending it to be matched
+func emailHash(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/routes"
+	"real-time-forum/internal/structure"
+)
+
+// Registers what belongs to the conversation of the current user with another user: the
+// draft (/conversations/{user}/draft) and the settings (/conversations/{user}/settings).
+// The unread messages of every conversation are counted at /conversations/unread.
+func conversationRoutes(rt *routes.Router, hub *chat.Hub) {
+	rt.HandleFunc("/conversations/unread", unreadCounts, "GET")
+	rt.HandleFunc("/conversations/{user}/draft", conversationRoute(draft), "GET", "PUT", "DELETE")
+	rt.HandleFunc("/conversations/{user}/settings", conversationRoute(func(w http.ResponseWriter, r *http.Request, curr structure.User, rid int) {
+		conversationSettings(hub, w, r, curr, rid)
+	}), "GET", "PUT")
+}
+
+// Serves an endpoint of the conversation with the user of the path to the currently
+// logged in user
+func conversationRoute(h func(http.ResponseWriter, *http.Request, structure.User, int)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rid, err := strconv.Atoi(routes.Param(r, "user"))
+		if err != nil {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		//Finds the currently logged in user
+		curr, err := currentUser(r)
+		if err != nil {
+			http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		h(w, r, curr, rid)
+	}
+}
+
+// Shows and changes whether the user shares typing indicators and read receipts in a
+// conversation. Both users are sent the settings as they now stand.
+func conversationSettings(hub *chat.Hub, w http.ResponseWriter, r *http.Request, curr structure.User, rid int) {
+	switch r.Method {
+	case "GET":
+		s, err := db.FindConversationSettings(r.Context(), curr.Id, rid)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, s)
+	case "PUT":
+		if rid == curr.Id {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		var p structure.ConversationPrefs
+
+		//Decodes the request body into the settings struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&p)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		err = db.UpdateConversationPrefs(r.Context(), curr.Id, rid, p)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		mine, err := db.FindConversationSettings(r.Context(), curr.Id, rid)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		theirs, err := db.FindConversationSettings(r.Context(), rid, curr.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		//The hub stops relaying what is no longer agreed on before anyone is told
+		hub.UpdateConversation(curr.Id, rid, mine.Agreed)
+
+		mine.Msg_type = "conversation_settings"
+		theirs.Msg_type = "conversation_settings"
+		if msg, err := json.Marshal(mine); err == nil {
+			hub.SendTo(curr.Id, msg)
+		}
+		if msg, err := json.Marshal(theirs); err == nil {
+			hub.SendTo(rid, msg)
+		}
+
+		mine.Msg_type = ""
+		writeJSON(w, http.StatusOK, mine)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// Counts the messages the current user has not read yet, in total and per user who sent them
+func unreadCounts(w http.ResponseWriter, r *http.Request) {
+	viewer := viewerId(r)
+	if viewer == 0 {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	counts, err := db.FindUnreadCounts(r.Context(), viewer)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := structure.UnreadCounts{Conversations: counts}
+	for _, c := range counts {
+		resp.Total += c.Count
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Hides when the other user read the messages of the viewer unless they both allow read receipts
+func readTimes(ctx context.Context, viewer, with int, messages []structure.Message) []structure.Message {
+	s, err := db.FindConversationSettings(ctx, viewer, with)
+	if err == nil && s.Agreed.Receipts {
+		return messages
+	}
+
+	for i := range messages {
+		if messages[i].Sender_id == viewer {
+			messages[i].Read_at = ""
+		}
+	}
+
+	return messages
+}
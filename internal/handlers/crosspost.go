@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Checks the categories a new post is cross-posted to, dropping repeats and its own
+// category. Writes a bad request when one is unknown or there are too many, and a
+// forbidden when the user can't post in one.
+func validCrossposts(ctx context.Context, w http.ResponseWriter, uid int, category string, crossposts []string) ([]string, bool) {
+	seen := map[string]bool{category: true}
+	valid := []string{}
+
+	for _, c := range crossposts {
+		if seen[c] {
+			continue
+		}
+		if !canPostIn(ctx, w, uid, c) {
+			return nil, false
+		}
+
+		seen[c] = true
+		valid = append(valid, c)
+	}
+
+	if len(valid) > config.MaxCrossposts {
+		http.Error(w, "400 bad request: Too many categories.", http.StatusBadRequest)
+		return nil, false
+	}
+
+	return valid, true
+}
+
+// Lists the categories a post is cross-posted to (GET /posts/{id}/crossposts), and lets its
+// authors add one (POST) or take one out (DELETE ?category=). The post itself stays in its
+// own category, so its comments and reactions are shared by every category it shows up in.
+func postCrossposts(w http.ResponseWriter, r *http.Request, curr structure.User, post structure.Post) {
+	if r.Method != "GET" {
+		ok, err := canEditPost(r.Context(), curr.Id, post)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "403 forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	crossposts, err := db.FindPostCrossposts(r.Context(), post.Id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, crossposts)
+	case "POST":
+		var c structure.Category
+
+		//Decodes the request body into the category struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&c)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		valid, ok := validCrossposts(r.Context(), w, curr.Id, post.Category, append(crossposts, c.Name))
+		if !ok {
+			return
+		}
+		if len(valid) == len(crossposts) {
+			http.Error(w, "409 conflict: The post is already in that category.", http.StatusConflict)
+			return
+		}
+
+		_, err = db.AddPostCrosspost(r.Context(), post.Id, c.Name)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, valid)
+	case "DELETE":
+		found, err := db.RemovePostCrosspost(r.Context(), post.Id, r.URL.Query().Get("category"))
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		writeNoContent(w)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"real-time-forum/internal/config"
+)
+
+// Share of requests whose bodies are logged, read once at startup
+var logSampleRate = sampleRate(config.LogSampleRateEnv, 0)
+
+// Emails and bearer tokens are removed from anything that gets logged
+var (
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	bearerPattern = regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._\-]+`)
+)
+
+// Reads a sample rate from the environment, which is the default when unset or invalid
+func sampleRate(env string, def float64) float64 {
+	s := os.Getenv(env)
+	if s == "" {
+		return def
+	}
+
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		log.Printf("Ignoring invalid %s %q, it must be between 0 and 1", env, s)
+		return def
+	}
+
+	return rate
+}
+
+// Records what was written to a response, up to the logged size
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+
+	if left := config.LogBodyMaxSize - rw.body.Len(); left > 0 {
+		if len(b) < left {
+			left = len(b)
+		}
+		rw.body.Write(b[:left])
+	}
+
+	return rw.ResponseWriter.Write(b)
+}
+
+// Keeps the unread part of a body after its start was read for logging
+type replayedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// bodyLogging logs the redacted request and response bodies of a sample of requests,
+// to help diagnose what a client sent and got back
+func bodyLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//Websockets need the raw connection and static files are not worth logging
+		if logSampleRate == 0 || r.URL.Path == "/ws" || strings.HasPrefix(r.URL.Path, "/frontend/") || rand.Float64() >= logSampleRate {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var reqBody []byte
+		if r.Body != nil {
+			reqBody, _ = io.ReadAll(io.LimitReader(r.Body, config.LogBodyMaxSize))
+			r.Body = replayedBody{io.MultiReader(bytes.NewReader(reqBody), r.Body), r.Body}
+		}
+
+		rw := &recordingWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+
+		log.Printf("%s %s %d request=%s response=%s",
+			r.Method,
+			redactQuery(r.URL),
+			rw.status,
+			redactBody(reqBody, r.Header.Get("Content-Type")),
+			redactBody(rw.body.Bytes(), rw.Header().Get("Content-Type")),
+		)
+	})
+}
+
+// Checks whether a json key or form field holds a credential or personal data
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range config.LogRedactedFields {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the path and query of the url with sensitive parameters removed
+func redactQuery(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+
+	q := u.Query()
+	for k := range q {
+		if isSensitive(k) {
+			q.Set(k, "[redacted]")
+		}
+	}
+
+	return u.Path + "?" + redactText(q.Encode())
+}
+
+// Makes a body safe to log. Json and form bodies have their sensitive fields removed,
+// other bodies only have their size logged. So do json bodies that don't parse, which
+// includes those cut off at the logged size, as their fields can't be told apart.
+func redactBody(body []byte, contentType string) string {
+	if len(body) == 0 {
+		return "-"
+	}
+
+	switch {
+	case strings.HasPrefix(contentType, "multipart/"), strings.HasPrefix(contentType, "image/"), strings.HasPrefix(contentType, "application/octet-stream"):
+		return bodySize(body, contentType)
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		form, err := url.ParseQuery(string(body))
+		if err == nil {
+			for k := range form {
+				if isSensitive(k) {
+					form.Set(k, "[redacted]")
+				}
+			}
+			return redactText(form.Encode())
+		}
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return bodySize(body, contentType)
+	}
+
+	b, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return "-"
+	}
+
+	return string(b)
+}
+
+// Describes a body by its size and type only, bodies at the logged size were cut off
+func bodySize(body []byte, contentType string) string {
+	size := strconv.Itoa(len(body))
+	if len(body) >= config.LogBodyMaxSize {
+		size += "+"
+	}
+
+	return "<" + size + " bytes " + contentType + ">"
+}
+
+// Replaces the sensitive fields of decoded json, at any depth
+func redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if isSensitive(k) {
+				t[k] = "[redacted]"
+			} else {
+				t[k] = redactValue(val)
+			}
+		}
+	case []interface{}:
+		for i, val := range t {
+			t[i] = redactValue(val)
+		}
+	case string:
+		return redactText(t)
+	}
+
+	return v
+}
+
+// Removes emails and bearer tokens from free text
+func redactText(s string) string {
+	s = emailPattern.ReplaceAllString(s, "[email]")
+	return bearerPattern.ReplaceAllString(s, "Bearer [redacted]")
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Limits how fast a single client can page through the directory
+var directoryLimiter = newRateLimiter(config.DirectoryRateLimit, config.DirectoryRateWindow*time.Second)
+
+// UserDirectoryHandler lists public profile cards, optionally filtered by a username or display name prefix
+func UserDirectoryHandler(w http.ResponseWriter, r *http.Request) {
+	//Deters scraping of the whole user base
+	if !limitRequest(w, directoryLimiter.take(clientKey(r))) {
+		return
+	}
+
+	search := strings.TrimSpace(r.URL.Query().Get("search"))
+
+	//Pages start at 1
+	page := 1
+	if p := r.URL.Query().Get("page"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 {
+			http.Error(w, "400 bad request: Invalid page.", http.StatusBadRequest)
+			return
+		}
+		page = n
+	}
+
+	//Fetches one extra user to know whether there is a next page
+	users, err := db.SearchUsers(r.Context(), search, config.DirectoryPageSize+1, (page-1)*config.DirectoryPageSize)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := structure.UserDirectory{
+		Users: []structure.ProfileCard{},
+		Page:  page,
+	}
+
+	if len(users) > config.DirectoryPageSize {
+		users = users[:config.DirectoryPageSize]
+		resp.Has_more = true
+	}
+
+	for _, u := range users {
+		resp.Users = append(resp.Users, structure.NewProfileCard(u))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
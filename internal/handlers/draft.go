@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Stores the message a user is writing in a conversation (/conversations/{user}/draft)
+func draft(w http.ResponseWriter, r *http.Request, curr structure.User, rid int) {
+	switch r.Method {
+	case "GET":
+		d, err := db.FindDraft(r.Context(), curr.Id, rid)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, d)
+	case "PUT":
+		var d structure.Draft
+
+		//Decodes the request body into the draft struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(r.Body).Decode(&d)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		if len(d.Content) > config.DraftMaxLength {
+			http.Error(w, "413 request entity too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		//An empty draft is the same as no draft
+		if strings.TrimSpace(d.Content) == "" {
+			err = db.DeleteDraft(r.Context(), curr.Id, rid)
+			if err != nil {
+				http.Error(w, "500 internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			writeNoContent(w)
+			return
+		}
+
+		//Users can only write their own drafts
+		d.User_id = curr.Id
+		d.Receiver_id = rid
+
+		d, err = db.UpdateDraft(r.Context(), d)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, d)
+	case "DELETE":
+		err := db.DeleteDraft(r.Context(), curr.Id, rid)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeNoContent(w)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// Periodically removes the drafts that have not been touched for a while
+func cleanupDrafts(ctx context.Context) {
+	ticker := clock.NewTicker(config.CleanupInterval * time.Second)
+	defer ticker.Stop()
+
+	for ticker.Next(ctx) {
+		removed, err := db.DeleteOldDrafts(ctx, clock.Now().Add(-config.DraftMaxAge*time.Second))
+		if err != nil {
+			log.Printf("Error cleaning up drafts: %v", err)
+			continue
+		}
+
+		if removed > 0 {
+			log.Printf("Removed %d stale drafts", removed)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/events"
+	"real-time-forum/internal/structure"
+)
+
+// Events published by the handlers and the websocket hub
+var bus = events.NewBus()
+
+// Subscribes the reactions to what happens on the forum. New features subscribe here
+// rather than being called from the handlers.
+func subscribe(ctx context.Context, hub *chat.Hub) {
+	bus.OnPostCreated("announce_post", func(e events.PostCreated) error {
+		return announcePost(ctx, hub, e)
+	})
+	bus.OnCommentCreated("stream_comment", func(e events.CommentCreated) error {
+		return streamComment(ctx, hub, e)
+	})
+	bus.OnCommentCreated("notify_comment", func(e events.CommentCreated) error {
+		return notifyComment(ctx, hub, e)
+	})
+	bus.OnPostReacted("notify_reaction", func(e events.PostReacted) error {
+		return notifyReaction(ctx, hub, e)
+	})
+	bus.OnMessageSent("delete_sent_draft", func(e events.MessageSent) error {
+		return deleteSentDraft(ctx, e)
+	})
+	bus.OnMessageSent("notify_message", func(e events.MessageSent) error {
+		return notifyMessage(ctx, hub, e)
+	})
+	bus.OnUserLoggedIn("notify_new_login", func(e events.UserLoggedIn) error {
+		notifyNewLogin(ctx, hub, e.UserID, e.IP, e.UserAgent)
+		return nil
+	})
+}
+
+// Lets the others on the feed know there is a new post in it
+func announcePost(ctx context.Context, hub *chat.Hub, e events.PostCreated) error {
+	created, err := db.FindPostByParam(ctx, "id", strconv.Itoa(e.PostID))
+	if err != nil {
+		return err
+	}
+	if len(created) == 0 {
+		return errors.New("post not found")
+	}
+
+	post := postDisplayNames(ctx, created)[0]
+
+	event, err := json.Marshal(structure.NewPostEvent{
+		Msg_type: "new_post",
+		Id:       post.Id,
+		User_id:  post.User_id,
+		Author:   post.Display_name,
+		Title:    post.Title,
+		Category: post.Category,
+	})
+	if err != nil {
+		return err
+	}
+
+	hub.AnnouncePost(post.User_id, event)
+	return nil
+}
+
+// Streams a new comment to everyone viewing its post and updates the feed counters
+func streamComment(ctx context.Context, hub *chat.Hub, e events.CommentCreated) error {
+	created, err := db.FindCommentByParam(ctx, "id", strconv.Itoa(e.CommentID))
+	if err != nil {
+		return err
+	}
+	if len(created) == 0 {
+		return errors.New("comment not found")
+	}
+
+	comment := commentDisplayNames(ctx, created)[0]
+
+	event, err := json.Marshal(structure.CommentEvent{
+		Msg_type: "new_comment",
+		Post_id:  comment.Post_id,
+		Comment:  comment,
+	})
+	if err != nil {
+		return err
+	}
+
+	hub.Publish(comment.Post_id, event)
+	pushCounts(ctx, hub, comment.Post_id)
+	return nil
+}
+
+// The draft of a conversation has been sent
+func deleteSentDraft(ctx context.Context, e events.MessageSent) error {
+	return db.DeleteDraft(ctx, e.Message.Sender_id, e.Message.Receiver_id)
+}
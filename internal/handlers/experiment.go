@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/routes"
+	"real-time-forum/internal/structure"
+)
+
+// Goals of experiments are short names like "posted" or "signed_up"
+var goalPattern = regexp.MustCompile(`^[a-z0-9_]{1,64}$`)
+
+// ExposureHandler records that the logged in user was shown their variant of an experiment
+// (POST /experiments/{name}/exposure). The frontend calls it when it shows the variant, so
+// only users who actually saw it are counted.
+func ExposureHandler(w http.ResponseWriter, r *http.Request) {
+	e, uid, ok := runningExperiment(w, r)
+	if !ok {
+		return
+	}
+
+	err := db.AddExposure(r.Context(), e.Name, assignVariant(e, uid), uid)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ConversionHandler records that the logged in user reached a goal in an experiment
+// (POST /experiments/{name}/conversion), e.g. {"goal": "posted"}
+func ConversionHandler(w http.ResponseWriter, r *http.Request) {
+	e, uid, ok := runningExperiment(w, r)
+	if !ok {
+		return
+	}
+
+	var c structure.ExperimentConversion
+
+	//Decodes the request body into the conversion struct
+	//Returns a bad request if there's an error
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&c)
+	if err != nil {
+		http.Error(w, "400 bad request.", http.StatusBadRequest)
+		return
+	}
+
+	if !goalPattern.MatchString(c.Goal) {
+		http.Error(w, "400 bad request: Invalid goal.", http.StatusBadRequest)
+		return
+	}
+
+	err = db.AddConversion(r.Context(), e.Name, assignVariant(e, uid), uid, c.Goal)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ExperimentResultHandler lists the experiments with the exposures and conversions of each
+// of their variants (GET /admin/experiments)
+func ExperimentResultHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requirePermission(w, r, config.PermMetricsView); !ok {
+		return
+	}
+
+	results := []structure.ExperimentResult{}
+	for _, e := range config.Experiments {
+		exposures, err := db.CountExposures(r.Context(), e.Name)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		conversions, err := db.CountConversions(r.Context(), e.Name)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		result := structure.ExperimentResult{Name: e.Name, Active: e.Active, Variants: []structure.VariantResult{}}
+		for _, v := range e.Variants {
+			goals := conversions[v.Name]
+			if goals == nil {
+				goals = map[string]int{}
+			}
+
+			result.Variants = append(result.Variants, structure.VariantResult{
+				Name:        v.Name,
+				Weight:      v.Weight,
+				Exposures:   exposures[v.Name],
+				Conversions: goals,
+			})
+		}
+
+		results = append(results, result)
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
+
+// Finds the experiment of the route and the logged in user, writing the error response
+// when the experiment isn't running or nobody is logged in
+func runningExperiment(w http.ResponseWriter, r *http.Request) (config.Experiment, int, bool) {
+	uid := viewerId(r)
+	if uid == 0 {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return config.Experiment{}, 0, false
+	}
+
+	e, found := findExperiment(routes.Param(r, "name"))
+	if !found {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return e, 0, false
+	}
+
+	if !e.Active || assignVariant(e, uid) == "" {
+		http.Error(w, "409 conflict: The experiment isn't running.", http.StatusConflict)
+		return e, 0, false
+	}
+
+	return e, uid, true
+}
+
+func findExperiment(name string) (config.Experiment, bool) {
+	for _, e := range config.Experiments {
+		if e.Name == name {
+			return e, true
+		}
+	}
+
+	return config.Experiment{}, false
+}
+
+// Picks the variant of an experiment a user sees. The hash of the experiment and the user
+// spreads users evenly and gives them the same variant every time without storing it,
+// and a different split in each experiment. Empty when no variant has any weight.
+func assignVariant(e config.Experiment, uid int) string {
+	total := 0
+	for _, v := range e.Variants {
+		total += v.Weight
+	}
+
+	if total <= 0 {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(e.Name + ":" + strconv.Itoa(uid)))
+	point := int(binary.BigEndian.Uint64(sum[:8]) % uint64(total))
+
+	for _, v := range e.Variants {
+		if point < v.Weight {
+			return v.Name
+		}
+		point -= v.Weight
+	}
+
+	return ""
+}
+
+// The variants of the running experiments a user sees, by experiment
+func experimentVariants(uid int) map[string]string {
+	variants := make(map[string]string)
+	for _, e := range config.Experiments {
+		if !e.Active {
+			continue
+		}
+
+		if v := assignVariant(e, uid); v != "" {
+			variants[e.Name] = v
+		}
+	}
+
+	return variants
+}
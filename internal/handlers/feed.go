@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// FeedPositionHandler reads and moves where the logged in user left off in the main feed,
+// so they can continue from there on their next visit (/me/feed-position)
+func FeedPositionHandler(w http.ResponseWriter, r *http.Request) {
+	//Finds the currently logged in user
+	curr, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+	case "PUT":
+		var pos structure.FeedPosition
+
+		//Decodes the request body into the feed position struct
+		//Returns a bad request if there's an error
+		err = json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&pos)
+		if err != nil || pos.Last_seen_post_id < 1 {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		err = db.SaveFeedPosition(r.Context(), curr.Id, pos.Last_seen_post_id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pos, err := db.FindFeedPosition(r.Context(), curr.Id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pos)
+}
+
+// Finds the posts of the main feed made after the last one the user saw.
+// Writes the error response when they can't be found.
+func feedAfterLastSeen(w http.ResponseWriter, r *http.Request, param string) ([]structure.Post, bool) {
+	//The position is only kept for the main feed
+	if param != "" {
+		http.Error(w, "400 bad request: after_last_seen only applies to the main feed.", http.StatusBadRequest)
+		return nil, false
+	}
+
+	curr, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	pos, err := db.FindFeedPosition(r.Context(), curr.Id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	posts, err := db.FindPostsAfter(r.Context(), pos.Last_seen_post_id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return posts, true
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"real-time-forum/internal/structure"
+)
+
+// The fields of the items of a list a client asked for with ?fields=id,title,comments,
+// nil when it wants all of them. Clients that only show titles and counters get much
+// smaller responses.
+type fieldSet []string
+
+// Fields of a post that can be selected, read straight from the post so the projection
+// doesn't go through reflection for every item
+var postFields = map[string]func(p *structure.Post) interface{}{
+	"id":             func(p *structure.Post) interface{} { return p.Id },
+	"user_id":        func(p *structure.Post) interface{} { return p.User_id },
+	"category":       func(p *structure.Post) interface{} { return p.Category },
+	"title":          func(p *structure.Post) interface{} { return p.Title },
+	"content":        func(p *structure.Post) interface{} { return p.Content },
+	"date":           func(p *structure.Post) interface{} { return p.Date },
+	"edited":         func(p *structure.Post) interface{} { return p.Edited },
+	"likes":          func(p *structure.Post) interface{} { return p.Likes },
+	"dislikes":       func(p *structure.Post) interface{} { return p.Dislikes },
+	"comments":       func(p *structure.Post) interface{} { return p.Comment_count },
+	"views":          func(p *structure.Post) interface{} { return p.View_count },
+	"display_name":   func(p *structure.Post) interface{} { return p.Display_name },
+	"attachment_ids": func(p *structure.Post) interface{} { return p.Attachment_ids },
+	"images":         func(p *structure.Post) interface{} { return p.Images },
+	"reaction":       func(p *structure.Post) interface{} { return p.Reaction },
+	"license":        func(p *structure.Post) interface{} { return p.License },
+	"event":          func(p *structure.Post) interface{} { return p.Event },
+	"series":         func(p *structure.Post) interface{} { return p.Series },
+	"crossposts":     func(p *structure.Post) interface{} { return p.Crossposts },
+}
+
+// Fields of a search result that can be selected, like postFields
+var searchResultFields = map[string]func(s *structure.SearchResult) interface{}{
+	"kind":         func(s *structure.SearchResult) interface{} { return s.Kind },
+	"post_id":      func(s *structure.SearchResult) interface{} { return s.Post_id },
+	"comment_id":   func(s *structure.SearchResult) interface{} { return s.Comment_id },
+	"title":        func(s *structure.SearchResult) interface{} { return s.Title },
+	"snippet":      func(s *structure.SearchResult) interface{} { return s.Snippet },
+	"user_id":      func(s *structure.SearchResult) interface{} { return s.User_id },
+	"display_name": func(s *structure.SearchResult) interface{} { return s.Display_name },
+	"date":         func(s *structure.SearchResult) interface{} { return s.Date },
+}
+
+// Reads the fields asked for in the query, answering a bad request when one of them isn't
+// a known field
+func selectedFields(w http.ResponseWriter, r *http.Request, known func(string) bool) (fieldSet, bool) {
+	q := r.URL.Query().Get("fields")
+	if q == "" {
+		return nil, true
+	}
+
+	var fields fieldSet
+	for _, f := range strings.Split(q, ",") {
+		f = strings.TrimSpace(f)
+		if !known(f) {
+			http.Error(w, "400 bad request: Unknown field "+f+".", http.StatusBadRequest)
+			return nil, false
+		}
+
+		if fields == nil || !fields.has(f) {
+			fields = append(fields, f)
+		}
+	}
+
+	return fields, true
+}
+
+func isPostField(f string) bool {
+	_, ok := postFields[f]
+	return ok
+}
+
+func isSearchResultField(f string) bool {
+	_, ok := searchResultFields[f]
+	return ok
+}
+
+// Checks whether a field was asked for
+func (f fieldSet) has(name string) bool {
+	if f == nil {
+		return true
+	}
+
+	for _, field := range f {
+		if field == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Keeps the fields asked for of each post
+func projectPosts(posts []structure.Post, fields fieldSet) []map[string]interface{} {
+	out := make([]map[string]interface{}, len(posts))
+	for i := range posts {
+		out[i] = make(map[string]interface{}, len(fields))
+		for _, f := range fields {
+			out[i][f] = postFields[f](&posts[i])
+		}
+	}
+
+	return out
+}
+
+// Keeps the fields asked for of each search result
+func projectSearchResults(results []structure.SearchResult, fields fieldSet) []map[string]interface{} {
+	out := make([]map[string]interface{}, len(results))
+	for i := range results {
+		out[i] = make(map[string]interface{}, len(fields))
+		for _, f := range fields {
+			out[i][f] = searchResultFields[f](&results[i])
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// The account the fuzz targets log in as, the one the login seeds use
+const (
+	fuzzUsername = "fuzzer"
+	fuzzPassword = "fuzz-password"
+)
+
+// setupFuzz creates a throwaway database with a verified account and logs it in, returning
+// the session cookie for the targets that need one
+func setupFuzz(f *testing.F) *http.Cookie {
+	setupTestDB(f)
+	newTestUser(f, fuzzUsername, fuzzPassword)
+
+	w := httptest.NewRecorder()
+	LoginHandler(w, httptest.NewRequest("POST", "/login", bytes.NewReader([]byte(`{"emailUsername":"`+fuzzUsername+`","password":"`+fuzzPassword+`"}`))))
+
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "session" {
+			return c
+		}
+	}
+
+	f.Fatalf("login failed with %d: %s", w.Code, w.Body)
+	return nil
+}
+
+// fuzzRequest sends the data as the body of a request to the handler, which must answer it
+// whatever it is
+func fuzzRequest(t *testing.T, handler http.HandlerFunc, target string, data []byte, session *http.Cookie) {
+	r := httptest.NewRequest("POST", target, bytes.NewReader(data))
+	if session != nil {
+		r.AddCookie(session)
+	}
+
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	if w.Code < 100 || w.Code > 599 {
+		t.Errorf("invalid status %d", w.Code)
+	}
+}
+
+func FuzzRegister(f *testing.F) {
+	setupFuzz(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzRequest(t, RegisterHandler, "/register", data, nil)
+	})
+}
+
+func FuzzLogin(f *testing.F) {
+	setupFuzz(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzRequest(t, LoginHandler, "/login", data, nil)
+	})
+}
+
+func FuzzPost(f *testing.F) {
+	session := setupFuzz(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzRequest(t, PostHandler, "/post", data, session)
+	})
+}
+
+func FuzzMessage(f *testing.F) {
+	session := setupFuzz(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzRequest(t, MessageHandler, "/message", data, session)
+	})
+}
@@ -3,17 +3,5 @@ package handlers
 import "net/http"
 
 func HomeHandler(w http.ResponseWriter, r *http.Request) {
-	//Prevents the endpoint being called by other url paths
-	if r.URL.Path != "/" {
-		http.Error(w, "404 not found.", http.StatusNotFound)
-		return
-	}
-
-	//Prevent all request types other than GET
-	if r.Method != "GET" {
-		http.Error(w, "405 method not allowed.", http.StatusMethodNotAllowed)
-		return
-	}
-
 	http.ServeFile(w, r, "./frontend/index.html")
 }
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/imgproxy"
+)
+
+// Recently proxied images
+var imageCache = imgproxy.NewCache(config.ImageProxyCacheSize, config.ImageProxyCacheAge*time.Second)
+
+// Downloads external images for the proxy
+var imageFetcher = imgproxy.NewFetcher(config.ImageProxyMaxSize, config.ImageProxyTimeout*time.Second)
+
+// ImageProxyHandler serves external images so readers never contact the image host directly
+func ImageProxyHandler(w http.ResponseWriter, r *http.Request) {
+	u := r.URL.Query().Get("url")
+	if u == "" {
+		http.Error(w, "400 bad request", http.StatusBadRequest)
+		return
+	}
+
+	img, ok := imageCache.Get(u)
+	if !ok {
+		var err error
+
+		img, err = imageFetcher.Fetch(r.Context(), u)
+		switch {
+		case err == nil:
+			imageCache.Add(u, img)
+		case errors.Is(err, imgproxy.ErrBadURL):
+			http.Error(w, "400 bad request", http.StatusBadRequest)
+			return
+		case errors.Is(err, imgproxy.ErrForbidden):
+			http.Error(w, "403 forbidden", http.StatusForbidden)
+			return
+		case errors.Is(err, imgproxy.ErrTooLarge):
+			http.Error(w, "413 request entity too large", http.StatusRequestEntityTooLarge)
+			return
+		case errors.Is(err, imgproxy.ErrNotAnImage):
+			http.Error(w, "415 unsupported media type", http.StatusUnsupportedMediaType)
+			return
+		default:
+			http.Error(w, "502 bad gateway", http.StatusBadGateway)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(img.Data)))
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(config.ImageProxyCacheAge))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.WriteHeader(http.StatusOK)
+	w.Write(img.Data)
+}
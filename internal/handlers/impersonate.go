@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/ids"
+	"real-time-forum/internal/structure"
+)
+
+// ImpersonateHandler lets admins start, inspect and end the impersonation of a user
+func ImpersonateHandler(w http.ResponseWriter, r *http.Request) {
+	//Always acts as the admin themselves, never as the impersonated user
+	admin, ok := requirePermission(w, r, config.PermUserImpersonate)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		imp, ok := activeImpersonation(r, admin)
+		if !ok {
+			http.Error(w, "404 not found: Not impersonating anyone.", http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, imp)
+	case "POST":
+		var imp structure.Impersonation
+
+		//Decodes the request body into the impersonation struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(r.Body).Decode(&imp)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		if imp.User_id == 0 || imp.User_id == admin {
+			http.Error(w, "400 bad request: Invalid user.", http.StatusBadRequest)
+			return
+		}
+
+		if imp.Reason == "" {
+			http.Error(w, "400 bad request: A reason is required.", http.StatusBadRequest)
+			return
+		}
+
+		_, err = db.FindUserByParam(r.Context(), "id", strconv.Itoa(imp.User_id))
+		if err != nil {
+			http.Error(w, "404 not found: User does not exist.", http.StatusNotFound)
+			return
+		}
+
+		//Ends any previous impersonation first
+		if c, err := r.Cookie("impersonate"); err == nil {
+			db.EndImpersonation(r.Context(), c.Value)
+		}
+
+		token, err := ids.New()
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		now := clock.Now()
+		imp.Admin_id = admin
+		imp.Token = token
+		imp.Started = now.UnixMilli()
+		imp.Expires = now.Add(config.ImpersonationMaxAge * time.Second).UnixMilli()
+		imp.Ended = 0
+
+		imp, err = db.NewImpersonation(r.Context(), imp)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		audit(r.Context(), admin, imp.User_id, "impersonate.start", imp.Reason)
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     "impersonate",
+			Value:    imp.Token,
+			HttpOnly: true,
+			Path:     "/",
+			MaxAge:   config.ImpersonationMaxAge,
+			SameSite: http.SameSiteNoneMode,
+			Secure:   true,
+		})
+
+		writeCreated(w, "/admin/impersonate", imp)
+	case "DELETE":
+		if imp, ok := activeImpersonation(r, admin); ok {
+			err := db.EndImpersonation(r.Context(), imp.Token)
+			if err != nil {
+				http.Error(w, "500 internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			audit(r.Context(), admin, imp.User_id, "impersonate.end", "")
+		}
+
+		clearImpersonation(w)
+		writeNoContent(w)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// Key of the user an admin impersonates in the context of a request
+const impersonatedKey contextKey = "impersonated"
+
+// impersonation makes requests carrying an impersonation cookie act as the impersonated user,
+// as long as they also carry the session of the admin who started it. The impersonation
+// token is never a session of the user, it only works through this middleware, so the
+// read-only mode and the audit trail can't be skipped.
+func impersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("impersonate")
+		if err != nil || r.URL.Path == "/admin/impersonate" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		admin := viewerId(r)
+		imp, ok := activeImpersonation(r, admin)
+		if !ok {
+			//Ends the admin's own impersonation once it has expired
+			if admin != 0 && imp.Admin_id == admin && imp.Ended == 0 {
+				db.EndImpersonation(r.Context(), c.Value)
+				audit(r.Context(), admin, imp.User_id, "impersonate.expire", "")
+			}
+
+			//The request continues as the admin
+			clearImpersonation(w)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		//Flags every response so clients can show a banner
+		w.Header().Set("X-Impersonating", strconv.Itoa(imp.User_id))
+		w.Header().Set("X-Impersonation-Expires", strconv.FormatInt(imp.Expires, 10))
+		if imp.Write {
+			w.Header().Set("X-Impersonation-Mode", "write")
+		} else {
+			w.Header().Set("X-Impersonation-Mode", "read-only")
+		}
+
+		audit(r.Context(), imp.Admin_id, imp.User_id, "impersonate.request", r.Method+" "+r.URL.RequestURI())
+
+		//Logging out would end every session of the user
+		if r.URL.Path == "/logout" {
+			http.Error(w, "403 forbidden: End the impersonation first.", http.StatusForbidden)
+			return
+		}
+
+		//Websocket messages can change data too
+		if !imp.Write && (!isSafeMethod(r.Method) || r.URL.Path == "/ws") && r.URL.Path != "/session" {
+			http.Error(w, "403 forbidden: Impersonation is read-only.", http.StatusForbidden)
+			return
+		}
+
+		//The request acts as the user, see currentUser
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), impersonatedKey, imp.User_id)))
+	})
+}
+
+// Finds the user an admin impersonates in the request, if any
+func impersonatedUser(r *http.Request) (int, bool) {
+	uid, ok := r.Context().Value(impersonatedKey).(int)
+	return uid, ok
+}
+
+// Finds the impersonation of the request if it was started by the admin and is still running
+func activeImpersonation(r *http.Request, admin int) (structure.Impersonation, bool) {
+	c, err := r.Cookie("impersonate")
+	if err != nil || admin == 0 {
+		return structure.Impersonation{}, false
+	}
+
+	imp, err := db.FindImpersonation(r.Context(), c.Value)
+	if err != nil {
+		return imp, false
+	}
+
+	if imp.Admin_id != admin || imp.Ended != 0 || clock.Now().UnixMilli() >= imp.Expires {
+		return imp, false
+	}
+
+	return imp, true
+}
+
+// Removes the impersonation cookie from the browser
+func clearImpersonation(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "impersonate",
+		Value:    "",
+		HttpOnly: true,
+		Path:     "/",
+		MaxAge:   -1,
+		SameSite: http.SameSiteNoneMode,
+		Secure:   true,
+	})
+}
+
+// Checks whether a request method only reads data
+func isSafeMethod(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "OPTIONS"
+}
+
+// Records a privileged action, failures are logged but never block the action
+func audit(ctx context.Context, actor, subject int, action, detail string) {
+	err := db.NewAuditEntry(ctx, structure.AuditEntry{
+		Actor_id:   actor,
+		Subject_id: subject,
+		Action:     action,
+		Detail:     detail,
+	})
+	if err != nil {
+		log.Printf("Error writing audit log: %v", err)
+	}
+}
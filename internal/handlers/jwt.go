@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/ids"
+	"real-time-forum/internal/secrets"
+)
+
+// A key access tokens are signed with, identified by the kid of the token header
+type signingKey struct {
+	id     string
+	secret []byte
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+type tokenClaims struct {
+	Sub int    `json:"sub"`
+	Iat int64  `json:"iat"`
+	Exp int64  `json:"exp"`
+	Jti string `json:"jti"`
+}
+
+type contextKey string
+
+const claimsKey contextKey = "claims"
+
+var tokenKeys = loadTokenKeys()
+
+// Reads the signing keys from the environment, the first one signs new tokens
+func loadTokenKeys() []signingKey {
+	var keys []signingKey
+
+	for _, pair := range strings.Split(secrets.Getenv(config.JWTKeysEnv), ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			continue
+		}
+
+		keys = append(keys, signingKey{id: parts[0], secret: []byte(parts[1])})
+	}
+
+	return keys
+}
+
+// Revoked token ids, and the time before which the tokens of a user were issued to be
+// revoked, checked on every request without going to the database
+var revokedTokens = struct {
+	sync.RWMutex
+	jti   map[string]int64
+	users map[int]int64
+}{jti: make(map[string]int64), users: make(map[int]int64)}
+
+// Periodically reloads the revoked tokens so revocations made elsewhere are picked up
+func syncRevokedTokens(ctx context.Context) {
+	ticker := clock.NewTicker(config.JWTRevocationSync * time.Second)
+	defer ticker.Stop()
+
+	for ok := true; ok; ok = ticker.Next(ctx) {
+		revoked, err := db.FindRevokedTokens(ctx, clock.Now().Unix())
+		if err != nil {
+			log.Printf("Error loading revoked tokens: %v", err)
+			continue
+		}
+
+		cutoffs, err := db.FindTokenCutoffs(ctx, clock.Now().Unix())
+		if err != nil {
+			log.Printf("Error loading revoked tokens: %v", err)
+			continue
+		}
+
+		revokedTokens.Lock()
+		revokedTokens.jti = revoked
+		revokedTokens.users = cutoffs
+		revokedTokens.Unlock()
+	}
+}
+
+// Revokes an access token until it expires
+func revokeToken(ctx context.Context, jti string, expires int64) error {
+	err := db.RevokeToken(ctx, jti, expires)
+	if err != nil {
+		return err
+	}
+
+	revokedTokens.Lock()
+	revokedTokens.jti[jti] = expires
+	revokedTokens.Unlock()
+
+	return nil
+}
+
+// Revokes every access token issued to a user so far, until the last of them expires
+func revokeUserTokens(ctx context.Context, uid int) error {
+	now := clock.Now()
+	err := db.RevokeUserTokens(ctx, uid, now.Unix(), now.Add(config.JWTAccessTokenAge*time.Second).Unix())
+	if err != nil {
+		return err
+	}
+
+	revokedTokens.Lock()
+	revokedTokens.users[uid] = now.Unix()
+	revokedTokens.Unlock()
+
+	return nil
+}
+
+func isRevoked(claims tokenClaims) bool {
+	revokedTokens.RLock()
+	defer revokedTokens.RUnlock()
+
+	if cutoff, ok := revokedTokens.users[claims.Sub]; ok && claims.Iat < cutoff {
+		return true
+	}
+
+	_, ok := revokedTokens.jti[claims.Jti]
+	return ok
+}
+
+// Signs a new access token for the user
+func signToken(uid int) (string, tokenClaims, error) {
+	if len(tokenKeys) == 0 {
+		return "", tokenClaims{}, errors.New("no signing key configured")
+	}
+	key := tokenKeys[0]
+
+	jti, err := ids.New()
+	if err != nil {
+		return "", tokenClaims{}, err
+	}
+
+	now := clock.Now()
+	claims := tokenClaims{
+		Sub: uid,
+		Iat: now.Unix(),
+		Exp: now.Add(config.JWTAccessTokenAge * time.Second).Unix(),
+		Jti: jti,
+	}
+
+	header, err := json.Marshal(tokenHeader{Alg: "HS256", Typ: "JWT", Kid: key.id})
+	if err != nil {
+		return "", claims, err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", claims, err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + tokenSignature(key, unsigned), claims, nil
+}
+
+func tokenSignature(key signingKey, unsigned string) string {
+	mac := hmac.New(sha256.New, key.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verifies the signature, expiry and revocation of an access token and returns its claims
+func parseToken(raw string) (tokenClaims, error) {
+	var header tokenHeader
+	var claims tokenClaims
+
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return claims, errors.New("malformed token")
+	}
+
+	h, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(h, &header) != nil || header.Alg != "HS256" {
+		return claims, errors.New("malformed token header")
+	}
+
+	//Finds the key the token was signed with, old keys stay valid until removed
+	var key *signingKey
+	for i := range tokenKeys {
+		if tokenKeys[i].id == header.Kid {
+			key = &tokenKeys[i]
+			break
+		}
+	}
+	if key == nil {
+		return claims, errors.New("unknown signing key")
+	}
+
+	if !hmac.Equal([]byte(tokenSignature(*key, parts[0]+"."+parts[1])), []byte(parts[2])) {
+		return claims, errors.New("invalid signature")
+	}
+
+	p, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(p, &claims) != nil {
+		return claims, errors.New("malformed token claims")
+	}
+
+	if clock.Now().Unix() >= claims.Exp {
+		return claims, errors.New("token expired")
+	}
+
+	if isRevoked(claims) {
+		return claims, errors.New("token revoked")
+	}
+
+	return claims, nil
+}
+
+// Finds the verified access token claims of a request
+func requestClaims(r *http.Request) (tokenClaims, bool) {
+	claims, ok := r.Context().Value(claimsKey).(tokenClaims)
+	return claims, ok
+}
+
+// Checks whether access tokens can be issued and are accepted
+func tokensEnabled() bool {
+	return len(tokenKeys) > 0 && config.AuthMode != config.AuthCookie
+}
+
+// bearerAuth authenticates requests carrying an access token in the Authorization header.
+// Cookies are ignored on those requests so the token is the only credential, and on every
+// request when only tokens are accepted.
+func bearerAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !tokensEnabled() || !strings.HasPrefix(auth, "Bearer ") {
+			if config.AuthMode == config.AuthToken {
+				r.Header.Del("Cookie")
+			}
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		claims, err := parseToken(strings.TrimPrefix(auth, "Bearer "))
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "401 unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		//Tokens outlive the sessions deleted by a ban, see BanHandler
+		if bannedUsers.banned(claims.Sub, clock.Now().Unix()) {
+			http.Error(w, "403 forbidden: Banned.", http.StatusForbidden)
+			return
+		}
+
+		r.Header.Del("Cookie")
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey, claims)))
+	})
+}
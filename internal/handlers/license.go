@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// LicenseHandler lists the licenses posts can be published under with the default one
+// (/licenses), and lets admins change the default
+func LicenseHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		def, err := db.FindSetting(r.Context(), config.SettingDefaultLicense)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, structure.Licenses{Default: def, Licenses: config.PostLicenses})
+	case "PUT":
+		admin, ok := requirePermission(w, r, config.PermLicenseManage)
+		if !ok {
+			return
+		}
+
+		var l structure.Licenses
+
+		//Decodes the request body into the licenses struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&l)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		//An empty default leaves new posts without a license
+		if l.Default != "" && !isLicense(l.Default) {
+			http.Error(w, "400 bad request: Unknown license.", http.StatusBadRequest)
+			return
+		}
+
+		err = db.SaveSetting(r.Context(), config.SettingDefaultLicense, l.Default)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		audit(r.Context(), admin, 0, "license.default", l.Default)
+		writeJSON(w, http.StatusOK, structure.Licenses{Default: l.Default, Licenses: config.PostLicenses})
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Checks a license is one posts can be published under
+func isLicense(license string) bool {
+	for _, known := range config.PostLicenses {
+		if license == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Finds the license of a new post, the forum default when the author didn't choose one.
+// Writes a bad request when the license is unknown.
+func postLicense(ctx context.Context, w http.ResponseWriter, license string) (string, bool) {
+	if license == "" {
+		def, err := db.FindSetting(ctx, config.SettingDefaultLicense)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return "", false
+		}
+
+		return def, true
+	}
+
+	if !isLicense(license) {
+		http.Error(w, "400 bad request: Unknown license.", http.StatusBadRequest)
+		return "", false
+	}
+
+	return license, true
+}
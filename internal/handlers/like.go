@@ -1,22 +1,19 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
 
+	"real-time-forum/internal/chat"
 	"real-time-forum/internal/config"
 	"real-time-forum/internal/database"
+	"real-time-forum/internal/events"
 	"real-time-forum/internal/structure"
 )
 
-func LikeHandler(w http.ResponseWriter, r *http.Request) {
-	//Prevents the endpoint being called by other url paths
-	if r.URL.Path != "/like" {
-		http.Error(w, "404 not found.", http.StatusNotFound)
-		return
-	}
-
+func LikeHandler(hub *chat.Hub, w http.ResponseWriter, r *http.Request) {
 	//Grabs the post_id and table column from the url
 	pid := r.URL.Query().Get("post_id")
 	col := r.URL.Query().Get("col")
@@ -29,14 +26,14 @@ func LikeHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
 		//Gets all users who liked the post from the database
-		users, err := database.PostLikedBy(config.Path, pid, col)
+		users, err := db.PostLikedBy(r.Context(), pid, col)
 		if err != nil {
 			http.Error(w, "500 internal server error", http.StatusInternalServerError)
 			return
 		}
 
 		//Marshals the user structs to a json object
-		resp, err := json.Marshal(users)
+		resp, err := json.Marshal(userResponses(r.Context(), viewerId(r), userDisplayNames(r.Context(), users)))
 		if err != nil {
 			http.Error(w, "500 internal server error", http.StatusInternalServerError)
 			return
@@ -46,102 +43,54 @@ func LikeHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write(resp)
 	case "POST":
-		//Grabs the session cookie
-		c, err := r.Cookie("session")
-		if err != nil {
-			http.Error(w, "500 internal server error", http.StatusInternalServerError)
-			return
-		}
-
 		//Finds the currenlty logged in user
-		curr, err := database.CurrentUser(config.Path, c.Value)
+		curr, err := currentUser(r)
 		if err != nil {
 			http.Error(w, "500 internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		var users []structure.User
-
-		//Finds all users who liked/disliked the post
-		users, err = database.PostLikedBy(config.Path, pid, col)
-		if err != nil {
-			users = []structure.User{}
-		}
-
-		var other string
-		var removecurr = false
-		var removeother = false
-
-		//Checks whether the user has already liked/disliked the post
-		for _, u := range users {
-			if u.Id == curr.Id {
-				removecurr = true
-				break
-			}
-		}
-
-		//Finds the other column
+		//Finds the reaction of the column
+		var kind string
 		if col == "likes" {
-			other = "dislikes"
+			kind = "like"
 		} else if col == "dislikes" {
-			other = "likes"
+			kind = "dislike"
 		} else {
 			http.Error(w, "400 bad request", http.StatusBadRequest)
 			return
 		}
 
-		var otherCol []structure.User
-
-		//Finds the users who liked/disliked the post (other column)
-		otherCol, err = database.PostLikedBy(config.Path, pid, other)
+		id, err := strconv.Atoi(pid)
 		if err != nil {
-			otherCol = []structure.User{}
+			http.Error(w, "400 bad request", http.StatusBadRequest)
+			return
 		}
 
-		//Checks whether the user has already liked/disliked the post (other column)
-		for _, u := range otherCol {
-			if u.Id == curr.Id && !removecurr {
-				removeother = true
-				break
-			}
+		//Liking or disliking a post again takes the reaction back
+		current, err := db.FindReaction(r.Context(), id, curr.Id)
+		if err != nil {
+			http.Error(w, "500 internal error", http.StatusInternalServerError)
+			return
 		}
-
-		//Converts the users id to a string
-		uid := strconv.Itoa(curr.Id)
-
-		//If the user liked/disliked (other column) the post, remove it from the database
-		if removeother {
-			err = database.UpdateLikeDislike(config.Path, pid, uid, other, -1)
-			if err != nil {
-				http.Error(w, "500 internal error", http.StatusInternalServerError)
-				return
-			}
+		if current == kind {
+			kind = ""
 		}
 
-		//If the user already liked/disliked (selected column) the post, remove it from the database
-		//Otherwise add it to the database
-		if removecurr {
-			err = database.UpdateLikeDislike(config.Path, pid, uid, col, -1)
-			if err != nil {
-				http.Error(w, "500 internal error", http.StatusInternalServerError)
-				return
-			}
-		} else {
-			err = database.UpdateLikeDislike(config.Path, pid, uid, col, 1)
-			if err != nil {
-				http.Error(w, "500 internal error", http.StatusInternalServerError)
-				return
-			}
-		}
-
-		currPost, err := database.FindPostByParam(config.Path, "id", pid)
+		reaction, found, err := db.React(r.Context(), id, curr.Id, kind)
 		if err != nil {
 			http.Error(w, "500 internal error", http.StatusInternalServerError)
 			return
 		}
+		if !found {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		publishReaction(hub, curr.Id, reaction)
 
-		likes := strconv.Itoa(currPost[0].Likes)
-		dislikes := strconv.Itoa(currPost[0].Dislikes)
+		likes := strconv.Itoa(reaction.Likes)
+		dislikes := strconv.Itoa(reaction.Dislikes)
 
 		var msg = structure.Resp{Msg: likes + "|" + dislikes}
 
@@ -159,3 +108,52 @@ func LikeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// Sets the reaction of the user to a post (/posts/{id}/react), "like", "dislike" or empty
+// to remove it, and returns the counts of the post with the reaction
+func reactToPost(hub *chat.Hub, w http.ResponseWriter, r *http.Request, curr structure.User, pid int) {
+	var req structure.PostReaction
+
+	//Decodes the request body into the reaction struct
+	//Returns a bad request if there's an error
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&req)
+	if err != nil {
+		http.Error(w, "400 bad request.", http.StatusBadRequest)
+		return
+	}
+
+	if req.Reaction != "" && !database.IsReactionKind(req.Reaction) {
+		http.Error(w, "400 bad request: Unknown reaction.", http.StatusBadRequest)
+		return
+	}
+
+	reaction, found, err := db.React(r.Context(), pid, curr.Id, req.Reaction)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	publishReaction(hub, curr.Id, reaction)
+	writeJSON(w, http.StatusOK, reaction)
+}
+
+// Streams the new reaction counts of a post to everyone viewing it and to the feed readers,
+// and tells the subscribers who reacted
+func publishReaction(hub *chat.Hub, uid int, reaction structure.PostReaction) {
+	event, err := json.Marshal(structure.ReactionEvent{
+		Msg_type: "reaction",
+		Post_id:  reaction.Post_id,
+		Likes:    reaction.Likes,
+		Dislikes: reaction.Dislikes,
+	})
+	if err == nil {
+		go hub.Publish(reaction.Post_id, event)
+	}
+	go pushCounts(context.Background(), hub, reaction.Post_id)
+
+	bus.PublishAsync(events.PostReacted{PostID: reaction.Post_id, UserID: uid, Kind: reaction.Reaction})
+}
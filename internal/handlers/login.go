@@ -1,52 +1,42 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"log"
 	"net/http"
 	"net/mail"
 	"strconv"
+	"time"
 
+	"real-time-forum/internal/clock"
 	"real-time-forum/internal/config"
-	"real-time-forum/internal/database"
+	"real-time-forum/internal/events"
+	"real-time-forum/internal/ids"
 	"real-time-forum/internal/structure"
 
-	uuid "github.com/gofrs/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
-	//Prevents the endpoint being called by other url paths
-	if r.URL.Path != "/login" {
-		http.Error(w, "404 not found.", http.StatusNotFound)
-		return
-	}
-
-	//Prevents all request types other than POST
-	if r.Method != "POST" {
-		http.Error(w, "405 method not allowed.", http.StatusMethodNotAllowed)
-		return
-	}
-
-	//Opens the database
-	db, err := database.OpenDB(config.Path)
-	if err != nil {
-		http.Error(w, "500 internal server error.", http.StatusInternalServerError)
-		return
-	}
-
-	defer db.Close()
-
 	//Stores the unmarshalled login data
 	var loginData structure.Login
 
 	//Decodes the request body into the login struct
 	//Returns a bad request if there's an error
-	err = json.NewDecoder(r.Body).Decode(&loginData)
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&loginData)
 	if err != nil {
 		http.Error(w, "400 bad request.", http.StatusBadRequest)
 		return
 	}
 
+	//Clients without cookies, like mobile apps and scripts, log in for an access token
+	wantsToken := loginData.Token || config.AuthMode == config.AuthToken
+	if wantsToken && !tokensEnabled() {
+		http.Error(w, "400 bad request: Access tokens are not enabled.", http.StatusBadRequest)
+		return
+	}
+
 	//Parameter to search for user
 	var param string
 
@@ -58,8 +48,27 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	//Searches database for a matching user
-	foundUser, err := database.FindUserByParam(config.Path, param, loginData.Data)
-	if err != nil {
+	foundUser, findErr := db.FindUserByParam(r.Context(), param, loginData.Data)
+
+	//Devices the user logged in with before get more attempts before being slowed down
+	device := ""
+	free := config.LoginFreeAttempts
+	if findErr == nil {
+		device = knownDevice(r, foundUser.Id)
+		if device != "" {
+			free = config.KnownDeviceFreeAttempts
+		}
+	}
+
+	//Makes the client wait longer after every failed login, the attempt counts
+	//as failed until the password matches
+	key := loginKey(r, loginData.Data, device)
+	if wait := logins.reserve(key, free); wait > 0 {
+		writeTooManyRequests(w, wait)
+		return
+	}
+
+	if findErr != nil {
 		http.Error(w, "500 internal server error.", http.StatusInternalServerError)
 		return
 	}
@@ -72,43 +81,84 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//Removes expired cookie based on valid user login
-	_, err = db.Exec(database.RemoveCookie, foundUser.Id)
+	logins.reset(key)
+
+	//Accounts locked from a "this wasn't me" link need a new password first
+	locked, err := db.IsResetRequired(r.Context(), foundUser.Id)
 	if err != nil {
 		http.Error(w, "500 internal server error.", http.StatusInternalServerError)
 		return
 	}
 
-	//Check for session cookie, and create one if it doesn't exist
-	cookie, err := r.Cookie("session")
-	if err != nil {
-		//Generates the session uuid
-		sessionId, err := uuid.NewV4()
+	if locked {
+		http.Error(w, "403 forbidden: Password reset required.", http.StatusForbidden)
+		return
+	}
+
+	if !notBanned(r.Context(), w, foundUser.Id) {
+		return
+	}
+
+	//New accounts may have to verify their email first, see config.Requirements
+	if !requireSteps(r.Context(), w, foundUser.Id, config.ActionLogin) {
+		return
+	}
+
+	noteUser(r, foundUser.Id)
+
+	if wantsToken {
+		token, claims, err := signToken(foundUser.Id)
 		if err != nil {
 			http.Error(w, "500 internal server error.", http.StatusInternalServerError)
 			return
 		}
 
-		//Creates and sets the cookie
-		cookie = &http.Cookie{
-			Name:     "session",
-			Value:    sessionId.String(),
-			HttpOnly: true,
-			Path:     "/",
-			MaxAge:   config.CookieAge,
-			SameSite: http.SameSiteNoneMode,
-			Secure:   true,
+		bus.PublishAsync(events.UserLoggedIn{UserID: foundUser.Id, IP: remoteIP(r), UserAgent: r.UserAgent()})
+		writeJSON(w, http.StatusOK, structure.AccessToken{Token: token, Expires: claims.Exp})
+		return
+	}
+
+	//Remembers the device so the user is not slowed down as much next time
+	if device == "" {
+		rememberDevice(r.Context(), w, foundUser.Id)
+	}
+
+	//Replaces the session this browser had, the user stays logged in on their other devices
+	if old, err := r.Cookie("session"); err == nil {
+		err = db.DeleteSession(r.Context(), old.Value)
+		if err != nil {
+			http.Error(w, "500 internal server error.", http.StatusInternalServerError)
+			return
 		}
-		http.SetCookie(w, cookie)
 	}
 
-	//Inserts the cookie into the database
-	_, err = db.Exec(database.AddSession, cookie.Value, foundUser.Id)
+	//Generates the session uuid
+	sessionId, err := ids.New()
+	if err != nil {
+		http.Error(w, "500 internal server error.", http.StatusInternalServerError)
+		return
+	}
+
+	//Inserts the session into the database
+	err = db.NewSession(r.Context(), sessionId, foundUser.Id, r.UserAgent(), remoteIP(r))
+	if err != nil {
+		http.Error(w, "500 internal server error.", http.StatusInternalServerError)
+		return
+	}
+
+	//Creates and sets the cookie
+	cookie := sessionCookie(sessionId, config.CookieAge)
+	http.SetCookie(w, cookie)
+
+	//Ties the session to this browser so a stolen cookie is of no use elsewhere
+	err = bindSession(r, cookie.Value)
 	if err != nil {
 		http.Error(w, "500 internal server error.", http.StatusInternalServerError)
 		return
 	}
 
+	bus.PublishAsync(events.UserLoggedIn{UserID: foundUser.Id, IP: remoteIP(r), UserAgent: r.UserAgent()})
+
 	cid := strconv.Itoa(foundUser.Id)
 
 	//Sends a message back if successfully logged in
@@ -123,3 +173,43 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(resp)
 }
+
+// Returns the device token of the request if the user has logged in with it before
+func knownDevice(r *http.Request, uid int) string {
+	cookie, err := r.Cookie("device")
+	if err != nil || cookie.Value == "" {
+		return ""
+	}
+
+	since := clock.Now().Add(-config.DeviceCookieAge * time.Second).Unix()
+	known, err := db.IsKnownDevice(r.Context(), uid, cookie.Value, since)
+	if err != nil || !known {
+		return ""
+	}
+
+	return cookie.Value
+}
+
+// Gives the browser a device cookie and remembers it for the user
+func rememberDevice(ctx context.Context, w http.ResponseWriter, uid int) {
+	token, err := ids.New()
+	if err != nil {
+		return
+	}
+
+	err = db.NewKnownDevice(ctx, uid, token)
+	if err != nil {
+		log.Printf("Error remembering device: %v", err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "device",
+		Value:    token,
+		HttpOnly: true,
+		Path:     "/",
+		MaxAge:   config.DeviceCookieAge,
+		SameSite: http.SameSiteNoneMode,
+		Secure:   true,
+	})
+}
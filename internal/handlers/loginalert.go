@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/ids"
+	"real-time-forum/internal/mail"
+	"real-time-forum/internal/structure"
+	"real-time-forum/internal/validation"
+)
+
+// Notifies the user of a login from an ip address and browser they have not used before,
+// with a link to lock the account if it was not them
+func notifyNewLogin(ctx context.Context, hub *chat.Hub, uid int, ip, userAgent string) {
+	isNew, err := db.RecordLogin(ctx, uid, ip, userAgent)
+	if err != nil {
+		log.Printf("Error recording login: %v", err)
+		return
+	}
+
+	if !isNew {
+		return
+	}
+
+	token, err := ids.New()
+	if err != nil {
+		return
+	}
+
+	err = db.NewLoginAlert(ctx, uid, token)
+	if err != nil {
+		log.Printf("Error storing login alert: %v", err)
+		return
+	}
+
+	if userAgent == "" {
+		userAgent = "unknown browser"
+	}
+
+	//The link to lock the account only goes to the email on file, a session that isn't the
+	//user's could read it from the notifications
+	err = notify(ctx, hub, structure.Notification{
+		User_id: uid,
+		Kind:    "login",
+		Content: "New login from " + ip + " (" + userAgent + ")",
+	})
+	if err != nil {
+		log.Printf("Error storing login notification: %v", err)
+	}
+
+	user, err := db.FindUserByParam(ctx, "id", strconv.Itoa(uid))
+	if err != nil {
+		log.Printf("Error finding user of login alert: %v", err)
+		return
+	}
+
+	link := config.SiteURL + "/?not-me=" + url.QueryEscape(token)
+	body := "Your account " + user.Username + " was just used to sign in from " + ip + " (" + userAgent + ").\n\n" +
+		"If it was not you, lock the account at " + link + "\n\n" +
+		"Every session is logged out and a link to choose a new password is sent to this address."
+
+	err = mail.Send(user.Email, "New login to your account", body)
+	if err != nil {
+		log.Printf("Error sending login alert email: %v", err)
+	}
+}
+
+// NotMeHandler locks an account from the link of a new login email (/login/not-me?token=).
+// Every session is logged out and a password reset link is emailed to the address on file,
+// never returned, as whoever holds the alert link isn't necessarily the owner.
+func NotMeHandler(hub *chat.Hub, w http.ResponseWriter, r *http.Request) {
+	reset, err := ids.New()
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	since := clock.Now().Add(-config.LoginAlertAge * time.Second).Unix()
+	uid, err := db.LockAccount(r.Context(), r.URL.Query().Get("token"), reset, since)
+	if err != nil {
+		http.Error(w, "404 not found: Link is invalid or has expired.", http.StatusNotFound)
+		return
+	}
+
+	err = logOutEverywhere(r.Context(), hub, uid)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	audit(r.Context(), uid, uid, "login.not_me", "")
+
+	user, err := db.FindUserByParam(r.Context(), "id", strconv.Itoa(uid))
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	link := config.SiteURL + "/?reset=" + url.QueryEscape(reset)
+	body := "Your account " + user.Username + " has been locked and logged out everywhere.\n\n" +
+		"Choose a new password at " + link
+
+	err = mail.Send(user.Email, "Your account has been locked", body)
+	if err != nil {
+		log.Printf("Error sending account lock email: %v", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// PasswordResetHandler sets a new password with a reset token, from a locked account or a
+// forgotten password (/reset-password)
+func PasswordResetHandler(hub *chat.Hub, w http.ResponseWriter, r *http.Request) {
+	var req structure.PasswordReset
+
+	//Decodes the request body into the password reset struct
+	//Returns a bad request if there's an error
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&req)
+	if err != nil || req.Token == "" {
+		http.Error(w, "400 bad request.", http.StatusBadRequest)
+		return
+	}
+
+	//The new password follows the same rules as at registration
+	if errs := validation.Password(req.Password); len(errs) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, validation.Response{Errors: errs})
+		return
+	}
+
+	hash, err := GenerateHash(req.Password)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	since := clock.Now().Add(-config.PasswordResetAge * time.Second).Unix()
+	uid, err := db.ResetPassword(r.Context(), req.Token, hash, since)
+	if err != nil {
+		http.Error(w, "404 not found: Reset token is invalid or has expired.", http.StatusNotFound)
+		return
+	}
+
+	err = logOutEverywhere(r.Context(), hub, uid)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	audit(r.Context(), uid, uid, "password.reset", "")
+	writeNoContent(w)
+}
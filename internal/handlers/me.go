@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"real-time-forum/internal/completion"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// MeHandler returns the logged in user and their capabilities (/me)
+func MeHandler(w http.ResponseWriter, r *http.Request) {
+	curr, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	me := structure.Me{User: userResponse(r.Context(), curr.Id, curr)}
+
+	me.Role, me.Permissions, err = db.FindUserPermissions(r.Context(), curr.Id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	me.Capabilities, err = capabilities(r.Context(), curr.Id, me.Permissions)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	me.Streak, err = currentStreak(r.Context(), curr.Id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, me)
+}
+
+// Works out what a user can do from their permissions, storage, the enabled features and
+// the steps of completing their account they took. The forum has no bans or reputation.
+func capabilities(ctx context.Context, uid int, permissions []string) (map[string]bool, error) {
+	granted := map[string]bool{}
+	for _, p := range permissions {
+		granted[p] = true
+	}
+
+	_, used, err := db.FindUserStorage(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	unverified, err := db.IsUnverified(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	//Whether the user completed what each action requires
+	allowed := map[string]bool{}
+	for _, action := range []string{config.ActionPost, config.ActionComment, config.ActionMessageStranger} {
+		missing, err := completion.Missing(ctx, db, uid, action)
+		if err != nil {
+			return nil, err
+		}
+
+		allowed[action] = len(missing) == 0
+	}
+
+	on := features()
+
+	return map[string]bool{
+		"can_post":              allowed[config.ActionPost],
+		"can_comment":           allowed[config.ActionComment],
+		"can_message":           true,
+		"can_message_strangers": allowed[config.ActionMessageStranger],
+		"can_upload_images":     on["image_uploads"] && used < config.StorageQuota,
+		"is_moderator":          granted[config.PermPostDeleteAny] || granted[config.PermUserBan],
+		"is_admin":              granted[config.PermRoleManage],
+		"can_impersonate":       granted[config.PermUserImpersonate],
+		"pending_verification":  unverified,
+	}, nil
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Relationship ranks of mention candidates, higher ranks are suggested first
+const (
+	rankOther = iota
+	rankContact
+	rankParticipant
+)
+
+// MentionSuggestionHandler suggests users to mention, ranked by their relationship to the
+// logged in user and the thread being written in (/mention-suggestions?prefix=&context=post|dm&id=)
+func MentionSuggestionHandler(w http.ResponseWriter, r *http.Request) {
+	viewer := viewerId(r)
+	if viewer == 0 {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	prefix := strings.TrimPrefix(strings.TrimSpace(r.URL.Query().Get("prefix")), "@")
+	context := r.URL.Query().Get("context")
+
+	//The id is the post for post threads and the other user for direct messages
+	id := 0
+	if s := r.URL.Query().Get("id"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			http.Error(w, "400 bad request: Invalid id.", http.StatusBadRequest)
+			return
+		}
+		id = n
+	}
+
+	rank := make(map[int]int)
+
+	//People in the thread are the most likely to be mentioned
+	switch context {
+	case "post":
+		if id != 0 {
+			for _, uid := range postParticipants(r.Context(), id) {
+				rank[uid] = rankParticipant
+			}
+		}
+	case "dm":
+		if id != 0 {
+			rank[id] = rankParticipant
+		}
+	case "":
+	default:
+		http.Error(w, "400 bad request: Invalid context.", http.StatusBadRequest)
+		return
+	}
+
+	//Followed by the people the user has chatted with, most recent first
+	recent := make(map[int]int)
+	chats, err := db.FindUserChats(r.Context(), viewer)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	for _, c := range chats {
+		other := c.User_one
+		if other == viewer {
+			other = c.User_two
+		}
+
+		if c.Time > recent[other] {
+			recent[other] = c.Time
+		}
+		if rank[other] < rankContact {
+			rank[other] = rankContact
+		}
+	}
+
+	candidates, err := db.SearchUsers(r.Context(), prefix, config.MentionCandidateLimit, 0)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	//Users cannot mention themselves
+	users := candidates[:0]
+	for _, u := range candidates {
+		if u.Id != viewer {
+			users = append(users, u)
+		}
+	}
+
+	sort.SliceStable(users, func(i, j int) bool {
+		a, b := users[i].Id, users[j].Id
+		if rank[a] != rank[b] {
+			return rank[a] > rank[b]
+		}
+		if recent[a] != recent[b] {
+			return recent[a] > recent[b]
+		}
+		return strings.ToLower(users[i].Username) < strings.ToLower(users[j].Username)
+	})
+
+	if len(users) > config.MentionSuggestionLimit {
+		users = users[:config.MentionSuggestionLimit]
+	}
+
+	suggestions := []structure.MentionSuggestion{}
+	for _, u := range users {
+		suggestions = append(suggestions, structure.NewMentionSuggestion(u, relation(rank[u.Id])))
+	}
+
+	writeJSON(w, http.StatusOK, suggestions)
+}
+
+// Finds the author and commenters of a post
+func postParticipants(ctx context.Context, pid int) []int {
+	var uids []int
+
+	posts, err := db.FindPostByParam(ctx, "id", strconv.Itoa(pid))
+	if err == nil && len(posts) > 0 {
+		uids = append(uids, posts[0].User_id)
+	}
+
+	comments, err := db.FindCommentByParam(ctx, "post_id", strconv.Itoa(pid))
+	if err == nil {
+		for _, c := range comments {
+			uids = append(uids, c.User_id)
+		}
+	}
+
+	return uids
+}
+
+// Names the relationship rank of a candidate
+func relation(rank int) string {
+	switch rank {
+	case rankParticipant:
+		return "participant"
+	case rankContact:
+		return "contact"
+	default:
+		return ""
+	}
+}
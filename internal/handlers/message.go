@@ -2,62 +2,44 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"real-time-forum/internal/clock"
 	"real-time-forum/internal/config"
-	"real-time-forum/internal/database"
+	"real-time-forum/internal/events"
 	"real-time-forum/internal/structure"
 )
 
 func MessageHandler(w http.ResponseWriter, r *http.Request) {
-	//Prevents the endpoint being called by other url paths
-	if r.URL.Path != "/message" {
-		http.Error(w, "404 not found.", http.StatusNotFound)
-		return
-	}
 	//Checks whether it is a POST or GET request
 	switch r.Method {
 	case "GET":
-		cookie, err := r.Cookie("session")
-		if err != nil {
-			return
-		}
-
-		foundVal := cookie.Value
-
-		curr, err := database.CurrentUser(config.Path, foundVal)
+		curr, err := currentUser(r)
 		if err != nil {
 			return
 		}
 
-		s := strconv.Itoa(curr.Id)
-		//Grabs the first id from the url
+		//Grabs the first id from the url, the page ends with that message
 		firstId, _ := strconv.Atoi(r.URL.Query().Get("firstId"))
 		//Grabs the receiver id from the url
-		fmt.Println("id", firstId)
-		r := r.URL.Query().Get("receiver")
-
-		//Makes sure neither are empty
-		if r == "" {
+		with, err := strconv.Atoi(r.URL.Query().Get("receiver"))
+		if err != nil {
 			http.Error(w, "400 bad request", http.StatusBadRequest)
 			return
 		}
-		//Gets the messages from the database
-		messages, err := database.FindChatMessages(config.Path, s, r, firstId)
+
+		//Gets the messages from the database, newest first
+		messages, err := db.FindMessagesBefore(r.Context(), curr.Id, with, firstId+1, config.MessagePageSize)
 		if err != nil {
 			http.Error(w, "500 internal server error", http.StatusInternalServerError)
 			return
 		}
-		//fmt.Println("lastMessage")
-		//fmt.Println(lastMessage)
-		//fmt.Println(messages)
-		//fmt.Println("firstId")
 
-		//	fmt.Println(firstId)
+		messages = readTimes(r.Context(), curr.Id, with, messages)
 		//Marshals the array of message structs to a json object
-		resp, err := json.Marshal(messages)
+		resp, err := json.Marshal(messageDisplayNames(r.Context(), messages))
 		if err != nil {
 			http.Error(w, "500 internal server error", http.StatusInternalServerError)
 			return
@@ -67,22 +49,51 @@ func MessageHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write(resp)
 	case "POST":
+		curr, err := currentUser(r)
+		if err != nil {
+			http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+			return
+		}
+
 		var newMessage structure.Message
 
 		//Decodes the request body into the message struct
 		//Returns a bad request if there's an error
-		err := json.NewDecoder(r.Body).Decode(&newMessage)
+		err = json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&newMessage)
 		if err != nil {
 			http.Error(w, "400 bad request.", http.StatusBadRequest)
 			return
 		}
 
+		//Messages are always sent as the logged in user
+		newMessage.Sender_id = curr.Id
+
+		//Empty messages are refused, as they are on the websocket
+		if newMessage.Receiver_id <= 0 || strings.TrimSpace(newMessage.Content) == "" {
+			http.Error(w, "400 bad request: The message needs a receiver and content.", http.StatusBadRequest)
+			return
+		}
+
+		if !requireStepsToMessage(r.Context(), w, newMessage.Sender_id, newMessage.Receiver_id) {
+			return
+		}
+
+		//The date is always assigned by the server
+		newMessage.Date = clock.Now().Format(config.TimeFormat)
+
 		//Attemps to add the new message to the database
-		err = database.NewMessage(config.Path, newMessage)
+		newMessage.Id, newMessage.Seq, err = db.NewMessage(r.Context(), newMessage)
 		if err != nil {
 			http.Error(w, "500 internal server error", http.StatusInternalServerError)
 			return
 		}
+
+		newMessage.Display_name, _ = db.FindDisplayName(r.Context(), newMessage.Sender_id)
+
+		bus.PublishAsync(events.MessageSent{Message: newMessage})
+
+		location := "/message?receiver=" + strconv.Itoa(newMessage.Receiver_id) + "&firstId=" + strconv.Itoa(newMessage.Id)
+		writeCreated(w, location, newMessage)
 	default:
 		//Prevents the use of other request types
 		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
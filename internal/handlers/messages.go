@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// MessageWindowHandler returns the messages of a conversation around a point in time along
+// with where each day starts, so clients can jump to a date (/messages?with=<user>&around=<timestamp>).
+// With after_seq it returns the messages that follow a sequence number instead, so clients
+// can fill the gaps they notice in the sequence (/messages?with=<user>&after_seq=<seq>).
+// With before it returns a page of the messages older than a message, or the latest page
+// when before is 0, so clients can load the history as it is scrolled through
+// (/messages?with=<user>&before=<id>&limit=<n>). The id of the first message is the next cursor.
+func MessageWindowHandler(w http.ResponseWriter, r *http.Request) {
+	viewer := viewerId(r)
+	if viewer == 0 {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	with, err := strconv.Atoi(r.URL.Query().Get("with"))
+	if err != nil {
+		http.Error(w, "400 bad request: Invalid user.", http.StatusBadRequest)
+		return
+	}
+
+	if s := r.URL.Query().Get("after_seq"); s != "" {
+		after, err := strconv.Atoi(s)
+		if err != nil || after < 0 {
+			http.Error(w, "400 bad request: Invalid sequence number.", http.StatusBadRequest)
+			return
+		}
+
+		//Asks for one more message than is returned to know whether there are more
+		messages, err := db.FindMessagesAfter(r.Context(), viewer, with, after, config.MessageWindowSize+1)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		resp := structure.MessageWindow{
+			Messages:   []structure.Message{},
+			Days:       []structure.DaySeparator{},
+			Has_before: after > 0,
+		}
+
+		if len(messages) > config.MessageWindowSize {
+			messages = messages[:config.MessageWindowSize]
+			resp.Has_after = true
+		}
+
+		if len(messages) > 0 {
+			resp.Messages = messageDisplayNames(r.Context(), readTimes(r.Context(), viewer, with, messages))
+			resp.Anchor_id = messages[0].Id
+			resp.Days = daySeparators(resp.Messages)
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	if s := r.URL.Query().Get("before"); s != "" {
+		before, err := strconv.Atoi(s)
+		if err != nil || before < 0 {
+			http.Error(w, "400 bad request: Invalid message id.", http.StatusBadRequest)
+			return
+		}
+
+		limit := config.MessagePageSize
+		if s := r.URL.Query().Get("limit"); s != "" {
+			limit, err = strconv.Atoi(s)
+			if err != nil || limit <= 0 || limit > config.MessageWindowSize {
+				http.Error(w, "400 bad request: Invalid limit.", http.StatusBadRequest)
+				return
+			}
+		}
+
+		//Asks for one more message than is returned to know whether there are more
+		messages, err := db.FindMessagesBefore(r.Context(), viewer, with, before, limit+1)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		resp := structure.MessageWindow{
+			Messages:  []structure.Message{},
+			Days:      []structure.DaySeparator{},
+			Has_after: before > 0,
+		}
+
+		if len(messages) > limit {
+			messages = messages[:limit]
+			resp.Has_before = true
+		}
+
+		//Messages come newest first and are returned oldest first like the other windows
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+
+		if len(messages) > 0 {
+			resp.Messages = messageDisplayNames(r.Context(), readTimes(r.Context(), viewer, with, messages))
+			resp.Anchor_id = messages[0].Id
+			resp.Days = daySeparators(resp.Messages)
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	//Defaults to the end of the conversation
+	around := clock.Now()
+	if s := r.URL.Query().Get("around"); s != "" {
+		around, err = parseAround(s)
+		if err != nil {
+			http.Error(w, "400 bad request: Invalid timestamp.", http.StatusBadRequest)
+			return
+		}
+	}
+
+	messages, err := db.FindConversation(r.Context(), viewer, with)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	//Finds the first message sent at or after the requested time
+	anchor := len(messages) - 1
+	for i, m := range messages {
+		if !messageTime(m).Before(around) {
+			anchor = i
+			break
+		}
+	}
+
+	resp := structure.MessageWindow{
+		Messages: []structure.Message{},
+		Days:     []structure.DaySeparator{},
+	}
+
+	if len(messages) == 0 {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	//Centres the window on the anchor, shifting it when close to either end
+	start := anchor - config.MessageWindowSize/2
+	if start < 0 {
+		start = 0
+	}
+	end := start + config.MessageWindowSize
+	if end > len(messages) {
+		end = len(messages)
+		start = end - config.MessageWindowSize
+		if start < 0 {
+			start = 0
+		}
+	}
+
+	resp.Messages = messageDisplayNames(r.Context(), readTimes(r.Context(), viewer, with, messages[start:end]))
+	resp.Anchor_id = messages[anchor].Id
+	resp.Has_before = start > 0
+	resp.Has_after = end < len(messages)
+	resp.Days = daySeparators(resp.Messages)
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Finds where each day starts within consecutive messages
+func daySeparators(messages []structure.Message) []structure.DaySeparator {
+	days := []structure.DaySeparator{}
+
+	for _, m := range messages {
+		day := messageTime(m).Format("2006-01-02")
+
+		last := len(days) - 1
+		if last >= 0 && days[last].Day == day {
+			days[last].Count++
+			continue
+		}
+
+		days = append(days, structure.DaySeparator{Day: day, First_id: m.Id, Count: 1})
+	}
+
+	return days
+}
+
+// Parses a unix timestamp in milliseconds, or a date in the yyyy-mm-dd format
+func parseAround(s string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(0, ms*int64(time.Millisecond)), nil
+	}
+
+	return time.ParseInLocation("2006-01-02", s, time.Local)
+}
+
+// Parses the date of a message, which is stored in the server's local time
+func messageTime(m structure.Message) time.Time {
+	t, err := time.ParseInLocation(config.TimeFormat, m.Date, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/config"
+)
+
+// MetricsHandler shows operators how the websocket hub copes with slow clients and what
+// compression saves (/admin/metrics)
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requirePermission(w, r, config.PermMetricsView); !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, chat.Stats())
+}
@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/routes"
+	"real-time-forum/internal/structure"
+)
+
+// Registers the moderation endpoints, each needing its own permission:
+//
+//	DELETE /mod/posts/{id}?reason=     removes any post, see config.PermPostDeleteAny
+//	DELETE /mod/comments/{id}?reason=  removes any comment, see config.PermCommentDeleteAny
+//	PUT    /mod/posts/{id}/lock        closes a post to new comments, DELETE opens it again
+//	GET    /mod/bans                   lists the bans in force, POST bans a user
+//	DELETE /mod/bans/{user}            lifts the ban of a user
+func moderationRoutes(rt *routes.Router, hub *chat.Hub) {
+	rt.Handle("/mod/posts/{id}", routes.Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		removePost(hub, w, r)
+	}), permitted(config.PermPostDeleteAny)), "DELETE")
+	rt.Handle("/mod/comments/{id}", routes.Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		removeComment(hub, w, r)
+	}), permitted(config.PermCommentDeleteAny)), "DELETE")
+	rt.Handle("/mod/posts/{id}/lock", routes.Chain(http.HandlerFunc(lockThread), permitted(config.PermThreadLock)), "PUT", "DELETE")
+	rt.Handle("/mod/bans", routes.Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		BanHandler(hub, w, r)
+	}), permitted(config.PermUserBan)), "GET", "POST")
+	rt.Handle("/mod/bans/{user}", routes.Chain(http.HandlerFunc(liftBan), permitted(config.PermUserBan)), "DELETE")
+}
+
+// Removes a post of any user. Moderators can't be undone by the author, so the post goes
+// to the trash as if it was deleted longer ago than it can be restored, and is purged with it.
+func removePost(hub *chat.Hub, w http.ResponseWriter, r *http.Request) {
+	reason, ok := moderationReason(w, r.URL.Query().Get("reason"))
+	if !ok {
+		return
+	}
+
+	post, ok := findPost(w, r)
+	if !ok {
+		return
+	}
+
+	ok, err := db.DeletePost(r.Context(), post.User_id, post.Id, clock.Now().Unix()-config.TrashRetention)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	moderator := viewerId(r)
+	audit(r.Context(), moderator, post.User_id, "mod.post.delete", strconv.Itoa(post.Id)+": "+reason)
+	go notifyRemoval(context.Background(), hub, post.User_id, "your post \""+post.Title+"\"", reason)
+
+	writeNoContent(w)
+}
+
+// Removes a comment of any user, like removePost
+func removeComment(hub *chat.Hub, w http.ResponseWriter, r *http.Request) {
+	reason, ok := moderationReason(w, r.URL.Query().Get("reason"))
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(routes.Param(r, "id"))
+	if err != nil {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	comments, err := db.FindCommentByParam(r.Context(), "id", strconv.Itoa(id))
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	if len(comments) == 0 {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	c := comments[0]
+	postID, ok, err := db.DeleteComment(r.Context(), c.User_id, c.Id, clock.Now().Unix()-config.TrashRetention)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	go pushCounts(context.Background(), hub, postID)
+
+	moderator := viewerId(r)
+	audit(r.Context(), moderator, c.User_id, "mod.comment.delete", strconv.Itoa(c.Id)+": "+reason)
+	go notifyRemoval(context.Background(), hub, c.User_id, "your comment", reason)
+
+	writeNoContent(w)
+}
+
+// Closes a post to new comments (PUT /mod/posts/{id}/lock) or opens it again (DELETE)
+func lockThread(w http.ResponseWriter, r *http.Request) {
+	post, ok := findPost(w, r)
+	if !ok {
+		return
+	}
+
+	moderator := viewerId(r)
+
+	switch r.Method {
+	case "PUT":
+		var l structure.ThreadLock
+
+		//Decodes the request body into the lock struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&l)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		l.Reason, ok = moderationReason(w, l.Reason)
+		if !ok {
+			return
+		}
+
+		l.Post_id, l.Moderator_id = post.Id, moderator
+		l.Date = clock.Now().Format(config.TimeFormat)
+
+		err = db.LockThread(r.Context(), l)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		audit(r.Context(), moderator, post.User_id, "mod.thread.lock", strconv.Itoa(post.Id)+": "+l.Reason)
+		writeJSON(w, http.StatusOK, l)
+	case "DELETE":
+		found, err := db.UnlockThread(r.Context(), post.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		audit(r.Context(), moderator, post.User_id, "mod.thread.unlock", strconv.Itoa(post.Id))
+		writeNoContent(w)
+	}
+}
+
+// Checks a post is open to comments, answering a 423 with the lock otherwise
+func threadOpen(ctx context.Context, w http.ResponseWriter, pid int) bool {
+	l, locked, err := db.FindThreadLock(ctx, pid)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return false
+	}
+
+	if locked {
+		writeJSON(w, http.StatusLocked, l)
+		return false
+	}
+
+	return true
+}
+
+// BanHandler lists the bans in force (GET /mod/bans) and bans users (POST). A banned user is
+// logged out everywhere, their chat connection is closed and they can't log in again until
+// the ban expires, or ever when it has no expiry.
+func BanHandler(hub *chat.Hub, w http.ResponseWriter, r *http.Request) {
+	moderator := viewerId(r)
+	now := clock.Now().Unix()
+
+	switch r.Method {
+	case "GET":
+		bans, err := db.FindBans(r.Context(), now)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		names := displayNames(r.Context())
+		for i := range bans {
+			bans[i].Display_name = names[bans[i].User_id]
+		}
+
+		writeJSON(w, http.StatusOK, bans)
+	case "POST":
+		var b structure.Ban
+
+		//Decodes the request body into the ban struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&b)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		var ok bool
+		b.Reason, ok = moderationReason(w, b.Reason)
+		if !ok {
+			return
+		}
+
+		if b.Expires != 0 && b.Expires <= now {
+			http.Error(w, "400 bad request: The ban has to expire in the future.", http.StatusBadRequest)
+			return
+		}
+
+		name, found := displayNames(r.Context())[b.User_id]
+		if !found || b.User_id == moderator {
+			http.Error(w, "400 bad request: Unknown user.", http.StatusBadRequest)
+			return
+		}
+
+		//Admins can't be banned, they would only give themselves their access back
+		admin, err := can(r.Context(), b.User_id, config.PermRoleManage)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if admin {
+			http.Error(w, "403 forbidden: Admins can't be banned.", http.StatusForbidden)
+			return
+		}
+
+		b.Moderator_id, b.Created, b.Display_name = moderator, now, name
+		err = db.NewBan(r.Context(), b)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		bannedUsers.set(b.User_id, b.Expires)
+
+		//Logs the user out everywhere
+		err = db.DeleteUserSessions(r.Context(), b.User_id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		chat.RevokeReconnectTokens(b.User_id)
+		hub.Disconnect(b.User_id)
+
+		audit(r.Context(), moderator, b.User_id, "mod.user.ban", b.Reason)
+		writeCreated(w, "/mod/bans", b)
+	}
+}
+
+// Lifts the ban of a user (DELETE /mod/bans/{user})
+func liftBan(w http.ResponseWriter, r *http.Request) {
+	uid, err := strconv.Atoi(routes.Param(r, "user"))
+	if err != nil {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	found, err := db.LiftBan(r.Context(), uid)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	bannedUsers.lift(uid)
+
+	audit(r.Context(), viewerId(r), uid, "mod.user.unban", "")
+	writeNoContent(w)
+}
+
+// Checks a user logging in isn't banned, answering a 403 with the reason and expiry otherwise
+func notBanned(ctx context.Context, w http.ResponseWriter, uid int) bool {
+	b, banned, err := db.FindBan(ctx, uid, clock.Now().Unix())
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return false
+	}
+
+	if banned {
+		until := "for good"
+		if b.Expires != 0 {
+			until = "until " + time.Unix(b.Expires, 0).Format(config.TimeFormat)
+		}
+
+		http.Error(w, "403 forbidden: Banned "+until+": "+b.Reason, http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// Checks the reason given for a moderation action, which is required so users and other
+// moderators know why it was taken
+func moderationReason(w http.ResponseWriter, reason string) (string, bool) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" || utf8.RuneCountInString(reason) > config.MaxModerationReason {
+		http.Error(w, "400 bad request: A reason of up to "+strconv.Itoa(config.MaxModerationReason)+" characters is required.", http.StatusBadRequest)
+		return "", false
+	}
+
+	return reason, true
+}
+
+// Lets a user know a moderator removed what they wrote, and why
+func notifyRemoval(ctx context.Context, hub *chat.Hub, uid int, what, reason string) {
+	err := notify(ctx, hub, structure.Notification{
+		User_id: uid,
+		Kind:    "moderation",
+		Content: "A moderator removed " + what + ": " + reason,
+	})
+	if err != nil {
+		log.Printf("Error storing moderation notification: %v", err)
+	}
+}
+
+// Users under a ban and when it expires, 0 for never. Access tokens are checked against it
+// on every request without going to the database.
+var bannedUsers = &banList{until: make(map[int]int64)}
+
+type banList struct {
+	sync.RWMutex
+	until map[int]int64
+}
+
+func (b *banList) set(uid int, expires int64) {
+	b.Lock()
+	b.until[uid] = expires
+	b.Unlock()
+}
+
+func (b *banList) lift(uid int) {
+	b.Lock()
+	delete(b.until, uid)
+	b.Unlock()
+}
+
+// Checks whether a user is banned at the given unix time
+func (b *banList) banned(uid int, now int64) bool {
+	b.RLock()
+	defer b.RUnlock()
+
+	expires, found := b.until[uid]
+	return found && (expires == 0 || expires > now)
+}
+
+// Periodically reloads the bans so bans made elsewhere are picked up
+func syncBans(ctx context.Context) {
+	ticker := clock.NewTicker(config.BanSync * time.Second)
+	defer ticker.Stop()
+
+	for ok := true; ok; ok = ticker.Next(ctx) {
+		bans, err := db.FindBans(ctx, clock.Now().Unix())
+		if err != nil {
+			log.Printf("Error loading bans: %v", err)
+			continue
+		}
+
+		until := make(map[int]int64, len(bans))
+		for _, b := range bans {
+			until[b.User_id] = b.Expires
+		}
+
+		bannedUsers.Lock()
+		bannedUsers.until = until
+		bannedUsers.Unlock()
+	}
+}
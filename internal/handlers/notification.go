@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/events"
+	"real-time-forum/internal/routes"
+	"real-time-forum/internal/structure"
+)
+
+// NotificationHandler lists the latest notifications of the user (GET /notifications) and
+// marks them all as seen (POST)
+func NotificationHandler(w http.ResponseWriter, r *http.Request) {
+	uid := viewerId(r)
+	if uid == 0 {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		notifications, err := db.FindNotifications(r.Context(), uid, config.NotificationLimit)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, notifications)
+	case "POST":
+		err := db.SeeNotifications(r.Context(), uid)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeNoContent(w)
+	}
+}
+
+// Marks a single notification of the user as seen (POST /notifications/{id}/read)
+func readNotification(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(routes.Param(r, "id"))
+	if err != nil {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	uid := viewerId(r)
+	if uid == 0 {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	found, err := db.SeeNotification(r.Context(), uid, id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	writeNoContent(w)
+}
+
+// Stores a notification and pushes it straight away to the user if they are connected,
+// otherwise they find it in their list when they come back
+func notify(ctx context.Context, hub *chat.Hub, n structure.Notification) error {
+	n, err := db.NewNotification(ctx, n)
+	if err != nil {
+		return err
+	}
+
+	n.Msg_type = "notification"
+	msg, err := json.Marshal(n)
+	if err == nil {
+		hub.SendTo(n.User_id, msg)
+	}
+
+	return nil
+}
+
+// Lets the author of a post know someone commented on it. Comment events can be delivered
+// more than once, so a comment already notified is skipped.
+func notifyComment(ctx context.Context, hub *chat.Hub, e events.CommentCreated) error {
+	posts, err := db.FindPostByParam(ctx, "id", strconv.Itoa(e.PostID))
+	if err != nil {
+		return err
+	}
+	if len(posts) == 0 {
+		return errors.New("post not found")
+	}
+
+	author := posts[0].User_id
+	if author == e.UserID {
+		return nil
+	}
+
+	n := structure.Notification{
+		User_id: author,
+		Kind:    "comment",
+		Content: displayNames(ctx)[e.UserID] + " commented on \"" + posts[0].Title + "\"",
+		Link:    "/post?param=id&data=" + strconv.Itoa(e.PostID) + "#comment-" + strconv.Itoa(e.CommentID),
+	}
+
+	sent, err := db.HasNotification(ctx, n)
+	if err != nil || sent {
+		return err
+	}
+
+	return notify(ctx, hub, n)
+}
+
+// Lets the author of a post know someone reacted to it. Reacting the same way again
+// doesn't notify them twice.
+func notifyReaction(ctx context.Context, hub *chat.Hub, e events.PostReacted) error {
+	if e.Kind == "" {
+		return nil
+	}
+
+	posts, err := db.FindPostByParam(ctx, "id", strconv.Itoa(e.PostID))
+	if err != nil || len(posts) == 0 || posts[0].User_id == e.UserID {
+		return err
+	}
+
+	n := structure.Notification{
+		User_id: posts[0].User_id,
+		Kind:    "reaction",
+		Content: displayNames(ctx)[e.UserID] + " reacted with a " + e.Kind + " to \"" + posts[0].Title + "\"",
+		Link:    "/post?param=id&data=" + strconv.Itoa(e.PostID),
+	}
+
+	sent, err := db.HasNotification(ctx, n)
+	if err != nil || sent {
+		return err
+	}
+
+	return notify(ctx, hub, n)
+}
+
+// Lets the receiver of a private message know about it when they are offline. Connected
+// users already get the message itself over the websocket.
+func notifyMessage(ctx context.Context, hub *chat.Hub, e events.MessageSent) error {
+	if hub.IsOnline(e.Message.Receiver_id) {
+		return nil
+	}
+
+	return notify(ctx, hub, structure.Notification{
+		User_id: e.Message.Receiver_id,
+		Kind:    "message",
+		Content: displayNames(ctx)[e.Message.Sender_id] + " sent you a message",
+		Link:    "/messages?with=" + strconv.Itoa(e.Message.Sender_id),
+	})
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/events"
+	"real-time-forum/internal/structure"
+)
+
+// Wakes the dispatcher up straight after a write instead of waiting for the next poll
+var outboxWake = make(chan struct{}, 1)
+
+func wakeOutbox() {
+	select {
+	case outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// Delivers the pending outbox events to the subscribers on the event bus, at least once.
+// A retry only runs the subscribers the event has not been delivered to yet, one can
+// still see it twice when recording its delivery fails.
+func dispatchOutbox(ctx context.Context) {
+	ticker := clock.NewTicker(config.OutboxPollInterval * time.Millisecond)
+	defer ticker.Stop()
+
+	cleanup := clock.NewTicker(config.CleanupInterval * time.Second)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-outboxWake:
+		case <-cleanup.C:
+			_, err := db.DeleteOldEvents(ctx, clock.Now().Add(-config.OutboxRetention*time.Second).Unix())
+			if err != nil {
+				log.Printf("Error cleaning up outbox: %v", err)
+			}
+			continue
+		}
+
+		events, err := db.FindPendingEvents(ctx, clock.Now().Unix(), config.OutboxBatchSize)
+		if err != nil {
+			log.Printf("Error reading outbox: %v", err)
+			continue
+		}
+
+		for _, e := range events {
+			deliverEvent(ctx, e)
+		}
+	}
+}
+
+func deliverEvent(ctx context.Context, e structure.OutboxEvent) {
+	event, err := outboxEvent(ctx, e)
+	if err == nil {
+		err = publishOnce(ctx, e.Id, event)
+	}
+
+	if err == nil {
+		err = db.MarkEventDelivered(ctx, e.Id)
+		if err != nil {
+			log.Printf("Error marking outbox event %d delivered: %v", e.Id, err)
+		}
+		return
+	}
+
+	//Retries with a doubling delay, giving up after the last attempt
+	e.Attempts++
+	e.Last_error = err.Error()
+	delay := time.Duration(config.OutboxMaxDelay) * time.Second
+	if e.Attempts < 12 {
+		if d := time.Second << uint(e.Attempts-1); d < delay {
+			delay = d
+		}
+	}
+	e.Next_attempt = clock.Now().Add(delay).Unix()
+	if e.Attempts >= config.OutboxMaxAttempts {
+		e.Status = "failed"
+		log.Printf("Giving up on outbox event %d (%s): %v", e.Id, e.Topic, err)
+	}
+
+	err = db.MarkEventFailed(ctx, e)
+	if err != nil {
+		log.Printf("Error recording outbox failure of event %d: %v", e.Id, err)
+	}
+}
+
+// Runs the subscribers of the event that have not had it yet, recording each delivery so
+// a failing subscriber does not have the ones before it run again on the next attempt
+func publishOnce(ctx context.Context, id int, event events.Event) error {
+	delivered, err := db.FindDeliveries(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range bus.Subscribers(event) {
+		if delivered[s.Name] {
+			continue
+		}
+
+		err = s.Handle(event)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.Name, err)
+		}
+
+		err = db.AddDelivery(ctx, id, s.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Turns an outbox row back into the event it was queued for
+func outboxEvent(ctx context.Context, e structure.OutboxEvent) (events.Event, error) {
+	var row structure.RowEvent
+	err := json.Unmarshal([]byte(e.Payload), &row)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.Topic {
+	case config.TopicPostCreated:
+		posts, err := db.FindPostByParam(ctx, "id", strconv.Itoa(row.Id))
+		if err != nil {
+			return nil, err
+		}
+		if len(posts) == 0 {
+			return nil, errors.New("post not found")
+		}
+
+		return events.PostCreated{PostID: posts[0].Id, UserID: posts[0].User_id}, nil
+	case config.TopicCommentCreated:
+		comments, err := db.FindCommentByParam(ctx, "id", strconv.Itoa(row.Id))
+		if err != nil {
+			return nil, err
+		}
+		if len(comments) == 0 {
+			return nil, errors.New("comment not found")
+		}
+
+		return events.CommentCreated{CommentID: comments[0].Id, PostID: comments[0].Post_id, UserID: comments[0].User_id}, nil
+	}
+
+	return nil, errors.New("unknown outbox topic " + e.Topic)
+}
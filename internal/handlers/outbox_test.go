@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/events"
+	"real-time-forum/internal/structure"
+)
+
+// A retry of an outbox event only runs the subscribers that failed, the ones that had it
+// already are not run again
+func TestOutboxRetry(t *testing.T) {
+	fake := useFakeClock(t)
+	setupTestDB(t)
+	uid := newTestUser(t, "alice", "outbox-password")
+
+	saved := bus
+	bus = events.NewBus()
+	t.Cleanup(func() { bus = saved })
+
+	var streamed, notified int
+	bus.OnPostCreated("stream", func(events.PostCreated) error {
+		streamed++
+		return nil
+	})
+	bus.OnPostCreated("notify", func(events.PostCreated) error {
+		notified++
+		if notified == 1 {
+			return errors.New("notification failed")
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	post := structure.Post{Category: config.Categories[0][0], Title: "Outbox", Content: "Delivered to every subscriber once."}
+	_, err := db.NewPost(ctx, post, structure.User{Id: uid, Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deliver := func() int {
+		pending, err := db.FindPendingEvents(ctx, fake.Now().Unix(), config.OutboxBatchSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range pending {
+			deliverEvent(ctx, e)
+		}
+		return len(pending)
+	}
+
+	if n := deliver(); n != 1 {
+		t.Fatalf("%d pending events, expected 1", n)
+	}
+	if streamed != 1 || notified != 1 {
+		t.Fatalf("streamed %d and notified %d times, expected once each", streamed, notified)
+	}
+
+	//The retry is due after a second
+	fake.Advance(time.Second)
+	if n := deliver(); n != 1 {
+		t.Fatalf("%d events retried, expected 1", n)
+	}
+	if streamed != 1 || notified != 2 {
+		t.Errorf("streamed %d and notified %d times after the retry, expected 1 and 2", streamed, notified)
+	}
+
+	fake.Advance(time.Hour)
+	if n := deliver(); n != 0 {
+		t.Errorf("%d events still pending after delivery", n)
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/ids"
+	"real-time-forum/internal/mail"
+	"real-time-forum/internal/structure"
+	"real-time-forum/internal/validation"
+)
+
+// Limits how many reset and verification emails go to an address, and how many a client can ask for
+var resetLimiter = newRateLimiter(config.PasswordResetLimit, config.PasswordResetWindow*time.Second)
+
+// ForgotPasswordHandler emails a password reset link to the user with the given email.
+// The response is the same whether or not the email belongs to a user.
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req structure.ForgotPassword
+
+	//Decodes the request body into the forgot password struct
+	//Returns a bad request if there's an error
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&req)
+	if err != nil || !validation.IsEmail(req.Email) {
+		http.Error(w, "400 bad request.", http.StatusBadRequest)
+		return
+	}
+
+	if ok, retry := resetLimiter.allow("ip:" + remoteIP(r)); !ok {
+		writeTooManyRequests(w, retry)
+		return
+	}
+
+	//Responds the same and as fast whether or not the email is known, the lookup
+	//and the email happen after the response
+	if ok, _ := resetLimiter.allow("email:" + strings.ToLower(req.Email)); ok {
+		go sendPasswordReset(context.Background(), req.Email)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Emails a reset link to the user with the email, if there is one
+func sendPasswordReset(ctx context.Context, email string) {
+	exists, err := db.UserExists(ctx, email)
+	if err != nil || !exists {
+		return
+	}
+
+	user, err := db.FindUserByParam(ctx, "email", email)
+	if err != nil {
+		return
+	}
+
+	token, err := ids.New()
+	if err != nil {
+		return
+	}
+
+	err = db.NewPasswordReset(ctx, user.Id, token)
+	if err != nil {
+		log.Printf("Error creating password reset: %v", err)
+		return
+	}
+
+	link := config.SiteURL + "/?reset=" + url.QueryEscape(token)
+	body := "Someone asked to reset the password of your account " + user.Username + ".\n\n" +
+		"Choose a new password at " + link + "\n\n" +
+		"The link works for " + (config.PasswordResetAge * time.Second).String() + ". " +
+		"If it was not you, ignore this email, your password stays the same."
+
+	err = mail.Send(user.Email, "Reset your password", body)
+	if err != nil {
+		log.Printf("Error sending password reset email: %v", err)
+		return
+	}
+
+	audit(ctx, user.Id, user.Id, "password.forgot", "")
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"real-time-forum/internal/routes"
+)
+
+// Checks whether a user's role grants the permission
+func can(ctx context.Context, uid int, permission string) (bool, error) {
+	if uid == 0 {
+		return false, nil
+	}
+
+	return db.HasPermission(ctx, uid, permission)
+}
+
+// Finds the logged in user and makes sure they hold the permission, writing the error response
+// and returning false otherwise. Every privileged handler goes through this check.
+func requirePermission(w http.ResponseWriter, r *http.Request, permission string) (int, bool) {
+	uid := viewerId(r)
+	if uid == 0 {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return 0, false
+	}
+
+	ok, err := can(r.Context(), uid, permission)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return 0, false
+	}
+
+	if !ok {
+		http.Error(w, "403 forbidden", http.StatusForbidden)
+		return 0, false
+	}
+
+	return uid, true
+}
+
+// Lets through only requests of users holding the permission, see requirePermission
+func permitted(permission string) routes.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := requirePermission(w, r, permission); !ok {
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -1,33 +1,53 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"strconv"
 
+	"real-time-forum/internal/chat"
 	"real-time-forum/internal/config"
-	"real-time-forum/internal/database"
+	"real-time-forum/internal/imgproxy"
+	"real-time-forum/internal/upload"
 	"real-time-forum/internal/structure"
 )
 
-// PostHandler handles the /post endpoint
+// PostHandler handles the /post endpoint, the feed is also served at /posts. A category is
+// served a page at a time with /posts?category=<slug>&page=<n>.
 func PostHandler(w http.ResponseWriter, r *http.Request) {
-	//Prevents the endpoint being called by other url paths
-	if r.URL.Path != "/post" {
-		http.Error(w, "404 not found.", http.StatusNotFound)
-		return
-	}
-
 	//Checks whether it is a POST or GET request
 	switch r.Method {
 	case "GET":
 		var posts []structure.Post
+		var page *structure.PostPage
 		var err error
 
+		//Clients can ask for only some of the fields of the posts
+		fields, ok := selectedFields(w, r, isPostField)
+		if !ok {
+			return
+		}
+
 		//Checks for a passed search parameter
 		param := r.URL.Query().Get("param")
-		if param == "" {
+		if r.URL.Query().Get("after_last_seen") == "true" {
+			//Only returns the feed posts made since the user was last there
+			var ok bool
+			posts, ok = feedAfterLastSeen(w, r, param)
+			if !ok {
+				return
+			}
+		} else if slug := r.URL.Query().Get("category"); slug != "" {
+			var ok bool
+			page, ok = categoryPage(w, r, slug)
+			if !ok {
+				return
+			}
+			posts = page.Posts
+		} else if param == "" {
 			//If not found, returns all users
-			posts, err = database.FindAllPosts(config.Path)
+			posts, err = db.FindAllPosts(r.Context())
 			if err != nil {
 				http.Error(w, "500 internal server error", http.StatusInternalServerError)
 				return
@@ -42,15 +62,43 @@ func PostHandler(w http.ResponseWriter, r *http.Request) {
 			}
 
 			//Finds the posts based on the parameter and data
-			posts, err = database.FindPostByParam(config.Path, param, data)
+			posts, err = db.FindPostByParam(r.Context(), param, data)
 			if err != nil {
 				http.Error(w, "500 internal server error", http.StatusInternalServerError)
 				return
 			}
 		}
 
-		//Marshals the array of post structs to a json object
-		resp, err := json.Marshal(posts)
+		//A post opened on its own links to the parts around it in its series
+		if param == "id" && len(posts) == 1 {
+			s, found, err := db.FindPostSeries(r.Context(), posts[0].Id)
+			if err == nil && found {
+				posts[0].Series = &s
+			}
+		}
+
+		posts = hydratePosts(r, posts, fields)
+
+		if page != nil {
+			page.Posts = posts
+			if fields != nil {
+				writeJSON(w, http.StatusOK, struct {
+					*structure.PostPage
+					Posts []map[string]interface{} `json:"posts"`
+				}{page, projectPosts(posts, fields)})
+				return
+			}
+
+			writeJSON(w, http.StatusOK, page)
+			return
+		}
+
+		var list interface{} = posts
+		if fields != nil {
+			list = projectPosts(posts, fields)
+		}
+
+		resp, err := json.Marshal(list)
 		if err != nil {
 			http.Error(w, "500 internal server error", http.StatusInternalServerError)
 			return
@@ -65,45 +113,151 @@ func PostHandler(w http.ResponseWriter, r *http.Request) {
 
 		//Decodes the request body into the post struct
 		//Returns a bad request if there's an error
-		err := json.NewDecoder(r.Body).Decode(&newPost)
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&newPost)
 		if err != nil {
 			http.Error(w, "400 bad request.", http.StatusBadRequest)
 			return
 		}
 		//Checks whether the post is empty
-		cookie, err := r.Cookie("session")
+		//Checks whether the user is logged in
+		curr, err := currentUser(r)
 		if err != nil {
+			http.Error(w, "401 unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		foundVal := cookie.Value
-		//Checks whether the user is logged in
-		curr, err := database.CurrentUser(config.Path, foundVal)
-		if err != nil {
+		if !requireSteps(r.Context(), w, curr.Id, config.ActionPost) {
 			return
 		}
 
+		if !canPostIn(r.Context(), w, curr.Id, newPost.Category) {
+			return
+		}
+
+		//Event posts need a valid time and place
+		if newPost.Event != nil && !validEvent(w, newPost.Event) {
+			return
+		}
+
+		var ok bool
+		newPost.Crossposts, ok = validCrossposts(r.Context(), w, curr.Id, newPost.Category, newPost.Crossposts)
+		if !ok {
+			return
+		}
+
+		//Posts without a license get the forum default
+		license, ok := postLicense(r.Context(), w, newPost.License)
+		if !ok {
+			return
+		}
+		newPost.License = license
+
 		//Attemps to add the new post to the database
-		err = database.NewPost(config.Path, newPost, curr)
+		pid, err := db.NewPost(r.Context(), newPost, curr)
 		if err != nil {
 			http.Error(w, "500 internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		//Sends a message back if successfully posted
-		var msg = structure.Resp{Msg: "New post added"}
-		//Marshals the message to a json object
-		resp, err := json.Marshal(msg)
+		//Attaches the images uploaded for the post
+		err = db.LinkPostAttachments(r.Context(), pid, curr.Id, newPost.Attachment_ids)
 		if err != nil {
 			http.Error(w, "500 internal server error", http.StatusInternalServerError)
 			return
 		}
-		//Writes the json object to the frontend
-		w.WriteHeader(http.StatusOK)
-		w.Write(resp)
+
+		//Sends the created post back
+		created, err := db.FindPostByParam(r.Context(), "id", strconv.Itoa(pid))
+		if err != nil || len(created) == 0 {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		post := postDisplayNames(r.Context(), created)[0]
+		post.Crossposts = newPost.Crossposts
+		if newPost.Event != nil {
+			e, found, err := db.FindPostEvent(r.Context(), pid, curr.Id)
+			if err == nil && found {
+				post.Event = &e
+			}
+		}
+
+		//The new post is announced from the outbox
+		wakeOutbox()
+
+		writeCreated(w, "/post?param=id&data="+strconv.Itoa(pid), post)
 	default:
 		//Prevents the use of other request types
 		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 }
+
+// Queues the current comment and reaction counters of a post for the feed readers
+func pushCounts(ctx context.Context, hub *chat.Hub, pid int) {
+	posts, err := db.FindPostByParam(ctx, "id", strconv.Itoa(pid))
+	if err != nil || len(posts) == 0 {
+		return
+	}
+
+	hub.UpdateCounts(structure.PostCounts{
+		Post_id:  pid,
+		Comments: posts[0].Comment_count,
+		Likes:    posts[0].Likes,
+		Dislikes: posts[0].Dislikes,
+	})
+}
+
+// Fills in what the posts of a response show besides their row, leaving out the fields
+// that weren't asked for when it takes more queries
+func hydratePosts(r *http.Request, posts []structure.Post, fields fieldSet) []structure.Post {
+	//Routes external images in the post content through the image proxy
+	//and adds the uploaded images with their resized variants
+	for i := range posts {
+		posts[i].Content = imgproxy.RewriteHTML(posts[i].Content)
+
+		if !fields.has("images") {
+			continue
+		}
+
+		images, err := db.FindPostImages(r.Context(), posts[i].Id)
+		if err != nil {
+			continue
+		}
+
+		for _, img := range images {
+			if img.Scan_status == upload.StatusClean {
+				posts[i].Images = append(posts[i].Images, imageURLs(img))
+			}
+		}
+	}
+
+	//Adds the time and place of event posts, with the user's answer
+	viewer := viewerId(r)
+	for i := 0; i < len(posts) && fields.has("event"); i++ {
+		e, found, err := db.FindPostEvent(r.Context(), posts[i].Id, viewer)
+		if err == nil && found {
+			posts[i].Event = &e
+		}
+	}
+
+	//Adds the other categories each post shows up in
+	crossposts, err := db.FindCrossposts(r.Context())
+	if err == nil {
+		for i := range posts {
+			posts[i].Crossposts = crossposts[posts[i].Id]
+		}
+	}
+
+	//Adds how the user reacted to each post
+	if viewer != 0 && fields.has("reaction") {
+		reactions, err := db.FindUserReactions(r.Context(), viewer)
+		if err == nil {
+			for i := range posts {
+				posts[i].Reaction = reactions[posts[i].Id]
+			}
+		}
+	}
+
+	return postDisplayNames(r.Context(), posts)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/routes"
+	"real-time-forum/internal/structure"
+)
+
+// Registers the /posts/{id} endpoints:
+//
+//	PUT    /posts/{id}            edits the post, holding its edit lock
+//	DELETE /posts/{id}            moves the post to the trash, see TrashHandler
+//	POST   /posts/{id}/restore    restores the post from the trash
+//	GET    /posts/{id}/coauthors  lists the co-authors, POST adds and DELETE ?user_id= removes one
+//	POST   /posts/{id}/lock       acquires the edit lock, PUT renews it and DELETE releases it
+//	GET    /posts/{id}/revisions  lists the versions of the post and who wrote them
+//	POST   /posts/{id}/react      sets or removes the reaction of the user to the post
+//	GET    /posts/{id}/comments   lists the comments of the post as a tree, see postThread
+//	GET    /posts/{id}/event      shows the event of an event post, PUT changes it
+//	PUT    /posts/{id}/rsvp       answers the event with going, maybe or no, DELETE takes it back
+//	GET    /posts/{id}/attendees  lists who answered the event, as far as the viewer may see
+//	GET    /posts/{id}/event.ics  exports the event for calendar apps
+//	GET    /posts/{id}/crossposts lists the other categories the post shows up in, POST and DELETE change them
+//	POST   /posts/{id}/share      gives the share link of the post, see ShareHandler
+//	GET    /posts/{id}/stats      shows the author the views of the post and the clicks on its share link
+func postRoutes(rt *routes.Router, hub *chat.Hub) {
+	trash := func(w http.ResponseWriter, r *http.Request) {
+		TrashHandler(hub, w, r)
+	}
+
+	rt.HandleFunc("/posts/{id}", postRoute(editPost), "PUT")
+	rt.HandleFunc("/posts/{id}", trash, "DELETE")
+	rt.HandleFunc("/posts/{id}/restore", trash, "POST")
+	rt.HandleFunc("/posts/{id}/coauthors", postRoute(func(w http.ResponseWriter, r *http.Request, curr structure.User, post structure.Post) {
+		postCoauthors(hub, w, r, curr, post)
+	}), "GET", "POST", "DELETE")
+	rt.HandleFunc("/posts/{id}/lock", postRoute(postLock), "POST", "PUT", "DELETE")
+	rt.HandleFunc("/posts/{id}/revisions", postRoute(postRevisions), "GET")
+	rt.HandleFunc("/posts/{id}/react", postRoute(func(w http.ResponseWriter, r *http.Request, curr structure.User, post structure.Post) {
+		reactToPost(hub, w, r, curr, post.Id)
+	}), "POST")
+	rt.HandleFunc("/posts/{id}/event", postRoute(postEvent), "GET", "PUT")
+	rt.HandleFunc("/posts/{id}/rsvp", postRoute(postRsvp), "PUT", "DELETE")
+	rt.HandleFunc("/posts/{id}/attendees", postRoute(postAttendees), "GET")
+	rt.HandleFunc("/posts/{id}/event.ics", postRoute(func(w http.ResponseWriter, r *http.Request, curr structure.User, post structure.Post) {
+		postICal(w, r, post)
+	}), "GET")
+	rt.HandleFunc("/posts/{id}/crossposts", postRoute(postCrossposts), "GET", "POST", "DELETE")
+	rt.HandleFunc("/posts/{id}/share", postRoute(func(w http.ResponseWriter, r *http.Request, curr structure.User, post structure.Post) {
+		postShare(w, r, post)
+	}), "POST")
+	rt.HandleFunc("/posts/{id}/stats", postRoute(postStats), "GET")
+
+	//The comments can be read by visitors in public read-only mode, see publicAccess
+	rt.HandleFunc("/posts/{id}/comments", func(w http.ResponseWriter, r *http.Request) {
+		post, ok := findPost(w, r)
+		if !ok {
+			return
+		}
+
+		postThread(w, r, post.Id)
+	}, "GET")
+}
+
+// Serves an endpoint of the post of the path to the currently logged in user
+func postRoute(h func(http.ResponseWriter, *http.Request, structure.User, structure.Post)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		post, ok := findPost(w, r)
+		if !ok {
+			return
+		}
+
+		//Finds the currently logged in user
+		curr, err := currentUser(r)
+		if err != nil {
+			http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		h(w, r, curr, post)
+	}
+}
+
+// Finds the post of the path, answering a 404 when there is none
+func findPost(w http.ResponseWriter, r *http.Request) (structure.Post, bool) {
+	pid, err := strconv.Atoi(routes.Param(r, "id"))
+	if err != nil {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return structure.Post{}, false
+	}
+
+	posts, err := db.FindPostByParam(r.Context(), "id", strconv.Itoa(pid))
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return structure.Post{}, false
+	}
+	if len(posts) == 0 {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return structure.Post{}, false
+	}
+
+	return posts[0], true
+}
+
+// Lists the versions of a post and who wrote them (GET /posts/{id}/revisions)
+func postRevisions(w http.ResponseWriter, r *http.Request, curr structure.User, post structure.Post) {
+	revisions, err := db.FindPostRevisions(r.Context(), post.Id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	names := displayNames(r.Context())
+	for i := range revisions {
+		revisions[i].Display_name = names[revisions[i].Editor_id]
+	}
+
+	writeJSON(w, http.StatusOK, revisions)
+}
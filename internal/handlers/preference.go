@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// PreferenceHandler reads and updates the display preferences of the logged in user
+func PreferenceHandler(hub *chat.Hub, w http.ResponseWriter, r *http.Request) {
+	//Finds the currently logged in user
+	curr, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	//Checks whether it is a POST or GET request
+	switch r.Method {
+	case "GET":
+		p, err := db.FindPreferences(r.Context(), curr.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		resp, err := json.Marshal(p)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
+	case "POST":
+		var p structure.Preferences
+
+		//Decodes the request body into the preferences struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(r.Body).Decode(&p)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		if !isValidDisplayName(p.Display_name) {
+			http.Error(w, "400 bad request: Invalid display name preference.", http.StatusBadRequest)
+			return
+		}
+
+		//Users can only change their own preferences
+		p.User_id = curr.Id
+
+		err = db.UpdatePreferences(r.Context(), p)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		//The open connections are listed online under the new name
+		if name, err := db.FindDisplayName(r.Context(), curr.Id); err == nil {
+			go hub.Rename(curr.Id, name)
+		}
+
+		var msg = structure.Resp{Msg: "Preferences updated"}
+
+		resp, err := json.Marshal(msg)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// Checks whether a display name preference is allowed, empty values fall back to the default
+func isValidDisplayName(pref string) bool {
+	switch pref {
+	case "", config.DisplayUsername, config.DisplayFullName:
+		return true
+	default:
+		return false
+	}
+}
+
+// Loads the display names of all users, an empty map is returned on failure
+func displayNames(ctx context.Context) map[int]string {
+	names, err := db.FindDisplayNames(ctx)
+	if err != nil {
+		return map[int]string{}
+	}
+
+	return names
+}
+
+// Sets the author display name of every post
+func postDisplayNames(ctx context.Context, posts []structure.Post) []structure.Post {
+	names := displayNames(ctx)
+	for i := range posts {
+		posts[i].Display_name = names[posts[i].User_id]
+	}
+
+	return posts
+}
+
+// Sets the author display name of every comment
+func commentDisplayNames(ctx context.Context, comments []structure.Comment) []structure.Comment {
+	names := displayNames(ctx)
+	for i := range comments {
+		comments[i].Display_name = names[comments[i].User_id]
+	}
+
+	return comments
+}
+
+// Sets the sender display name of every message
+func messageDisplayNames(ctx context.Context, messages []structure.Message) []structure.Message {
+	names := displayNames(ctx)
+	for i := range messages {
+		messages[i].Display_name = names[messages[i].Sender_id]
+	}
+
+	return messages
+}
+
+// Sets the display name of every user
+func userDisplayNames(ctx context.Context, users []structure.User) []structure.User {
+	names := displayNames(ctx)
+	for i := range users {
+		users[i].Display_name = names[users[i].Id]
+	}
+
+	return users
+}
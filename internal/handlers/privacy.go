@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+	"real-time-forum/internal/upload"
+	"real-time-forum/internal/validation"
+)
+
+// PrivacyHandler reads and updates the visibility settings of the logged in user
+func PrivacyHandler(hub *chat.Hub, w http.ResponseWriter, r *http.Request) {
+	//Finds the currently logged in user
+	curr, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	//Checks whether it is a POST or GET request
+	switch r.Method {
+	case "GET":
+		p, err := db.FindPrivacy(r.Context(), curr.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		resp, err := json.Marshal(p)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
+	case "POST":
+		var p structure.Privacy
+
+		//Decodes the request body into the privacy struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(r.Body).Decode(&p)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		if !validation.IsVisibility(p.DOB) || !validation.IsVisibility(p.Gender) || !validation.IsVisibility(p.Presence) {
+			http.Error(w, "400 bad request: Invalid visibility.", http.StatusBadRequest)
+			return
+		}
+
+		//Users can only change their own settings
+		p.User_id = curr.Id
+
+		err = db.UpdatePrivacy(r.Context(), p)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		//Applies the presence setting to the open connection straight away
+		if p.Presence == "" {
+			p.Presence = config.DefaultVisibility
+		}
+		go hub.SetPresence(curr.Id, p.Presence)
+
+		var msg = structure.Resp{Msg: "Privacy updated"}
+
+		resp, err := json.Marshal(msg)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// Finds the user making the request, the one an admin impersonates, otherwise from their
+// access token or their session cookie
+func currentUser(r *http.Request) (structure.User, error) {
+	var u structure.User
+	var err error
+
+	if uid, ok := impersonatedUser(r); ok {
+		u, err = db.FindUserByParam(r.Context(), "id", strconv.Itoa(uid))
+	} else if claims, ok := requestClaims(r); ok {
+		u, err = db.FindUserByParam(r.Context(), "id", strconv.Itoa(claims.Sub))
+	} else {
+		var cookie *http.Cookie
+		cookie, err = r.Cookie("session")
+		if err != nil {
+			return structure.User{}, err
+		}
+
+		u, err = db.CurrentUser(r.Context(), cookie.Value)
+	}
+
+	if err == nil {
+		noteUser(r, u.Id)
+	}
+
+	return u, err
+}
+
+// Finds the id of the user making the request, 0 if they are not logged in
+func viewerId(r *http.Request) int {
+	curr, err := currentUser(r)
+	if err != nil {
+		return 0
+	}
+
+	return curr.Id
+}
+
+// Maps a user to the response the viewer is allowed to see
+func userResponse(ctx context.Context, viewer int, u structure.User) structure.UserResponse {
+	p, err := db.FindPrivacy(ctx, u.Id)
+	if err != nil {
+		//Fails closed if the settings cannot be read
+		p.DOB = config.VisibilityPrivate
+		p.Gender = config.VisibilityPrivate
+	}
+
+	var resp structure.UserResponse
+
+	//Users can always see their own profile and settings
+	if viewer == u.Id {
+		u.Privacy = &p
+		resp = structure.NewSelfResponse(u)
+	} else {
+		contact := false
+		if viewer != 0 && (p.DOB == config.VisibilityContacts || p.Gender == config.VisibilityContacts) {
+			contact, _ = db.AreContacts(ctx, viewer, u.Id)
+		}
+
+		resp = structure.NewUserResponse(u, isVisible(p.Gender, contact), isVisible(p.DOB, contact))
+	}
+
+	//Avatars are public, but only shown once they passed the malware scan
+	avatar, err := db.FindAvatar(ctx, u.Id)
+	if err == nil && avatar.Id != 0 && avatar.Scan_status == upload.StatusClean {
+		avatar = imageURLs(avatar)
+		resp.Avatar = &avatar
+	}
+
+	return resp
+}
+
+// Maps every user in the array to the response the viewer is allowed to see
+func userResponses(ctx context.Context, viewer int, users []structure.User) []structure.UserResponse {
+	resp := make([]structure.UserResponse, 0, len(users))
+	for _, u := range users {
+		resp = append(resp, userResponse(ctx, viewer, u))
+	}
+
+	return resp
+}
+
+// Checks whether a field with the given visibility can be seen
+func isVisible(visibility string, contact bool) bool {
+	switch visibility {
+	case config.VisibilityPublic:
+		return true
+	case config.VisibilityContacts:
+		return contact
+	default:
+		return false
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+	"real-time-forum/internal/validation"
+)
+
+// ProfileHandler fills in the date of birth and gender of the user (PUT /me/profile), the
+// profile step some actions require, see config.Requirements
+func ProfileHandler(w http.ResponseWriter, r *http.Request) {
+	//Finds the currently logged in user
+	curr, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var p structure.Profile
+
+	//Decodes the request body into the profile struct
+	//Returns a bad request if there's an error
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&p)
+	if err != nil {
+		http.Error(w, "400 bad request.", http.StatusBadRequest)
+		return
+	}
+
+	p.Gender = strings.TrimSpace(p.Gender)
+	if errs := validation.Profile(p); len(errs) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, validation.Response{Errors: errs})
+		return
+	}
+
+	err = db.UpdateProfile(r.Context(), curr.Id, p)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p)
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+)
+
+// Limits how fast a visitor who isn't logged in can read the forum
+var publicLimiter = newRateLimiter(config.PublicRateLimit, config.PublicRateWindow*time.Second)
+
+// Responses given to visitors, keyed by their url. Visitors all see the same content, so
+// one response serves every one of them until it expires.
+var publicCache = struct {
+	sync.Mutex
+	pages map[string]cachedPage
+}{pages: make(map[string]cachedPage)}
+
+type cachedPage struct {
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// publicAccess decides what visitors who aren't logged in can reach. Profiles always need
+// an account, the forum content can only be read in public read-only mode. Endpoints
+// doing their own checks, like logging in, chat and posting, are left to them.
+func publicAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if viewerId(r) != 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/user" || r.URL.Path == "/users":
+			http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		case publicRead(r):
+			if !config.PublicReadOnly {
+				http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if !limitRequest(w, publicLimiter.take("ip:"+remoteIP(r))) {
+				return
+			}
+
+			servePublic(next, w, r)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// Checks whether a request reads the posts, comments or categories, or searches them
+func publicRead(r *http.Request) bool {
+	if r.Method != "GET" {
+		return false
+	}
+
+	switch r.URL.Path {
+	case "/post", "/posts", "/comment", "/categories", "/search":
+		return true
+	}
+
+	//The comments of a post and the replies to a comment
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	return len(parts) == 3 && ((parts[0] == "posts" && parts[2] == "comments") || (parts[0] == "comments" && parts[2] == "replies"))
+}
+
+// Serves a visitor from the cache, or passes the request on and caches a successful response
+func servePublic(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	key := r.URL.RequestURI()
+	now := clock.Now()
+
+	publicCache.Lock()
+	page, found := publicCache.pages[key]
+	publicCache.Unlock()
+
+	if found && now.Before(page.expires) {
+		for k, v := range page.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("X-Cache", "HIT")
+		w.WriteHeader(http.StatusOK)
+		w.Write(page.body)
+		return
+	}
+
+	pw := &publicWriter{ResponseWriter: w}
+	next.ServeHTTP(pw, r)
+
+	if pw.status != http.StatusOK || pw.body.Len() > config.PublicCacheMaxBody {
+		return
+	}
+
+	publicCache.Lock()
+	defer publicCache.Unlock()
+
+	//Forgets the expired responses so the cache doesn't fill up with them
+	for k, p := range publicCache.pages {
+		if !now.Before(p.expires) {
+			delete(publicCache.pages, k)
+		}
+	}
+
+	if len(publicCache.pages) < config.PublicCacheEntries {
+		//The rate limit headers belong to the visitor who got the response first
+		header := w.Header().Clone()
+		for k := range header {
+			if strings.HasPrefix(k, "X-Ratelimit-") {
+				header.Del(k)
+			}
+		}
+
+		publicCache.pages[key] = cachedPage{
+			header:  header,
+			body:    pw.body.Bytes(),
+			expires: now.Add(config.PublicCacheAge * time.Second),
+		}
+	}
+}
+
+// Records a response to a visitor so it can be cached, letting browsers cache it too
+type publicWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (pw *publicWriter) WriteHeader(status int) {
+	pw.status = status
+	if status == http.StatusOK {
+		pw.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(config.PublicCacheAge))
+	}
+	pw.ResponseWriter.WriteHeader(status)
+}
+
+func (pw *publicWriter) Write(b []byte) (int, error) {
+	if pw.status == 0 {
+		pw.WriteHeader(http.StatusOK)
+	}
+
+	if pw.body.Len() <= config.PublicCacheMaxBody {
+		pw.body.Write(b)
+	}
+
+	return pw.ResponseWriter.Write(b)
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/routes"
+)
+
+// Counts requests per client in fixed time windows
+type rateLimiter struct {
+	sync.Mutex
+	limit  int
+	window time.Duration
+	hits   map[string]*rateWindow
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:  limit,
+		window: window,
+		hits:   make(map[string]*rateWindow),
+	}
+}
+
+// State of the limit of a client after a request, as told to it in the X-RateLimit headers
+type rateStatus struct {
+	ok        bool          // Whether the request is allowed
+	limit     int           // Requests the client can make at once
+	remaining int           // Requests the client can still make now
+	reset     time.Time     // When the client can make the whole limit of requests again
+	retry     time.Duration // Time until the next request is allowed, when this one is not
+}
+
+// Records a request of the client, returning false and the time left in the window once the limit is reached
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	s := l.take(key)
+	return s.ok, s.retry
+}
+
+// Records a request of the client and returns the state of its limit
+func (l *rateLimiter) take(key string) rateStatus {
+	l.Lock()
+	defer l.Unlock()
+
+	now := clock.Now()
+
+	//Forgets the windows that have ended so the map does not grow forever
+	for k, w := range l.hits {
+		if now.Sub(w.start) >= l.window {
+			delete(l.hits, k)
+		}
+	}
+
+	w, ok := l.hits[key]
+	if !ok {
+		w = &rateWindow{start: now}
+		l.hits[key] = w
+	}
+
+	s := rateStatus{limit: l.limit, reset: w.start.Add(l.window)}
+	if w.count >= l.limit {
+		s.retry = s.reset.Sub(now)
+		return s
+	}
+
+	w.count++
+	s.ok = true
+	s.remaining = l.limit - w.count
+	return s
+}
+
+// Tells the client the state of its limit in the X-RateLimit headers, the reset being a unix
+// time in seconds. Returns whether the request is allowed, writing a 429 response if it is not.
+func limitRequest(w http.ResponseWriter, s rateStatus) bool {
+	reset := s.reset.Unix()
+	if s.reset.After(time.Unix(reset, 0)) {
+		reset++
+	}
+
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(s.limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(s.remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+
+	if !s.ok {
+		writeTooManyRequests(w, s.retry)
+		return false
+	}
+
+	return true
+}
+
+// Identifies the client of a request by user id when logged in, otherwise by ip address
+func clientKey(r *http.Request) string {
+	if id := viewerId(r); id != 0 {
+		return "user:" + strconv.Itoa(id)
+	}
+
+	return "ip:" + remoteIP(r)
+}
+
+// Finds the ip address a request came from
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// Writes a 429 response telling the client when to retry
+func writeTooManyRequests(w http.ResponseWriter, retry time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(retry/time.Second)+1))
+	http.Error(w, "429 too many requests", http.StatusTooManyRequests)
+}
+
+// Token buckets of the clients of an endpoint
+type tokenBuckets struct {
+	sync.Mutex
+	cfg     config.RateLimit
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Takes a token from the bucket of the client and returns the state of its limit, the
+// request being refused when the bucket is empty
+func (b *tokenBuckets) take(key string) rateStatus {
+	b.Lock()
+	defer b.Unlock()
+
+	now := clock.Now()
+	interval := time.Duration(b.cfg.Interval * float64(time.Second))
+
+	//Forgets the buckets that have filled up again so the map does not grow forever
+	for k, t := range b.buckets {
+		if now.Sub(t.last) >= interval*time.Duration(b.cfg.Burst) {
+			delete(b.buckets, k)
+		}
+	}
+
+	t, ok := b.buckets[key]
+	if !ok {
+		t = &tokenBucket{tokens: float64(b.cfg.Burst), last: now}
+		b.buckets[key] = t
+	}
+
+	//Refills the bucket for the time since the last request
+	t.tokens += now.Sub(t.last).Seconds() / b.cfg.Interval
+	if t.tokens > float64(b.cfg.Burst) {
+		t.tokens = float64(b.cfg.Burst)
+	}
+	t.last = now
+
+	s := rateStatus{limit: b.cfg.Burst}
+	if t.tokens < 1 {
+		s.retry = time.Duration((1 - t.tokens) * float64(interval))
+	} else {
+		t.tokens--
+		s.ok = true
+	}
+
+	s.remaining = int(t.tokens)
+	s.reset = now.Add(time.Duration((float64(b.cfg.Burst) - t.tokens) * float64(interval)))
+	return s
+}
+
+// RateLimit throttles the requests of each ip address with a token bucket, answering 429
+// with a Retry-After header once a client has used up its bucket. Every response tells the
+// client how much of the bucket is left in the X-RateLimit headers.
+func RateLimit(next http.Handler, cfg config.RateLimit) http.Handler {
+	buckets := &tokenBuckets{cfg: cfg, buckets: make(map[string]*tokenBucket)}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !limitRequest(w, buckets.take(remoteIP(r))) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Rate limits a route, see RateLimit
+func rateLimited(cfg config.RateLimit) routes.Middleware {
+	return func(next http.Handler) http.Handler {
+		return RateLimit(next, cfg)
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"log"
+	"strconv"
+	texttemplate "text/template"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+var (
+	recapTitle   = texttemplate.Must(texttemplate.New("recap_title").Parse(config.RecapTitleTemplate))
+	recapContent = template.Must(template.New("recap").Parse(config.RecapTemplate))
+)
+
+// Posts a recap of the community's week in the announcements category once a week.
+// The first check only marks where the first recap starts from.
+func weeklyRecap(ctx context.Context) {
+	ticker := clock.NewTicker(config.CleanupInterval * time.Second)
+	defer ticker.Stop()
+
+	err := postRecap(ctx)
+	for {
+		if err != nil {
+			log.Printf("Error posting the weekly recap: %v", err)
+		}
+
+		if !ticker.Next(ctx) {
+			return
+		}
+		err = postRecap(ctx)
+	}
+}
+
+// Posts the recap when the last one is a week old, returning without posting otherwise
+func postRecap(ctx context.Context) error {
+	now := clock.Now()
+
+	last, err := recapSetting(ctx, config.SettingRecapTime)
+	if err != nil {
+		return err
+	}
+	if last != 0 && now.Unix()-int64(last) < config.RecapInterval {
+		return nil
+	}
+
+	afterPost, err := recapSetting(ctx, config.SettingRecapPost)
+	if err != nil {
+		return err
+	}
+
+	afterUser, err := recapSetting(ctx, config.SettingRecapUser)
+	if err != nil {
+		return err
+	}
+
+	system, err := db.FindSystemUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	recap, err := db.FindRecap(ctx, afterPost, afterUser, system.Id)
+	if err != nil {
+		return err
+	}
+
+	//Weeks without any activity, and the first check, are skipped
+	if last != 0 && (len(recap.Top_posts) > 0 || len(recap.Member_ids) > 0) {
+		err = publishRecap(ctx, recap, system, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	for key, value := range map[string]int{
+		config.SettingRecapPost: recap.Last_post_id,
+		config.SettingRecapUser: recap.Last_user_id,
+		config.SettingRecapTime: int(now.Unix()),
+	} {
+		err = db.SaveSetting(ctx, key, strconv.Itoa(value))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Fills in the recap templates and posts the result as the system user
+func publishRecap(ctx context.Context, recap structure.Recap, system structure.User, now time.Time) error {
+	names := displayNames(ctx)
+	for i := range recap.Top_posts {
+		recap.Top_posts[i].Display_name = names[recap.Top_posts[i].User_id]
+	}
+	for i, uid := range recap.Member_ids {
+		if i == config.RecapNewMembers {
+			recap.More_members = len(recap.Member_ids) - i
+			break
+		}
+		recap.New_members = append(recap.New_members, names[uid])
+	}
+	recap.Week = now.AddDate(0, 0, -7).Format("Jan 2") + " - " + now.Format("Jan 2, 2006")
+
+	var title, content bytes.Buffer
+	err := recapTitle.Execute(&title, recap)
+	if err != nil {
+		return err
+	}
+
+	err = recapContent.Execute(&content, recap)
+	if err != nil {
+		return err
+	}
+
+	license, err := db.FindSetting(ctx, config.SettingDefaultLicense)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewPost(ctx, structure.Post{
+		Category: config.RecapCategory,
+		Title:    title.String(),
+		Content:  content.String(),
+		License:  license,
+	}, system)
+	if err != nil {
+		return err
+	}
+
+	//The recap is announced from the outbox like any new post
+	wakeOutbox()
+	return nil
+}
+
+// Reads a number kept in the recap settings, 0 when it was never set
+func recapSetting(ctx context.Context, key string) (int, error) {
+	value, err := db.FindSetting(ctx, key)
+	if err != nil || value == "" {
+		return 0, err
+	}
+
+	return strconv.Atoi(value)
+}
@@ -3,73 +3,59 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
-	"regexp"
-	"strconv"
 
 	"real-time-forum/internal/config"
-	"real-time-forum/internal/database"
+	"real-time-forum/internal/events"
+	"real-time-forum/internal/ids"
 	"real-time-forum/internal/structure"
+	"real-time-forum/internal/validation"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 // RegisterHandler handles the registration endpoint
 func RegisterHandler(w http.ResponseWriter, r *http.Request) {
-	// Prevents the endpoint being called by other URL paths
-	if r.URL.Path != "/register" {
-		http.Error(w, "404 not found.", http.StatusNotFound)
-		return
-	}
-
-	// Prevent all request types other than POST
-	if r.Method != "POST" {
-		http.Error(w, "405 method not allowed.", http.StatusMethodNotAllowed)
-		return
-	}
-
 	// Stores the unmarshalled register data
-	var newUser structure.User
+	var req structure.RegisterRequest
 
-	// Decodes the request body into the user struct
+	// Decodes the request body into the register struct
 	// Returns a bad request if there's an error
-	err := json.NewDecoder(r.Body).Decode(&newUser)
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&req)
 	if err != nil {
 		http.Error(w, "400 bad request.", http.StatusBadRequest)
 		return
 	}
-	// Validate the email format
-	if !isValidEmail(newUser.Email) {
-		http.Error(w, "400 bad request: Invalid email address.", http.StatusBadRequest)
-		return
-	}
-	//checks if age is on valid format
-	age, err := strconv.Atoi(newUser.DOB)
-	if err != nil || age < 0 {
-		http.Error(w, "400 bad request: Invalid date of birth.", http.StatusBadRequest)
+
+	newUser := req.User()
+
+	// Validates every field, so the client can show all the problems next to their fields
+	if errs := validation.User(newUser); len(errs) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, validation.Response{Errors: errs})
 		return
 	}
 
 	// Check if the email or username already exists
-	emailExists, err := database.UserExists(config.Path, newUser.Email)
+	emailExists, err := db.UserExists(r.Context(), newUser.Email)
 	if err != nil {
 		http.Error(w, "500 internal server error.", http.StatusInternalServerError)
 		return
 	}
 
-	usernameExists, err := database.UserExists(config.Path, newUser.Username)
+	usernameExists, err := db.UserExists(r.Context(), newUser.Username)
 	if err != nil {
 		http.Error(w, "500 internal server error.", http.StatusInternalServerError)
 		return
 	}
 
-	if emailExists && usernameExists {
-		http.Error(w, "409 conflict: Email and username already exist.", http.StatusConflict)
-		return
-	} else if emailExists {
-		http.Error(w, "409 conflict: The email you entered is already taken.", http.StatusConflict)
-		return
-	} else if usernameExists {
-		http.Error(w, "409 conflict: The username you entered is already taken.", http.StatusConflict)
+	taken := validation.Errors{}
+	if emailExists {
+		taken.Add("email", "already taken")
+	}
+	if usernameExists {
+		taken.Add("username", "already taken")
+	}
+	if len(taken) > 0 {
+		writeJSON(w, http.StatusConflict, validation.Response{Errors: taken})
 		return
 	}
 
@@ -82,15 +68,28 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 
 	newUser.Password = passwordHash
 
+	// The account stays unverified until the link emailed to the user is opened
+	newUser.Verification, err = ids.New()
+	if err != nil {
+		http.Error(w, "500 internal server error.", http.StatusInternalServerError)
+		return
+	}
+
 	// Attempts to add the new user to the database
-	err = database.NewUser(config.Path, newUser)
+	err = db.NewUser(r.Context(), newUser)
 	if err != nil {
 		http.Error(w, "500 internal server error: Failed to register user.", http.StatusInternalServerError)
 		return
 	}
 
+	if created, err := db.FindUserByParam(r.Context(), "username", newUser.Username); err == nil {
+		bus.PublishAsync(events.UserRegistered{UserID: created.Id})
+	}
+
+	sendVerification(newUser, newUser.Verification)
+
 	// Sends a message back if successfully registered
-	var msg = structure.Resp{Msg: "Successful registration"}
+	var msg = structure.Resp{Msg: "Successful registration, check your email to verify your account"}
 
 	resp, err := json.Marshal(msg)
 	if err != nil {
@@ -108,11 +107,3 @@ func GenerateHash(password string) (string, error) {
 
 	return string(hash), err
 }
-
-// Helper function to validate email format
-func isValidEmail(email string) bool {
-	// Email validation pattern using regular expression
-	emailRegex := `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`
-	match, _ := regexp.MatchString(emailRegex, email)
-	return match
-}
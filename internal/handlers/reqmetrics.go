@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/routes"
+)
+
+// Requests of each endpoint since the alert rules were last evaluated
+var requests = &requestStats{endpoints: make(map[string]*endpointStats)}
+
+type requestStats struct {
+	sync.Mutex
+	endpoints map[string]*endpointStats
+}
+
+// Requests, server errors and latencies of an endpoint over an interval
+type endpointStats struct {
+	requests  int
+	errors    int
+	latencies []float64 // Milliseconds, sampled once there are more than AlertMaxSamples
+}
+
+// Counts a request of an endpoint, keeping a uniform sample of the latencies
+func (s *requestStats) record(endpoint string, status int, d time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+
+	e, ok := s.endpoints[endpoint]
+	if !ok {
+		e = &endpointStats{}
+		s.endpoints[endpoint] = e
+	}
+
+	e.requests++
+	if status >= 500 {
+		e.errors++
+	}
+
+	ms := float64(d) / float64(time.Millisecond)
+	if len(e.latencies) < config.AlertMaxSamples {
+		e.latencies = append(e.latencies, ms)
+	} else if i := rand.Intn(e.requests); i < config.AlertMaxSamples {
+		e.latencies[i] = ms
+	}
+}
+
+// Returns the requests of the interval that just ended and starts a new one
+func (s *requestStats) take() map[string]*endpointStats {
+	s.Lock()
+	defer s.Unlock()
+
+	endpoints := s.endpoints
+	s.endpoints = make(map[string]*endpointStats)
+	return endpoints
+}
+
+// Computes a metric of the alert rules over the requests of an endpoint
+func (e *endpointStats) metric(name string) float64 {
+	switch name {
+	case "p50":
+		return e.percentile(50)
+	case "p95":
+		return e.percentile(95)
+	case "p99":
+		return e.percentile(99)
+	case "error_rate":
+		return 100 * float64(e.errors) / float64(e.requests)
+	}
+
+	return 0
+}
+
+// Finds the latency under which the given percentage of the requests were answered
+func (e *endpointStats) percentile(p float64) float64 {
+	if len(e.latencies) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), e.latencies...)
+	sort.Float64s(sorted)
+
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+
+	return sorted[i]
+}
+
+// Remembers the status of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	sw.status = status
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+// Times the requests of each endpoint for the alert rules. Endpoints are the patterns the
+// routes of the router are registered with, so paths that are not routes are not timed.
+func requestMetrics(rt *routes.Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//Websockets stay open for as long as the page does
+		if r.URL.Path == "/ws" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		endpoint, found := rt.Match(r)
+		if !found {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		requests.record(endpoint, sw.status, time.Since(start))
+	})
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Role names are short lowercase identifiers, e.g. "janitor"
+var roleName = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// RoleHandler lists, creates, updates and removes roles (/admin/roles)
+func RoleHandler(w http.ResponseWriter, r *http.Request) {
+	admin, ok := requirePermission(w, r, config.PermRoleManage)
+	if !ok {
+		return
+	}
+
+	manager, err := findManager(r, admin)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		roles, err := db.FindRoles(r.Context())
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, roles)
+	case "POST":
+		var role structure.Role
+
+		//Decodes the request body into the role struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(r.Body).Decode(&role)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		if !roleName.MatchString(role.Name) {
+			http.Error(w, "400 bad request: Invalid role name.", http.StatusBadRequest)
+			return
+		}
+
+		for _, p := range role.Permissions {
+			if !isPermission(p) {
+				http.Error(w, "400 bad request: Unknown permission "+p+".", http.StatusBadRequest)
+				return
+			}
+		}
+
+		//Built-in roles cannot be changed
+		existing, err := db.FindRoleByName(r.Context(), role.Name)
+		if err == nil && existing.Builtin {
+			http.Error(w, "403 forbidden: Built-in roles cannot be changed.", http.StatusForbidden)
+			return
+		}
+
+		//Roles can't grant more than the manager holds, before or after the change
+		if !manager.canManage(role) || (err == nil && !manager.canManage(existing)) {
+			http.Error(w, "403 forbidden: You can't manage permissions you don't hold.", http.StatusForbidden)
+			return
+		}
+
+		err = db.UpdateRole(r.Context(), role)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		audit(r.Context(), admin, 0, "role.update", role.Name)
+
+		saved, err := db.FindRoleByName(r.Context(), role.Name)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeCreated(w, "/admin/roles", saved)
+	case "DELETE":
+		name := r.URL.Query().Get("name")
+
+		existing, err := db.FindRoleByName(r.Context(), name)
+		if err != nil {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		if existing.Builtin {
+			http.Error(w, "403 forbidden: Built-in roles cannot be removed.", http.StatusForbidden)
+			return
+		}
+
+		if !manager.canManage(existing) {
+			http.Error(w, "403 forbidden: You can't manage permissions you don't hold.", http.StatusForbidden)
+			return
+		}
+
+		err = db.DeleteRole(r.Context(), name)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		audit(r.Context(), admin, 0, "role.delete", name)
+		writeNoContent(w)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// UserRoleHandler gives roles to users and takes them away (/admin/user-roles)
+func UserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	admin, ok := requirePermission(w, r, config.PermRoleManage)
+	if !ok {
+		return
+	}
+
+	manager, err := findManager(r, admin)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var ur structure.UserRole
+
+	switch r.Method {
+	case "POST":
+		//Decodes the request body into the user role struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(r.Body).Decode(&ur)
+		if err != nil || ur.User_id == 0 {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		granted, err := db.FindRoleByName(r.Context(), ur.Role)
+		if err != nil {
+			http.Error(w, "400 bad request: Unknown role.", http.StatusBadRequest)
+			return
+		}
+
+		if !manager.canManage(granted) {
+			http.Error(w, "403 forbidden: You can't grant a role with permissions you don't hold.", http.StatusForbidden)
+			return
+		}
+	case "DELETE":
+		uid, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+		ur.User_id = uid
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	//Admins cannot lock themselves out
+	if ur.User_id == admin {
+		http.Error(w, "403 forbidden: You cannot change your own role.", http.StatusForbidden)
+		return
+	}
+
+	_, err = db.FindUserByParam(r.Context(), "id", strconv.Itoa(ur.User_id))
+	if err != nil {
+		http.Error(w, "404 not found: User does not exist.", http.StatusNotFound)
+		return
+	}
+
+	//Users holding more than the manager, e.g. admins, can only be changed by someone who does too
+	current, perms, err := db.FindUserPermissions(r.Context(), ur.User_id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !manager.canManage(structure.Role{Name: current, Permissions: perms}) {
+		http.Error(w, "403 forbidden: You can't change the role of this user.", http.StatusForbidden)
+		return
+	}
+
+	err = db.UpdateUserRole(r.Context(), ur.User_id, ur.Role)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if ur.Role == "" {
+		audit(r.Context(), admin, ur.User_id, "role.revoke", "")
+		writeNoContent(w)
+		return
+	}
+
+	audit(r.Context(), admin, ur.User_id, "role.grant", ur.Role)
+	writeJSON(w, http.StatusOK, ur)
+}
+
+// A user managing roles, with the role and permissions they hold
+type roleManager struct {
+	role        string
+	permissions map[string]bool
+}
+
+// Finds the role and permissions of the user managing roles
+func findManager(r *http.Request, uid int) (roleManager, error) {
+	role, perms, err := db.FindUserPermissions(r.Context(), uid)
+	if err != nil {
+		return roleManager{}, err
+	}
+
+	m := roleManager{role: role, permissions: make(map[string]bool, len(perms))}
+	for _, p := range perms {
+		m.permissions[p] = true
+	}
+
+	return m, nil
+}
+
+// Checks a manager may create, change, grant or take away a role. They can only hand out
+// permissions they hold themselves, and only admins can touch the admin role.
+func (m roleManager) canManage(role structure.Role) bool {
+	if role.Name == config.RoleAdmin && m.role != config.RoleAdmin {
+		return false
+	}
+
+	for _, p := range role.Permissions {
+		if !m.permissions[p] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Checks whether a permission exists
+func isPermission(p string) bool {
+	for _, known := range config.Permissions {
+		if p == known {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Turns the matched terms of a snippet into <mark> elements, escaping the rest of it
+var snippetMarks = strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>")
+
+// SearchHandler finds the posts and comments matching the words of a query (/search?q=),
+// best matches first with a snippet of the text around them
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" || len(q) > config.MaxSearchLength {
+		http.Error(w, "400 bad request: Invalid query.", http.StatusBadRequest)
+		return
+	}
+
+	//Clients can ask for only some of the fields of the results
+	fields, ok := selectedFields(w, r, isSearchResultField)
+	if !ok {
+		return
+	}
+
+	//Pages start at 1
+	page := 1
+	if p := r.URL.Query().Get("page"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 1 {
+			http.Error(w, "400 bad request: Invalid page.", http.StatusBadRequest)
+			return
+		}
+		page = n
+	}
+
+	//Fetches one extra match to know whether there is a next page
+	results, err := db.SearchPostsAndComments(r.Context(), q, config.SearchPageSize+1, (page-1)*config.SearchPageSize)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := structure.SearchPage{Results: []structure.SearchResult{}, Page: page}
+	if len(results) > config.SearchPageSize {
+		results = results[:config.SearchPageSize]
+		resp.Has_more = true
+	}
+
+	names := displayNames(r.Context())
+	for _, res := range results {
+		res.Snippet = snippetMarks.Replace(html.EscapeString(res.Snippet))
+		res.Display_name = names[res.User_id]
+		resp.Results = append(resp.Results, res)
+	}
+
+	if fields != nil {
+		writeJSON(w, http.StatusOK, struct {
+			structure.SearchPage
+			Results []map[string]interface{} `json:"results"`
+		}{resp, projectSearchResults(resp.Results, fields)})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
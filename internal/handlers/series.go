@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/routes"
+	"real-time-forum/internal/structure"
+)
+
+// Registers the endpoints of the series posts are grouped into:
+//
+//	GET    /series                lists the series, of a single author with ?user_id=
+//	POST   /series                creates a series of the user
+//	GET    /series/{id}           shows a series with its parts in order
+//	POST   /series/{id}/posts     adds a post of the author as the next part, DELETE ?post_id= takes one out
+//	PUT    /series/{id}/follow    follows the series to hear about new parts, DELETE stops following it
+func seriesRoutes(rt *routes.Router, hub *chat.Hub) {
+	rt.HandleFunc("/series", func(w http.ResponseWriter, r *http.Request) {
+		//Finds the currently logged in user
+		curr, err := currentUser(r)
+		if err != nil {
+			http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		seriesList(w, r, curr)
+	}, "GET", "POST")
+	rt.HandleFunc("/series/{id}", seriesRoute(showSeries), "GET")
+	rt.HandleFunc("/series/{id}/posts", seriesRoute(func(w http.ResponseWriter, r *http.Request, curr structure.User, s structure.Series) {
+		seriesPosts(hub, w, r, curr, s)
+	}), "POST", "DELETE")
+	rt.HandleFunc("/series/{id}/follow", seriesRoute(followSeries), "PUT", "DELETE")
+}
+
+// Serves an endpoint of the series of the path to the currently logged in user
+func seriesRoute(h func(http.ResponseWriter, *http.Request, structure.User, structure.Series)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		//Finds the currently logged in user
+		curr, err := currentUser(r)
+		if err != nil {
+			http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		sid, err := strconv.Atoi(routes.Param(r, "id"))
+		if err != nil {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		s, found, err := db.FindSeries(r.Context(), sid, curr.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		h(w, r, curr, s)
+	}
+}
+
+// Shows a series with its parts in order
+func showSeries(w http.ResponseWriter, r *http.Request, curr structure.User, s structure.Series) {
+	var err error
+	s.Posts, err = db.FindSeriesPosts(r.Context(), s.Id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	s.Display_name = displayNames(r.Context())[s.User_id]
+	s.Posts = postDisplayNames(r.Context(), s.Posts)
+	writeJSON(w, http.StatusOK, s)
+}
+
+// Lists the series or creates one
+func seriesList(w http.ResponseWriter, r *http.Request, curr structure.User) {
+	switch r.Method {
+	case "GET":
+		uid := 0
+		if q := r.URL.Query().Get("user_id"); q != "" {
+			var err error
+			uid, err = strconv.Atoi(q)
+			if err != nil {
+				http.Error(w, "400 bad request.", http.StatusBadRequest)
+				return
+			}
+		}
+
+		series, err := db.FindAllSeries(r.Context(), uid)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		names := displayNames(r.Context())
+		for i := range series {
+			series[i].Display_name = names[series[i].User_id]
+		}
+
+		writeJSON(w, http.StatusOK, series)
+	case "POST":
+		var s structure.Series
+
+		//Decodes the request body into the series struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&s)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		s.Title = strings.TrimSpace(s.Title)
+		if s.Title == "" || utf8.RuneCountInString(s.Title) > config.MaxSeriesTitleLength {
+			http.Error(w, "400 bad request: The title is required and has to be shorter.", http.StatusBadRequest)
+			return
+		}
+		if utf8.RuneCountInString(s.Description) > config.MaxSeriesDescriptionLength {
+			http.Error(w, "400 bad request: The description is too long.", http.StatusBadRequest)
+			return
+		}
+
+		s.User_id = curr.Id
+		s.Id, err = db.NewSeries(r.Context(), s)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		created, _, err := db.FindSeries(r.Context(), s.Id, curr.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		created.Display_name = displayNames(r.Context())[curr.Id]
+		writeCreated(w, "/series/"+strconv.Itoa(s.Id), created)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Adds a post to the end of a series, letting its followers know about the new part, or
+// takes one out. Only the author of the series does so, with posts they can edit.
+func seriesPosts(hub *chat.Hub, w http.ResponseWriter, r *http.Request, curr structure.User, s structure.Series) {
+	if curr.Id != s.User_id {
+		http.Error(w, "403 forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		var part structure.SeriesPart
+
+		//Decodes the request body into the series part struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&part)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		posts, err := db.FindPostByParam(r.Context(), "id", strconv.Itoa(part.Post_id))
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if len(posts) == 0 {
+			http.Error(w, "400 bad request: Unknown post.", http.StatusBadRequest)
+			return
+		}
+
+		ok, err := canEditPost(r.Context(), curr.Id, posts[0])
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "403 forbidden", http.StatusForbidden)
+			return
+		}
+
+		_, found, err := db.FindPostSeries(r.Context(), part.Post_id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if found {
+			http.Error(w, "409 conflict: The post is already part of a series.", http.StatusConflict)
+			return
+		}
+
+		err = db.AddPostToSeries(r.Context(), s.Id, part.Post_id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		link, _, err := db.FindPostSeries(r.Context(), part.Post_id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		go notifySeries(context.Background(), hub, s, posts[0], curr.Id)
+
+		writeCreated(w, "/post?param=id&data="+strconv.Itoa(part.Post_id), link)
+	case "DELETE":
+		pid, err := strconv.Atoi(r.URL.Query().Get("post_id"))
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		found, err := db.RemovePostFromSeries(r.Context(), s.Id, pid)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		writeNoContent(w)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Follows a series or stops following it
+func followSeries(w http.ResponseWriter, r *http.Request, curr structure.User, s structure.Series) {
+	switch r.Method {
+	case "PUT":
+		err := db.FollowSeries(r.Context(), s.Id, curr.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+	case "DELETE":
+		_, err := db.UnfollowSeries(r.Context(), s.Id, curr.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s, _, err := db.FindSeries(r.Context(), s.Id, curr.Id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	s.Display_name = displayNames(r.Context())[s.User_id]
+	writeJSON(w, http.StatusOK, s)
+}
+
+// Lets the followers of a series know a new part was published
+func notifySeries(ctx context.Context, hub *chat.Hub, s structure.Series, post structure.Post, author int) {
+	uids, err := db.FindFollowers(ctx, s.Id)
+	if err != nil {
+		log.Printf("Error finding the followers of series %d: %v", s.Id, err)
+		return
+	}
+
+	for _, uid := range uids {
+		if uid == author {
+			continue
+		}
+
+		err = notify(ctx, hub, structure.Notification{
+			User_id: uid,
+			Kind:    "series",
+			Content: "\"" + s.Title + "\" has a new part: \"" + post.Title + "\"",
+			Link:    "/post?param=id&data=" + strconv.Itoa(post.Id),
+		})
+		if err != nil {
+			log.Printf("Error storing series notification: %v", err)
+		}
+	}
+}
@@ -1,46 +1,244 @@
 package handlers
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"os/exec"
+	"os/signal"
 	"runtime"
+	"syscall"
+	"time"
 
 	"real-time-forum/internal/chat"
 	"real-time-forum/internal/config"
 	"real-time-forum/internal/database"
+	"real-time-forum/internal/routes"
 )
 
-// Sets up the router with endpoints and starts the server
-func StartServer() {
-	database.InitDB(config.Path)
+// The database every handler works with, set by StartServer
+var db *database.Store
 
-	mux := http.NewServeMux()
-	hub := chat.NewHub()
+// Sets up the router with endpoints and starts the server on the database
+func StartServer(store *database.Store) {
+	db = store
+	err := db.InitDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	//Background work is cancelled once the server is shut down
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hub := chat.NewHub(bus, db)
+	subscribe(ctx, hub)
 	go hub.Run()
+	go cleanupUploads(ctx)
+	go cleanupDrafts(ctx)
+	go cleanupTrash(ctx)
+	go cleanupChanges(ctx)
+	go cleanupClientErrors(ctx)
+	go cleanupSessions(ctx)
+	go remindEvents(ctx, hub)
+	if config.RecapEnabled {
+		go weeklyRecap(ctx)
+	}
+	go syncRevokedTokens(ctx)
+	go syncBans(ctx)
+	go dispatchOutbox(ctx)
+	go evaluateAlerts(ctx, hub)
+
+	server := newServer(newRouter(hub))
+	cert, key := os.Getenv(config.TLSCertEnv), os.Getenv(config.TLSKeyEnv)
+
+	go func() {
+		var err error
+		if cert != "" && key != "" {
+			err = server.ListenAndServeTLS(cert, key)
+		} else {
+			err = server.ListenAndServe()
+		}
+
+		if err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	if cert != "" && key != "" {
+		fmt.Println("Server running on port 8000 with TLS....")
+	} else {
+		fmt.Println("Server running on port 8000....")
+	}
+	openBrowser(config.SiteURL)
+
+	//Waits for the server to be stopped
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	shutdown(server, hub)
+}
+
+// Sets up the router with its middleware and endpoints, on the hub of the websocket clients
+func newRouter(hub *chat.Hub) *routes.Router {
+	rt := routes.New()
 
-	mux.Handle("/frontend/", http.StripPrefix("/frontend/", http.FileServer(http.Dir("./frontend"))))
-
-	mux.HandleFunc("/", HomeHandler)
-	mux.HandleFunc("/session", SessionHandler)
-	mux.HandleFunc("/login", LoginHandler)
-	mux.HandleFunc("/logout", LogoutHandler)
-	mux.HandleFunc("/register", RegisterHandler)
-	mux.HandleFunc("/user", UserHandler)
-	mux.HandleFunc("/post", PostHandler)
-	mux.HandleFunc("/message", MessageHandler)
-	mux.HandleFunc("/comment", CommentHandler)
-	mux.HandleFunc("/like", LikeHandler)
-	mux.HandleFunc("/chat", ChatHandler)
-	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+	//Runs for every request, in order, before it is routed
+	rt.Use(
+		accessLogging,
+		func(next http.Handler) http.Handler { return requestMetrics(rt, next) },
+		routes.CORS(config.CORSOrigins),
+		bodyLogging,
+		faultInjection,
+		bearerAuth,
+		sessionBinding,
+		slidingSession,
+		func(next http.Handler) http.Handler { return activityTracking(hub, next) },
+		publicAccess,
+		impersonation,
+	)
+
+	rt.Handle("/frontend/{file...}", http.StripPrefix("/frontend/", http.FileServer(http.Dir("./frontend"))), "GET")
+
+	rt.HandleFunc("/", HomeHandler, "GET")
+	rt.HandleFunc("/session", SessionHandler, "POST")
+	rt.HandleFunc("/sessions", func(w http.ResponseWriter, r *http.Request) {
+		SessionsHandler(hub, w, r)
+	}, "GET", "DELETE")
+	rt.HandleFunc("/bootstrap", BootstrapHandler, "GET")
+	rt.Handle("/login", routes.Chain(http.HandlerFunc(LoginHandler), rateLimited(config.LoginRateLimit)), "POST")
+	rt.Handle("/auth/token", routes.Chain(http.HandlerFunc(TokenHandler), rateLimited(config.LoginRateLimit)), "POST", "DELETE")
+	rt.HandleFunc("/login/not-me", func(w http.ResponseWriter, r *http.Request) {
+		NotMeHandler(hub, w, r)
+	}, "POST")
+	rt.HandleFunc("/verify", VerifyHandler, "GET", "POST")
+	rt.Handle("/forgot-password", routes.Chain(http.HandlerFunc(ForgotPasswordHandler), rateLimited(config.ForgotPasswordRateLimit)), "POST")
+	rt.HandleFunc("/reset-password", func(w http.ResponseWriter, r *http.Request) {
+		PasswordResetHandler(hub, w, r)
+	}, "POST")
+	rt.HandleFunc("/notifications", NotificationHandler, "GET", "POST")
+	rt.HandleFunc("/notifications/{id}/read", readNotification, "POST")
+	rt.HandleFunc("/logout", LogoutHandler, "POST")
+	rt.Handle("/register", routes.Chain(http.HandlerFunc(RegisterHandler), rateLimited(config.RegisterRateLimit)), "POST")
+	rt.HandleFunc("/user", UserHandler, "GET")
+	rt.HandleFunc("/users", UserDirectoryHandler, "GET")
+	rt.HandleFunc("/mention-suggestions", MentionSuggestionHandler, "GET")
+	rt.HandleFunc("/post", PostHandler, "GET", "POST")
+	rt.HandleFunc("/posts", PostHandler, "GET")
+	rt.HandleFunc("/message", MessageHandler, "GET", "POST")
+	rt.HandleFunc("/messages", MessageWindowHandler, "GET")
+	rt.HandleFunc("/comment", CommentHandler, "GET", "POST")
+	rt.HandleFunc("/batch", BatchHandler, "POST")
+	rt.HandleFunc("/sync", SyncHandler, "GET")
+	rt.HandleFunc("/client-errors", ClientErrorHandler, "POST")
+	rt.HandleFunc("/experiments/{name}/exposure", ExposureHandler, "POST")
+	rt.HandleFunc("/experiments/{name}/conversion", ConversionHandler, "POST")
+	rt.HandleFunc("/banners/{id}/dismiss", DismissBannerHandler, "POST")
+	rt.HandleFunc("/like", func(w http.ResponseWriter, r *http.Request) {
+		LikeHandler(hub, w, r)
+	}, "GET", "POST")
+	postRoutes(rt, hub)
+	rt.HandleFunc("/search", SearchHandler, "GET")
+	rt.HandleFunc("/s/{token}", ShareHandler, "GET")
+	rt.HandleFunc("/categories", CategoryHandler, "GET", "POST")
+	rt.HandleFunc("/categories/{slug}", CategoryHandler, "PUT", "DELETE")
+	seriesRoutes(rt, hub)
+	commentRoutes(rt, hub)
+	moderationRoutes(rt, hub)
+	rt.HandleFunc("/messages/{id}", func(w http.ResponseWriter, r *http.Request) {
+		TrashHandler(hub, w, r)
+	}, "DELETE")
+	rt.HandleFunc("/messages/{id}/restore", func(w http.ResponseWriter, r *http.Request) {
+		TrashHandler(hub, w, r)
+	}, "POST")
+	rt.HandleFunc("/chat", ChatHandler, "GET")
+	conversationRoutes(rt, hub)
+	rt.HandleFunc("/time", TimeHandler, "GET")
+	rt.HandleFunc("/licenses", LicenseHandler, "GET", "PUT")
+	rt.HandleFunc("/privacy", func(w http.ResponseWriter, r *http.Request) {
+		PrivacyHandler(hub, w, r)
+	}, "GET", "POST")
+	rt.HandleFunc("/preferences", func(w http.ResponseWriter, r *http.Request) {
+		PreferenceHandler(hub, w, r)
+	}, "GET", "POST")
+	rt.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		UploadHandler(hub, w, r)
+	}, "GET", "POST", "DELETE")
+	rt.HandleFunc("/attachment", AttachmentHandler, "GET")
+	rt.HandleFunc("/imgproxy", ImageProxyHandler, "GET")
+	rt.HandleFunc("/contacts/match", ContactMatchHandler, "POST")
+	rt.HandleFunc("/me", MeHandler, "GET")
+	rt.HandleFunc("/me/storage", StorageHandler, "GET")
+	rt.HandleFunc("/me/profile", ProfileHandler, "PUT")
+	rt.HandleFunc("/me/avatar", AvatarHandler, "PUT", "DELETE")
+	rt.HandleFunc("/me/feed-position", FeedPositionHandler, "GET", "PUT")
+	rt.HandleFunc("/me/trash", UserTrashHandler, "GET")
+	rt.HandleFunc("/me/analytics", AnalyticsHandler, "GET")
+	rt.HandleFunc("/me/client-preferences", ClientPrefHandler, "GET", "PUT", "DELETE")
+	rt.HandleFunc("/me/settings/export", func(w http.ResponseWriter, r *http.Request) {
+		SettingsExportHandler(hub, w, r)
+	}, "GET", "PUT")
+	rt.HandleFunc("/admin/impersonate", ImpersonateHandler, "GET", "POST", "DELETE")
+	rt.HandleFunc("/admin/roles", RoleHandler, "GET", "POST", "DELETE")
+	rt.HandleFunc("/admin/metrics", MetricsHandler, "GET")
+	rt.HandleFunc("/admin/client-errors", ClientErrorListHandler, "GET")
+	rt.HandleFunc("/admin/experiments", ExperimentResultHandler, "GET")
+	rt.HandleFunc("/admin/banners", BannerHandler, "GET", "POST")
+	rt.HandleFunc("/admin/banners/{id}", BannerHandler, "PUT", "DELETE")
+	rt.HandleFunc("/admin/alerts", func(w http.ResponseWriter, r *http.Request) {
+		AlertHandler(rt, w, r)
+	}, "GET", "POST", "DELETE")
+	rt.HandleFunc("/admin/user-roles", UserRoleHandler, "POST", "DELETE")
+	rt.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		if uid, ok := impersonatedUser(r); ok {
+			chat.ServeWsAs(hub, w, r, uid)
+			return
+		}
 		chat.ServeWs(hub, w, r)
-	})
+	}, "GET")
 
-	fmt.Println("Server running on port 8000....")
-	openBrowser("http://localhost:8000")
-	if err := http.ListenAndServe(":8000", mux); err != nil {
-		log.Fatal(err)
+	return rt
+}
+
+// Creates the http server with its timeouts. Over TLS, net/http negotiates HTTP/2 with the
+// clients on its own, unless it is turned off in the config.
+func newServer(h http.Handler) *http.Server {
+	server := &http.Server{
+		Addr:              config.ServerAddr,
+		Handler:           h,
+		ReadHeaderTimeout: config.ServerReadHeaderTimeout * time.Second,
+		IdleTimeout:       config.ServerIdleTimeout * time.Second,
+		MaxHeaderBytes:    config.MaxHeaderBytes,
+	}
+
+	if !config.HTTP2 {
+		//A non-nil empty map keeps HTTP/2 from being set up
+		server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+
+	return server
+}
+
+// Stops the server without cutting off what is in progress. New connections are refused
+// while the requests being served finish, then the websocket clients are told to reconnect
+// and the messages they were sending are stored. The database itself is closed by the
+// caller once the server is stopped.
+func shutdown(server *http.Server, hub *chat.Hub) {
+	fmt.Println("Shutting down....")
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error waiting for requests to finish: %v", err)
+	}
+
+	if err := hub.Shutdown(ctx); err != nil {
+		log.Printf("Error closing websocket connections: %v", err)
 	}
 }
 
@@ -1,41 +1,22 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/clock"
 	"real-time-forum/internal/config"
-	"real-time-forum/internal/database"
 	"real-time-forum/internal/structure"
 )
 
 func SessionHandler(w http.ResponseWriter, r *http.Request) {
-	// Prevents the endpoint being called by other URL paths
-	if r.URL.Path != "/session" {
-		http.Error(w, "404 not found.", http.StatusNotFound)
-		return
-	}
-
-	// Prevents all request types other than POST
-	if r.Method != "POST" {
-		http.Error(w, "405 method not allowed.", http.StatusMethodNotAllowed)
-		return
-	}
-
-	cookie, err := r.Cookie("session")
-	if err != nil {
-		// Handle the absence of session cookie here
-		// For example, you can log the error or perform other actions
-		fmt.Println("Session cookie not found or expired")
-		// Set a dummy value for the cookie to avoid "401 unauthorized" error
-		cookie = &http.Cookie{Name: "session", Value: "dummy"}
-	}
-
-	foundVal := cookie.Value
-
-	curr, err := database.CurrentUser(config.Path, foundVal)
+	//Finds the user of the session, or the one an admin impersonates
+	curr, err := currentUser(r)
 	if err != nil {
 		http.Error(w, "500 internal server error", http.StatusInternalServerError)
 		return
@@ -55,3 +36,110 @@ func SessionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// SessionsHandler lists the devices the logged in user is logged in on (GET /sessions),
+// and logs them out everywhere (DELETE /sessions)
+func SessionsHandler(hub *chat.Hub, w http.ResponseWriter, r *http.Request) {
+	//Finds the currently logged in user
+	curr, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		sessions, err := db.FindSessions(r.Context(), curr.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		//Marks the device making the request
+		if c, err := r.Cookie("session"); err == nil {
+			for i := range sessions {
+				sessions[i].Current = sessions[i].Session_uuid == c.Value
+			}
+		}
+
+		writeJSON(w, http.StatusOK, sessions)
+	case "DELETE":
+		err = db.DeleteUserSessions(r.Context(), curr.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		err = logOutEverywhere(r.Context(), hub, curr.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		audit(r.Context(), curr.Id, curr.Id, "session.logout_all", "")
+
+		//Removes the cookie from this browser too
+		http.SetCookie(w, sessionCookie("", -1))
+		writeNoContent(w)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Ends what keeps a user logged in besides their sessions, once those are deleted: the
+// websocket connections, the reconnect tokens and the access tokens issued so far
+func logOutEverywhere(ctx context.Context, hub *chat.Hub, uid int) error {
+	chat.RevokeReconnectTokens(uid)
+	hub.LogOut(uid)
+	return revokeUserTokens(ctx, uid)
+}
+
+// Creates the session cookie, a negative max age removes it
+func sessionCookie(session string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     "session",
+		Value:    session,
+		HttpOnly: true,
+		Path:     "/",
+		MaxAge:   maxAge,
+		SameSite: http.SameSiteNoneMode,
+		Secure:   true,
+	}
+}
+
+// slidingSession keeps sessions alive while they are used. Their expiry moves forward on
+// activity, and the cookie is sent again so the browser keeps it as long.
+func slidingSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("session")
+		if err == nil {
+			expires, touched, err := db.TouchSession(r.Context(), c.Value)
+			if err != nil {
+				log.Printf("Error refreshing session: %v", err)
+			} else if touched {
+				http.SetCookie(w, sessionCookie(c.Value, int(expires-clock.Now().Unix())))
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Periodically removes the sessions that expired
+func cleanupSessions(ctx context.Context) {
+	ticker := clock.NewTicker(config.CleanupInterval * time.Second)
+	defer ticker.Stop()
+
+	for ticker.Next(ctx) {
+		removed, err := db.PurgeSessions(ctx)
+		if err != nil {
+			log.Printf("Error purging expired sessions: %v", err)
+			continue
+		}
+
+		if removed > 0 {
+			log.Printf("Purged %d expired sessions", removed)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+	"real-time-forum/internal/validation"
+)
+
+// SettingsExportHandler exports all settings of the logged in user as one document, and imports such a document
+func SettingsExportHandler(hub *chat.Hub, w http.ResponseWriter, r *http.Request) {
+	//Finds the currently logged in user
+	curr, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		s, err := exportSettings(r.Context(), curr.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		//Lets browsers save the document as a file
+		w.Header().Set("Content-Disposition", `attachment; filename="settings.json"`)
+		writeJSON(w, http.StatusOK, s)
+	case "PUT":
+		var s structure.Settings
+
+		//Decodes the request body into the settings struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(r.Body).Decode(&s)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		if s.Version > config.SettingsVersion {
+			http.Error(w, "400 bad request: Unsupported settings version.", http.StatusBadRequest)
+			return
+		}
+
+		//Validates everything before changing anything
+		if s.Privacy != nil && (!validation.IsVisibility(s.Privacy.DOB) || !validation.IsVisibility(s.Privacy.Gender) || !validation.IsVisibility(s.Privacy.Presence)) {
+			http.Error(w, "400 bad request: Invalid visibility.", http.StatusBadRequest)
+			return
+		}
+
+		if s.Preferences != nil && !isValidDisplayName(s.Preferences.Display_name) {
+			http.Error(w, "400 bad request: Invalid display name preference.", http.StatusBadRequest)
+			return
+		}
+
+		//Client preferences are validated and stored together
+		if s.Client_preferences != nil {
+			_, err = applyClientPrefs(r.Context(), curr.Id, s.Client_preferences)
+			if err != nil {
+				http.Error(w, "400 bad request: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		//Settings are always imported for the logged in user, whoever exported them
+		if s.Privacy != nil {
+			s.Privacy.User_id = curr.Id
+
+			err = db.UpdatePrivacy(r.Context(), *s.Privacy)
+			if err != nil {
+				http.Error(w, "500 internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			presence := s.Privacy.Presence
+			if presence == "" {
+				presence = config.DefaultVisibility
+			}
+			go hub.SetPresence(curr.Id, presence)
+		}
+
+		if s.Preferences != nil {
+			s.Preferences.User_id = curr.Id
+
+			err = db.UpdatePreferences(r.Context(), *s.Preferences)
+			if err != nil {
+				http.Error(w, "500 internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		imported, err := exportSettings(r.Context(), curr.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, imported)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// Gathers every setting of a user into one document
+func exportSettings(ctx context.Context, uid int) (structure.Settings, error) {
+	s := structure.Settings{Version: config.SettingsVersion}
+
+	privacy, err := db.FindPrivacy(ctx, uid)
+	if err != nil {
+		return s, err
+	}
+
+	preferences, err := db.FindPreferences(ctx, uid)
+	if err != nil {
+		return s, err
+	}
+
+	clientPrefs, err := db.FindClientPrefs(ctx, uid)
+	if err != nil {
+		return s, err
+	}
+
+	s.Privacy = &privacy
+	s.Preferences = &preferences
+	s.Client_preferences = clientPrefs
+
+	return s, nil
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/routes"
+	"real-time-forum/internal/structure"
+)
+
+// ShareHandler follows the share link of a post (/s/{token}) to the post in the frontend,
+// counting the click under the referring site
+func ShareHandler(w http.ResponseWriter, r *http.Request) {
+	token := routes.Param(r, "token")
+
+	pid, found, err := db.FindSharedPost(r.Context(), token)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	//Posts in the trash keep their link but can't be reached through it
+	posts, err := db.FindPostByParam(r.Context(), "id", strconv.Itoa(pid))
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	if len(posts) == 0 {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	//A click that can't be counted shouldn't stop the visitor
+	err = db.CountShareClick(r.Context(), pid, referrerHost(r))
+	if err != nil {
+		log.Printf("Error counting share click: %v", err)
+	}
+
+	http.Redirect(w, r, "/?post="+strconv.Itoa(pid), http.StatusFound)
+}
+
+// Gives the share link of a post, making it the first time the post is shared
+func postShare(w http.ResponseWriter, r *http.Request, post structure.Post) {
+	token, err := newShareToken()
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	token, err = db.NewPostShare(r.Context(), post.Id, token)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, structure.PostShare{Post_id: post.Id, Token: token, Url: "/s/" + token})
+}
+
+// Shows the author of a post how often it was viewed and its share link followed
+func postStats(w http.ResponseWriter, r *http.Request, curr structure.User, post structure.Post) {
+	if curr.Id != post.User_id {
+		http.Error(w, "403 forbidden", http.StatusForbidden)
+		return
+	}
+
+	stats, err := db.FindPostStats(r.Context(), post)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// Makes a short random token for a share link
+func newShareToken() (string, error) {
+	b := make([]byte, config.ShareTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Finds the site a share link was followed from. Only its host is kept, the rest of the
+// referrer can tell who the visitor is.
+func referrerHost(r *http.Request) string {
+	u, err := url.Parse(r.Referer())
+	if err != nil || u.Hostname() == "" {
+		return "direct"
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if len(host) > config.MaxReferrerLength {
+		host = host[:config.MaxReferrerLength]
+	}
+
+	return host
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"real-time-forum/internal/completion"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Checks the user completed the steps an action requires, otherwise answers a 403 telling
+// the client which steps are missing
+func requireSteps(ctx context.Context, w http.ResponseWriter, uid int, action string) bool {
+	missing, err := completion.Missing(ctx, db, uid, action)
+	return stepsDone(w, action, missing, err)
+}
+
+// Checks the sender completed the steps needed to message the receiver, see requireSteps
+func requireStepsToMessage(ctx context.Context, w http.ResponseWriter, sender, receiver int) bool {
+	missing, err := completion.MissingToMessage(ctx, db, sender, receiver)
+	return stepsDone(w, config.ActionMessageStranger, missing, err)
+}
+
+func stepsDone(w http.ResponseWriter, action string, missing []string, err error) bool {
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return false
+	}
+
+	if len(missing) > 0 {
+		writeJSON(w, http.StatusForbidden, structure.MissingSteps{
+			Action:  action,
+			Missing: missing,
+			Message: completion.Describe(missing),
+		})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Day each user was last recorded active on, with the time zone it was counted in,
+// so only their first request of a day touches the database
+var activeDays sync.Map
+
+type activeDay struct {
+	day string
+	loc *time.Location
+}
+
+// activityTracking counts the days in a row each user makes an authenticated request.
+// The streak is updated from the last active day alone, without going through their history.
+func activityTracking(hub *chat.Hub, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//Recorded before the request is served so it already shows today's activity
+		if uid := viewerId(r); uid != 0 {
+			tz := r.Header.Get(config.TimezoneHeader)
+			if !activeToday(uid, tz) {
+				recordActivity(r.Context(), hub, uid, tz)
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Checks whether a user was already recorded active today, in the time zone they were counted in
+func activeToday(uid int, tz string) bool {
+	cached, ok := activeDays.Load(uid)
+	if !ok {
+		return false
+	}
+
+	a := cached.(activeDay)
+	if tz != "" && tz != a.loc.String() {
+		return false
+	}
+
+	today, _ := streakDays(a.loc)
+	return today == a.day
+}
+
+// Moves the streak of a user on to today in their time zone, or starts a new one when they
+// missed a day. Lets them know when the streak reaches a milestone.
+func recordActivity(ctx context.Context, hub *chat.Hub, uid int, tz string) {
+	s, err := db.FindStreak(ctx, uid)
+	if err != nil {
+		log.Printf("Error finding streak of user %d: %v", uid, err)
+		return
+	}
+
+	//Unknown time zones keep the one the user had
+	moved := false
+	if tz != "" && tz != s.Timezone {
+		if _, err := time.LoadLocation(tz); err == nil {
+			s.Timezone, moved = tz, true
+		}
+	}
+
+	loc := streakLocation(s.Timezone)
+	today, yesterday := streakDays(loc)
+	activeDays.Store(uid, activeDay{day: today, loc: loc})
+
+	//A day already counted, also when moving to a time zone where it isn't over yet
+	if s.Last_day >= today {
+		if moved {
+			db.SaveStreak(ctx, uid, s)
+		}
+		return
+	}
+
+	if s.Last_day == yesterday {
+		s.Current++
+	} else {
+		s.Current = 1
+	}
+	if s.Current > s.Best {
+		s.Best = s.Current
+	}
+	s.Last_day = today
+
+	err = db.SaveStreak(ctx, uid, s)
+	if err != nil {
+		log.Printf("Error saving streak of user %d: %v", uid, err)
+		return
+	}
+
+	for _, m := range config.StreakMilestones {
+		if s.Current == m {
+			go notifyStreak(context.Background(), hub, uid, m)
+		}
+	}
+}
+
+// Finds the streak of a user as it stands today, a streak not continued yesterday or today is over
+func currentStreak(ctx context.Context, uid int) (structure.Streak, error) {
+	s, err := db.FindStreak(ctx, uid)
+	if err != nil {
+		return s, err
+	}
+
+	today, yesterday := streakDays(streakLocation(s.Timezone))
+	if s.Last_day != today && s.Last_day != yesterday {
+		s.Current = 0
+	}
+
+	return s, nil
+}
+
+// Loads a stored time zone, falling back to the default one
+func streakLocation(tz string) *time.Location {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		loc, _ = time.LoadLocation(config.DefaultTimezone)
+	}
+
+	return loc
+}
+
+// Finds the dates of today and yesterday in a time zone
+func streakDays(loc *time.Location) (string, string) {
+	now := clock.Now().In(loc)
+	return now.Format("2006-01-02"), now.AddDate(0, 0, -1).Format("2006-01-02")
+}
+
+// Lets a user know their streak reached a milestone
+func notifyStreak(ctx context.Context, hub *chat.Hub, uid, days int) {
+	err := notify(ctx, hub, structure.Notification{
+		User_id: uid,
+		Kind:    "achievement",
+		Content: "You have been active " + strconv.Itoa(days) + " days in a row!",
+		Link:    "/me",
+	})
+	if err != nil {
+		log.Printf("Error storing achievement notification: %v", err)
+	}
+}
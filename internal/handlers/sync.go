@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// SyncHandler returns what changed since a cursor in the posts, the messages of the logged
+// in user and their notifications, as a log of changes oldest first (GET /sync?since=cursor).
+// Clients that were offline or asleep catch up with it instead of loading everything again.
+// Without since only the current cursor is returned, for clients that just loaded everything.
+func SyncHandler(w http.ResponseWriter, r *http.Request) {
+	//Finds the currently logged in user
+	curr, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	first, last, err := db.FindChangeBounds(r.Context())
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := structure.Sync{Changes: []structure.Change{}, Cursor: last}
+
+	param := r.URL.Query().Get("since")
+	if param == "" {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	since, err := strconv.Atoi(param)
+	if err != nil || since < 0 {
+		http.Error(w, "400 bad request: Invalid cursor.", http.StatusBadRequest)
+		return
+	}
+
+	//Changes after the cursor were removed, or the cursor is from another database
+	if (first > 0 && since < first-1) || (first == 0 && since < last) || since > last {
+		http.Error(w, "410 gone: The cursor has expired, everything has to be loaded again.", http.StatusGone)
+		return
+	}
+
+	changes, err := db.FindChanges(r.Context(), curr.Id, since, config.SyncMaxChanges+1)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if len(changes) > config.SyncMaxChanges {
+		changes = changes[:config.SyncMaxChanges]
+		resp.More = true
+	}
+
+	//The cursor moves past the changes the user doesn't see too, unless there are more to come
+	if len(changes) > 0 && (resp.More || changes[len(changes)-1].Cursor > last) {
+		resp.Cursor = changes[len(changes)-1].Cursor
+	}
+
+	err = attachChanged(r, changes)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	resp.Changes = changes
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Adds to the created and updated items of the changes what they are now, with one query
+// for each kind
+func attachChanged(r *http.Request, changes []structure.Change) error {
+	ids := map[string][]int{}
+	for _, c := range changes {
+		if c.Action != "deleted" {
+			ids[c.Kind] = append(ids[c.Kind], c.Id)
+		}
+	}
+
+	posts := map[int]*structure.Post{}
+	if len(ids["post"]) > 0 {
+		found, err := db.FindPostsByIds(r.Context(), ids["post"])
+		if err != nil {
+			return err
+		}
+
+		found = hydratePosts(r, found, nil)
+		for i := range found {
+			posts[found[i].Id] = &found[i]
+		}
+	}
+
+	messages := map[int]*structure.Message{}
+	if len(ids["message"]) > 0 {
+		found, err := db.FindMessagesByIds(r.Context(), ids["message"])
+		if err != nil {
+			return err
+		}
+
+		for i := range found {
+			messages[found[i].Id] = &found[i]
+		}
+	}
+
+	notifications := map[int]*structure.Notification{}
+	if len(ids["notification"]) > 0 {
+		found, err := db.FindNotificationsByIds(r.Context(), ids["notification"])
+		if err != nil {
+			return err
+		}
+
+		for i := range found {
+			notifications[found[i].Id] = &found[i]
+		}
+	}
+
+	for i, c := range changes {
+		if c.Action == "deleted" {
+			continue
+		}
+
+		switch c.Kind {
+		case "post":
+			changes[i].Post = posts[c.Id]
+		case "message":
+			changes[i].Message = messages[c.Id]
+		case "notification":
+			changes[i].Notification = notifications[c.Id]
+		}
+	}
+
+	return nil
+}
+
+// Removes the changes older than clients are expected to stay offline for
+func cleanupChanges(ctx context.Context) {
+	ticker := clock.NewTicker(config.CleanupInterval * time.Second)
+	defer ticker.Stop()
+
+	for ticker.Next(ctx) {
+		removed, err := db.DeleteOldChanges(ctx, clock.Now().Add(-config.SyncRetention*time.Second).Unix())
+		if err != nil {
+			log.Printf("Error removing old changes: %v", err)
+			continue
+		}
+
+		if removed > 0 {
+			log.Printf("Removed %d old changes", removed)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/routes"
+	"real-time-forum/internal/structure"
+)
+
+// Registers the /comments/{id} endpoints:
+//
+//	GET    /comments/{id}/replies  lists the replies to the comment as a tree, ?depth= levels deep
+//	POST   /comments/{id}/replies  replies to the comment
+//	DELETE /comments/{id}          moves the comment to the trash, see TrashHandler
+//	POST   /comments/{id}/restore  restores the comment from the trash
+func commentRoutes(rt *routes.Router, hub *chat.Hub) {
+	trash := func(w http.ResponseWriter, r *http.Request) {
+		TrashHandler(hub, w, r)
+	}
+
+	rt.HandleFunc("/comments/{id}", trash, "DELETE")
+	rt.HandleFunc("/comments/{id}/restore", trash, "POST")
+	rt.HandleFunc("/comments/{id}/replies", CommentsHandler, "GET", "POST")
+}
+
+// CommentsHandler lists the replies to a comment and replies to it (/comments/{id}/replies)
+func CommentsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(routes.Param(r, "id"))
+	if err != nil {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		depth, ok := threadDepth(w, r)
+		if !ok {
+			return
+		}
+
+		parent, err := db.FindCommentByParam(r.Context(), "id", strconv.Itoa(id))
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if len(parent) == 0 {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		comments, err := db.FindCommentByParam(r.Context(), "post_id", strconv.Itoa(parent[0].Post_id))
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, commentThread(commentDisplayNames(r.Context(), comments), id, depth))
+	case "POST":
+		//Finds the currently logged in user
+		curr, err := currentUser(r)
+		if err != nil {
+			http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if !requireSteps(r.Context(), w, curr.Id, config.ActionComment) {
+			return
+		}
+
+		var reply structure.Comment
+
+		//Decodes the request body into the comment struct
+		//Returns a bad request if there's an error
+		err = json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&reply)
+		if err != nil || strings.TrimSpace(reply.Content) == "" {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		parent, ok := replyParent(r.Context(), w, id)
+		if !ok {
+			return
+		}
+
+		if !threadOpen(r.Context(), w, parent.Post_id) || !canCommentOn(r.Context(), w, curr.Id, parent.Post_id) {
+			return
+		}
+
+		reply.Post_id = parent.Post_id
+		reply.User_id = curr.Id
+		reply.Parent_comment_id = parent.Id
+
+		rid, err := db.NewComment(r.Context(), reply)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		created, err := db.FindCommentByParam(r.Context(), "id", strconv.Itoa(rid))
+		if err != nil || len(created) == 0 {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		//The reply is streamed to the viewers of the post from the outbox
+		wakeOutbox()
+
+		writeCreated(w, "/comment?param=id&data="+strconv.Itoa(rid), commentDisplayNames(r.Context(), created)[0])
+	}
+}
+
+// Lists the comments of a post as a tree (/posts/{id}/comments), ?depth= levels deep
+func postThread(w http.ResponseWriter, r *http.Request, pid int) {
+	depth, ok := threadDepth(w, r)
+	if !ok {
+		return
+	}
+
+	comments, err := db.FindCommentByParam(r.Context(), "post_id", strconv.Itoa(pid))
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, commentThread(commentDisplayNames(r.Context(), comments), 0, depth))
+}
+
+// Reads how many levels of replies to return, CommentMaxDepth when it is not given
+func threadDepth(w http.ResponseWriter, r *http.Request) (int, bool) {
+	depth := config.CommentMaxDepth
+	if d := r.URL.Query().Get("depth"); d != "" {
+		var err error
+		depth, err = strconv.Atoi(d)
+		if err != nil || depth < 1 || depth > config.CommentMaxDepth {
+			http.Error(w, "400 bad request: Invalid depth.", http.StatusBadRequest)
+			return 0, false
+		}
+	}
+
+	return depth, true
+}
+
+// Finds the comment a reply is to, checking the thread is not already as deep as it can go
+func replyParent(ctx context.Context, w http.ResponseWriter, id int) (structure.Comment, bool) {
+	parent, err := db.FindCommentByParam(ctx, "id", strconv.Itoa(id))
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return structure.Comment{}, false
+	}
+	if len(parent) == 0 {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return structure.Comment{}, false
+	}
+
+	depth, err := db.FindCommentDepth(ctx, id)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return structure.Comment{}, false
+	}
+	if depth >= config.CommentMaxDepth {
+		http.Error(w, "400 bad request: Replies are nested too deeply.", http.StatusBadRequest)
+		return structure.Comment{}, false
+	}
+
+	return parent[0], true
+}
+
+// Nests the replies to a comment, or the top level comments of a post when the parent is 0,
+// down to the given depth. Replies whose comment is gone are shown at the top level.
+func commentThread(comments []structure.Comment, parent, depth int) []structure.Comment {
+	ids := make(map[int]bool)
+	for _, c := range comments {
+		ids[c.Id] = true
+	}
+
+	children := make(map[int][]structure.Comment)
+	for _, c := range comments {
+		p := c.Parent_comment_id
+		if !ids[p] {
+			p = 0
+		}
+		children[p] = append(children[p], c)
+	}
+
+	return nestReplies(children, parent, depth)
+}
+
+func nestReplies(children map[int][]structure.Comment, parent, depth int) []structure.Comment {
+	replies := children[parent]
+	if replies == nil {
+		return []structure.Comment{}
+	}
+
+	for i := range replies {
+		replies[i].Reply_count = len(children[replies[i].Id])
+		if depth > 1 && replies[i].Reply_count > 0 {
+			replies[i].Replies = nestReplies(children, replies[i].Id, depth-1)
+		}
+	}
+
+	return replies
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+)
+
+var logins = newLoginThrottle()
+
+// Tracks failed logins and makes clients wait longer after every failure
+type loginThrottle struct {
+	sync.Mutex
+	failures map[string]*loginFailures
+}
+
+type loginFailures struct {
+	count int
+	last  time.Time
+}
+
+func newLoginThrottle() *loginThrottle {
+	return &loginThrottle{
+		failures: make(map[string]*loginFailures),
+	}
+}
+
+// Reserves a login attempt of the client, returning how long it has to wait before trying
+// again instead when it can't try now. The attempt counts as failed until reset is called,
+// so parallel attempts can't all get through before any of them fails.
+func (t *loginThrottle) reserve(key string, free int) time.Duration {
+	t.Lock()
+	defer t.Unlock()
+
+	now := clock.Now()
+
+	//Forgets the clients that stopped failing so the map does not grow forever
+	for k, f := range t.failures {
+		if now.Sub(f.last) >= config.LoginFailureReset*time.Second {
+			delete(t.failures, k)
+		}
+	}
+
+	f, ok := t.failures[key]
+	if !ok {
+		f = &loginFailures{}
+		t.failures[key] = f
+	}
+
+	if f.count < free {
+		f.count++
+		f.last = now
+		return 0
+	}
+
+	//Doubles the delay with every failure past the free attempts
+	delay := config.LoginMaxDelay * time.Second
+	if n := f.count - free; n < 20 {
+		delay = config.LoginBaseDelay * time.Second << uint(n)
+		if delay > config.LoginMaxDelay*time.Second {
+			delay = config.LoginMaxDelay * time.Second
+		}
+	}
+
+	if left := f.last.Add(delay).Sub(now); left > 0 {
+		return left
+	}
+
+	f.count++
+	f.last = now
+	return 0
+}
+
+// Clears the failures of the client after a successful login, including the reserved attempt
+func (t *loginThrottle) reset(key string) {
+	t.Lock()
+	defer t.Unlock()
+
+	delete(t.failures, key)
+}
+
+// Identifies a login attempt by the account it targets and where it comes from.
+// Known devices are counted apart from their ip address, so failures from
+// others on the same network cannot slow the user down.
+func loginKey(r *http.Request, identifier, device string) string {
+	identifier = strings.ToLower(identifier)
+	if device != "" {
+		return identifier + "|device:" + device
+	}
+
+	return identifier + "|ip:" + remoteIP(r)
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"net/http"
+
+	"real-time-forum/internal/chat"
+)
+
+// TimeHandler returns the current server time so clients can correct their clocks
+func TimeHandler(w http.ResponseWriter, r *http.Request) {
+	//The time must never be cached
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, chat.ServerTime())
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// TokenHandler issues access tokens to API clients logging in with a username or email and
+// password, and revokes them (/auth/token)
+func TokenHandler(w http.ResponseWriter, r *http.Request) {
+	if !tokensEnabled() {
+		http.Error(w, "404 not found: Access tokens are not enabled.", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		var loginData structure.Login
+
+		//Decodes the request body into the login struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(r.Body).Decode(&loginData)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		//API clients are throttled like logins from unknown devices
+		key := loginKey(r, loginData.Data, "")
+		if wait := logins.reserve(key, config.LoginFreeAttempts); wait > 0 {
+			writeTooManyRequests(w, wait)
+			return
+		}
+
+		param := "username"
+		if _, err := mail.ParseAddress(loginData.Data); err == nil {
+			param = "email"
+		}
+
+		foundUser, err := db.FindUserByParam(r.Context(), param, loginData.Data)
+		if err == nil {
+			err = bcrypt.CompareHashAndPassword([]byte(foundUser.Password), []byte(loginData.Password))
+		}
+		if err != nil {
+			http.Error(w, "401 unauthorized: username or password incorrect", http.StatusUnauthorized)
+			return
+		}
+
+		logins.reset(key)
+
+		locked, err := db.IsResetRequired(r.Context(), foundUser.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if locked {
+			http.Error(w, "403 forbidden: Password reset required.", http.StatusForbidden)
+			return
+		}
+
+		if !notBanned(r.Context(), w, foundUser.Id) {
+			return
+		}
+
+		//New accounts may have to verify their email first, see config.Requirements
+		if !requireSteps(r.Context(), w, foundUser.Id, config.ActionLogin) {
+			return
+		}
+
+		token, claims, err := signToken(foundUser.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, structure.AccessToken{Token: token, Expires: claims.Exp})
+	case "DELETE":
+		//Admins can revoke any token by its id in an emergency
+		if jti := r.URL.Query().Get("jti"); jti != "" {
+			admin, ok := requirePermission(w, r, config.PermRoleManage)
+			if !ok {
+				return
+			}
+
+			//The expiry of the token is unknown, it is kept for as long as any token can live
+			err := revokeToken(r.Context(), jti, clock.Now().Add(config.JWTAccessTokenAge*time.Second).Unix())
+			if err != nil {
+				http.Error(w, "500 internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			audit(r.Context(), admin, 0, "token.revoke", jti)
+			writeNoContent(w)
+			return
+		}
+
+		//Otherwise revokes the token the request was made with
+		claims, ok := requestClaims(r)
+		if !ok {
+			http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		err := revokeToken(r.Context(), claims.Jti, claims.Exp)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeNoContent(w)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
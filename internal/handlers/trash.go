@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/routes"
+	"real-time-forum/internal/structure"
+)
+
+// TrashHandler lets users delete their own posts, comments and messages and restore them
+// while they are recoverable:
+//
+//	DELETE /posts/{id}          POST /posts/{id}/restore
+//	DELETE /comments/{id}       POST /comments/{id}/restore
+//	DELETE /messages/{id}       POST /messages/{id}/restore
+func TrashHandler(hub *chat.Hub, w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(routes.Param(r, "id"))
+	if err != nil {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	//The routes are /{kind}/{id} and /{kind}/{id}/restore
+	kind := strings.Split(strings.Trim(r.URL.Path, "/"), "/")[0]
+	restore := strings.HasSuffix(r.URL.Path, "/restore")
+
+	//Finds the currently logged in user
+	curr, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	now := clock.Now().Unix()
+	since := now - config.TrashRetention
+
+	switch kind {
+	case "posts":
+		var ok bool
+		if restore {
+			ok, err = db.RecoverPost(r.Context(), curr.Id, id, since)
+		} else {
+			ok, err = db.DeletePost(r.Context(), curr.Id, id, now)
+		}
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+	case "comments":
+		var postID int
+		var ok bool
+		if restore {
+			postID, err = db.FindTrashedComment(r.Context(), curr.Id, id, since)
+			if err != nil {
+				http.Error(w, "404 not found.", http.StatusNotFound)
+				return
+			}
+
+			//Comments can only go back on posts that are still there
+			ok, err = db.RecoverComment(r.Context(), curr.Id, id, since)
+			if err == nil && !ok {
+				http.Error(w, "409 conflict: The post was deleted.", http.StatusConflict)
+				return
+			}
+		} else {
+			postID, ok, err = db.DeleteComment(r.Context(), curr.Id, id, now)
+		}
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		go pushCounts(context.Background(), hub, postID)
+	case "messages":
+		var receiverID int
+		var ok bool
+		event := structure.MessageEvent{Msg_type: "message_deleted", Id: id, Sender_id: curr.Id}
+		if restore {
+			event.Msg_type = "message_restored"
+			receiverID, ok, err = db.RecoverMessage(r.Context(), curr.Id, id, since)
+		} else {
+			receiverID, ok, err = db.DeleteMessage(r.Context(), curr.Id, id, now)
+		}
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		//Both users of the conversation update the messages they show
+		event.Receiver_id = receiverID
+		msg, err := json.Marshal(event)
+		if err == nil {
+			hub.SendTo(curr.Id, msg)
+			hub.SendTo(receiverID, msg)
+		}
+	default:
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	writeNoContent(w)
+}
+
+// Lists what the user deleted and can still restore (/me/trash)
+func UserTrashHandler(w http.ResponseWriter, r *http.Request) {
+	//Finds the currently logged in user
+	curr, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	items, err := db.FindTrash(r.Context(), curr.Id, clock.Now().Unix()-config.TrashRetention)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// Periodically removes for good what was deleted longer ago than it can be restored
+func cleanupTrash(ctx context.Context) {
+	ticker := clock.NewTicker(config.CleanupInterval * time.Second)
+	defer ticker.Stop()
+
+	for ticker.Next(ctx) {
+		removed, err := db.PurgeTrash(ctx, clock.Now().Unix()-config.TrashRetention)
+		if err != nil {
+			log.Printf("Error purging deleted items: %v", err)
+			continue
+		}
+
+		if removed > 0 {
+			log.Printf("Purged %d deleted items", removed)
+		}
+	}
+}
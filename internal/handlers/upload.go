@@ -0,0 +1,561 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+	"real-time-forum/internal/upload"
+)
+
+// Scans every new upload for malware
+var scanner = upload.NewScanner()
+
+// Backend holding the uploaded files
+var store = upload.NewStorage()
+
+// UploadHandler stores new attachments and issues fresh signed urls for existing ones
+func UploadHandler(hub *chat.Hub, w http.ResponseWriter, r *http.Request) {
+	//Finds the currently logged in user
+	curr, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	//Checks whether it is a POST or GET request
+	switch r.Method {
+	case "GET":
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			http.Error(w, "400 bad request", http.StatusBadRequest)
+			return
+		}
+
+		a, err := db.FindAttachment(r.Context(), id)
+		if err != nil {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		//Only the chat participants can get a url for a private attachment
+		if !canAccessAttachment(curr.Id, a) {
+			http.Error(w, "403 forbidden", http.StatusForbidden)
+			return
+		}
+
+		a.Url = upload.SignedURL(a.Id)
+
+		resp, err := json.Marshal(a)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(resp)
+	case "POST":
+		//Rejects files over the size limit before reading them
+		if r.ContentLength > config.MaxUploadSize {
+			http.Error(w, "413 request entity too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		//Limits the size of the request body
+		r.Body = http.MaxBytesReader(w, r.Body, config.MaxUploadSize)
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			if strings.Contains(err.Error(), "request body too large") {
+				http.Error(w, "413 request entity too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		defer file.Close()
+
+		//Rejects uploads that would take the user over their storage quota
+		_, used, err := db.FindUserStorage(r.Context(), curr.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if used+header.Size > config.StorageQuota {
+			http.Error(w, "403 forbidden: storage quota exceeded", http.StatusForbidden)
+			return
+		}
+
+		//The type is sniffed from the content, the one sent by the client can't be trusted
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(file, head)
+		sniffed := http.DetectContentType(head[:n])
+
+		//Files sent as images have to really be one, as they are resized and shown inline
+		mime := header.Header.Get("Content-Type")
+		if upload.IsImage(mime) && sniffed != mime {
+			http.Error(w, "400 bad request: The file content does not match its type.", http.StatusBadRequest)
+			return
+		}
+
+		mime = attachmentType(sniffed)
+
+		_, err = file.Seek(0, io.SeekStart)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		//The receiver is optional, attachments sent in a chat are private
+		var receiver int
+		if rid := r.FormValue("receiver_id"); rid != "" {
+			receiver, err = strconv.Atoi(rid)
+			if err != nil {
+				http.Error(w, "400 bad request.", http.StatusBadRequest)
+				return
+			}
+		}
+
+		key, err := upload.Save(store, file, header.Size)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		a := structure.Attachment{
+			User_id:     curr.Id,
+			Receiver_id: receiver,
+			Name:        header.Filename,
+			Path:        key,
+			Mime:        mime,
+			Size:        header.Size,
+			Scan_status: upload.StatusPending,
+		}
+
+		a.Id, err = db.NewAttachment(r.Context(), a, config.StorageQuota)
+		if err != nil {
+			store.Delete(key)
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		//Other uploads of the user took the space since the check above
+		if a.Id == 0 {
+			store.Delete(key)
+			http.Error(w, "403 forbidden: storage quota exceeded", http.StatusForbidden)
+			return
+		}
+
+		//Scans the file in the background, the uploader is notified of the result
+		go scanAttachment(context.Background(), hub, a)
+
+		a.Url = upload.SignedURL(a.Id)
+
+		writeCreated(w, "/upload?id="+strconv.Itoa(a.Id), a)
+	case "DELETE":
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil {
+			http.Error(w, "400 bad request", http.StatusBadRequest)
+			return
+		}
+
+		a, err := db.FindAttachment(r.Context(), id)
+		if err != nil {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		//Only the uploader can delete an attachment
+		if a.User_id != curr.Id {
+			http.Error(w, "403 forbidden", http.StatusForbidden)
+			return
+		}
+
+		err = deleteAttachment(r.Context(), id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeNoContent(w)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// StorageHandler shows how much of their storage quota the logged in user has used
+func StorageHandler(w http.ResponseWriter, r *http.Request) {
+	uid := viewerId(r)
+	if uid == 0 {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	count, used, err := db.FindUserStorage(r.Context(), uid)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	usage := structure.StorageUsage{
+		Attachments: count,
+		Used:        used,
+		Quota:       config.StorageQuota,
+		Remaining:   config.StorageQuota - used,
+	}
+	if usage.Remaining < 0 {
+		usage.Remaining = 0
+	}
+
+	resp, err := json.Marshal(usage)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(resp)
+}
+
+// AvatarHandler sets the logged in user's avatar to one of their uploaded images,
+// or removes it (/me/avatar)
+func AvatarHandler(w http.ResponseWriter, r *http.Request) {
+	//Finds the currently logged in user
+	curr, err := currentUser(r)
+	if err != nil {
+		http.Error(w, "401 unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case "PUT":
+		var body struct {
+			Attachment_id int `json:"attachment_id"`
+		}
+
+		//Decodes the request body into the avatar struct
+		//Returns a bad request if there's an error
+		err = json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&body)
+		if err != nil {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		a, err := db.FindAttachment(r.Context(), body.Attachment_id)
+		if err != nil || a.User_id != curr.Id {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		//Avatars are shown to everyone, so chat attachments can't be used
+		if a.Receiver_id != 0 || !upload.IsImage(a.Mime) {
+			http.Error(w, "400 bad request: The avatar has to be a public jpeg, png or gif image.", http.StatusBadRequest)
+			return
+		}
+		if a.Scan_status == upload.StatusInfected || a.Scan_status == upload.StatusFailed {
+			http.Error(w, "409 conflict: attachment is "+a.Scan_status, http.StatusConflict)
+			return
+		}
+
+		err = db.SetAvatar(r.Context(), curr.Id, a.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, imageURLs(a))
+	case "DELETE":
+		found, err := db.RemoveUserAvatar(r.Context(), curr.Id)
+		if err != nil {
+			http.Error(w, "500 internal server error", http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+
+		writeNoContent(w)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Deletes an attachment and its stored files, freeing the space in the uploader's quota
+func deleteAttachment(ctx context.Context, id int) error {
+	keys, err := db.DeleteAttachment(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	//Files that fail to delete are picked up by the orphan cleanup
+	for _, key := range keys {
+		if err := store.Delete(key); err != nil {
+			log.Printf("Error deleting stored file %s: %v", key, err)
+		}
+	}
+
+	return nil
+}
+
+// AttachmentHandler serves the file behind a signed attachment url
+func AttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "400 bad request", http.StatusBadRequest)
+		return
+	}
+
+	//Rejects missing, forged and expired signatures
+	if !upload.Verify(id, r.URL.Query().Get("expires"), r.URL.Query().Get("sig")) {
+		http.Error(w, "403 forbidden", http.StatusForbidden)
+		return
+	}
+
+	a, err := db.FindAttachment(r.Context(), id)
+	if err != nil {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	//A leaked url is not enough to read a private attachment
+	if !canAccessAttachment(viewerId(r), a) {
+		http.Error(w, "403 forbidden", http.StatusForbidden)
+		return
+	}
+
+	//Only files that passed the malware scan are served
+	if a.Scan_status != upload.StatusClean {
+		http.Error(w, "409 conflict: attachment is "+a.Scan_status, http.StatusConflict)
+		return
+	}
+
+	//Serves a resized copy when a width is requested
+	key := a.Path
+	if width := r.URL.Query().Get("w"); width != "" {
+		key, err = variantPath(r.Context(), a.Id, width)
+		if err != nil {
+			http.Error(w, "404 not found.", http.StatusNotFound)
+			return
+		}
+	}
+
+	f, err := store.Get(key)
+	if err != nil {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	defer f.Close()
+
+	//Only images are shown inline, anything else could run script on the forum's origin
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if upload.IsImage(a.Mime) {
+		w.Header().Set("Content-Type", a.Mime)
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Name}))
+	}
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	//Local files support range requests, other backends are streamed as is
+	if rs, ok := f.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, a.Name, time.Time{}, rs)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, f)
+}
+
+// The type an attachment is stored with, the sniffed one when it is allowed
+func attachmentType(sniffed string) string {
+	for _, t := range config.AttachmentTypes {
+		if t == sniffed {
+			return t
+		}
+	}
+
+	return "application/octet-stream"
+}
+
+// Finds the storage key of the resized copy of an attachment with the given width
+func variantPath(ctx context.Context, id int, width string) (string, error) {
+	wd, err := strconv.Atoi(width)
+	if err != nil {
+		return "", err
+	}
+
+	variants, err := db.FindVariants(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	for _, v := range variants {
+		if v.Width == wd {
+			return v.Path, nil
+		}
+	}
+
+	return "", errors.New("no variant with that width")
+}
+
+// Checks whether a user is allowed to read an attachment
+func canAccessAttachment(uid int, a structure.Attachment) bool {
+	//Attachments without a receiver are public
+	if a.Receiver_id == 0 {
+		return true
+	}
+
+	return uid != 0 && (uid == a.User_id || uid == a.Receiver_id)
+}
+
+// Scans an attachment, quarantines it if flagged and notifies the uploader of the result
+func scanAttachment(ctx context.Context, hub *chat.Hub, a structure.Attachment) {
+	infected, signature, err := scanStored(a.Path)
+
+	switch {
+	case err != nil:
+		log.Printf("Error scanning attachment %d: %v", a.Id, err)
+		a.Scan_status = upload.StatusFailed
+	case infected:
+		log.Printf("Attachment %d flagged as %s", a.Id, signature)
+		a.Scan_status = upload.StatusInfected
+
+		a.Path, err = upload.Quarantine(store, a.Path, a.Size)
+		if err != nil {
+			log.Printf("Error quarantining attachment %d: %v", a.Id, err)
+		}
+	default:
+		a.Scan_status = upload.StatusClean
+	}
+
+	err = db.UpdateAttachmentScan(ctx, a.Id, a.Scan_status, a.Path)
+	if err != nil {
+		log.Printf("Error storing scan result of attachment %d: %v", a.Id, err)
+		return
+	}
+
+	//Clean images get resized copies for responsive loading
+	if a.Scan_status == upload.StatusClean && upload.IsImage(a.Mime) {
+		processImage(ctx, a)
+	}
+
+	notice, err := json.Marshal(structure.ScanNotice{
+		Msg_type:      "scan",
+		Attachment_id: a.Id,
+		Name:          a.Name,
+		Scan_status:   a.Scan_status,
+	})
+	if err != nil {
+		return
+	}
+
+	hub.SendTo(a.User_id, notice)
+}
+
+// Generates and stores the resized copies and placeholder colour of an image
+func processImage(ctx context.Context, a structure.Attachment) {
+	f, err := store.Get(a.Path)
+	if err != nil {
+		log.Printf("Error opening image %d: %v", a.Id, err)
+		return
+	}
+
+	defer f.Close()
+
+	width, height, variants, placeholder, err := upload.ProcessImage(f, config.ImageVariantWidths, config.MaxImagePixels)
+	if err != nil {
+		log.Printf("Error processing image %d: %v", a.Id, err)
+		return
+	}
+
+	a.Width, a.Height, a.Placeholder = width, height, placeholder
+
+	for _, v := range variants {
+		key := a.Path + "_w" + strconv.Itoa(v.Width)
+
+		err = store.Put(key, bytes.NewReader(v.Data), int64(len(v.Data)))
+		if err != nil {
+			log.Printf("Error storing variant of image %d: %v", a.Id, err)
+			return
+		}
+
+		a.Variants = append(a.Variants, structure.ImageVariant{Width: v.Width, Height: v.Height, Path: key})
+	}
+
+	err = db.UpdateAttachmentImage(ctx, a)
+	if err != nil {
+		log.Printf("Error storing variants of image %d: %v", a.Id, err)
+	}
+}
+
+// Fills in the urls of an image and its variants, including a srcset built from them
+func imageURLs(a structure.Attachment) structure.Attachment {
+	a.Url = upload.SignedURL(a.Id)
+
+	var srcset []string
+	for i, v := range a.Variants {
+		a.Variants[i].Url = upload.VariantURL(a.Id, v.Width)
+		srcset = append(srcset, a.Variants[i].Url+" "+strconv.Itoa(v.Width)+"w")
+	}
+
+	if len(srcset) > 0 && a.Width > 0 {
+		srcset = append(srcset, a.Url+" "+strconv.Itoa(a.Width)+"w")
+	}
+
+	a.Srcset = strings.Join(srcset, ", ")
+	return a
+}
+
+// Streams a stored file through the scanner
+func scanStored(key string) (bool, string, error) {
+	f, err := store.Get(key)
+	if err != nil {
+		return false, "", err
+	}
+
+	defer f.Close()
+
+	return scanner.Scan(f)
+}
+
+// Periodically removes stored files that no attachment references anymore
+func cleanupUploads(ctx context.Context) {
+	ticker := clock.NewTicker(config.CleanupInterval * time.Second)
+	defer ticker.Stop()
+
+	for ticker.Next(ctx) {
+		keys, err := db.FindAttachmentPaths(ctx)
+		if err != nil {
+			log.Printf("Error finding attachment paths: %v", err)
+			continue
+		}
+
+		removed, err := upload.CleanupOrphans(store, keys, config.CleanupGrace*time.Second)
+		if err != nil {
+			log.Printf("Error cleaning up uploads: %v", err)
+		}
+
+		if removed > 0 {
+			log.Printf("Removed %d orphaned uploads", removed)
+		}
+	}
+}
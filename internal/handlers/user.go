@@ -3,36 +3,22 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
-
-	"real-time-forum/internal/config"
-	"real-time-forum/internal/database"
 )
 
 func UserHandler(w http.ResponseWriter, r *http.Request) {
-	//Prevents the endpoint being called by other url paths
-	if r.URL.Path != "/user" {
-		http.Error(w, "404 not found.", http.StatusNotFound)
-		return
-	}
-
-	//Prevents all request types other than GET
-	if r.Method != "GET" {
-		http.Error(w, "405 method not allowed.", http.StatusMethodNotAllowed)
-		return
-	}
-
 	//Check whether an id is passed in the url (/user?id=)
 	//If no, get all users. If yes, get user with matching id
 	id := r.URL.Query().Get("id")
+	viewer := viewerId(r)
 	if id == "" {
-		users, err := database.FindAllUsers(config.Path)
+		users, err := db.FindAllUsers(r.Context())
 		if err != nil {
 			http.Error(w, "500 internal server error", http.StatusInternalServerError)
 			return
 		}
 
 		//Marshals the array of user structs to a json object
-		resp, err := json.Marshal(users)
+		resp, err := json.Marshal(userResponses(r.Context(), viewer, userDisplayNames(r.Context(), users)))
 		if err != nil {
 			http.Error(w, "500 internal server error", http.StatusInternalServerError)
 			return
@@ -42,14 +28,16 @@ func UserHandler(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write(resp)
 	} else {
-		user, err := database.FindUserByParam(config.Path, "id", id)
+		user, err := db.FindUserByParam(r.Context(), "id", id)
 		if err != nil {
 			http.Error(w, "500 internal server error", http.StatusInternalServerError)
 			return
 		}
 
+		user.Display_name, _ = db.FindDisplayName(r.Context(), user.Id)
+
 		//Marshals the user struct to a json object
-		resp, err := json.Marshal(user)
+		resp, err := json.Marshal(userResponse(r.Context(), viewer, user))
 		if err != nil {
 			http.Error(w, "500 internal server error", http.StatusInternalServerError)
 			return
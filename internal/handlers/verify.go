@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/ids"
+	"real-time-forum/internal/mail"
+	"real-time-forum/internal/structure"
+	"real-time-forum/internal/validation"
+)
+
+// VerifyHandler verifies the email of a new account from the emailed link (GET /verify?token=)
+// and sends a new link to an unverified account (POST /verify with its email). A new link is
+// sent the same way whether or not the email belongs to an unverified account.
+func VerifyHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		since := clock.Now().Add(-config.EmailVerificationAge * time.Second).Unix()
+		uid, err := db.VerifyEmail(r.Context(), r.URL.Query().Get("token"), since)
+		if err != nil {
+			http.Error(w, "404 not found: Link is invalid or has expired.", http.StatusNotFound)
+			return
+		}
+
+		audit(r.Context(), uid, uid, "email.verified", "")
+
+		//Back to the forum, which asks the user to sign in
+		http.Redirect(w, r, "/?verified=1", http.StatusSeeOther)
+	case "POST":
+		var req structure.VerificationRequest
+
+		//Decodes the request body into the verification request struct
+		//Returns a bad request if there's an error
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBody)).Decode(&req)
+		if err != nil || !validation.IsEmail(req.Email) {
+			http.Error(w, "400 bad request.", http.StatusBadRequest)
+			return
+		}
+
+		if ok, retry := resetLimiter.allow("ip:" + remoteIP(r)); !ok {
+			writeTooManyRequests(w, retry)
+			return
+		}
+
+		if ok, _ := resetLimiter.allow("verify:" + strings.ToLower(req.Email)); ok {
+			resendVerification(r.Context(), req.Email)
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		//Prevents the use of other request types
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+}
+
+// Sends a new verification link to the user with the email, if they are not verified yet
+func resendVerification(ctx context.Context, email string) {
+	exists, err := db.UserExists(ctx, email)
+	if err != nil || !exists {
+		return
+	}
+
+	user, err := db.FindUserByParam(ctx, "email", email)
+	if err != nil {
+		return
+	}
+
+	unverified, err := db.IsUnverified(ctx, user.Id)
+	if err != nil || !unverified {
+		return
+	}
+
+	token, err := ids.New()
+	if err != nil {
+		return
+	}
+
+	err = db.RenewVerification(ctx, user.Id, token, clock.Now().Unix())
+	if err != nil {
+		log.Printf("Error renewing email verification: %v", err)
+		return
+	}
+
+	sendVerification(user, token)
+}
+
+// Emails the link verifying the email of a new account
+func sendVerification(user structure.User, token string) {
+	link := config.SiteURL + "/verify?token=" + url.QueryEscape(token)
+	body := "Welcome " + user.Username + ".\n\n" +
+		"Verify your email at " + link + " to start using your account.\n\n" +
+		"The link works for " + (config.EmailVerificationAge * time.Second).String() + ". " +
+		"If you did not create this account, ignore this email."
+
+	err := mail.Send(user.Email, "Verify your email", body)
+	if err != nil {
+		log.Printf("Error sending verification email: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+// Package ids generates the random identifiers of sessions, tokens and stored files.
+// Tests can swap in a sequence to get the same identifiers on every run.
+package ids
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/gofrs/uuid"
+)
+
+// IDGenerator makes new unique identifiers
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+var (
+	mu      sync.RWMutex
+	current IDGenerator = UUID{}
+)
+
+// Set replaces the generator used by the server, it should be called before the server starts
+func Set(g IDGenerator) {
+	mu.Lock()
+	current = g
+	mu.Unlock()
+}
+
+// New returns a new identifier from the generator
+func New() (string, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return current.NewID()
+}
+
+// UUID generates random version 4 uuids
+type UUID struct{}
+
+func (UUID) NewID() (string, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+
+	return id.String(), nil
+}
+
+// Sequence generates the identifiers prefix-1, prefix-2 and so on
+type Sequence struct {
+	sync.Mutex
+	prefix string
+	n      int
+}
+
+// NewSequence returns a sequence starting at prefix-1
+func NewSequence(prefix string) *Sequence {
+	return &Sequence{prefix: prefix}
+}
+
+func (s *Sequence) NewID() (string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.n++
+	return s.prefix + "-" + strconv.Itoa(s.n), nil
+}
@@ -0,0 +1,90 @@
+package imgproxy
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"real-time-forum/internal/clock"
+)
+
+// Image is a fetched image and its content type
+type Image struct {
+	Data        []byte
+	ContentType string
+	fetched     time.Time
+}
+
+// Cache keeps recently fetched images in memory, evicting the least recently
+// used ones once the total size goes over the limit or they reach the max age
+type Cache struct {
+	mu       sync.Mutex
+	maxBytes int
+	maxAge   time.Duration
+	size     int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+type cacheEntry struct {
+	url   string
+	image Image
+}
+
+// NewCache creates a cache holding at most maxBytes of image data for maxAge
+func NewCache(maxBytes int, maxAge time.Duration) *Cache {
+	return &Cache{
+		maxBytes: maxBytes,
+		maxAge:   maxAge,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached image for a url if it has not expired
+func (c *Cache) Get(url string) (Image, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[url]
+	if !ok {
+		return Image{}, false
+	}
+
+	e := el.Value.(*cacheEntry)
+	if clock.Now().Sub(e.image.fetched) > c.maxAge {
+		c.remove(el)
+		return Image{}, false
+	}
+
+	c.order.MoveToFront(el)
+	return e.image, true
+}
+
+// Add stores an image, evicting older entries to stay under the size limit
+func (c *Cache) Add(url string, img Image) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(img.Data) > c.maxBytes {
+		return
+	}
+
+	if el, ok := c.entries[url]; ok {
+		c.remove(el)
+	}
+
+	img.fetched = clock.Now()
+	c.entries[url] = c.order.PushFront(&cacheEntry{url: url, image: img})
+	c.size += len(img.Data)
+
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	e := c.order.Remove(el).(*cacheEntry)
+	delete(c.entries, e.url)
+	c.size -= len(e.image.Data)
+}
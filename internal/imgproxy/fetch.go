@@ -0,0 +1,206 @@
+package imgproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrBadURL      = errors.New("only absolute http and https urls can be proxied")
+	ErrForbidden   = errors.New("address is not allowed")
+	ErrTooLarge    = errors.New("image is too large")
+	ErrNotAnImage  = errors.New("response is not an image")
+	ErrUnavailable = errors.New("image could not be fetched")
+)
+
+// Image types the proxy serves
+var rasterTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Fetcher downloads external images without letting requests reach internal addresses
+type Fetcher struct {
+	MaxBytes int64
+	client   *http.Client
+}
+
+// NewFetcher creates a fetcher that rejects images larger than maxBytes
+func NewFetcher(maxBytes int64, timeout time.Duration) *Fetcher {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		//Checks the address actually dialed, so DNS rebinding and redirects cannot bypass it
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublic(ip) {
+				return ErrForbidden
+			}
+
+			return nil
+		},
+	}
+
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	return &Fetcher{
+		MaxBytes: maxBytes,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 3 {
+					return errors.New("too many redirects")
+				}
+				return checkURL(req.URL)
+			},
+		},
+	}
+}
+
+// Fetch downloads the image at the url
+func (f *Fetcher) Fetch(ctx context.Context, raw string) (Image, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Image{}, ErrBadURL
+	}
+
+	err = checkURL(u)
+	if err != nil {
+		return Image{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return Image{}, ErrBadURL
+	}
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrForbidden) {
+			return Image{}, ErrForbidden
+		}
+		return Image{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Image{}, fmt.Errorf("%w: %s", ErrUnavailable, resp.Status)
+	}
+
+	//Refuses anything announced as another type before reading it, the type served is checked below
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "image/") {
+		return Image{}, ErrNotAnImage
+	}
+
+	if resp.ContentLength > f.MaxBytes {
+		return Image{}, ErrTooLarge
+	}
+
+	//Reads one byte past the limit to detect oversized bodies without a length
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, f.MaxBytes+1))
+	if err != nil {
+		return Image{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	if int64(len(data)) > f.MaxBytes {
+		return Image{}, ErrTooLarge
+	}
+
+	//The type is sniffed from the bytes, only raster formats are served. An svg can hold
+	//scripts that would run on the forum's origin.
+	contentType := http.DetectContentType(data)
+	if !rasterTypes[contentType] {
+		return Image{}, ErrNotAnImage
+	}
+
+	return Image{Data: data, ContentType: contentType}, nil
+}
+
+// Checks the scheme and host of a url before anything is dialed
+func checkURL(u *url.URL) error {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return ErrBadURL
+	}
+
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !isPublic(ip) {
+		return ErrForbidden
+	}
+
+	if strings.EqualFold(u.Hostname(), "localhost") {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// Private, loopback, link-local and other special purpose ranges
+var blockedNets = mustParseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// Checks whether an ip address is publicly routable
+func isPublic(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,23 @@
+package imgproxy
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+)
+
+// Matches the src attribute of img tags pointing to an absolute http(s) url
+var imgSrc = regexp.MustCompile(`(?i)(<img\b[^>]*?\bsrc\s*=\s*)(["'])(https?://[^"']+)(["'])`)
+
+// ProxyURL returns the proxy url serving an external image
+func ProxyURL(raw string) string {
+	return "/imgproxy?url=" + url.QueryEscape(raw)
+}
+
+// RewriteHTML points every external image in the html through the proxy
+func RewriteHTML(content string) string {
+	return imgSrc.ReplaceAllStringFunc(content, func(tag string) string {
+		m := imgSrc.FindStringSubmatch(tag)
+		return m[1] + m[2] + ProxyURL(html.UnescapeString(m[3])) + m[4]
+	})
+}
@@ -0,0 +1,52 @@
+// Package logger sets up the structured logger the server writes to, as text or as json
+// lines for log collectors. Once set up, what is written with the log package goes through
+// it too.
+package logger
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"real-time-forum/internal/config"
+)
+
+type contextKey struct{}
+
+// New creates a logger writing to w in the given format, "json" or "text"
+func New(w io.Writer, format string) *slog.Logger {
+	if strings.EqualFold(format, "json") {
+		return slog.New(slog.NewJSONHandler(w, nil))
+	}
+
+	return slog.New(slog.NewTextHandler(w, nil))
+}
+
+// Setup makes the logger in the format of the environment the default one, it should be
+// called before anything is logged
+func Setup() {
+	format := os.Getenv(config.LogFormatEnv)
+	if format != "" && !strings.EqualFold(format, "json") && !strings.EqualFold(format, "text") {
+		log.Printf("Ignoring invalid %s %q, it must be text or json", config.LogFormatEnv, format)
+	}
+
+	slog.SetDefault(New(os.Stderr, format))
+}
+
+// WithLogger returns a copy of the context carrying the logger
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger of the context, the default one when it has none. Loggers
+// of requests come with their request id.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return l
+	}
+
+	return slog.Default()
+}
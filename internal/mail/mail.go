@@ -0,0 +1,105 @@
+// Package mail sends emails to users. Emails go through the SMTP server named in the
+// environment, or only to the server log when there is none. Tests can swap in their own
+// Mailer.
+package mail
+
+import (
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/secrets"
+)
+
+// Mailer delivers an email to a single address
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+var (
+	mu      sync.RWMutex
+	current Mailer
+)
+
+// Set replaces the mailer used by the server, it should be called before the server starts
+func Set(m Mailer) {
+	mu.Lock()
+	current = m
+	mu.Unlock()
+}
+
+// Send delivers an email with the mailer of the server, chosen from the environment unless one was set
+func Send(to, subject, body string) error {
+	mu.Lock()
+	if current == nil {
+		current = FromEnv()
+	}
+	m := current
+	mu.Unlock()
+
+	return m.Send(to, subject, body)
+}
+
+// FromEnv returns an SMTP mailer when an SMTP server is configured, otherwise a Log mailer
+func FromEnv() Mailer {
+	addr := os.Getenv(config.SMTPAddrEnv)
+	if addr == "" {
+		return Log{}
+	}
+
+	from := os.Getenv(config.MailFromEnv)
+	if from == "" {
+		from = config.MailFrom
+	}
+
+	return SMTP{
+		Addr:     addr,
+		Username: os.Getenv(config.SMTPUserEnv),
+		Password: secrets.Getenv(config.SMTPPasswordEnv),
+		From:     from,
+	}
+}
+
+// Log writes emails to the server log instead of sending them, for development
+type Log struct{}
+
+func (Log) Send(to, subject, body string) error {
+	log.Printf("Email to %s: %s\n%s", to, subject, body)
+	return nil
+}
+
+// SMTP sends emails through an SMTP server, logging in when a username is given
+type SMTP struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+func (s SMTP) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	msg := "From: " + header(s.From) + "\r\n" +
+		"To: " + header(to) + "\r\n" +
+		"Subject: " + header(subject) + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + body
+
+	return smtp.SendMail(s.Addr, auth, s.From, []string{to}, []byte(msg))
+}
+
+// Keeps a value on its header line
+func header(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
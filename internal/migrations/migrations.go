@@ -0,0 +1,288 @@
+// Package migrations applies the versioned changes to the schema of the database. Each
+// migration is a pair of files in sql/, NNNN_name.up.sql making the change and
+// NNNN_name.down.sql undoing it, numbered in the order they apply. The versions applied are
+// kept in the schema_migrations table, so a database is brought up to date whatever version
+// it was left at. The tables that existed before migrations are still created by
+// database.CreateTables, changes to the schema from then on are migrations. The few changes
+// SQL can't make on its own are migrations written in Go, see Register.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"real-time-forum/internal/clock"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+const (
+	createTable   = `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied INTEGER NOT NULL)`
+	getApplied    = `SELECT version FROM schema_migrations ORDER BY version DESC`
+	addApplied    = `INSERT INTO schema_migrations (version, name, applied) VALUES (?, ?, ?)`
+	removeApplied = `DELETE FROM schema_migrations WHERE version = ?`
+)
+
+// Migration is a single change to the schema
+type Migration struct {
+	Version  int
+	Name     string
+	up       string
+	down     string
+	upFunc   Func
+	downFunc Func
+}
+
+// Func is a migration step written in Go. It runs in the transaction the migration is
+// recorded in.
+type Func func(ctx context.Context, tx *sql.Tx) error
+
+// The migrations written in Go, by version
+var funcs = make(map[int]Migration)
+
+// Register adds a migration written in Go, for changes like adding a column to the tables
+// that don't have it yet. Without a down step it can't be rolled back. It must be called
+// from an init function, before any migration is applied.
+func Register(version int, name string, up, down Func) {
+	funcs[version] = Migration{Version: version, Name: name, upFunc: up, downFunc: down}
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Reads the migrations from their files, in the order they apply
+func load() ([]Migration, error) {
+	entries, err := files.ReadDir("sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		name := e.Name()
+
+		//Names are NNNN_name.up.sql or NNNN_name.down.sql
+		base := strings.TrimSuffix(name, ".sql")
+		direction := path.Ext(base)
+		base = strings.TrimSuffix(base, direction)
+
+		i := strings.Index(base, "_")
+		if i < 0 || (direction != ".up" && direction != ".down") {
+			return nil, fmt.Errorf("migration %s isn't named NNNN_name.up.sql or NNNN_name.down.sql", name)
+		}
+
+		version, err := strconv.Atoi(base[:i])
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s has no version", name)
+		}
+
+		content, err := files.ReadFile("sql/" + name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: base[i+1:]}
+			byVersion[version] = m
+		}
+		if m.Name != base[i+1:] {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", m, name)
+		}
+
+		if direction == ".up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	for version, f := range funcs {
+		if m, ok := byVersion[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", m, f)
+		}
+
+		f := f
+		byVersion[version] = &f
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" && m.upFunc == nil {
+			return nil, fmt.Errorf("migration %s has no up file", m)
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Finds the versions applied to the database, latest first
+func applied(ctx context.Context, db *sql.DB) ([]int, error) {
+	_, err := db.ExecContext(ctx, createTable)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, getApplied)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var v int
+		err = rows.Scan(&v)
+		if err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, v)
+	}
+
+	return versions, rows.Err()
+}
+
+// Up applies the migrations the database doesn't have yet, in order, and returns them. Each
+// migration is applied in a transaction with its version, so a failed one leaves nothing
+// behind and is tried again next time.
+func Up(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	migrations, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := applied(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[int]bool, len(versions))
+	for _, v := range versions {
+		done[v] = true
+	}
+
+	var ran []Migration
+	for _, m := range migrations {
+		if done[m.Version] {
+			continue
+		}
+
+		err = run(ctx, db, m.upStep(), addApplied, m.Version, m.Name, clock.Now().Unix())
+		if err != nil {
+			return ran, fmt.Errorf("migration %s: %w", m, err)
+		}
+
+		ran = append(ran, m)
+	}
+
+	return ran, nil
+}
+
+// Down undoes the last steps migrations applied, latest first, and returns them. Migrations
+// without a down file can't be undone, nothing before them is.
+func Down(ctx context.Context, db *sql.DB, steps int) ([]Migration, error) {
+	migrations, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := applied(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	var undone []Migration
+	for i := 0; i < steps && i < len(versions); i++ {
+		m, ok := known[versions[i]]
+		if !ok {
+			return undone, fmt.Errorf("migration %04d is applied but isn't known to this version of the forum", versions[i])
+		}
+		if m.down == "" && m.downFunc == nil {
+			return undone, fmt.Errorf("migration %s can't be rolled back", m)
+		}
+
+		err = run(ctx, db, m.downStep(), removeApplied, m.Version)
+		if err != nil {
+			return undone, fmt.Errorf("rolling back migration %s: %w", m, err)
+		}
+
+		undone = append(undone, m)
+	}
+
+	return undone, nil
+}
+
+// Version returns the latest migration applied to the database, 0 when there is none
+func Version(ctx context.Context, db *sql.DB) (int, error) {
+	versions, err := applied(ctx, db)
+	if err != nil || len(versions) == 0 {
+		return 0, err
+	}
+
+	return versions[0], nil
+}
+
+// Returns the step applying the migration, its Go function or its up statements
+func (m Migration) upStep() Func {
+	if m.upFunc != nil {
+		return m.upFunc
+	}
+
+	return statements(m.up)
+}
+
+// Returns the step undoing the migration, its Go function or its down statements
+func (m Migration) downStep() Func {
+	if m.downFunc != nil {
+		return m.downFunc
+	}
+
+	return statements(m.down)
+}
+
+// Returns a step running the statements of a migration file
+func statements(s string) Func {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, s)
+		return err
+	}
+}
+
+// Runs a step of a migration and records it in a single transaction
+func run(ctx context.Context, db *sql.DB, step Func, record string, args ...interface{}) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = step(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, record, args...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
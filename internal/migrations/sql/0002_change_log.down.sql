@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS changes_posts_insert;
+DROP TRIGGER IF EXISTS changes_posts_update;
+DROP TRIGGER IF EXISTS changes_posts_delete;
+DROP TRIGGER IF EXISTS changes_messages_insert;
+DROP TRIGGER IF EXISTS changes_messages_update;
+DROP TRIGGER IF EXISTS changes_messages_delete;
+DROP TRIGGER IF EXISTS changes_notifications_insert;
+DROP TRIGGER IF EXISTS changes_notifications_update;
+DROP TRIGGER IF EXISTS changes_notifications_delete;
+DROP TABLE IF EXISTS changes;
@@ -0,0 +1,47 @@
+-- Log of the changes to posts, messages and notifications, read by /sync so clients that
+-- were offline only load what changed. Posts are seen by everyone and have user_id 0,
+-- messages are seen by their sender and receiver, notifications by their user.
+CREATE TABLE IF NOT EXISTS changes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind VARCHAR(16) NOT NULL,
+	item_id INTEGER NOT NULL,
+	action VARCHAR(16) NOT NULL,
+	user_id INTEGER NOT NULL,
+	with_id INTEGER NOT NULL DEFAULT 0,
+	date INTEGER NOT NULL
+);
+
+CREATE TRIGGER IF NOT EXISTS changes_posts_insert AFTER INSERT ON posts BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, date) VALUES ('post', new.id, 'created', 0, strftime('%s', 'now'));
+END;
+CREATE TRIGGER IF NOT EXISTS changes_posts_update AFTER UPDATE OF category, title, content, license ON posts BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, date) VALUES ('post', new.id, 'updated', 0, strftime('%s', 'now'));
+END;
+CREATE TRIGGER IF NOT EXISTS changes_posts_delete AFTER DELETE ON posts BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, date) VALUES ('post', old.id, 'deleted', 0, strftime('%s', 'now'));
+END;
+
+-- Only read receipts change a message, its content is rewritten when messages are
+-- encrypted again but stays the same to the users
+CREATE TRIGGER IF NOT EXISTS changes_messages_insert AFTER INSERT ON messages BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, with_id, date) VALUES ('message', new.id, 'created', new.sender_id, new.receiver_id, strftime('%s', 'now'));
+END;
+CREATE TRIGGER IF NOT EXISTS changes_messages_update AFTER UPDATE OF read_at ON messages BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, with_id, date) VALUES ('message', new.id, 'updated', new.sender_id, new.receiver_id, strftime('%s', 'now'));
+END;
+CREATE TRIGGER IF NOT EXISTS changes_messages_delete AFTER DELETE ON messages BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, with_id, date) VALUES ('message', old.id, 'deleted', old.sender_id, old.receiver_id, strftime('%s', 'now'));
+END;
+
+CREATE TRIGGER IF NOT EXISTS changes_notifications_insert AFTER INSERT ON notifications BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, date) VALUES ('notification', new.id, 'created', new.user_id, strftime('%s', 'now'));
+END;
+CREATE TRIGGER IF NOT EXISTS changes_notifications_update AFTER UPDATE OF seen ON notifications BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, date) VALUES ('notification', new.id, 'updated', new.user_id, strftime('%s', 'now'));
+END;
+CREATE TRIGGER IF NOT EXISTS changes_notifications_delete AFTER DELETE ON notifications BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, date) VALUES ('notification', old.id, 'deleted', old.user_id, strftime('%s', 'now'));
+END;
+
+CREATE INDEX IF NOT EXISTS idx_changes_user_id ON changes(user_id);
+CREATE INDEX IF NOT EXISTS idx_changes_with_id ON changes(with_id);
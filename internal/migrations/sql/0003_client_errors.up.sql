@@ -0,0 +1,13 @@
+-- Errors the frontend ran into, reported by the browsers of the users through /client-errors
+CREATE TABLE IF NOT EXISTS client_errors (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL DEFAULT 0,
+	message TEXT NOT NULL,
+	stack TEXT NOT NULL,
+	route TEXT NOT NULL,
+	app_version VARCHAR(64) NOT NULL,
+	request_id VARCHAR(64) NOT NULL,
+	status INTEGER NOT NULL,
+	user_agent TEXT NOT NULL,
+	created INTEGER NOT NULL
+);
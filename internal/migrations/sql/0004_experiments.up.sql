@@ -0,0 +1,18 @@
+-- Users who were shown a variant of an experiment, once per experiment, and the goals they
+-- reached afterwards, once per goal
+CREATE TABLE IF NOT EXISTS experiment_exposures (
+	experiment VARCHAR(64) NOT NULL,
+	variant VARCHAR(64) NOT NULL,
+	user_id INTEGER NOT NULL,
+	created INTEGER NOT NULL,
+	UNIQUE(experiment, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS experiment_conversions (
+	experiment VARCHAR(64) NOT NULL,
+	variant VARCHAR(64) NOT NULL,
+	user_id INTEGER NOT NULL,
+	goal VARCHAR(64) NOT NULL,
+	created INTEGER NOT NULL,
+	UNIQUE(experiment, user_id, goal)
+);
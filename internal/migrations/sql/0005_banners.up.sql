@@ -0,0 +1,21 @@
+-- Site banners posted by the admins, shown between starts and ends (0 when they don't end),
+-- and the users who dismissed each of them
+CREATE TABLE IF NOT EXISTS banners (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	text TEXT NOT NULL,
+	severity VARCHAR(16) NOT NULL,
+	audience VARCHAR(16) NOT NULL,
+	starts INTEGER NOT NULL,
+	ends INTEGER NOT NULL DEFAULT 0,
+	user_id INTEGER NOT NULL,
+	created INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_banners_ends ON banners(ends);
+
+CREATE TABLE IF NOT EXISTS banner_dismissals (
+	banner_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	created INTEGER NOT NULL,
+	UNIQUE(banner_id, user_id)
+);
@@ -0,0 +1,2 @@
+-- The links removed from the notifications aren't restored
+SELECT 1;
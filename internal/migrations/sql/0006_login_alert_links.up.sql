@@ -0,0 +1,3 @@
+-- New login notifications no longer carry the link that locks the account, it is only
+-- emailed. Removes it from the ones already stored, any session could read them.
+UPDATE notifications SET link = '' WHERE kind = 'login';
@@ -0,0 +1,2 @@
+-- The sessions removed aren't restored, impersonations no longer need them
+SELECT 1;
@@ -0,0 +1,4 @@
+-- Impersonation tokens used to double as sessions of the impersonated user, which let them
+-- be used without the impersonation cookie. Removes the sessions left from them.
+DELETE FROM session_bindings WHERE session_uuid IN (SELECT token FROM impersonations);
+DELETE FROM sessions WHERE session_uuid IN (SELECT token FROM impersonations);
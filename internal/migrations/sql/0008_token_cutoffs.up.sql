@@ -0,0 +1,7 @@
+-- Access tokens of a user issued before the cutoff are revoked, after a password reset or
+-- a "this wasn't me" lock. Rows are kept until the last of those tokens has expired.
+CREATE TABLE IF NOT EXISTS token_cutoffs (
+	user_id INTEGER PRIMARY KEY,
+	cutoff INTEGER NOT NULL,
+	expires INTEGER NOT NULL
+);
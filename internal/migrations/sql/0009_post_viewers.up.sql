@@ -0,0 +1,9 @@
+-- The users who viewed each post. A view is counted the first time a user opens the post,
+-- opening it again or from another tab does not add to it.
+CREATE TABLE IF NOT EXISTS post_viewers (
+	post_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	UNIQUE(post_id, user_id),
+	FOREIGN KEY(post_id) REFERENCES posts(id),
+	FOREIGN KEY(user_id) REFERENCES users(id)
+);
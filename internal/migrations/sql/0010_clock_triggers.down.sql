@@ -0,0 +1,37 @@
+DROP TRIGGER IF EXISTS changes_posts_insert;
+DROP TRIGGER IF EXISTS changes_posts_update;
+DROP TRIGGER IF EXISTS changes_posts_delete;
+DROP TRIGGER IF EXISTS changes_messages_insert;
+DROP TRIGGER IF EXISTS changes_messages_update;
+DROP TRIGGER IF EXISTS changes_messages_delete;
+DROP TRIGGER IF EXISTS changes_notifications_insert;
+DROP TRIGGER IF EXISTS changes_notifications_update;
+DROP TRIGGER IF EXISTS changes_notifications_delete;
+
+CREATE TRIGGER changes_posts_insert AFTER INSERT ON posts BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, date) VALUES ('post', new.id, 'created', 0, strftime('%s', 'now'));
+END;
+CREATE TRIGGER changes_posts_update AFTER UPDATE OF category, title, content, license ON posts BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, date) VALUES ('post', new.id, 'updated', 0, strftime('%s', 'now'));
+END;
+CREATE TRIGGER changes_posts_delete AFTER DELETE ON posts BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, date) VALUES ('post', old.id, 'deleted', 0, strftime('%s', 'now'));
+END;
+CREATE TRIGGER changes_messages_insert AFTER INSERT ON messages BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, with_id, date) VALUES ('message', new.id, 'created', new.sender_id, new.receiver_id, strftime('%s', 'now'));
+END;
+CREATE TRIGGER changes_messages_update AFTER UPDATE OF read_at ON messages BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, with_id, date) VALUES ('message', new.id, 'updated', new.sender_id, new.receiver_id, strftime('%s', 'now'));
+END;
+CREATE TRIGGER changes_messages_delete AFTER DELETE ON messages BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, with_id, date) VALUES ('message', old.id, 'deleted', old.sender_id, old.receiver_id, strftime('%s', 'now'));
+END;
+CREATE TRIGGER changes_notifications_insert AFTER INSERT ON notifications BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, date) VALUES ('notification', new.id, 'created', new.user_id, strftime('%s', 'now'));
+END;
+CREATE TRIGGER changes_notifications_update AFTER UPDATE OF seen ON notifications BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, date) VALUES ('notification', new.id, 'updated', new.user_id, strftime('%s', 'now'));
+END;
+CREATE TRIGGER changes_notifications_delete AFTER DELETE ON notifications BEGIN
+	INSERT INTO changes(kind, item_id, action, user_id, date) VALUES ('notification', old.id, 'deleted', old.user_id, strftime('%s', 'now'));
+END;
@@ -0,0 +1,2 @@
+-- The reactions stay in the reactions table, the versions of the forum before migrations
+-- read them there too
@@ -0,0 +1,6 @@
+-- Older versions kept likes and dislikes in liked_posts and disliked_posts, they are moved
+-- into the reactions table
+INSERT OR IGNORE INTO reactions(post_id, user_id, kind, date) SELECT post_id, user_id, 'like', '' FROM liked_posts;
+INSERT OR IGNORE INTO reactions(post_id, user_id, kind, date) SELECT post_id, user_id, 'dislike', '' FROM disliked_posts;
+DELETE FROM liked_posts;
+DELETE FROM disliked_posts;
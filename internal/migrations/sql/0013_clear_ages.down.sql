@@ -0,0 +1 @@
+-- The ages cleared are gone, nothing to undo
@@ -0,0 +1,3 @@
+-- Older versions stored ages in place of dates of birth. There is no date to turn them
+-- into, so they are cleared. Dates of birth are stored as ISO 8601 dates.
+UPDATE users SET dob = '' WHERE dob NOT GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]';
@@ -0,0 +1,8 @@
+-- The subscribers each outbox event has been delivered to, so a retry only runs the ones
+-- that failed instead of pushing the event again to those that already had it
+CREATE TABLE IF NOT EXISTS outbox_deliveries (
+	event_id INTEGER NOT NULL,
+	subscriber VARCHAR(64) NOT NULL,
+	UNIQUE(event_id, subscriber),
+	FOREIGN KEY(event_id) REFERENCES outbox(id)
+);
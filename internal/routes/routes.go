@@ -0,0 +1,269 @@
+// Package routes is the router of the server. Routes are registered with the methods they
+// answer and a pattern whose segments can capture path parameters, like /posts/{id}, or the
+// rest of the path, like /frontend/{file...}. Middleware wraps every request or single routes.
+package routes
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Middleware wraps a handler with behaviour shared by several routes
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps a handler with middleware, the first one given running first
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+
+	return h
+}
+
+// Router dispatches requests to the route matching their path and method. Paths without a
+// route get a 404, methods a route doesn't answer a 405 listing the allowed ones.
+type Router struct {
+	routes     []*route
+	middleware []Middleware
+}
+
+type route struct {
+	pattern  string
+	segments []string
+	methods  []string
+	handlers map[string]http.Handler
+}
+
+// New returns a router without routes
+func New() *Router {
+	return &Router{}
+}
+
+// Use adds middleware run for every request, in the order given, before the router
+// looks for the route. Requests to paths without a route go through it too.
+func (rt *Router) Use(mws ...Middleware) {
+	rt.middleware = append(rt.middleware, mws...)
+}
+
+// Handle registers a handler for the methods on the pattern. A pattern can be registered
+// again with other methods and handlers. HEAD requests are served by the GET handler
+// without a body and OPTIONS requests are answered with the Allow header.
+func (rt *Router) Handle(pattern string, h http.Handler, methods ...string) {
+	var ro *route
+	for _, existing := range rt.routes {
+		if existing.pattern == pattern {
+			ro = existing
+		}
+	}
+
+	if ro == nil {
+		ro = &route{pattern: pattern, segments: split(pattern), handlers: make(map[string]http.Handler)}
+		rt.routes = append(rt.routes, ro)
+	}
+
+	for _, m := range methods {
+		if !ro.answers(m) {
+			ro.methods = append(ro.methods, m)
+		}
+		ro.handlers[m] = h
+	}
+}
+
+// HandleFunc registers a handler function for the methods on the pattern, see Handle
+func (rt *Router) HandleFunc(pattern string, h http.HandlerFunc, methods ...string) {
+	rt.Handle(pattern, h, methods...)
+}
+
+// Match finds the pattern of the route a request goes to, false when there is none
+func (rt *Router) Match(r *http.Request) (string, bool) {
+	ro, _ := rt.find(r.URL.Path)
+	if ro == nil {
+		return "", false
+	}
+
+	return ro.pattern, true
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	Chain(http.HandlerFunc(rt.dispatch), rt.middleware...).ServeHTTP(w, r)
+}
+
+// Serves a request with the handler of its route
+func (rt *Router) dispatch(w http.ResponseWriter, r *http.Request) {
+	ro, params := rt.find(r.URL.Path)
+	if ro == nil {
+		http.Error(w, "404 not found.", http.StatusNotFound)
+		return
+	}
+
+	if len(params) > 0 {
+		r = r.WithContext(context.WithValue(r.Context(), paramsKey{}, params))
+	}
+
+	allowed := ro.allowed()
+	switch {
+	case r.Method == "OPTIONS":
+		w.Header().Set("Allow", allowed)
+		w.Header().Set("Access-Control-Allow-Methods", allowed)
+		if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+			w.Header().Set("Access-Control-Allow-Headers", reqHeaders)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "HEAD" && ro.answers("GET"):
+		r.Method = "GET"
+		ro.handlers["GET"].ServeHTTP(headWriter{w}, r)
+	case ro.answers(r.Method):
+		ro.handlers[r.Method].ServeHTTP(w, r)
+	default:
+		w.Header().Set("Allow", allowed)
+		http.Error(w, "405 method not allowed.", http.StatusMethodNotAllowed)
+	}
+}
+
+// Finds the route matching a path with the parameters it captures. Static segments take
+// precedence over parameters, and parameters over the rest of the path, so /me/trash wins
+// over /me/{page} whatever order they were registered in.
+func (rt *Router) find(path string) (*route, map[string]string) {
+	segments := split(path)
+
+	var best *route
+	var bestParams map[string]string
+	var bestRank []int
+	for _, ro := range rt.routes {
+		params, rank, ok := ro.match(segments)
+		if ok && (best == nil || before(rank, bestRank)) {
+			best, bestParams, bestRank = ro, params, rank
+		}
+	}
+
+	return best, bestParams
+}
+
+// Matches the segments of a path, ranking each segment 0 for static, 1 for a parameter
+// and 2 for the rest of the path
+func (ro *route) match(segments []string) (map[string]string, []int, bool) {
+	params := map[string]string{}
+	rank := make([]int, 0, len(ro.segments))
+
+	for i, s := range ro.segments {
+		if name, ok := param(s); ok && strings.HasSuffix(name, "...") {
+			params[strings.TrimSuffix(name, "...")] = strings.Join(segments[min(i, len(segments)):], "/")
+			return params, append(rank, 2), true
+		}
+
+		if i >= len(segments) {
+			return nil, nil, false
+		}
+
+		if name, ok := param(s); ok {
+			if segments[i] == "" {
+				return nil, nil, false
+			}
+			params[name] = segments[i]
+			rank = append(rank, 1)
+			continue
+		}
+
+		if s != segments[i] {
+			return nil, nil, false
+		}
+		rank = append(rank, 0)
+	}
+
+	return params, rank, len(ro.segments) == len(segments)
+}
+
+// Whether a route answers a method
+func (ro *route) answers(method string) bool {
+	for _, m := range ro.methods {
+		if m == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The methods of a route as listed in the Allow header
+func (ro *route) allowed() string {
+	methods := append([]string{}, ro.methods...)
+	if ro.answers("GET") {
+		methods = append(methods, "HEAD")
+	}
+
+	return strings.Join(append(methods, "OPTIONS"), ", ")
+}
+
+// Param returns a parameter captured from the path of the request, "" when the pattern
+// of its route has none of that name
+func Param(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params[name]
+}
+
+type paramsKey struct{}
+
+// Splits a path into its segments, / having the single empty segment
+func split(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "/"), "/")
+}
+
+// Reads the name of a {parameter} segment
+func param(segment string) (string, bool) {
+	if len(segment) > 2 && segment[0] == '{' && segment[len(segment)-1] == '}' {
+		return segment[1 : len(segment)-1], true
+	}
+
+	return "", false
+}
+
+// Whether a ranking is more specific than another, comparing segment by segment
+func before(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+
+	return len(a) > len(b)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// Drops the body of a response so GET handlers can answer HEAD requests
+type headWriter struct {
+	http.ResponseWriter
+}
+
+func (w headWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// CORS lets pages of the given origins call the routes with the credentials of the user.
+// Requests from other origins are left without the headers, so browsers keep them to
+// the same origin.
+func CORS(origins []string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Origin")
+
+			origin := r.Header.Get("Origin")
+			for _, o := range origins {
+				if o == origin {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+					break
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
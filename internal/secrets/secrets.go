@@ -0,0 +1,118 @@
+// Package secrets decrypts secrets kept encrypted in the environment, so they
+// are not stored in plaintext next to the binary.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"real-time-forum/internal/config"
+)
+
+// Prefix marking an encrypted value
+const Prefix = "enc:"
+
+// Key reads the key secrets are encrypted with, from the environment or the file it names
+func Key() ([]byte, error) {
+	material := os.Getenv(config.SecretKeyEnv)
+	if material == "" {
+		if file := os.Getenv(config.SecretKeyFileEnv); file != "" {
+			b, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			material = strings.TrimSpace(string(b))
+		}
+	}
+
+	if material == "" {
+		return nil, errors.New("no secret key configured, set " + config.SecretKeyEnv + " or " + config.SecretKeyFileEnv)
+	}
+
+	//Any length of key material is stretched to an AES-256 key
+	key := sha256.Sum256([]byte(material))
+	return key[:], nil
+}
+
+// Encrypt seals a value with AES-GCM and returns it with the encrypted prefix
+func Encrypt(key []byte, plain string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return Prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value sealed by Encrypt, values without the prefix are returned as they are
+func Decrypt(key []byte, value string) (string, error) {
+	if !strings.HasPrefix(value, Prefix) {
+		return value, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, Prefix))
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted value is too short")
+	}
+
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("cannot decrypt value, wrong key?")
+	}
+
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// Getenv reads an environment variable, decrypting it if it is encrypted.
+// Secrets are read at startup, so a value that cannot be decrypted stops the server.
+func Getenv(name string) string {
+	value := os.Getenv(name)
+	if !strings.HasPrefix(value, Prefix) {
+		return value
+	}
+
+	key, err := Key()
+	if err != nil {
+		log.Fatalf("Cannot decrypt %s: %v", name, err)
+	}
+
+	plain, err := Decrypt(key, value)
+	if err != nil {
+		log.Fatalf("Cannot decrypt %s: %v", name, err)
+	}
+
+	return plain
+}
@@ -1,50 +1,279 @@
 package structure
 
+import "encoding/json"
+
 type Post struct {
-	Id       int    `json:"id"`
-	User_id  int    `json:"user_id"`
-	Category string `json:"category"`
-	Title    string `json:"title"`
-	Content  string `json:"content"`
-	Date     string `json:"date"`
-	Likes    int    `json:"likes"`
-	Dislikes int    `json:"dislikes"`
+	Id             int          `json:"id"`
+	User_id        int          `json:"user_id"`
+	Category       string       `json:"category"`
+	Title          string       `json:"title"`
+	Content        string       `json:"content"`
+	Date           string       `json:"date"`
+	Edited         string       `json:"edited,omitempty"` // Date of the last edit, empty when never edited
+	Likes          int          `json:"likes"`
+	Dislikes       int          `json:"dislikes"`
+	Comment_count  int          `json:"comments"`
+	View_count     int          `json:"views"`
+	Display_name   string       `json:"display_name"`
+	Attachment_ids []int        `json:"attachment_ids,omitempty"`
+	Images         []Attachment `json:"images,omitempty"`
+	Reaction       string       `json:"reaction"`
+	License        string       `json:"license"`
+	Event          *PostEvent   `json:"event,omitempty"`
+	Series         *SeriesLink  `json:"series,omitempty"`
+	// Other categories the post shows up in. Comments and reactions stay on the post itself.
+	Crossposts []string `json:"crossposts,omitempty"`
 }
 
-type Comment struct {
-	Id      int    `json:"id"`
+// An ordered series of posts by the same author, such as the chapters of a story
+type Series struct {
+	Id           int    `json:"id"`
+	User_id      int    `json:"user_id"`
+	Display_name string `json:"display_name"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	Date         string `json:"date"`
+	Followers    int    `json:"followers"`
+	// Whether the user viewing a single series follows it, false in lists
+	Following bool `json:"following"`
+	// Parts of the series in order, only filled in on its landing page
+	Posts []Post `json:"posts,omitempty"`
+}
+
+// Where a post stands in its series, with the parts before and after it
+type SeriesLink struct {
+	Series_id int         `json:"series_id"`
+	Title     string      `json:"title"`
+	Part      int         `json:"part"`
+	Parts     int         `json:"parts"`
+	Previous  *SeriesPart `json:"previous"`
+	Next      *SeriesPart `json:"next"`
+}
+
+// The short link sharing a post, /s/{token}
+type PostShare struct {
 	Post_id int    `json:"post_id"`
-	User_id int    `json:"user_id"`
-	Content string `json:"content"`
-	Date    string `json:"date"`
+	Token   string `json:"token"`
+	Url     string `json:"url"`
+}
+
+// How often a post was viewed and its share link followed. Clicks are only counted per day
+// and referring site, nothing is kept about who followed the link.
+type PostStats struct {
+	Post_id   int           `json:"post_id"`
+	Views     int           `json:"views"`
+	Clicks    int           `json:"clicks"`
+	Referrers []ShareClicks `json:"referrers"`
+	Days      []ShareClicks `json:"days"`
+}
+
+// The clicks on a share link from one referring site, "direct" for none, or on one day
+type ShareClicks struct {
+	Referrer string `json:"referrer,omitempty"`
+	Day      string `json:"day,omitempty"`
+	Clicks   int    `json:"clicks"`
+}
+
+// What an author's posts got, and how their followers grew, over the last days. Only
+// activity on the posts they still have is counted.
+type AuthorAnalytics struct {
+	Since string          `json:"since"`
+	Posts []PostAnalytics `json:"posts"`
+	// Users following at least one of the author's series
+	Followers     int           `json:"followers"`
+	Follower_days []FollowerDay `json:"follower_days"`
+	Generated_at  string        `json:"generated_at"`
+}
+
+// The totals of a post with its activity per day since the start of the analytics,
+// only listing the days something happened
+type PostAnalytics struct {
+	Post_id   int            `json:"post_id"`
+	Title     string         `json:"title"`
+	Date      string         `json:"date"`
+	Views     int            `json:"views"`
+	Reactions int            `json:"reactions"`
+	Comments  int            `json:"comments"`
+	Days      []AnalyticsDay `json:"days"`
+}
+
+// The views, reactions and comments a post got on a day
+type AnalyticsDay struct {
+	Day       string `json:"day"`
+	Views     int    `json:"views"`
+	Reactions int    `json:"reactions"`
+	Comments  int    `json:"comments"`
+}
+
+// The followers an author gained on a day and how many they had at its end
+type FollowerDay struct {
+	Day   string `json:"day"`
+	New   int    `json:"new"`
+	Total int    `json:"total"`
+}
+
+// A post of a series, as linked from the other parts
+type SeriesPart struct {
+	Post_id int    `json:"post_id"`
+	Title   string `json:"title"`
+}
+
+// When and where the event of an event post takes place, with how many answered each way
+type PostEvent struct {
+	Post_id  int    `json:"post_id"`
+	Starts   int64  `json:"starts"`
+	Ends     int64  `json:"ends"`
+	Location string `json:"location"`
+	// Who can see the guest list, public, attendees or private
+	Attendees string `json:"attendees"`
+	Going     int    `json:"going"`
+	Maybe     int    `json:"maybe"`
+	Not_going int    `json:"not_going"`
+	// Answer of the user viewing the event, empty when they haven't answered
+	Rsvp string `json:"rsvp"`
+}
+
+// A user's answer to an event
+type Rsvp struct {
+	User_id      int    `json:"user_id"`
+	Display_name string `json:"display_name"`
+	Status       string `json:"status"`
+	Date         string `json:"date"`
+}
+
+// An event starting soon whose guests haven't been reminded yet
+type DueEvent struct {
+	Post_id int
+	Title   string
+	Starts  int64
+}
+
+// Licenses posts can choose from and the one new posts get when they don't choose
+type Licenses struct {
+	Default  string   `json:"default"`
+	Licenses []string `json:"licenses,omitempty"`
+}
+
+// Activity of the community since the last weekly recap, which the recap templates are
+// filled in with
+type Recap struct {
+	Week         string
+	Top_posts    []Post
+	Categories   []CategoryActivity
+	New_members  []string
+	More_members int
+
+	// Members who joined, and the newest post and user the recap covers
+	Member_ids   []int
+	Last_post_id int
+	Last_user_id int
+}
+
+// A category and how many posts were made in it
+type CategoryActivity struct {
+	Name  string
+	Posts int
+}
+
+// Consecutive days a user has been active, counted in their own time zone
+type Streak struct {
+	Current  int    `json:"current"`
+	Best     int    `json:"best"`
+	Last_day string `json:"last_day,omitempty"`
+	Timezone string `json:"timezone"`
+}
+
+// Where a user left off in the main feed and how many posts were made since
+type FeedPosition struct {
+	Last_seen_post_id int `json:"last_seen_post_id"`
+	New_posts         int `json:"new_posts"`
+}
+
+// A user the author of a post allowed to edit it
+type Coauthor struct {
+	User_id      int    `json:"user_id"`
+	Display_name string `json:"display_name"`
+}
+
+// Allows a single author of a post to edit it until it expires
+type PostLock struct {
+	Post_id      int    `json:"post_id"`
+	User_id      int    `json:"user_id"`
+	Display_name string `json:"display_name"`
+	Expires      int64  `json:"expires"`
+}
+
+// A version of a post and who wrote it, the first one being the post as it was created
+type PostRevision struct {
+	Id           int    `json:"id"`
+	Post_id      int    `json:"post_id"`
+	Editor_id    int    `json:"editor_id"`
+	Display_name string `json:"display_name"`
+	Title        string `json:"title"`
+	Content      string `json:"content"`
+	Date         string `json:"date"`
+}
+
+type Comment struct {
+	Id                int    `json:"id"`
+	Post_id           int    `json:"post_id"`
+	User_id           int    `json:"user_id"`
+	Content           string `json:"content"`
+	Date              string `json:"date"`
+	Parent_comment_id int    `json:"parent_comment_id"`
+	Display_name      string `json:"display_name"`
+
+	// Set in threads, the replies are left out below the depth asked for
+	Reply_count int       `json:"reply_count,omitempty"`
+	Replies     []Comment `json:"replies,omitempty"`
 }
 
 type User struct {
-	Id        int    `json:"id"`
-	Username  string `json:"username"`
-	Firstname string `json:"firstname"`
-	Surname   string `json:"surname"`
-	Gender    string `json:"gender"`
-	Email     string `json:"email"`
-	DOB       string `json:"dob"`
-	Password  string `json:"password"`
+	Id           int      `json:"id"`
+	Username     string   `json:"username"`
+	Firstname    string   `json:"firstname"`
+	Surname      string   `json:"surname"`
+	Gender       string   `json:"gender"`
+	Email        string   `json:"email"`
+	DOB          string   `json:"dob"`
+	Password     string   `json:"-"`
+	Privacy      *Privacy `json:"privacy,omitempty"`
+	Display_name string   `json:"display_name"`
+	Verification string   `json:"-"` // Token the new user verifies their email with, verified straight away when empty
+}
+
+// Visibility settings of a user's optional profile fields
+type Privacy struct {
+	User_id      int    `json:"user_id"`
+	DOB          string `json:"dob"`
+	Gender       string `json:"gender"`
+	Hide_viewing bool   `json:"hide_viewing"`
+	Presence     string `json:"presence"`
+	Discoverable bool   `json:"discoverable"` // Whether people who know the user's email can find them
 }
 
 type Message struct {
-	Id          int    `json:"id"`
-	Sender_id   int    `json:"sender_id"`
-	Receiver_id int    `json:"receiver_id"`
-	Content     string `json:"content"`
-	Date        string `json:"date"`
-	Msg_type    string `json:"msg_type"`
-	UserID      int    `json:"user_id"`
-	IsTyping    bool   `json:"is_typing"`
-	ImageData   string `json:"image_data"`
+	Id           int    `json:"id"`
+	Sender_id    int    `json:"sender_id"`
+	Receiver_id  int    `json:"receiver_id"`
+	Content      string `json:"content"`
+	Date         string `json:"date"`
+	Msg_type     string `json:"msg_type"`
+	UserID       int    `json:"user_id"`
+	IsTyping     bool   `json:"is_typing"`
+	Expires      int64  `json:"expires,omitempty"`
+	Post_id      int    `json:"post_id,omitempty"`
+	ImageData    string `json:"image_data"`
+	Display_name string `json:"display_name"`
+	Seq          int    `json:"seq,omitempty"`
+	Temp_id      string `json:"temp_id,omitempty"`
+	Read_at      string `json:"read_at,omitempty"`
 }
 
 type Login struct {
 	Data     string `json:"emailUsername"`
 	Password string `json:"password"`
+	Token    bool   `json:"token"`
 }
 
 type Chat struct {
@@ -54,17 +283,410 @@ type Chat struct {
 }
 
 type OnlineUsers struct {
-	UserIds  []int  `json:"user_ids"`
-	Msg_type string `json:"msg_type"`
+	UserIds       []int          `json:"user_ids"`
+	Msg_type      string         `json:"msg_type"`
+	Display_names map[int]string `json:"display_names,omitempty"`
 }
 
 type Resp struct {
 	Msg string `json:"msg"`
 }
 
+// A device a user is logged in on. The session token itself is never sent back.
 type Session struct {
-	Session_uuid string
-	User_id      int
+	Id           int    `json:"id"`
+	Session_uuid string `json:"-"`
+	User_id      int    `json:"-"`
+	Created_at   int64  `json:"created_at"`
+	Last_seen    int64  `json:"last_seen"`
+	Expires_at   int64  `json:"expires_at"`
+	User_agent   string `json:"user_agent"`
+	Ip           string `json:"ip"`
+	Current      bool   `json:"current"`
+}
+
+// Uploaded file, private when sent to a receiver in a chat
+type Attachment struct {
+	Id          int    `json:"id"`
+	User_id     int    `json:"user_id"`
+	Receiver_id int    `json:"receiver_id"`
+	Name        string `json:"name"`
+	Path        string `json:"-"`
+	Mime        string `json:"mime"`
+	Size        int64  `json:"size"`
+	Date        string `json:"date"`
+	Url         string `json:"url"`
+	Scan_status string `json:"scan_status"`
+	Post_id     int    `json:"post_id"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Placeholder string `json:"placeholder"`
+	// Resized copies of an image, smallest first
+	Variants []ImageVariant `json:"variants,omitempty"`
+	// Variants formatted for the srcset attribute of an img tag
+	Srcset string `json:"srcset,omitempty"`
+}
+
+// Storage used by a user's attachments
+type StorageUsage struct {
+	Attachments int   `json:"attachments"`
+	Used        int64 `json:"used"`
+	Quota       int64 `json:"quota"`
+	Remaining   int64 `json:"remaining"`
+}
+
+// Resized copy of an uploaded image
+type ImageVariant struct {
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Path   string `json:"-"`
+	Url    string `json:"url"`
+}
+
+// Sent to the uploader when the scan of an attachment finishes
+type ScanNotice struct {
+	Msg_type      string `json:"msg_type"`
+	Attachment_id int    `json:"attachment_id"`
+	Name          string `json:"name"`
+	Scan_status   string `json:"scan_status"`
+}
+
+// Pushed to feed readers when a post is created
+type NewPostEvent struct {
+	Msg_type string `json:"msg_type"`
+	Id       int    `json:"id"`
+	User_id  int    `json:"user_id"`
+	Author   string `json:"author"`
+	Title    string `json:"title"`
+	Category string `json:"category"`
+}
+
+// Pushed to the viewers of a post when it is commented on
+type CommentEvent struct {
+	Msg_type string  `json:"msg_type"`
+	Post_id  int     `json:"post_id"`
+	Comment  Comment `json:"comment"`
+}
+
+// Pushed to the viewers of a post when its likes or dislikes change
+type ReactionEvent struct {
+	Msg_type string `json:"msg_type"`
+	Post_id  int    `json:"post_id"`
+	Likes    int    `json:"likes"`
+	Dislikes int    `json:"dislikes"`
+}
+
+// Reaction counts of a post and the reaction of the user who asked, "like", "dislike" or
+// empty when they have not reacted
+type PostReaction struct {
+	Post_id  int    `json:"post_id"`
+	Likes    int    `json:"likes"`
+	Dislikes int    `json:"dislikes"`
+	Reaction string `json:"reaction"`
+}
+
+// Pushed to the viewers of a post with how many other people are reading it
+type ViewerCount struct {
+	Msg_type string `json:"msg_type"`
+	Post_id  int    `json:"post_id"`
+	Count    int    `json:"count"`
+}
+
+// Pushed to both users of a conversation when a message is deleted or restored
+type MessageEvent struct {
+	Msg_type    string `json:"msg_type"`
+	Id          int    `json:"id"`
+	Sender_id   int    `json:"sender_id"`
+	Receiver_id int    `json:"receiver_id"`
+}
+
+// Current counters of a post
+type PostCounts struct {
+	Post_id  int `json:"post_id"`
+	Comments int `json:"comments"`
+	Likes    int `json:"likes"`
+	Dislikes int `json:"dislikes"`
+}
+
+// Pushed to feed readers with the counters that changed since the last batch
+type FeedCounts struct {
+	Msg_type string       `json:"msg_type"`
+	Posts    []PostCounts `json:"posts"`
+}
+
+// Current time of the server so clients can correct for their own clock skew
+type ServerTime struct {
+	Msg_type string `json:"msg_type,omitempty"`
+	Time     int64  `json:"time"`
+	Date     string `json:"date"`
+}
+
+// Sent over the websocket so the client can reconnect without the session cookie
+type ReconnectToken struct {
+	Msg_type string `json:"msg_type"`
+	Token    string `json:"token"`
+	Expires  int64  `json:"expires"`
+}
+
+// Tells the sender a message was stored, mapping the id it chose to the one of the server
+type MessageAck struct {
+	Msg_type string `json:"msg_type"`
+	Temp_id  string `json:"temp_id"`
+	Id       int    `json:"id"`
+	Seq      int    `json:"seq"`
+	Date     string `json:"date"`
+}
+
+// Tells the sender a message was not stored, and whether sending it again may work
+type MessageNack struct {
+	Msg_type string   `json:"msg_type"`
+	Temp_id  string   `json:"temp_id"`
+	Reason   string   `json:"reason"`
+	Retry    bool     `json:"retry"`
+	Missing  []string `json:"missing,omitempty"` // Steps the sender has to complete first, see MissingSteps
+}
+
+// First message of a day within a window of messages, for rendering date separators
+type DaySeparator struct {
+	Day      string `json:"day"`
+	First_id int    `json:"first_id"`
+	Count    int    `json:"count"`
+}
+
+// Messages of a conversation around a point in time
+type MessageWindow struct {
+	Messages   []Message      `json:"messages"`
+	Anchor_id  int            `json:"anchor_id"`
+	Days       []DaySeparator `json:"days"`
+	Has_before bool           `json:"has_before"`
+	Has_after  bool           `json:"has_after"`
+}
+
+// What a user allows in a conversation
+type ConversationPrefs struct {
+	Typing   bool `json:"typing"`
+	Receipts bool `json:"receipts"`
+}
+
+// Settings of a conversation as seen by one of its users. Typing indicators and read
+// receipts are only shared when both users allow them, which is the agreed state.
+type ConversationSettings struct {
+	Msg_type string            `json:"msg_type,omitempty"`
+	With     int               `json:"with"`
+	Mine     ConversationPrefs `json:"mine"`
+	Agreed   ConversationPrefs `json:"agreed"`
+}
+
+// Tells the sender of messages that they were read up to a sequence number
+type ReadReceipt struct {
+	Msg_type  string `json:"msg_type"`
+	Reader_id int    `json:"reader_id"`
+	Seq       int    `json:"seq"`
+}
+
+// Messages a user has not read yet from another user
+type UnreadCount struct {
+	User_id int `json:"user_id"`
+	Count   int `json:"count"`
+}
+
+// Messages a user has not read yet, in total and per conversation
+type UnreadCounts struct {
+	Msg_type      string        `json:"msg_type,omitempty"`
+	Total         int           `json:"total"`
+	Conversations []UnreadCount `json:"conversations"`
+}
+
+// A post, comment or message its author deleted and can still restore
+type TrashItem struct {
+	Kind        string `json:"kind"`
+	Id          int    `json:"id"`
+	Post_id     int    `json:"post_id,omitempty"`
+	Receiver_id int    `json:"receiver_id,omitempty"`
+	Preview     string `json:"preview"`
+	Deleted     string `json:"deleted"`
+	Expires     string `json:"expires"`
+}
+
+// Message a user has started writing in a conversation
+type Draft struct {
+	User_id     int    `json:"user_id"`
+	Receiver_id int    `json:"receiver_id"`
+	Content     string `json:"content"`
+	Updated     int64  `json:"updated"`
+}
+
+// Every setting of a user, as exported and imported between deployments
+type Settings struct {
+	Version     int          `json:"version"`
+	Privacy     *Privacy     `json:"privacy,omitempty"`
+	Preferences *Preferences `json:"preferences,omitempty"`
+
+	Client_preferences map[string]json.RawMessage `json:"client_preferences,omitempty"`
+}
+
+// An admin acting as another user, read-only unless write is set
+type Impersonation struct {
+	Id       int    `json:"id"`
+	Admin_id int    `json:"admin_id"`
+	User_id  int    `json:"user_id"`
+	Token    string `json:"-"`
+	Write    bool   `json:"write"`
+	Reason   string `json:"reason"`
+	Started  int64  `json:"started"`
+	Expires  int64  `json:"expires"`
+	Ended    int64  `json:"ended,omitempty"`
+}
+
+// A named set of permissions that can be given to users
+type Role struct {
+	Name        string   `json:"name"`
+	Builtin     bool     `json:"builtin"`
+	Permissions []string `json:"permissions"`
+}
+
+// A service level rule alerting when a metric of the requests of an endpoint goes over the
+// threshold. Endpoints are the paths routes are registered with, e.g. "/post" or "/conversations/".
+type AlertRule struct {
+	Id        int     `json:"id"`
+	Endpoint  string  `json:"endpoint"`
+	Metric    string  `json:"metric"`
+	Threshold float64 `json:"threshold"`
+	Created   string  `json:"created"`
+
+	// Result of the last evaluation
+	Value    float64 `json:"value"`
+	Requests int     `json:"requests"`
+	Firing   bool    `json:"firing"`
+}
+
+// An alert rule starting ("firing") or ending ("resolved") to be breached, as posted to the webhook
+type Alert struct {
+	Status   string    `json:"status"`
+	Rule     AlertRule `json:"rule"`
+	Interval int       `json:"interval"`
+	Date     string    `json:"date"`
+}
+
+// An error the frontend ran into, as reported by the browser of a user. Request_id is the
+// X-Request-ID of the api response the error followed, if any, to find it in the access log.
+type ClientError struct {
+	Id          int    `json:"id"`
+	User_id     int    `json:"user_id"`
+	Message     string `json:"message"`
+	Stack       string `json:"stack"`
+	Route       string `json:"route"`
+	App_version string `json:"app_version"`
+	Request_id  string `json:"request_id"`
+	Status      int    `json:"status"`
+	User_agent  string `json:"user_agent"`
+	Created     int64  `json:"created"`
+}
+
+// A banner shown at the top of the site between Starts and Ends, unix times with Ends 0 when
+// it stays until removed. Audience is who sees it, Severity how it is styled.
+type Banner struct {
+	Id       int    `json:"id"`
+	Text     string `json:"text"`
+	Severity string `json:"severity"`
+	Audience string `json:"audience"`
+	Starts   int64  `json:"starts"`
+	Ends     int64  `json:"ends"`
+	User_id  int    `json:"user_id"`
+	Created  int64  `json:"created"`
+}
+
+// Gives a role to a user
+type UserRole struct {
+	User_id int    `json:"user_id"`
+	Role    string `json:"role"`
+}
+
+// A privileged action recorded in the audit log
+type AuditEntry struct {
+	Id         int    `json:"id"`
+	Actor_id   int    `json:"actor_id"`
+	Subject_id int    `json:"subject_id"`
+	Action     string `json:"action"`
+	Detail     string `json:"detail"`
+	Date       string `json:"date"`
+}
+
+// Something that happened that the user should know about
+type Notification struct {
+	Msg_type string `json:"msg_type,omitempty"`
+	Id       int    `json:"id"`
+	User_id  int    `json:"user_id"`
+	Kind     string `json:"kind"`
+	Content  string `json:"content"`
+	Link     string `json:"link"`
+	Date     string `json:"date"`
+	Seen     bool   `json:"seen"`
+}
+
+// A stored value that does not match what it is derived from, found by the repair command
+type Discrepancy struct {
+	Check  string `json:"check"`
+	Row    string `json:"row"`
+	Stored string `json:"stored"`
+	Actual string `json:"actual"`
+}
+
+// A side effect waiting in the outbox to be delivered
+type OutboxEvent struct {
+	Id           int    `json:"id"`
+	Topic        string `json:"topic"`
+	Payload      string `json:"payload"`
+	Created      int64  `json:"created"`
+	Attempts     int    `json:"attempts"`
+	Next_attempt int64  `json:"next_attempt"`
+	Status       string `json:"status"`
+	Last_error   string `json:"last_error"`
+}
+
+// Payload of outbox events about a single row
+type RowEvent struct {
+	Id int `json:"id"`
+}
+
+// A signed access token for API clients
+type AccessToken struct {
+	Token   string `json:"token"`
+	Expires int64  `json:"expires"`
+}
+
+// A token allowing a new password to be set, and the new password
+type PasswordReset struct {
+	Token    string `json:"token"`
+	Password string `json:"password,omitempty"`
+}
+
+// The email of a user who forgot their password
+type ForgotPassword struct {
+	Email string `json:"email"`
+}
+
+// The email of an account asking for a new verification link
+type VerificationRequest struct {
+	Email string `json:"email"`
+}
+
+// Hashed emails of the contacts of a user, see ContactMatchHandler for the hashing
+type ContactHashes struct {
+	Hashes []string `json:"hashes"`
+}
+
+// A hashed email belonging to a user who can be found by it
+type ContactMatch struct {
+	Hash         string `json:"hash"`
+	User_id      int    `json:"user_id"`
+	Display_name string `json:"display_name"`
+}
+
+// Display preferences of a user
+type Preferences struct {
+	User_id      int    `json:"user_id"`
+	Display_name string `json:"display_name"`
 }
 
 // typing status
@@ -74,6 +696,7 @@ type TypingStatus struct {
 	Msgtype    string `json:"msg_type"`
 	ReceiverID int    `json:"receiver_id"`
 	SenderID   int    `json:"sender_id"`
+	Expires    int64  `json:"expires,omitempty"`
 }
 
 // ...
@@ -82,3 +705,34 @@ type TypingStatus struct {
 var data struct {
 	Typing bool `json:"typing"`
 }
+
+// Steps of completing their account a user has to take before an action, answered with a 403
+type MissingSteps struct {
+	Action  string   `json:"action"`
+	Missing []string `json:"missing"`
+	Message string   `json:"message"`
+}
+
+// Optional profile fields filled in after registration (PUT /me/profile)
+type Profile struct {
+	Gender string `json:"gender"`
+	DOB    string `json:"dob"`
+}
+
+// A user kept out of the forum by a moderator, until the expiry or for good when it is 0
+type Ban struct {
+	User_id      int    `json:"user_id"`
+	Moderator_id int    `json:"moderator_id"`
+	Reason       string `json:"reason"`
+	Created      int64  `json:"created"`
+	Expires      int64  `json:"expires"`
+	Display_name string `json:"display_name"`
+}
+
+// A post closed to new comments by a moderator
+type ThreadLock struct {
+	Post_id      int    `json:"post_id"`
+	Moderator_id int    `json:"moderator_id"`
+	Reason       string `json:"reason"`
+	Date         string `json:"date"`
+}
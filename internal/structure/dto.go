@@ -0,0 +1,281 @@
+package structure
+
+import (
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+)
+
+// Request and response bodies of the API. Storage structs are mapped to these
+// before being sent, so fields such as password hashes are never serialized.
+
+// Body of a registration request
+type RegisterRequest struct {
+	Username  string   `json:"username"`
+	Firstname string   `json:"firstname"`
+	Surname   string   `json:"surname"`
+	Gender    string   `json:"gender"`
+	Email     string   `json:"email"`
+	DOB       string   `json:"dob"`
+	Password  string   `json:"password"`
+	Privacy   *Privacy `json:"privacy,omitempty"`
+}
+
+// Converts a registration request to the user it creates
+func (r RegisterRequest) User() User {
+	return User{
+		Username:  r.Username,
+		Firstname: r.Firstname,
+		Surname:   r.Surname,
+		Gender:    r.Gender,
+		Email:     r.Email,
+		DOB:       r.DOB,
+		Password:  r.Password,
+		Privacy:   r.Privacy,
+	}
+}
+
+// User as returned by the API. Email, date of birth and privacy settings are
+// only filled in when users view their own profile
+type UserResponse struct {
+	Id           int      `json:"id"`
+	Username     string   `json:"username"`
+	Firstname    string   `json:"firstname"`
+	Surname      string   `json:"surname"`
+	Display_name string   `json:"display_name"`
+	Gender       string   `json:"gender,omitempty"`
+	Age          int      `json:"age,omitempty"`
+	Email        string   `json:"email,omitempty"`
+	DOB          string   `json:"dob,omitempty"`
+	Privacy      *Privacy `json:"privacy,omitempty"`
+	// Image uploaded by the user to show on their profile
+	Avatar *Attachment `json:"avatar,omitempty"`
+}
+
+// Maps a user to the response seen by others, the caller decides which optional fields are visible
+func NewUserResponse(u User, showGender, showAge bool) UserResponse {
+	resp := UserResponse{
+		Id:           u.Id,
+		Username:     u.Username,
+		Firstname:    u.Firstname,
+		Surname:      u.Surname,
+		Display_name: u.Display_name,
+	}
+
+	if showGender {
+		resp.Gender = u.Gender
+	}
+
+	//Only the age is shown to others, not the date of birth it comes from
+	if age, ok := AgeOn(u.DOB, clock.Now()); showAge && ok {
+		resp.Age = age
+	}
+
+	return resp
+}
+
+// Maps a user to the response seen by themselves
+func NewSelfResponse(u User) UserResponse {
+	resp := NewUserResponse(u, true, true)
+	resp.Email = u.Email
+	resp.DOB = u.DOB
+	resp.Privacy = u.Privacy
+
+	return resp
+}
+
+// Public card of a user shown in the directory, without any optional profile fields
+type ProfileCard struct {
+	Id           int    `json:"id"`
+	Username     string `json:"username"`
+	Display_name string `json:"display_name"`
+}
+
+func NewProfileCard(u User) ProfileCard {
+	return ProfileCard{
+		Id:           u.Id,
+		Username:     u.Username,
+		Display_name: u.Display_name,
+	}
+}
+
+// A page of the user directory
+type UserDirectory struct {
+	Users    []ProfileCard `json:"users"`
+	Page     int           `json:"page"`
+	Has_more bool          `json:"has_more"`
+}
+
+// A user suggested while writing an @mention, with why they were suggested
+type MentionSuggestion struct {
+	Id           int    `json:"id"`
+	Username     string `json:"username"`
+	Display_name string `json:"display_name"`
+	Relation     string `json:"relation,omitempty"`
+}
+
+func NewMentionSuggestion(u User, relation string) MentionSuggestion {
+	return MentionSuggestion{
+		Id:           u.Id,
+		Username:     u.Username,
+		Display_name: u.Display_name,
+		Relation:     relation,
+	}
+}
+
+// A post category and the name it is shown with. Categories are listed by position.
+type Category struct {
+	Name     string `json:"name"`
+	Label    string `json:"label"`
+	Position int    `json:"position"`
+	// Permissions needed to post and to comment in the category, empty when any member can
+	Post_permission    string `json:"post_permission"`
+	Comment_permission string `json:"comment_permission"`
+	// Whether the viewer can post and comment in the category
+	Can_post    bool `json:"can_post"`
+	Can_comment bool `json:"can_comment"`
+}
+
+// A page of the posts of a category
+type PostPage struct {
+	Posts    []Post `json:"posts"`
+	Page     int    `json:"page"`
+	Has_more bool   `json:"has_more"`
+}
+
+// A post or comment matching a search. The snippet is HTML with the matched terms
+// wrapped in <mark>, the comment id is 0 for posts.
+type SearchResult struct {
+	Kind         string `json:"kind"`
+	Post_id      int    `json:"post_id"`
+	Comment_id   int    `json:"comment_id"`
+	Title        string `json:"title"`
+	Snippet      string `json:"snippet"`
+	User_id      int    `json:"user_id"`
+	Display_name string `json:"display_name"`
+	Date         string `json:"date"`
+}
+
+// A page of search results, best matches first
+type SearchPage struct {
+	Results  []SearchResult `json:"results"`
+	Page     int            `json:"page"`
+	Has_more bool           `json:"has_more"`
+}
+
+// Ids of the posts, users and comments asked for at once, and in the response the ids of
+// those that no longer exist
+type BatchIds struct {
+	Posts    []int `json:"posts"`
+	Users    []int `json:"users"`
+	Comments []int `json:"comments"`
+}
+
+// Posts, users and comments found by their ids, for clients bringing what they cached up to
+// date after they reconnect
+type Batch struct {
+	Posts    []Post         `json:"posts"`
+	Users    []UserResponse `json:"users"`
+	Comments []Comment      `json:"comments"`
+	Missing  BatchIds       `json:"missing"`
+}
+
+// A change to a post, message or notification since a sync cursor. Created and updated
+// items come with what they are now, unless they were deleted since.
+type Change struct {
+	Cursor       int           `json:"cursor"`
+	Kind         string        `json:"kind"`
+	Action       string        `json:"action"`
+	Id           int           `json:"id"`
+	Post         *Post         `json:"post,omitempty"`
+	Message      *Message      `json:"message,omitempty"`
+	Notification *Notification `json:"notification,omitempty"`
+}
+
+// The changes made since a sync cursor, oldest first. Cursor is sent as since in the next
+// request, straight away while more is true.
+type Sync struct {
+	Changes []Change `json:"changes"`
+	Cursor  int      `json:"cursor"`
+	More    bool     `json:"more"`
+}
+
+// Everything the frontend needs when it loads, in a single response. User and
+// settings are left out when nobody is logged in.
+type Bootstrap struct {
+	User                 *UserResponse     `json:"user"`
+	Unread_notifications int               `json:"unread_notifications"`
+	Categories           []Category        `json:"categories"`
+	Features             map[string]bool   `json:"features"`
+	Banners              []Banner          `json:"banners"`
+	Experiments          map[string]string `json:"experiments,omitempty"`
+	Settings             *Settings         `json:"settings,omitempty"`
+	Feed_position        *FeedPosition     `json:"feed_position,omitempty"`
+	Server_time          ServerTime        `json:"server_time"`
+}
+
+// A goal reached by a user in an experiment, like "posted" or "signed_up"
+type ExperimentConversion struct {
+	Goal string `json:"goal"`
+}
+
+// How the users of each variant of an experiment behaved
+type ExperimentResult struct {
+	Name     string          `json:"name"`
+	Active   bool            `json:"active"`
+	Variants []VariantResult `json:"variants"`
+}
+
+// Users shown a variant and, by goal, those of them who reached it
+type VariantResult struct {
+	Name        string         `json:"name"`
+	Weight      int            `json:"weight"`
+	Exposures   int            `json:"exposures"`
+	Conversions map[string]int `json:"conversions"`
+}
+
+// The logged in user with what they are allowed to do, so the frontend only shows
+// the actions that will succeed
+type Me struct {
+	User         UserResponse    `json:"user"`
+	Role         string          `json:"role"`
+	Permissions  []string        `json:"permissions"`
+	Capabilities map[string]bool `json:"capabilities"`
+	Streak       Streak          `json:"streak"`
+}
+
+// Counters of the websocket hub since the server started, for operators
+type HubStats struct {
+	Connected         int64            `json:"connected"`
+	Slow_clients      int64            `json:"slow_clients"`
+	Disconnected_slow int64            `json:"disconnected_slow"`
+	Dropped           map[string]int64 `json:"dropped"`
+	Compression       CompressionStats `json:"compression"`
+}
+
+// How much websocket compression saves. The ratio is the bytes sent on the network over the
+// bytes of the messages, on the connections that compress.
+type CompressionStats struct {
+	Enabled       bool    `json:"enabled"`
+	Connections   int64   `json:"connections"`
+	Payload_bytes int64   `json:"payload_bytes"`
+	Wire_bytes    int64   `json:"wire_bytes"`
+	Ratio         float64 `json:"ratio"`
+}
+
+// Works out how old someone born on the given ISO 8601 date is on a day, in whole years.
+// Returns false when the date of birth is missing or invalid.
+func AgeOn(dob string, day time.Time) (int, bool) {
+	born, err := time.Parse(config.DateFormat, dob)
+	if err != nil {
+		return 0, false
+	}
+
+	age := day.Year() - born.Year()
+	if day.Month() < born.Month() || (day.Month() == born.Month() && day.Day() < born.Day()) {
+		age--
+	}
+
+	return age, true
+}
@@ -0,0 +1,172 @@
+package upload
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	_ "image/gif"
+)
+
+// Variant is a resized copy of an image, ready to be stored
+type Variant struct {
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// IsImage checks whether the content type is an image format that can be resized
+func IsImage(mime string) bool {
+	switch mime {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	default:
+		return false
+	}
+}
+
+// ErrTooManyPixels is returned for images larger than the pixels allowed
+var ErrTooManyPixels = errors.New("image has too many pixels")
+
+// ProcessImage decodes an image and creates a copy for every width smaller than
+// the original, returning the original size, the copies and a placeholder colour.
+// The size is read from the header first, images over maxPixels are never decoded.
+func ProcessImage(r io.Reader, widths []int, maxPixels int) (int, int, []Variant, string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, 0, nil, "", err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, nil, "", err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > maxPixels/cfg.Height {
+		return 0, 0, nil, "", fmt.Errorf("%w: %dx%d", ErrTooManyPixels, cfg.Width, cfg.Height)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, nil, "", err
+	}
+
+	//Every copy is made from the same converted source, with a known pixel layout
+	src := toRGBA(img)
+
+	b := src.Bounds()
+	var variants []Variant
+
+	for _, w := range widths {
+		if w >= b.Dx() {
+			continue
+		}
+
+		h := b.Dy() * w / b.Dx()
+		if h < 1 {
+			h = 1
+		}
+
+		dst := resize(src, w, h)
+
+		//Keeps transparency for png images, everything else becomes a jpeg
+		var buf bytes.Buffer
+		if format == "png" {
+			err = png.Encode(&buf, dst)
+		} else {
+			err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80})
+		}
+		if err != nil {
+			return 0, 0, nil, "", err
+		}
+
+		variants = append(variants, Variant{Width: w, Height: h, Data: buf.Bytes()})
+	}
+
+	return b.Dx(), b.Dy(), variants, dominantColor(src), nil
+}
+
+// Copies an image to an RGBA image starting at 0,0
+func toRGBA(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+
+	return rgba
+}
+
+// Scales an image down by averaging the source pixels covered by each destination pixel
+func resize(rgba *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := rgba.Bounds().Dx(), rgba.Bounds().Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, (y+1)*sh/h
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, (x+1)*sw/w
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, bl, a, n int
+			for sy := y0; sy < y1; sy++ {
+				i := rgba.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(rgba.Pix[i])
+					g += int(rgba.Pix[i+1])
+					bl += int(rgba.Pix[i+2])
+					a += int(rgba.Pix[i+3])
+					n++
+					i += 4
+				}
+			}
+
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n), uint8(g / n), uint8(bl / n), uint8(a / n)})
+		}
+	}
+
+	return dst
+}
+
+// Finds the most common colour of a small copy of the image, as a hex string
+func dominantColor(src *image.RGBA) string {
+	small := resize(src, 32, 32)
+
+	//Groups similar colours by keeping the top 4 bits of each channel
+	counts := make(map[[3]uint8]int)
+	sums := make(map[[3]uint8][3]int)
+	var best [3]uint8
+	for i := 0; i < len(small.Pix); i += 4 {
+		if small.Pix[i+3] < 128 {
+			continue
+		}
+
+		r, g, b := small.Pix[i], small.Pix[i+1], small.Pix[i+2]
+		k := [3]uint8{r >> 4, g >> 4, b >> 4}
+
+		counts[k]++
+		s := sums[k]
+		sums[k] = [3]int{s[0] + int(r), s[1] + int(g), s[2] + int(b)}
+
+		if counts[k] > counts[best] {
+			best = k
+		}
+	}
+
+	n := counts[best]
+	if n == 0 {
+		return "#000000"
+	}
+
+	s := sums[best]
+	return fmt.Sprintf("#%02x%02x%02x", s[0]/n, s[1]/n, s[2]/n)
+}
@@ -0,0 +1,312 @@
+package upload
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/secrets"
+)
+
+// Files larger than this, or of unknown size, are sent to S3 with a multipart upload
+const (
+	s3MultipartThreshold = 16 << 20
+	s3PartSize           = 8 << 20
+)
+
+// S3Storage keeps files in a bucket of an S3-compatible server such as MinIO,
+// addressed with path-style urls and signed with AWS signature version 4
+type S3Storage struct {
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+// NewS3Storage reads the S3 settings from the environment
+func NewS3Storage() (S3Storage, error) {
+	s := S3Storage{
+		Endpoint:  strings.TrimSuffix(os.Getenv(config.S3EndpointEnv), "/"),
+		Bucket:    os.Getenv(config.S3BucketEnv),
+		Region:    os.Getenv(config.S3RegionEnv),
+		AccessKey: secrets.Getenv(config.S3AccessKeyEnv),
+		SecretKey: secrets.Getenv(config.S3SecretKeyEnv),
+		Client:    &http.Client{Timeout: 5 * time.Minute},
+	}
+
+	if s.Region == "" {
+		s.Region = "us-east-1"
+	}
+
+	if s.Endpoint == "" || s.Bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+		return s, errors.New("s3 storage needs an endpoint, bucket, access key and secret key")
+	}
+
+	return s, nil
+}
+
+func (s S3Storage) Put(key string, src io.Reader, size int64) error {
+	if size < 0 || size > s3MultipartThreshold {
+		return s.putMultipart(key, src)
+	}
+
+	resp, err := s.do("PUT", key, nil, src, size)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	return nil
+}
+
+// Uploads a large file in parts so it never has to be held in memory at once
+func (s S3Storage) putMultipart(key string, src io.Reader) error {
+	resp, err := s.do("POST", key, url.Values{"uploads": {""}}, nil, 0)
+	if err != nil {
+		return err
+	}
+
+	var initiated struct {
+		UploadId string
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	type part struct {
+		PartNumber int
+		ETag       string
+	}
+	var parts []part
+
+	buf := make([]byte, s3PartSize)
+	for n := 1; ; n++ {
+		read, rerr := io.ReadFull(src, buf)
+		if read > 0 {
+			q := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {initiated.UploadId}}
+
+			resp, err := s.do("PUT", key, q, bytes.NewReader(buf[:read]), int64(read))
+			if err != nil {
+				s.abortMultipart(key, initiated.UploadId)
+				return err
+			}
+			resp.Body.Close()
+
+			parts = append(parts, part{PartNumber: n, ETag: resp.Header.Get("ETag")})
+		}
+
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			s.abortMultipart(key, initiated.UploadId)
+			return rerr
+		}
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		s.abortMultipart(key, initiated.UploadId)
+		return err
+	}
+
+	resp, err = s.do("POST", key, url.Values{"uploadId": {initiated.UploadId}}, bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		s.abortMultipart(key, initiated.UploadId)
+		return err
+	}
+
+	resp.Body.Close()
+	return nil
+}
+
+// Discards the parts of a failed multipart upload
+func (s S3Storage) abortMultipart(key, uploadId string) {
+	resp, err := s.do("DELETE", key, url.Values{"uploadId": {uploadId}}, nil, 0)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+func (s S3Storage) Get(key string) (io.ReadCloser, error) {
+	resp, err := s.do("GET", key, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+func (s S3Storage) Delete(key string) error {
+	resp, err := s.do("DELETE", key, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	return nil
+}
+
+func (s S3Storage) List() ([]Object, error) {
+	var objects []Object
+	token := ""
+
+	for {
+		q := url.Values{"list-type": {"2"}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+
+		resp, err := s.do("GET", "", q, nil, 0)
+		if err != nil {
+			return objects, err
+		}
+
+		var page struct {
+			Contents []struct {
+				Key          string
+				LastModified time.Time
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return objects, err
+		}
+
+		for _, c := range page.Contents {
+			objects = append(objects, Object{Key: c.Key, Modified: c.LastModified})
+		}
+
+		if !page.IsTruncated {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// Sends a signed request for a key in the bucket, non 2xx responses are returned as errors
+func (s S3Storage) do(method, key string, query url.Values, body io.Reader, size int64) (*http.Response, error) {
+	path := "/" + s.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+
+	u, err := url.Parse(s.Endpoint + path)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	//An empty body must be nil so the length is not treated as unknown
+	if size == 0 {
+		body = nil
+	}
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+
+	s.sign(req, time.Now().UTC())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 %s %s: %s %s", method, key, resp.Status, msg)
+	}
+
+	return resp, nil
+}
+
+// Signs a request with AWS signature version 4, leaving the payload unsigned
+func (s S3Storage) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payload := "UNSIGNED-PAYLOAD"
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payload)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payload + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payload,
+	}, "\n")
+
+	scope := day + "/" + s.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Encodes query parameters sorted by key with spaces as %20, as signature version 4 expects
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+func s3Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
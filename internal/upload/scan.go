@@ -0,0 +1,137 @@
+package upload
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/config"
+)
+
+// Scan statuses stored with each attachment
+const (
+	StatusPending  = "pending"
+	StatusClean    = "clean"
+	StatusInfected = "infected"
+	StatusFailed   = "failed"
+)
+
+// Scanner checks an uploaded file for malware
+type Scanner interface {
+	// Scan returns whether the file is infected and the name of the matched signature
+	Scan(r io.Reader) (bool, string, error)
+}
+
+// NewScanner returns a ClamAV scanner when an address is configured, otherwise a no-op scanner
+func NewScanner() Scanner {
+	if addr := os.Getenv(config.ClamAVAddrEnv); addr != "" {
+		return ClamAVScanner{Addr: addr, Timeout: 30 * time.Second}
+	}
+
+	return NopScanner{}
+}
+
+// NopScanner reports every file as clean
+type NopScanner struct{}
+
+func (NopScanner) Scan(r io.Reader) (bool, string, error) {
+	return false, "", nil
+}
+
+// ClamAVScanner streams files to a clamd server over TCP using the INSTREAM command
+type ClamAVScanner struct {
+	Addr    string
+	Timeout time.Duration
+}
+
+// Size of the chunks streamed to clamd
+const clamChunkSize = 32 * 1024
+
+func (s ClamAVScanner) Scan(r io.Reader) (bool, string, error) {
+	conn, err := net.DialTimeout("tcp", s.Addr, s.Timeout)
+	if err != nil {
+		return false, "", err
+	}
+
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(s.Timeout))
+
+	_, err = conn.Write([]byte("zINSTREAM\x00"))
+	if err != nil {
+		return false, "", err
+	}
+
+	//Each chunk is prefixed with its length, a zero length chunk ends the stream
+	buf := make([]byte, clamChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return false, "", err
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return false, "", err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false, "", err
+		}
+	}
+
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return false, "", err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return false, "", err
+	}
+
+	//Replies look like "stream: OK" or "stream: <signature> FOUND"
+	reply = strings.TrimSuffix(reply, "\x00")
+	reply = strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case reply == "OK":
+		return false, "", nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return true, strings.TrimSuffix(reply, " FOUND"), nil
+	default:
+		return false, "", errors.New("clamd: " + reply)
+	}
+}
+
+// Quarantine moves a flagged file under the quarantine prefix, returning its new key
+func Quarantine(store Storage, key string, size int64) (string, error) {
+	src, err := store.Get(key)
+	if err != nil {
+		return key, err
+	}
+
+	defer src.Close()
+
+	dst := config.QuarantinePrefix + key
+
+	err = store.Put(dst, src, size)
+	if err != nil {
+		return key, err
+	}
+
+	err = store.Delete(key)
+	if err != nil {
+		return dst, err
+	}
+
+	return dst, nil
+}
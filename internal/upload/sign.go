@@ -0,0 +1,62 @@
+package upload
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/secrets"
+)
+
+// Key used to sign attachment urls. When no secret is configured a random one
+// is generated, which invalidates previously issued urls on restart.
+var secret = loadSecret()
+
+func loadSecret() []byte {
+	if s := secrets.Getenv(config.UploadSecretEnv); s != "" {
+		return []byte(s)
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return b
+}
+
+// Sign computes the signature of an attachment id and expiry time
+func Sign(id int, expires int64) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%d:%d", id, expires)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignedURL builds an expiring url to fetch an attachment
+func SignedURL(id int) string {
+	expires := clock.Now().Add(config.SignedURLAge * time.Second).Unix()
+
+	return fmt.Sprintf("/attachment?id=%d&expires=%d&sig=%s", id, expires, Sign(id, expires))
+}
+
+// VariantURL builds an expiring url to fetch a resized copy of an image attachment
+func VariantURL(id, width int) string {
+	return SignedURL(id) + "&w=" + strconv.Itoa(width)
+}
+
+// Verify checks that the signature matches the attachment id and has not expired
+func Verify(id int, expires, sig string) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || clock.Now().Unix() > exp {
+		return false
+	}
+
+	return hmac.Equal([]byte(sig), []byte(Sign(id, exp)))
+}
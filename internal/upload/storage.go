@@ -0,0 +1,157 @@
+package upload
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/ids"
+)
+
+// Storage keeps uploaded files under string keys
+type Storage interface {
+	// Put stores the bytes read from src under the key, size is -1 when unknown
+	Put(key string, src io.Reader, size int64) error
+	// Get opens the object stored under the key
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the object stored under the key
+	Delete(key string) error
+	// List returns every stored object
+	List() ([]Object, error)
+}
+
+// Object is a stored file and the time it was last written
+type Object struct {
+	Key      string
+	Modified time.Time
+}
+
+// NewStorage returns the storage backend selected by the config
+func NewStorage() Storage {
+	switch os.Getenv(config.StorageEnv) {
+	case "", "local":
+		return LocalStorage{Dir: config.UploadDir}
+	case "s3":
+		s, err := NewS3Storage()
+		if err != nil {
+			log.Fatal(err)
+		}
+		return s
+	default:
+		log.Fatalf("unknown storage backend %q", os.Getenv(config.StorageEnv))
+		return nil
+	}
+}
+
+// Save stores an uploaded file under a random key, returning the key
+func Save(store Storage, src io.Reader, size int64) (string, error) {
+	key, err := ids.New()
+	if err != nil {
+		return "", err
+	}
+
+	err = store.Put(key, src, size)
+	if err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// LocalStorage keeps files in a directory on disk
+type LocalStorage struct {
+	Dir string
+}
+
+func (s LocalStorage) Put(key string, src io.Reader, size int64) error {
+	path := filepath.Join(s.Dir, filepath.FromSlash(key))
+
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, src)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	return nil
+}
+
+func (s LocalStorage) Get(key string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.Dir, filepath.FromSlash(key)))
+}
+
+func (s LocalStorage) Delete(key string) error {
+	err := os.Remove(filepath.Join(s.Dir, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (s LocalStorage) List() ([]Object, error) {
+	var objects []Object
+
+	err := filepath.Walk(s.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.Dir, path)
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, Object{Key: filepath.ToSlash(rel), Modified: info.ModTime()})
+		return nil
+	})
+
+	return objects, err
+}
+
+// CleanupOrphans deletes objects that are not referenced by any attachment. Objects
+// newer than the grace period are kept so uploads in progress are not removed.
+func CleanupOrphans(store Storage, referenced map[string]bool, grace time.Duration) (int, error) {
+	objects, err := store.List()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, o := range objects {
+		if referenced[o.Key] || clock.Now().Sub(o.Modified) < grace {
+			continue
+		}
+
+		err = store.Delete(o.Key)
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,148 @@
+// Package validation checks the data sent by clients field by field, so every problem can
+// be shown next to the form field it belongs to instead of as a single message.
+package validation
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"real-time-forum/internal/clock"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+var emailPattern = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
+// Errors maps the json name of each invalid field to what is wrong with it
+type Errors map[string]string
+
+// Add records a problem with a field, keeping the first one found for it
+func (e Errors) Add(field, msg string) {
+	if _, found := e[field]; !found {
+		e[field] = msg
+	}
+}
+
+// Response is the body sent back when a request has invalid fields
+type Response struct {
+	Errors Errors `json:"errors"`
+}
+
+// IsEmail checks an email address has a valid format
+func IsEmail(email string) bool {
+	return emailPattern.MatchString(email)
+}
+
+// IsVisibility checks a field visibility is one of the known levels, empty keeping the default
+func IsVisibility(visibility string) bool {
+	switch visibility {
+	case "", config.VisibilityPublic, config.VisibilityContacts, config.VisibilityPrivate:
+		return true
+	default:
+		return false
+	}
+}
+
+// User checks every field of a new account, returning no errors when it is valid
+func User(u structure.User) Errors {
+	errs := Errors{}
+
+	switch {
+	case strings.TrimSpace(u.Username) == "":
+		errs.Add("username", "required")
+	case utf8.RuneCountInString(u.Username) > config.MaxNameLength:
+		errs.Add("username", "too long")
+	case strings.ContainsAny(u.Username, "@ \t\n"):
+		errs.Add("username", "must not contain spaces or @")
+	}
+
+	if utf8.RuneCountInString(u.Firstname) > config.MaxNameLength {
+		errs.Add("firstname", "too long")
+	}
+	if utf8.RuneCountInString(u.Surname) > config.MaxNameLength {
+		errs.Add("surname", "too long")
+	}
+
+	switch {
+	case u.Email == "":
+		errs.Add("email", "required")
+	case !IsEmail(u.Email):
+		errs.Add("email", "invalid format")
+	}
+
+	checkPassword(errs, u.Password)
+
+	if u.DOB == "" {
+		if config.RequireDOB {
+			errs.Add("dob", "required")
+		}
+	} else {
+		checkDOB(errs, u.DOB)
+	}
+
+	if u.Gender == "" && config.RequireGender {
+		errs.Add("gender", "required")
+	}
+
+	if u.Privacy != nil {
+		if !IsVisibility(u.Privacy.DOB) {
+			errs.Add("privacy.dob", "invalid visibility")
+		}
+		if !IsVisibility(u.Privacy.Gender) {
+			errs.Add("privacy.gender", "invalid visibility")
+		}
+	}
+
+	return errs
+}
+
+// Profile checks the profile fields a user fills in after registering, which are then required
+func Profile(p structure.Profile) Errors {
+	errs := Errors{}
+
+	if p.DOB == "" {
+		errs.Add("dob", "required")
+	} else {
+		checkDOB(errs, p.DOB)
+	}
+
+	switch {
+	case strings.TrimSpace(p.Gender) == "":
+		errs.Add("gender", "required")
+	case utf8.RuneCountInString(p.Gender) > config.MaxNameLength:
+		errs.Add("gender", "too long")
+	}
+
+	return errs
+}
+
+// Password checks a new password, like the one chosen with a reset link, returning no
+// errors when it is valid
+func Password(password string) Errors {
+	errs := Errors{}
+	checkPassword(errs, password)
+	return errs
+}
+
+// Checks a password is long enough
+func checkPassword(errs Errors, password string) {
+	switch {
+	case password == "":
+		errs.Add("password", "required")
+	case utf8.RuneCountInString(password) < config.MinPasswordLength:
+		errs.Add("password", "must be at least "+strconv.Itoa(config.MinPasswordLength)+" characters")
+	}
+}
+
+// Checks a date of birth is a real date the user is old enough at
+func checkDOB(errs Errors, dob string) {
+	if age, ok := structure.AgeOn(dob, clock.Now()); !ok {
+		errs.Add("dob", "must be a date formatted as YYYY-MM-DD")
+	} else if age < 0 || age > config.MaxAge {
+		errs.Add("dob", "invalid date")
+	} else if age < config.MinAge {
+		errs.Add("dob", "must be at least "+strconv.Itoa(config.MinAge)+" years old")
+	}
+}
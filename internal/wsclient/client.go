@@ -0,0 +1,397 @@
+// Package wsclient is a reference client of the websocket protocol of the forum.
+//
+// A connection is opened on /ws with the session cookie of a logged in user, or with
+// ?token= and a reconnect token from a previous connection. The server then sends, in order,
+// a "server_time" message and a "reconnect_token" message, each token being usable once.
+// Every message is json with a "msg_type", and a single frame can hold several messages
+// separated by newlines. Clients send "msg" (a private message to receiver_id),
+// "typing_start"/"typing_stop", "mark_read" and "subscribe"/"unsubscribe" (the post being viewed).
+//
+// A "typing_start" reaches the receiver with the time it expires, unless the sender starts
+// again before then, and a "typing_stop" is sent when the sender stops, sends the message,
+// starts typing to someone else, disconnects or lets it expire. Everyone is sent the "online"
+// users they can see whenever they change, and again regularly.
+//
+// A "msg" is only delivered to its receiver. It can carry a temp_id chosen by the sender,
+// who gets back an "ack" with the temp_id and the id, seq and date given by the server, or a
+// "nack" with the temp_id, a reason and whether it is worth retrying. Messages carry a "seq"
+// numbering them within their conversation, a client seeing a gap gets the missing ones
+// from /messages?with=<user>&after_seq=<last seq seen>.
+//
+// A "mark_read" names the sender in receiver_id and the seq read up to, or 0 for every message,
+// and reaches the sender as a "read" with the reader_id. The older "read" works the same but
+// needs a seq. Messages stay unread until then, /conversations/unread counts them per sender.
+// Read receipts and typing indicators are only shared when
+// both users of the conversation allow them, and both get a "conversation_settings"
+// message whenever one of them changes their settings.
+package wsclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"real-time-forum/internal/structure"
+)
+
+// User agent sent on every request, sessions are bound to the browser that logged in
+const UserAgent = "real-time-forum-wsclient/1"
+
+// Frame is a single message received from the server
+type Frame struct {
+	Type string
+	Raw  json.RawMessage
+}
+
+// Decode unmarshals the message into v
+func (f Frame) Decode(v interface{}) error {
+	return json.Unmarshal(f.Raw, v)
+}
+
+// Client is an open websocket connection
+type Client struct {
+	conn    *websocket.Conn
+	pending []Frame
+
+	// Sent by the server when the connection opened
+	ServerTime     structure.ServerTime
+	ReconnectToken structure.ReconnectToken
+}
+
+// Login logs in with an email or username, returning the session and id of the user
+func Login(base, identifier, password string) (string, int, error) {
+	body, err := json.Marshal(structure.Login{Data: identifier, Password: password})
+	if err != nil {
+		return "", 0, err
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(base, "/")+"/login", bytes.NewReader(body))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", UserAgent)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("login failed: %s", resp.Status)
+	}
+
+	var msg structure.Resp
+	err = json.NewDecoder(resp.Body).Decode(&msg)
+	if err != nil {
+		return "", 0, err
+	}
+
+	//The response is "id|username"
+	uid, err := strconv.Atoi(strings.SplitN(msg.Msg, "|", 2)[0])
+	if err != nil {
+		return "", 0, fmt.Errorf("unexpected login response %q", msg.Msg)
+	}
+
+	for _, c := range resp.Cookies() {
+		if c.Name == "session" {
+			return c.Value, uid, nil
+		}
+	}
+
+	return "", 0, errors.New("login did not set a session cookie")
+}
+
+// SetConversation changes what the user of the session allows in their conversation with another user
+func SetConversation(base, session string, with int, prefs structure.ConversationPrefs) error {
+	body, err := json.Marshal(prefs)
+	if err != nil {
+		return err
+	}
+
+	u := strings.TrimSuffix(base, "/") + "/conversations/" + strconv.Itoa(with) + "/settings"
+	req, err := http.NewRequest("PUT", u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", UserAgent)
+	req.AddCookie(&http.Cookie{Name: "session", Value: session})
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("changing the conversation settings failed: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// FindPost reads a post as a logged in user
+func FindPost(base, session string, id int) (structure.Post, error) {
+	var posts []structure.Post
+
+	u := strings.TrimSuffix(base, "/") + "/post?param=id&data=" + strconv.Itoa(id)
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return structure.Post{}, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	req.AddCookie(&http.Cookie{Name: "session", Value: session})
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return structure.Post{}, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return structure.Post{}, fmt.Errorf("reading post %d failed: %s", id, resp.Status)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&posts)
+	if err != nil {
+		return structure.Post{}, err
+	}
+	if len(posts) == 0 {
+		return structure.Post{}, fmt.Errorf("post %d not found", id)
+	}
+
+	return posts[0], nil
+}
+
+// CreatePost publishes a post as a logged in user, returning it as the server stored it
+func CreatePost(base, session string, post structure.Post) (structure.Post, error) {
+	body, err := json.Marshal(post)
+	if err != nil {
+		return structure.Post{}, err
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(base, "/")+"/post", bytes.NewReader(body))
+	if err != nil {
+		return structure.Post{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", UserAgent)
+	req.AddCookie(&http.Cookie{Name: "session", Value: session})
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return structure.Post{}, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return structure.Post{}, fmt.Errorf("creating a post failed: %s", resp.Status)
+	}
+
+	var created structure.Post
+	err = json.NewDecoder(resp.Body).Decode(&created)
+	return created, err
+}
+
+// Unread counts the messages a logged in user has not read yet
+func Unread(base, session string) (structure.UnreadCounts, error) {
+	var counts structure.UnreadCounts
+
+	req, err := http.NewRequest("GET", strings.TrimSuffix(base, "/")+"/conversations/unread", nil)
+	if err != nil {
+		return counts, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	req.AddCookie(&http.Cookie{Name: "session", Value: session})
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return counts, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return counts, fmt.Errorf("counting unread messages failed: %s", resp.Status)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&counts)
+	return counts, err
+}
+
+// Dial opens a connection with the session of a logged in user
+func Dial(base, session string) (*Client, error) {
+	header := http.Header{}
+	header.Set("Cookie", (&http.Cookie{Name: "session", Value: session}).String())
+
+	return dial(base, "", header)
+}
+
+// Resume opens a connection with a reconnect token from a previous connection
+func Resume(base, token string) (*Client, error) {
+	return dial(base, "?token="+url.QueryEscape(token), http.Header{})
+}
+
+func dial(base, query string, header http.Header) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(base, "/") + "/ws" + query)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	}
+
+	header.Set("User-Agent", UserAgent)
+
+	conn, resp, err := websocket.DefaultDialer.Dial(u.String(), header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("handshake failed: %s", resp.Status)
+		}
+		return nil, err
+	}
+
+	c := &Client{conn: conn}
+
+	//The server always starts with its time and a reconnect token
+	f, err := c.Next(5 * time.Second)
+	if err == nil {
+		err = expect(f, "server_time", &c.ServerTime)
+	}
+	if err == nil {
+		f, err = c.Next(5 * time.Second)
+	}
+	if err == nil {
+		err = expect(f, "reconnect_token", &c.ReconnectToken)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Checks the type of a frame and decodes it
+func expect(f Frame, msgType string, v interface{}) error {
+	if f.Type != msgType {
+		return fmt.Errorf("expected %q, got %q", msgType, f.Type)
+	}
+
+	return f.Decode(v)
+}
+
+// Next returns the next message from the server, waiting at most the timeout
+func (c *Client) Next(timeout time.Duration) (Frame, error) {
+	if len(c.pending) == 0 {
+		c.conn.SetReadDeadline(time.Now().Add(timeout))
+
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return Frame{}, err
+		}
+
+		for _, line := range bytes.Split(data, []byte{'\n'}) {
+			var head struct {
+				Msg_type string `json:"msg_type"`
+			}
+
+			err := json.Unmarshal(line, &head)
+			if err != nil {
+				return Frame{}, fmt.Errorf("invalid message %q: %v", line, err)
+			}
+
+			c.pending = append(c.pending, Frame{Type: head.Msg_type, Raw: append(json.RawMessage{}, line...)})
+		}
+	}
+
+	f := c.pending[0]
+	c.pending = c.pending[1:]
+
+	return f, nil
+}
+
+// WaitFor skips messages until one of the type arrives, waiting at most the timeout
+func (c *Client) WaitFor(msgType string, timeout time.Duration) (Frame, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		left := time.Until(deadline)
+		if left <= 0 {
+			return Frame{}, fmt.Errorf("no %q message within %v", msgType, timeout)
+		}
+
+		f, err := c.Next(left)
+		if err != nil {
+			return Frame{}, err
+		}
+
+		if f.Type == msgType {
+			return f, nil
+		}
+	}
+}
+
+// Send writes a message to the server
+func (c *Client) Send(msg structure.Message) error {
+	return c.conn.WriteJSON(msg)
+}
+
+// SendMessage sends a private message to a user
+func (c *Client) SendMessage(receiver int, content string) error {
+	return c.Send(structure.Message{Msg_type: "msg", Receiver_id: receiver, Content: content})
+}
+
+// SendTracked sends a private message under a temporary id, answered by an "ack" or a "nack"
+func (c *Client) SendTracked(receiver int, tempID, content string) error {
+	return c.Send(structure.Message{Msg_type: "msg", Receiver_id: receiver, Content: content, Temp_id: tempID})
+}
+
+// Typing tells a user whether the client is typing to them
+func (c *Client) Typing(receiver int, typing bool) error {
+	msgType := "typing_stop"
+	if typing {
+		msgType = "typing_start"
+	}
+
+	return c.Send(structure.Message{Msg_type: msgType, Receiver_id: receiver})
+}
+
+// MarkRead marks the messages received from a user as read up to seq, or all of them when seq is 0
+func (c *Client) MarkRead(sender, seq int) error {
+	return c.Send(structure.Message{Msg_type: "mark_read", Receiver_id: sender, Seq: seq})
+}
+
+// Subscribe starts receiving the events of a post
+func (c *Client) Subscribe(postID int) error {
+	return c.Send(structure.Message{Msg_type: "subscribe", Post_id: postID})
+}
+
+// Unsubscribe stops receiving the events of the post being viewed
+func (c *Client) Unsubscribe() error {
+	return c.Send(structure.Message{Msg_type: "unsubscribe"})
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	return c.conn.Close()
+}
@@ -0,0 +1,646 @@
+package wsclient
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/structure"
+)
+
+// Time allowed for a message to arrive during the checks
+const waitTime = 5 * time.Second
+
+// Account is a user the conformance checks log in as
+type Account struct {
+	Identifier string
+	Password   string
+}
+
+// Result is the outcome of a single conformance check
+type Result struct {
+	Name string
+	Err  error
+}
+
+// Conform runs the conformance checks of the protocol against a running server, with two
+// accounts talking to each other and a post they both view. Checks needing a connection
+// that could not be opened are reported as failed.
+func Conform(base string, a, b Account, postID int) []Result {
+	var results []Result
+	check := func(name string, fn func() error) bool {
+		err := fn()
+		results = append(results, Result{Name: name, Err: err})
+		return err == nil
+	}
+
+	var (
+		sessionA, sessionB string
+		idA, idB           int
+		clientA, clientB   *Client
+	)
+
+	ok := check("log in both accounts", func() (err error) {
+		sessionA, idA, err = Login(base, a.Identifier, a.Password)
+		if err != nil {
+			return err
+		}
+		sessionB, idB, err = Login(base, b.Identifier, b.Password)
+		if err == nil && idA == idB {
+			err = errors.New("both accounts are the same user")
+		}
+		return err
+	})
+	if !ok {
+		return results
+	}
+
+	check("handshake without a valid session is refused", func() error {
+		c, err := Dial(base, "not-a-session")
+		if err == nil {
+			c.Close()
+			return errors.New("connection was accepted")
+		}
+		return nil
+	})
+
+	ok = check("handshake sends the server time then a reconnect token", func() (err error) {
+		clientA, err = Dial(base, sessionA)
+		if err != nil {
+			return err
+		}
+		clientB, err = Dial(base, sessionB)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range []*Client{clientA, clientB} {
+			if c.ServerTime.Time == 0 || c.ServerTime.Date == "" {
+				return fmt.Errorf("incomplete server time %+v", c.ServerTime)
+			}
+			if c.ReconnectToken.Token == "" || c.ReconnectToken.Expires <= c.ServerTime.Time/1000 {
+				return fmt.Errorf("invalid reconnect token %+v", c.ReconnectToken)
+			}
+		}
+		return nil
+	})
+	if !ok {
+		if clientA != nil {
+			clientA.Close()
+		}
+		return results
+	}
+
+	defer clientA.Close()
+
+	check("messages reach the receiver with a server assigned id, sender and date", func() error {
+		content := "conformance " + strconv.FormatInt(time.Now().UnixNano(), 10)
+		err := clientA.SendMessage(idB, content)
+		if err != nil {
+			return err
+		}
+
+		for {
+			f, err := clientB.WaitFor("msg", waitTime)
+			if err != nil {
+				return err
+			}
+
+			var msg structure.Message
+			err = f.Decode(&msg)
+			if err != nil {
+				return err
+			}
+
+			//Skips messages sent by anyone else in the meantime
+			if msg.Content != content {
+				continue
+			}
+
+			switch {
+			case msg.Id == 0:
+				return errors.New("message has no id")
+			case msg.Seq == 0:
+				return errors.New("message has no sequence number")
+			case msg.Sender_id != idA:
+				return fmt.Errorf("sender is %d, not %d", msg.Sender_id, idA)
+			case msg.Receiver_id != idB:
+				return fmt.Errorf("receiver is %d, not %d", msg.Receiver_id, idB)
+			}
+
+			_, err = time.ParseInLocation(config.TimeFormat, msg.Date, time.Local)
+			return err
+		}
+	})
+
+	check("messages are acknowledged to the sender with the id the receiver gets", func() error {
+		tempID := "conformance-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+		err := clientA.SendTracked(idB, tempID, tempID)
+		if err != nil {
+			return err
+		}
+
+		var ack structure.MessageAck
+		for ack.Temp_id != tempID {
+			f, err := clientA.WaitFor("ack", waitTime)
+			if err != nil {
+				return err
+			}
+			err = f.Decode(&ack)
+			if err != nil {
+				return err
+			}
+		}
+
+		for {
+			f, err := clientB.WaitFor("msg", waitTime)
+			if err != nil {
+				return err
+			}
+
+			var msg structure.Message
+			err = f.Decode(&msg)
+			if err != nil {
+				return err
+			}
+
+			if msg.Content != tempID {
+				continue
+			}
+
+			switch {
+			case msg.Temp_id != "":
+				return errors.New("the temporary id reached the receiver")
+			case msg.Id != ack.Id || msg.Seq != ack.Seq || msg.Date != ack.Date:
+				return fmt.Errorf("ack %+v does not match the message %+v", ack, msg)
+			}
+			return nil
+		}
+	})
+
+	check("empty messages are refused with a nack", func() error {
+		err := clientA.SendTracked(idB, "conformance-empty", " ")
+		if err != nil {
+			return err
+		}
+
+		f, err := clientA.WaitFor("nack", waitTime)
+		if err != nil {
+			return err
+		}
+
+		var nack structure.MessageNack
+		err = f.Decode(&nack)
+		if err != nil {
+			return err
+		}
+
+		if nack.Temp_id != "conformance-empty" || nack.Reason == "" || nack.Retry {
+			return fmt.Errorf("unexpected nack %+v", nack)
+		}
+		return nil
+	})
+
+	check("typing indicators reach the receiver with an expiry", func() error {
+		err := clientA.Typing(idB, true)
+		if err != nil {
+			return err
+		}
+
+		f, err := clientB.WaitFor("typing_start", waitTime)
+		if err != nil {
+			return err
+		}
+
+		var t structure.TypingStatus
+		err = f.Decode(&t)
+		if err != nil {
+			return err
+		}
+
+		if t.UserID != idA || !t.IsTyping || t.Expires == 0 {
+			return fmt.Errorf("unexpected typing status %+v", t)
+		}
+
+		err = clientA.Typing(idB, false)
+		if err != nil {
+			return err
+		}
+
+		f, err = clientB.WaitFor("typing_stop", waitTime)
+		if err != nil {
+			return err
+		}
+
+		t = structure.TypingStatus{}
+		err = f.Decode(&t)
+		if err != nil {
+			return err
+		}
+
+		if t.UserID != idA || t.IsTyping {
+			return fmt.Errorf("unexpected typing status %+v", t)
+		}
+		return nil
+	})
+
+	check("sending a message stops the typing indicator", func() error {
+		err := clientA.Typing(idB, true)
+		if err == nil {
+			_, err = clientB.WaitFor("typing_start", waitTime)
+		}
+		if err == nil {
+			err = clientA.SendMessage(idB, "conformance typing")
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = clientB.WaitFor("typing_stop", waitTime)
+		return err
+	})
+
+	check("typing indicators and read receipts stop once one user turns them off", func() error {
+		err := SetConversation(base, sessionB, idA, structure.ConversationPrefs{})
+		if err != nil {
+			return err
+		}
+
+		defer SetConversation(base, sessionB, idA, structure.ConversationPrefs{Typing: true, Receipts: true})
+
+		//Both users are told what they now agree on
+		for _, c := range []*Client{clientA, clientB} {
+			f, err := c.WaitFor("conversation_settings", waitTime)
+			if err != nil {
+				return err
+			}
+
+			var s structure.ConversationSettings
+			err = f.Decode(&s)
+			if err != nil {
+				return err
+			}
+
+			if s.Agreed.Typing || s.Agreed.Receipts {
+				return fmt.Errorf("unexpected settings %+v", s)
+			}
+		}
+
+		err = clientA.Typing(idB, true)
+		if err == nil {
+			err = clientA.Send(structure.Message{Msg_type: "read", Receiver_id: idB, Seq: 1})
+		}
+		if err != nil {
+			return err
+		}
+
+		//Anything relayed would arrive before the message sent afterwards
+		content := "conformance " + strconv.FormatInt(time.Now().UnixNano(), 10)
+		err = clientA.SendMessage(idB, content)
+		if err != nil {
+			return err
+		}
+
+		for {
+			f, err := clientB.Next(waitTime)
+			if err != nil {
+				return err
+			}
+
+			switch f.Type {
+			case "typing_start", "typing_stop":
+				//Indicators can always be turned off
+				var t structure.TypingStatus
+				if f.Decode(&t) != nil || t.IsTyping {
+					return fmt.Errorf("%q message was relayed", f.Type)
+				}
+			case "read":
+				return errors.New("read receipt was relayed")
+			case "msg":
+				var msg structure.Message
+				if f.Decode(&msg) == nil && msg.Content == content {
+					return nil
+				}
+			}
+		}
+	})
+
+	check("messages stay unread until they are marked read", func() error {
+		err := clientA.SendMessage(idB, "conformance unread")
+		if err == nil {
+			_, err = clientB.WaitFor("msg", waitTime)
+		}
+		if err != nil {
+			return err
+		}
+
+		unread := func() (int, error) {
+			counts, err := Unread(base, sessionB)
+			for _, c := range counts.Conversations {
+				if c.User_id == idA {
+					return c.Count, err
+				}
+			}
+			return 0, err
+		}
+
+		n, err := unread()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errors.New("the message is not counted as unread")
+		}
+
+		//The read receipt is sent once the messages are marked
+		err = clientB.MarkRead(idA, 0)
+		if err == nil {
+			_, err = clientA.WaitFor("read", waitTime)
+		}
+		if err != nil {
+			return err
+		}
+
+		n, err = unread()
+		if err == nil && n != 0 {
+			err = fmt.Errorf("%d messages still unread", n)
+		}
+		return err
+	})
+
+	check("viewers of a post are counted", func() error {
+		err := clientA.Subscribe(postID)
+		if err == nil {
+			err = clientB.Subscribe(postID)
+		}
+		if err != nil {
+			return err
+		}
+
+		//Counts are batched, so a few rounds can go by before both viewers are in one
+		deadline := time.Now().Add(waitTime + 2*config.ViewerCountInterval*time.Millisecond)
+		for time.Now().Before(deadline) {
+			f, err := clientA.WaitFor("viewers", time.Until(deadline))
+			if err != nil {
+				return err
+			}
+
+			var v structure.ViewerCount
+			err = f.Decode(&v)
+			if err != nil {
+				return err
+			}
+
+			if v.Post_id == postID && v.Count >= 1 {
+				clientA.Unsubscribe()
+				clientB.Unsubscribe()
+				return nil
+			}
+		}
+
+		return errors.New("the other viewer was never counted")
+	})
+
+	check("new posts are announced on the feed but not to the viewers of a post", func() error {
+		viewed, err := FindPost(base, sessionB, postID)
+		if err != nil {
+			return err
+		}
+
+		//The first connection of the second user views the post, a new one stays on the feed
+		err = clientB.Subscribe(postID)
+		if err != nil {
+			return err
+		}
+		_, err = clientB.WaitFor("viewers", waitTime+2*config.ViewerCountInterval*time.Millisecond)
+		if err != nil {
+			return err
+		}
+
+		feed, err := Dial(base, sessionB)
+		if err != nil {
+			return err
+		}
+		defer feed.Close()
+
+		created, err := CreatePost(base, sessionA, structure.Post{
+			Category: viewed.Category,
+			Title:    "Conformance " + strconv.FormatInt(time.Now().UnixNano(), 10),
+			Content:  "Posted by the websocket conformance checks.",
+		})
+		if err != nil {
+			return err
+		}
+
+		for {
+			f, err := feed.WaitFor("new_post", waitTime)
+			if err != nil {
+				return err
+			}
+
+			var p structure.NewPostEvent
+			err = f.Decode(&p)
+			if err != nil {
+				return err
+			}
+
+			if p.Id == created.Id {
+				break
+			}
+		}
+
+		//The post was fanned out by now, a message sent after it marks where it would have been
+		content := "after post " + strconv.Itoa(created.Id)
+		err = clientA.SendMessage(idB, content)
+		if err != nil {
+			return err
+		}
+
+		defer clientB.Unsubscribe()
+		for {
+			f, err := clientB.Next(waitTime)
+			if err != nil {
+				return err
+			}
+
+			switch f.Type {
+			case "new_post":
+				var p structure.NewPostEvent
+				err = f.Decode(&p)
+				if err == nil && p.Id == created.Id {
+					return errors.New("the viewer of a post was sent the new post")
+				}
+			case "msg":
+				var msg structure.Message
+				err = f.Decode(&msg)
+				if err == nil && msg.Content == content {
+					return nil
+				}
+			}
+		}
+	})
+
+	check("every connection of a user gets their messages", func() error {
+		second, err := Dial(base, sessionB)
+		if err != nil {
+			return err
+		}
+		defer second.Close()
+
+		//The hub sends the online users once it has registered the client
+		if _, err := second.WaitFor("online", waitTime); err != nil {
+			return err
+		}
+
+		content := "conformance " + strconv.FormatInt(time.Now().UnixNano(), 10)
+		err = clientA.SendMessage(idB, content)
+		if err != nil {
+			return err
+		}
+
+		for i, c := range []*Client{clientB, second} {
+			for {
+				f, err := c.WaitFor("msg", waitTime)
+				if err != nil {
+					return fmt.Errorf("connection %d: %v", i+1, err)
+				}
+
+				var msg structure.Message
+				err = f.Decode(&msg)
+				if err != nil {
+					return err
+				}
+
+				if msg.Content == content {
+					break
+				}
+			}
+		}
+		return nil
+	})
+
+	check("other connections of the sender get the message but not the ack", func() error {
+		second, err := Dial(base, sessionA)
+		if err != nil {
+			return err
+		}
+		defer second.Close()
+
+		if _, err := second.WaitFor("online", waitTime); err != nil {
+			return err
+		}
+
+		tempID := "conformance-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+		err = clientA.SendTracked(idB, tempID, tempID)
+		if err != nil {
+			return err
+		}
+
+		var ack structure.MessageAck
+		for ack.Temp_id != tempID {
+			f, err := clientA.WaitFor("ack", waitTime)
+			if err != nil {
+				return err
+			}
+			err = f.Decode(&ack)
+			if err != nil {
+				return err
+			}
+		}
+
+		for {
+			f, err := second.Next(waitTime)
+			if err != nil {
+				return err
+			}
+
+			switch f.Type {
+			case "ack", "nack":
+				return fmt.Errorf("%q reached another connection of the sender", f.Type)
+			case "msg":
+				var msg structure.Message
+				err = f.Decode(&msg)
+				if err != nil {
+					return err
+				}
+				if msg.Content != tempID {
+					continue
+				}
+				if msg.Id != ack.Id || msg.Sender_id != idA || msg.Temp_id != "" {
+					return fmt.Errorf("ack %+v does not match the message %+v", ack, msg)
+				}
+				return nil
+			}
+		}
+	})
+
+	check("connections over the per user limit are refused", func() error {
+		var open []*Client
+		defer func() {
+			for _, c := range open {
+				c.Close()
+			}
+		}()
+
+		//The first connection of the user is clientB. Connections closed by the previous
+		//checks may still count for a moment, they are waited for.
+		deadline := time.Now().Add(waitTime)
+		for len(open) < config.MaxConnectionsPerUser-1 {
+			c, err := Dial(base, sessionB)
+			if err != nil {
+				return err
+			}
+
+			_, err = c.WaitFor("online", waitTime)
+			if err == nil {
+				open = append(open, c)
+				continue
+			}
+
+			c.Close()
+			if !websocket.IsCloseError(err, websocket.CloseTryAgainLater) || time.Now().After(deadline) {
+				return fmt.Errorf("connection %d: %v", len(open)+2, err)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		c, err := Dial(base, sessionB)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+
+		_, err = c.WaitFor("online", waitTime)
+		if err == nil {
+			return errors.New("the connection was accepted")
+		}
+		if !websocket.IsCloseError(err, websocket.CloseTryAgainLater) {
+			return fmt.Errorf("expected a try again later close, got %v", err)
+		}
+		return nil
+	})
+
+	check("reconnect tokens resume the session once", func() error {
+		token := clientB.ReconnectToken.Token
+		clientB.Close()
+
+		resumed, err := Resume(base, token)
+		if err != nil {
+			return err
+		}
+		resumed.Close()
+
+		again, err := Resume(base, token)
+		if err == nil {
+			again.Close()
+			return errors.New("the token was accepted twice")
+		}
+		return nil
+	})
+
+	return results
+}
@@ -0,0 +1,100 @@
+package wsclient
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Session is a logged in user taking part in a stress run
+type Session struct {
+	UserID  int
+	Session string
+}
+
+// StressReport counts what happened during a stress run
+type StressReport struct {
+	Connections int64
+	Sent        int64
+	Received    int64
+	Errors      int64
+}
+
+// Stress connects every session at once and has them chat, type, view posts and disconnect
+// for the given number of rounds, to shake out races in the hub. Frames are read in the
+// background so the hub never has to drop a client for falling behind.
+func Stress(base string, sessions []Session, rounds, posts int) StressReport {
+	var (
+		report StressReport
+		wg     sync.WaitGroup
+	)
+
+	for _, s := range sessions {
+		wg.Add(1)
+
+		go func(s Session) {
+			defer wg.Done()
+
+			r := rand.New(rand.NewSource(int64(s.UserID)))
+
+			for i := 0; i < rounds; i++ {
+				c, err := Dial(base, s.Session)
+				if err != nil {
+					atomic.AddInt64(&report.Errors, 1)
+					continue
+				}
+				atomic.AddInt64(&report.Connections, 1)
+
+				//The hub sends the online users once it has registered the client
+				if _, err := c.WaitFor("online", time.Minute); err != nil {
+					atomic.AddInt64(&report.Errors, 1)
+					c.Close()
+					continue
+				}
+
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					for {
+						if _, err := c.Next(10 * time.Second); err != nil {
+							return
+						}
+						atomic.AddInt64(&report.Received, 1)
+					}
+				}()
+
+				for j := 0; j < 5; j++ {
+					peer := sessions[r.Intn(len(sessions))].UserID
+
+					var err error
+					switch r.Intn(4) {
+					case 0:
+						err = c.SendMessage(peer, "stress")
+					case 1:
+						err = c.Typing(peer, r.Intn(2) == 0)
+					case 2:
+						err = c.Subscribe(1 + r.Intn(posts))
+					case 3:
+						err = c.Unsubscribe()
+					}
+
+					if err != nil {
+						atomic.AddInt64(&report.Errors, 1)
+						break
+					}
+					atomic.AddInt64(&report.Sent, 1)
+
+					time.Sleep(time.Duration(r.Intn(20)) * time.Millisecond)
+				}
+
+				c.Close()
+				<-done
+			}
+		}(s)
+	}
+
+	wg.Wait()
+
+	return report
+}
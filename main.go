@@ -1,7 +1,314 @@
 package main
 
-import "real-time-forum/internal/handlers"
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"real-time-forum/internal/chat"
+	"real-time-forum/internal/config"
+	"real-time-forum/internal/database"
+	"real-time-forum/internal/events"
+	"real-time-forum/internal/handlers"
+	"real-time-forum/internal/logger"
+	"real-time-forum/internal/secrets"
+	"real-time-forum/internal/structure"
+	"real-time-forum/internal/wsclient"
+)
 
 func main() {
-	handlers.StartServer()
+	logger.Setup()
+
+	migrateOnly := flag.Bool("migrate", false, "apply the pending schema migrations and exit")
+	rollbackSteps := flag.Int("rollback", 0, "roll back the last `n` schema migrations and exit")
+	flag.Parse()
+
+	switch {
+	case *migrateOnly:
+		migrate()
+		return
+	case *rollbackSteps > 0:
+		rollback(*rollbackSteps)
+		return
+	case flag.NArg() > 0:
+		runCommand(flag.Arg(0), flag.Args()[1:])
+		return
+	}
+
+	db := openDB()
+	defer db.Close()
+
+	handlers.StartServer(db)
+}
+
+// Opens the database of the forum
+func openDB() *database.Store {
+	db, err := database.Open(config.Path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return db
+}
+
+// Runs a maintenance command instead of the server
+func runCommand(name string, args []string) {
+	switch name {
+	case "encrypt-secret":
+		encryptSecret()
+	case "rekey-db":
+		rekeyDB()
+	case "encrypt-messages":
+		encryptMessages()
+	case "repair":
+		repair(args)
+	case "ws-conformance":
+		wsConformance(args)
+	case "hub-stress":
+		hubStress(args)
+	default:
+		log.Fatalf("Unknown command %q", name)
+	}
+}
+
+// Runs the hub with hundreds of simulated clients connecting and disconnecting at once, in a
+// throwaway database. Running it as "go run -race . hub-stress" makes the race detector
+// report any unsynchronised access to the hub.
+func hubStress(args []string) {
+	flags := flag.NewFlagSet("hub-stress", flag.ExitOnError)
+	clients := flags.Int("clients", 300, "number of simulated clients")
+	rounds := flags.Int("rounds", 3, "times each client connects")
+	posts := flags.Int("posts", 5, "number of posts the clients view")
+	flags.Parse(args)
+
+	dir, err := ioutil.TempDir("", "hub-stress")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	//The database path is relative to the working directory
+	err = os.Chdir(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db := openDB()
+	defer db.Close()
+
+	err = db.InitDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sessions := make([]wsclient.Session, *clients)
+	for i := range sessions {
+		name := "stress" + strconv.Itoa(i)
+		err := db.NewUser(context.Background(), structure.User{Username: name, Firstname: name, Surname: name, Email: name + "@example.com", Password: "-"})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		u, err := db.FindUserByParam(context.Background(), "username", name)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		sessions[i] = wsclient.Session{UserID: u.Id, Session: name}
+		err = db.NewSession(context.Background(), name, u.Id, "stress", "")
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	log.SetOutput(ioutil.Discard)
+
+	hub := chat.NewHub(events.NewBus(), db)
+	go hub.Run()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chat.ServeWs(hub, w, r)
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	report := wsclient.Stress(server.URL, sessions, *rounds, *posts)
+
+	fmt.Printf("%d connections, %d messages sent, %d received, %d errors in %v\n",
+		report.Connections, report.Sent, report.Received, report.Errors, time.Since(start).Round(time.Millisecond))
+}
+
+// Checks that a running server follows the websocket protocol, using the reference client
+func wsConformance(args []string) {
+	flags := flag.NewFlagSet("ws-conformance", flag.ExitOnError)
+	base := flags.String("url", "http://localhost:8000", "address of the server")
+	user := flags.String("user", "", "email or username of the first account")
+	password := flags.String("password", "", "password of the first account")
+	peer := flags.String("peer", "", "email or username of the second account")
+	peerPassword := flags.String("peer-password", "", "password of the second account")
+	post := flags.Int("post", 1, "id of an existing post to view")
+	flags.Parse(args)
+
+	if *user == "" || *peer == "" {
+		log.Fatal("Two accounts are needed, set -user and -peer")
+	}
+
+	results := wsclient.Conform(*base,
+		wsclient.Account{Identifier: *user, Password: *password},
+		wsclient.Account{Identifier: *peer, Password: *peerPassword},
+		*post,
+	)
+
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Printf("FAIL %s: %v\n", r.Name, r.Err)
+		} else {
+			fmt.Printf("ok   %s\n", r.Name)
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d checks failed\n", failed, len(results))
+		os.Exit(1)
+	}
+}
+
+// Applies the pending schema migrations and reports the version the database is at, for
+// -migrate. The server applies them when it starts too.
+func migrate() {
+	db := openDB()
+	defer db.Close()
+
+	//Creating the tables applies the migrations
+	err := db.InitDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	version, err := db.SchemaVersion(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Database schema at version %04d\n", version)
+}
+
+// Undoes the last steps schema migrations, for -rollback, before going back to a version
+// of the forum that doesn't know them. Starting the server again applies them again.
+func rollback(steps int) {
+	db := openDB()
+	defer db.Close()
+
+	undone, err := db.RollbackMigrations(context.Background(), steps)
+	for _, m := range undone {
+		fmt.Fprintf(os.Stderr, "Rolled back migration %s\n", m)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	version, err := db.SchemaVersion(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Database schema at version %04d\n", version)
+}
+
+// Recomputes derived values from their source rows and reports what was wrong
+func repair(args []string) {
+	flags := flag.NewFlagSet("repair", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only report discrepancies, do not fix them")
+	flags.Parse(args)
+
+	db := openDB()
+	defer db.Close()
+
+	err := db.InitDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	found, err := db.Repair(context.Background(), *dryRun)
+	for _, d := range found {
+		fmt.Printf("%s %s: stored %s, actual %s\n", d.Check, d.Row, d.Stored, d.Actual)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch {
+	case len(found) == 0:
+		fmt.Fprintln(os.Stderr, "No discrepancies found")
+	case *dryRun:
+		fmt.Fprintf(os.Stderr, "Found %d discrepancies, run without -dry-run to fix them\n", len(found))
+	default:
+		fmt.Fprintf(os.Stderr, "Fixed %d discrepancies\n", len(found))
+	}
+}
+
+// Encrypts existing message bodies with the current message key
+func encryptMessages() {
+	db := openDB()
+	defer db.Close()
+
+	n, skipped, err := db.EncryptMessages(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Encrypted %d messages\n", n)
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d messages that could not be decrypted\n", skipped)
+	}
+}
+
+// Re-encrypts the database with a new key read from stdin
+func rekeyDB() {
+	fmt.Fprint(os.Stderr, "New database key: ")
+	value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && value == "" {
+		log.Fatal(err)
+	}
+
+	err = database.Rekey(config.Path, strings.TrimRight(value, "\r\n"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Fprintln(os.Stderr, "Database key changed, update "+config.DBKeyEnv+" before starting the server.")
+}
+
+// Reads a secret from stdin and prints it encrypted, ready to be put in the environment.
+// Reading from stdin keeps the secret out of the shell history.
+func encryptSecret() {
+	key, err := secrets.Key()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Fprint(os.Stderr, "Secret: ")
+	value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && value == "" {
+		log.Fatal(err)
+	}
+
+	enc, err := secrets.Encrypt(key, strings.TrimRight(value, "\r\n"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(enc)
 }